@@ -0,0 +1,22 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterCalendarPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/calendar")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.GetCalendar).Name = "calendar"
+	g.PUT("", h.SetCalendarDate).Name = "calendar-set"
+	g.GET("/date", h.SessionDate).Name = "calendar-date"
+	g.POST("/advance", h.AdvanceCalendar).Name = "calendar-advance"
+
+	g.GET("/events", h.ListScheduledEvents).Name = "calendar-events"
+	g.POST("/events", h.CreateScheduledEvent).Name = "calendar-event-create"
+	g.DELETE("/events/:eventId", h.DeleteScheduledEvent).Name = "calendar-event-delete"
+}
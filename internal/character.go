@@ -0,0 +1,30 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterCharacterPages(e *echo.Echo, h *handler.Handler) {
+	e.GET("/session/:sessionId/equipment", h.ListEquipment, sessionRequired).Name = "equipment"
+
+	g := e.Group("/session/:sessionId/characters")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListCharacters).Name = "characters"
+	g.POST("", h.CreateCharacter).Name = "character-create"
+	g.GET("/:characterId", h.GetCharacter).Name = "character"
+	g.PUT("/:characterId", h.UpdateCharacter).Name = "character-update"
+	g.DELETE("/:characterId", h.DeleteCharacter).Name = "character-delete"
+	g.POST("/:characterId/check", h.RollCheck).Name = "character-check"
+	g.GET("/:characterId/stats", h.GetCharacterStats).Name = "character-stats"
+	g.GET("/:characterId/inventory", h.GetInventory).Name = "character-inventory"
+	g.POST("/:characterId/inventory", h.AddInventoryItem).Name = "inventory-add"
+	g.PUT("/:characterId/inventory/:inventoryId", h.UpdateInventoryItem).Name = "inventory-update"
+	g.DELETE("/:characterId/inventory/:inventoryId", h.DeleteInventoryItem).Name = "inventory-delete"
+	g.GET("/:characterId/augments", h.ListAugments).Name = "character-augments"
+	g.POST("/:characterId/augments", h.InstallAugment).Name = "augment-install"
+	g.DELETE("/:characterId/augments/:augmentId", h.RemoveAugment).Name = "augment-remove"
+}
@@ -0,0 +1,25 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterCombatPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/combat")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListEncounters).Name = "encounters"
+	g.POST("", h.CreateEncounter).Name = "encounter-create"
+	g.GET("/:encounterId", h.GetEncounter).Name = "encounter"
+	g.DELETE("/:encounterId", h.DeleteEncounter).Name = "encounter-delete"
+	g.POST("/:encounterId/combatants", h.AddCombatant).Name = "combatant-add"
+	g.DELETE("/:encounterId/combatants/:combatantId", h.RemoveCombatant).Name = "combatant-remove"
+	g.POST("/:encounterId/start", h.StartEncounter).Name = "encounter-start"
+	g.POST("/:encounterId/next", h.NextTurn).Name = "encounter-next"
+	g.POST("/:encounterId/attack", h.Attack).Name = "encounter-attack"
+	g.POST("/:encounterId/damage", h.DamageCombatant).Name = "encounter-damage"
+	g.POST("/:encounterId/end", h.EndEncounter).Name = "encounter-end"
+}
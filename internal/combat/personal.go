@@ -0,0 +1,194 @@
+package combat
+
+import (
+	"fmt"
+	"sort"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/model"
+)
+
+// Skill ids used by personal combat
+const (
+	Skill_Tactics = "tactics"
+	Skill_Melee   = "melee"
+)
+
+// unskilled is the DM for using a weapon without its skill
+const unskilled = -3
+
+// an attack hits on 8+
+const attackTarget = 8
+
+// unarmed strikes use Melee and do 1D
+var unarmed = equipment.Weapon{Name: "Unarmed", Skill: Skill_Melee, Damage: "1D"}
+
+// Initiative rolls 2D plus the better of the DEX and INT DMs of the combatant and their Tactics skill
+func Initiative(r *dice.Roller, c *model.Combatant) int {
+	dm := max(model.CharacteristicDM(c.Current(model.Characteristic_DEX)), model.CharacteristicDM(c.Current(model.Characteristic_INT)))
+	return r.D6(2) + dm + c.Skills[Skill_Tactics]
+}
+
+// Start rolls initiative for every combatant, puts them in order and begins the first round
+func Start(r *dice.Roller, e *model.Encounter) {
+	for i := range e.Combatants {
+		e.Combatants[i].Initiative = Initiative(r, &e.Combatants[i])
+	}
+	sort.SliceStable(e.Combatants, func(i, j int) bool {
+		return e.Combatants[i].Initiative > e.Combatants[j].Initiative
+	})
+
+	e.Status = model.EncounterStatus_Active
+	e.Round = 1
+	e.Turn = -1
+	e.Turn = nextActive(e)
+	if e.Turn < 0 {
+		e.Turn = 0
+	}
+}
+
+// Join puts a combatant that arrives during the fight into the initiative order
+func Join(r *dice.Roller, e *model.Encounter, c model.Combatant) {
+	if e.Status != model.EncounterStatus_Active {
+		e.Combatants = append(e.Combatants, c)
+		return
+	}
+
+	c.Initiative = Initiative(r, &c)
+	at := len(e.Combatants)
+	for i, item := range e.Combatants {
+		if c.Initiative > item.Initiative {
+			at = i
+			break
+		}
+	}
+	e.Combatants = append(e.Combatants[:at], append([]model.Combatant{c}, e.Combatants[at:]...)...)
+	// the combatant acting keeps their turn, in an encounter everyone had left the newcomer acts
+	if at <= e.Turn && len(e.Combatants) > 1 {
+		e.Turn++
+	}
+}
+
+// Remove takes a combatant out of the encounter keeping the turn with whoever is acting
+func Remove(e *model.Encounter, id string) {
+	for i, item := range e.Combatants {
+		if item.Id != id {
+			continue
+		}
+		e.Combatants = append(e.Combatants[:i], e.Combatants[i+1:]...)
+		if i < e.Turn || (i == e.Turn && e.Turn == len(e.Combatants)) {
+			e.Turn--
+		}
+		e.Turn = max(e.Turn, 0)
+		return
+	}
+}
+
+// Next passes the turn to the next combatant still standing, starting a new round after the last
+func Next(e *model.Encounter) {
+	if next := nextActive(e); next >= 0 {
+		if next <= e.Turn {
+			e.Round++
+		}
+		e.Turn = next
+	}
+}
+
+// nextActive is the index of the first active combatant after the current turn, wrapping around, or -1 when none are left
+func nextActive(e *model.Encounter) int {
+	count := len(e.Combatants)
+	for step := 1; step <= count; step++ {
+		i := (e.Turn + step + count) % count
+		if e.Combatants[i].Status == model.CombatantStatus_Active {
+			return i
+		}
+	}
+	return -1
+}
+
+type Attack struct {
+	Weapon string `json:"weapon"`
+	Roll   int    `json:"roll"`
+	DM     int    `json:"dm"`
+	Total  int    `json:"total"`
+	Hit    bool   `json:"hit"`
+	Effect int    `json:"effect"`
+	// damage rolled plus the effect, before armour
+	Damage int `json:"damage"`
+	// what got through the armour of the target
+	Applied int `json:"applied"`
+}
+
+// weapon returns the catalog entry the combatant attacks with and its parsed damage
+func weapon(catalog *equipment.Catalog, c *model.Combatant) (equipment.Weapon, dice.Expression) {
+	if item, ok := catalog.Weapon(c.WeaponId); ok {
+		return item, item.DamageRoll()
+	}
+	damage, _ := dice.ParseExpression(unarmed.Damage)
+	return unarmed, damage
+}
+
+// ResolveAttack rolls an attack from one combatant on another. Ranged weapons use DEX and melee
+// weapons STR, either way the encumbrance DM of the attacker applies. A hit does the weapon damage
+// plus the effect of the roll less the armour of the target.
+func ResolveAttack(r *dice.Roller, catalog *equipment.Catalog, attacker *model.Combatant, target *model.Combatant, dm int) Attack {
+	item, damage := weapon(catalog, attacker)
+
+	characteristic := model.Characteristic_STR
+	if item.Range > 0 {
+		characteristic = model.Characteristic_DEX
+	}
+	skill, ok := attacker.Skills[item.Skill]
+	if !ok {
+		skill = unskilled
+	}
+
+	result := Attack{Weapon: item.Name, DM: model.CharacteristicDM(attacker.Current(characteristic)) + attacker.Encumbrance + skill + dm}
+	result.Total, result.Hit = r.Check(attackTarget, result.DM)
+	result.Roll = result.Total - result.DM
+	result.Effect = result.Total - attackTarget
+	if !result.Hit {
+		return result
+	}
+
+	result.Damage = damage.Roll(r) + result.Effect
+	result.Applied = max(result.Damage-target.Protection, 0)
+	ApplyDamage(target, result.Applied)
+	return result
+}
+
+// ApplyDamage takes damage off END first and then STR and DEX, the higher of the two first.
+// A combatant with END and one of STR or DEX at zero falls unconscious, with all three at zero they are dead.
+func ApplyDamage(c *model.Combatant, amount int) {
+	for _, code := range damageOrder(c) {
+		if amount <= 0 {
+			break
+		}
+		taken := min(amount, c.Current(code))
+		c.Damage.Add(code, taken)
+		amount -= taken
+	}
+
+	end, str, dex := c.Current(model.Characteristic_END), c.Current(model.Characteristic_STR), c.Current(model.Characteristic_DEX)
+	switch {
+	case end == 0 && str == 0 && dex == 0:
+		c.Status = model.CombatantStatus_Dead
+	case end == 0 && (str == 0 || dex == 0):
+		c.Status = model.CombatantStatus_Unconscious
+	}
+}
+
+func damageOrder(c *model.Combatant) []string {
+	if c.Current(model.Characteristic_DEX) > c.Current(model.Characteristic_STR) {
+		return []string{model.Characteristic_END, model.Characteristic_DEX, model.Characteristic_STR}
+	}
+	return []string{model.Characteristic_END, model.Characteristic_STR, model.Characteristic_DEX}
+}
+
+// Describe is the log line for an attack
+func (a Attack) Describe(attacker string, target string) string {
+	if !a.Hit {
+		return fmt.Sprintf("%s attacks %s with %s and misses (%d)", attacker, target, a.Weapon, a.Total)
+	}
+	return fmt.Sprintf("%s hits %s with %s (%d) for %d damage, %d after armour", attacker, target, a.Weapon, a.Total, a.Damage, a.Applied)
+}
@@ -0,0 +1,227 @@
+package combat
+
+import (
+	"testing"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/model"
+)
+
+func combatant(id string) model.Combatant {
+	return model.Combatant{Id: id, Name: id, Skills: map[string]int{}, Status: model.CombatantStatus_Active}
+}
+
+// fighter is a combatant with the physical characteristics given
+func fighter(id string, str int, dex int, end int) model.Combatant {
+	c := combatant(id)
+	c.Characteristics = model.Characteristics{STR: str, DEX: dex, END: end, INT: 7}
+	return c
+}
+
+func testCatalog(t *testing.T) *equipment.Catalog {
+	t.Helper()
+
+	catalog, err := equipment.Load("../../configs/equipment.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+func TestStartOrdersByInitiative(t *testing.T) {
+	e := &model.Encounter{Combatants: []model.Combatant{combatant("slow"), combatant("fast"), combatant("middle")}}
+	Start(scripted(t, 1, 1, 6, 6, 3, 4), e)
+
+	if e.Status != model.EncounterStatus_Active || e.Round != 1 || e.Turn != 0 {
+		t.Fatalf("encounter is %s round %d turn %d", e.Status, e.Round, e.Turn)
+	}
+	for i, id := range []string{"fast", "middle", "slow"} {
+		if e.Combatants[i].Id != id {
+			t.Fatalf("combatant %d is %s, want %s", i, e.Combatants[i].Id, id)
+		}
+	}
+}
+
+func TestNextSkipsFallenAndStartsRounds(t *testing.T) {
+	e := &model.Encounter{Status: model.EncounterStatus_Active, Round: 1, Combatants: []model.Combatant{combatant("a"), combatant("b"), combatant("c")}}
+	e.Combatants[1].Status = model.CombatantStatus_Unconscious
+
+	Next(e)
+	if e.Turn != 2 || e.Round != 1 {
+		t.Fatalf("turn %d round %d, want the turn to skip b", e.Turn, e.Round)
+	}
+	Next(e)
+	if e.Turn != 0 || e.Round != 2 {
+		t.Fatalf("turn %d round %d, want a new round", e.Turn, e.Round)
+	}
+}
+
+func TestRemoveKeepsTheTurnInRange(t *testing.T) {
+	e := &model.Encounter{Status: model.EncounterStatus_Active, Round: 1, Turn: 2, Combatants: []model.Combatant{combatant("a"), combatant("b"), combatant("c")}}
+
+	Remove(e, "a")
+	if e.Turn != 1 || e.Combatants[e.Turn].Id != "c" {
+		t.Fatalf("turn %d after removing someone before the acting combatant", e.Turn)
+	}
+
+	Remove(e, "c")
+	if e.Turn != 0 || e.Combatants[e.Turn].Id != "b" {
+		t.Fatalf("turn %d after removing the acting combatant", e.Turn)
+	}
+
+	Remove(e, "b")
+	if len(e.Combatants) != 0 || e.Turn != 0 {
+		t.Fatalf("%d combatants and turn %d after removing everyone", len(e.Combatants), e.Turn)
+	}
+
+	// passing the turn in an empty encounter does nothing
+	Next(e)
+	if e.Turn != 0 || e.Round != 1 {
+		t.Fatalf("turn %d round %d", e.Turn, e.Round)
+	}
+}
+
+func TestJoinAnEmptiedEncounter(t *testing.T) {
+	e := &model.Encounter{Status: model.EncounterStatus_Active, Round: 3, Combatants: []model.Combatant{}}
+
+	Join(scripted(t, 3, 3), e, combatant("late"))
+	if len(e.Combatants) != 1 || e.Turn != 0 {
+		t.Fatalf("%d combatants and turn %d, the newcomer should act", len(e.Combatants), e.Turn)
+	}
+
+	// joining ahead of the acting combatant keeps their turn
+	Join(scripted(t, 6, 6), e, combatant("quick"))
+	if e.Combatants[e.Turn].Id != "late" {
+		t.Fatalf("turn passed to %s", e.Combatants[e.Turn].Id)
+	}
+}
+
+func TestResolveAttackAppliesEncumbrance(t *testing.T) {
+	catalog := testCatalog(t)
+
+	tests := []struct {
+		name   string
+		weapon string
+		skill  string
+		// DM of the characteristic the weapon uses and the skill
+		dm int
+	}{
+		// DEX 9 +1 and Gun Combat 1
+		{"ranged", "autopistol", "gun_combat", 2},
+		// STR 9 +1 and Melee 1
+		{"melee", "cutlass", Skill_Melee, 2},
+	}
+
+	for _, test := range tests {
+		for _, encumbrance := range []int{0, -2} {
+			attacker, target := fighter("attacker", 9, 9, 7), fighter("target", 7, 7, 7)
+			attacker.WeaponId = test.weapon
+			attacker.Skills[test.skill] = 1
+			attacker.Encumbrance = encumbrance
+
+			// 3 and 4 make 7, a hit with the DMs and a miss once an overloaded attacker loses 2
+			faces := []int{3, 4}
+			if encumbrance == 0 {
+				faces = append(faces, 1, 1, 1)
+			}
+			attack := ResolveAttack(scripted(t, faces...), catalog, &attacker, &target, 0)
+			if attack.DM != test.dm+encumbrance || attack.Hit != (encumbrance == 0) {
+				t.Errorf("%s with encumbrance %d: DM %d, hit %v", test.name, encumbrance, attack.DM, attack.Hit)
+			}
+		}
+	}
+}
+
+func TestResolveAttack(t *testing.T) {
+	catalog := testCatalog(t)
+
+	tests := []struct {
+		name   string
+		weapon string
+		skills map[string]int
+		faces  []int
+		dm     int
+		// of the attack on a target with protection 4
+		hit     bool
+		damage  int
+		applied int
+	}{
+		// 8 + DEX +1 + Gun Combat 1 is 10, effect 2, 3D-3 of 9 makes 11 and 7 gets through
+		{"hit", "autopistol", map[string]int{"gun_combat": 1}, []int{4, 4, 3, 3, 6}, 0, true, 11, 7},
+		// 5 +2 is 7
+		{"miss", "autopistol", map[string]int{"gun_combat": 1}, []int{2, 3}, 0, false, 0, 0},
+		// 9 + 1 - 3 unskilled is 7, a miss until the referee gives +1
+		{"unskilled", "autopistol", map[string]int{}, []int{4, 5, 1, 1, 1}, 1, true, 0, 0},
+		// 8 + STR +1 + Melee 1 - 1 is 9, effect 1, 1D+2 of 1 makes 4 which the armour stops
+		{"stopped by armour", "dagger", map[string]int{Skill_Melee: 1}, []int{4, 4, 1}, -1, true, 4, 0},
+		// with no weapon it is an unarmed Melee attack for 1D
+		{"unarmed", "", map[string]int{Skill_Melee: 0}, []int{6, 6, 6}, 0, true, 11, 7},
+	}
+
+	for _, test := range tests {
+		attacker, target := fighter("attacker", 9, 9, 7), fighter("target", 9, 9, 12)
+		attacker.WeaponId = test.weapon
+		attacker.Skills = test.skills
+		target.Protection = 4
+
+		attack := ResolveAttack(scripted(t, test.faces...), catalog, &attacker, &target, test.dm)
+		if attack.Hit != test.hit || attack.Damage != test.damage || attack.Applied != test.applied {
+			t.Errorf("%s: %+v", test.name, attack)
+		}
+		if attack.Total != attack.Roll+attack.DM || attack.Effect != attack.Total-attackTarget {
+			t.Errorf("%s: roll %d DM %d total %d effect %d", test.name, attack.Roll, attack.DM, attack.Total, attack.Effect)
+		}
+		if target.Damage.END != test.applied {
+			t.Errorf("%s: target took %+v", test.name, target.Damage)
+		}
+	}
+}
+
+func TestApplyDamage(t *testing.T) {
+	tests := []struct {
+		name          string
+		str, dex, end int
+		amount        int
+		damage        model.Characteristics
+		status        model.CombatantStatus
+	}{
+		{"END first", 7, 8, 6, 4, model.Characteristics{END: 4}, model.CombatantStatus_Active},
+		// END goes to zero, the rest comes off the higher of STR and DEX
+		{"then DEX when it is higher", 7, 8, 6, 9, model.Characteristics{END: 6, DEX: 3}, model.CombatantStatus_Active},
+		{"then STR when it is higher", 9, 8, 6, 9, model.Characteristics{END: 6, STR: 3}, model.CombatantStatus_Active},
+		{"STR on a tie", 8, 8, 6, 7, model.Characteristics{END: 6, STR: 1}, model.CombatantStatus_Active},
+		// the first of STR or DEX used up and then the other
+		{"unconscious", 7, 8, 6, 14, model.Characteristics{END: 6, DEX: 8}, model.CombatantStatus_Unconscious},
+		{"still unconscious", 7, 8, 6, 16, model.Characteristics{END: 6, DEX: 8, STR: 2}, model.CombatantStatus_Unconscious},
+		{"dead", 7, 8, 6, 21, model.Characteristics{END: 6, DEX: 8, STR: 7}, model.CombatantStatus_Dead},
+		{"more than enough", 7, 8, 6, 40, model.Characteristics{END: 6, DEX: 8, STR: 7}, model.CombatantStatus_Dead},
+		{"nothing", 7, 8, 6, 0, model.Characteristics{}, model.CombatantStatus_Active},
+	}
+
+	for _, test := range tests {
+		c := fighter("target", test.str, test.dex, test.end)
+		ApplyDamage(&c, test.amount)
+		if c.Damage != test.damage || c.Status != test.status {
+			t.Errorf("%s: damage %+v status %s", test.name, c.Damage, c.Status)
+		}
+	}
+}
+
+func TestApplyDamageOverSeveralHits(t *testing.T) {
+	c := fighter("target", 5, 4, 3)
+
+	// what is left of END soaks the next hit before STR and DEX
+	ApplyDamage(&c, 2)
+	ApplyDamage(&c, 3)
+	if c.Damage != (model.Characteristics{END: 3, STR: 2}) || c.Status != model.CombatantStatus_Active {
+		t.Fatalf("damage %+v status %s", c.Damage, c.Status)
+	}
+
+	// STR 3 and DEX 4 left, DEX is now the higher
+	ApplyDamage(&c, 4)
+	if c.Damage != (model.Characteristics{END: 3, STR: 2, DEX: 4}) || c.Status != model.CombatantStatus_Unconscious {
+		t.Fatalf("damage %+v status %s", c.Damage, c.Status)
+	}
+	if c.Current(model.Characteristic_STR) != 3 {
+		t.Fatalf("STR is %d", c.Current(model.Characteristic_STR))
+	}
+}
@@ -0,0 +1,510 @@
+package combat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/shipyard"
+)
+
+var (
+	ErrUnknownBand    = errors.New("unknown range band")
+	ErrNoThrust       = errors.New("not enough thrust left this round")
+	ErrRangeLimit     = errors.New("ships can not move any closer or further apart")
+	ErrOutOfRange     = errors.New("target is out of range of the weapon")
+	ErrWeaponUsed     = errors.New("weapon has already fired this round")
+	ErrWeaponDisabled = errors.New("weapon is disabled")
+	ErrNotAWeapon     = errors.New("weapon can not attack")
+	ErrNotAdjacent    = errors.New("boarding needs the ships to be adjacent")
+	ErrNoCritical     = errors.New("location has no critical damage")
+)
+
+// Band is a range band of space combat. Thrust is what it costs to move from the band to the
+// one next further out, or back in, the furthest band has nowhere further to go.
+type Band struct {
+	Name   string `json:"name"`
+	DM     int    `json:"dm"`
+	Thrust int    `json:"thrust"`
+}
+
+// RangeBands from closest to furthest
+var RangeBands = []Band{
+	{"adjacent", 0, 1},
+	{"close", 0, 1},
+	{"short", 1, 1},
+	{"medium", 0, 2},
+	{"long", -2, 4},
+	{"very_long", -4, 6},
+	{"distant", -6, 0},
+}
+
+// StartBand is the range ships join an encounter at unless the referee sets another
+const StartBand = "long"
+
+func bandIndex(name string) int {
+	for i, band := range RangeBands {
+		if band.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func ValidBand(name string) bool {
+	return bandIndex(name) >= 0
+}
+
+// Critical hit locations
+const (
+	Location_Sensors    = "sensors"
+	Location_PowerPlant = "power_plant"
+	Location_Fuel       = "fuel"
+	Location_Weapon     = "weapon"
+	Location_Armour     = "armour"
+	Location_Hull       = "hull"
+	Location_MDrive     = "m_drive"
+	Location_Cargo      = "cargo"
+	Location_JDrive     = "j_drive"
+	Location_Crew       = "crew"
+	Location_Computer   = "computer"
+)
+
+// criticalLocations is the location hit for a 2D roll of 2 to 12
+var criticalLocations = []string{
+	Location_Sensors, Location_PowerPlant, Location_Fuel, Location_Weapon, Location_Armour, Location_Hull,
+	Location_MDrive, Location_Cargo, Location_JDrive, Location_Crew, Location_Computer,
+}
+
+const maxSeverity = 6
+
+// sensor locks give attacks against the target this DM
+const lockDM = 2
+
+// skills the crew roles use
+var crewSkills = map[string]string{
+	model.CrewRole_Pilot:    "pilot",
+	model.CrewRole_Gunner:   "gunner",
+	model.CrewRole_Engineer: "engineer",
+	model.CrewRole_Sensors:  "electronics",
+}
+
+// CrewSkill is the skill a crew role rolls
+func CrewSkill(role string) (string, bool) {
+	skill, ok := crewSkills[role]
+	return skill, ok
+}
+
+// crew returns the skill of the crew in a role, a ship with nobody in the role is unskilled
+func crew(s *model.SpaceCombatant, role string) int {
+	if level, ok := s.Crew[role]; ok {
+		return level
+	}
+	return unskilled
+}
+
+// NewShip copies a ship into a space combatant with its hull, armour, thrust and weapons
+func NewShip(tables *shipyard.Tables, ship model.Ship, id string, crew map[string]int) model.SpaceCombatant {
+	report := tables.Calculate(ship.Design)
+	combatant := model.SpaceCombatant{
+		Id:         id,
+		ShipId:     ship.Id,
+		Name:       ship.Name,
+		Crew:       crew,
+		HullPoints: int(report.HullPoints),
+		Hull:       int(report.HullPoints),
+		Armour:     ship.Design.Armour.Rating,
+		Thrust:     ship.Design.ManeuverDrive.Rating,
+		Weapons:    []model.ShipWeapon{},
+		Criticals:  []model.Critical{},
+		Locks:      []string{},
+		Status:     model.SpaceCombatantStatus_Active,
+	}
+
+	for _, mount := range ship.Design.Weapons {
+		for _, id := range mount.Weapons {
+			weapon, ok := tables.Weapon(id)
+			if !ok {
+				continue
+			}
+			combatant.Weapons = append(combatant.Weapons, model.ShipWeapon{
+				WeaponId: weapon.Id,
+				Name:     weapon.Name,
+				Mount:    mount.Mount,
+				Range:    weapon.Range,
+				Damage:   weapon.Damage,
+			})
+		}
+	}
+
+	return combatant
+}
+
+// Thrust is what the M-drive can give this round, less the damage to the drive and power plant
+func Thrust(s *model.SpaceCombatant) int {
+	return max(s.Thrust-s.Severity(Location_MDrive)-s.Severity(Location_PowerPlant), 0)
+}
+
+// Range is the band between two ships of the encounter
+func Range(e *model.SpaceEncounter, a string, b string) string {
+	for _, item := range e.Ranges {
+		if (item.A == a && item.B == b) || (item.A == b && item.B == a) {
+			return item.Band
+		}
+	}
+	return StartBand
+}
+
+// SetRange puts two ships of the encounter at a range band
+func SetRange(e *model.SpaceEncounter, a string, b string, band string) error {
+	if !ValidBand(band) {
+		return ErrUnknownBand
+	}
+	for i, item := range e.Ranges {
+		if (item.A == a && item.B == b) || (item.A == b && item.B == a) {
+			e.Ranges[i].Band = band
+			return nil
+		}
+	}
+	e.Ranges = append(e.Ranges, model.SpaceRange{A: a, B: b, Band: band})
+	return nil
+}
+
+// JoinSpace adds a ship to the encounter at the given band from every ship already in it
+func JoinSpace(r *dice.Roller, e *model.SpaceEncounter, s model.SpaceCombatant, band string) error {
+	if !ValidBand(band) {
+		return ErrUnknownBand
+	}
+	for _, other := range e.Ships {
+		e.Ranges = append(e.Ranges, model.SpaceRange{A: other.Id, B: s.Id, Band: band})
+	}
+	if e.Status == model.EncounterStatus_Active {
+		s.Initiative = SpaceInitiative(r, &s)
+	}
+	e.Ships = append(e.Ships, s)
+	sortShips(e)
+	return nil
+}
+
+// RemoveSpace takes a ship and its ranges out of the encounter
+func RemoveSpace(e *model.SpaceEncounter, id string) {
+	ships := e.Ships[:0]
+	for _, item := range e.Ships {
+		if item.Id != id {
+			ships = append(ships, item)
+		}
+	}
+	e.Ships = ships
+
+	ranges := e.Ranges[:0]
+	for _, item := range e.Ranges {
+		if item.A != id && item.B != id {
+			ranges = append(ranges, item)
+		}
+	}
+	e.Ranges = ranges
+}
+
+// SpaceInitiative rolls 2D plus the Pilot skill and thrust of the ship
+func SpaceInitiative(r *dice.Roller, s *model.SpaceCombatant) int {
+	return r.D6(2) + crew(s, model.CrewRole_Pilot) + Thrust(s)
+}
+
+func sortShips(e *model.SpaceEncounter) {
+	if e.Status != model.EncounterStatus_Active {
+		return
+	}
+	sort.SliceStable(e.Ships, func(i, j int) bool {
+		return e.Ships[i].Initiative > e.Ships[j].Initiative
+	})
+}
+
+// StartSpace rolls initiative for every ship and begins the first round
+func StartSpace(r *dice.Roller, e *model.SpaceEncounter) {
+	for i := range e.Ships {
+		e.Ships[i].Initiative = SpaceInitiative(r, &e.Ships[i])
+	}
+	e.Status = model.EncounterStatus_Active
+	e.Round = 1
+	sortShips(e)
+}
+
+// NextRound gives every ship its thrust and weapons back for a new round
+func NextRound(e *model.SpaceEncounter) {
+	e.Round++
+	for i := range e.Ships {
+		ship := &e.Ships[i]
+		ship.ThrustUsed = 0
+		ship.Evasion = 0
+		for w := range ship.Weapons {
+			ship.Weapons[w].Fired = false
+		}
+	}
+}
+
+// spend uses thrust of the ship for this round
+func spend(s *model.SpaceCombatant, thrust int) error {
+	if thrust <= 0 || s.ThrustUsed+thrust > Thrust(s) {
+		return ErrNoThrust
+	}
+	s.ThrustUsed += thrust
+	return nil
+}
+
+// Move spends thrust to close one band with the target, or open one band away from it
+func Move(e *model.SpaceEncounter, s *model.SpaceCombatant, target *model.SpaceCombatant, closer bool) (string, error) {
+	current := bandIndex(Range(e, s.Id, target.Id))
+	next := current + 1
+	if closer {
+		next = current - 1
+	}
+	if next < 0 || next >= len(RangeBands) {
+		return RangeBands[current].Name, ErrRangeLimit
+	}
+
+	if err := spend(s, RangeBands[min(current, next)].Thrust); err != nil {
+		return RangeBands[current].Name, err
+	}
+
+	band := RangeBands[next].Name
+	return band, SetRange(e, s.Id, target.Id, band)
+}
+
+// Evade spends thrust on evasive action, each point is a DM of -1 to attacks on the ship this round
+func Evade(s *model.SpaceCombatant, thrust int) error {
+	if err := spend(s, thrust); err != nil {
+		return err
+	}
+	s.Evasion += thrust
+	return nil
+}
+
+// Lock has the sensor operator try for a lock on the target, an Electronics (sensors) check at 8+
+func Lock(r *dice.Roller, s *model.SpaceCombatant, target *model.SpaceCombatant) (int, bool) {
+	total, success := r.Check(8, crew(s, model.CrewRole_Sensors)-s.Severity(Location_Sensors))
+	if success {
+		for _, id := range s.Locks {
+			if id == target.Id {
+				return total, true
+			}
+		}
+		s.Locks = append(s.Locks, target.Id)
+	}
+	return total, success
+}
+
+func locked(s *model.SpaceCombatant, target string) bool {
+	for _, id := range s.Locks {
+		if id == target {
+			return true
+		}
+	}
+	return false
+}
+
+type SpaceAttack struct {
+	Weapon string `json:"weapon"`
+	Band   string `json:"band"`
+	Roll   int    `json:"roll"`
+	DM     int    `json:"dm"`
+	Total  int    `json:"total"`
+	Hit    bool   `json:"hit"`
+	Effect int    `json:"effect"`
+	// hull damage after armour
+	Damage    int              `json:"damage"`
+	Criticals []model.Critical `json:"criticals"`
+	Destroyed bool             `json:"destroyed"`
+}
+
+// Fire has a gunner attack the target with one weapon of the ship. The attack is a Gunner check at 8+
+// with the DM of the range band, +2 with a sensor lock and less the evasion of the target. A hit does
+// the weapon damage plus the effect less armour, with a critical when the effect is 6 or more and
+// every 10% of hull lost.
+func Fire(r *dice.Roller, e *model.SpaceEncounter, s *model.SpaceCombatant, weapon int, target *model.SpaceCombatant, dm int) (SpaceAttack, error) {
+	if weapon < 0 || weapon >= len(s.Weapons) {
+		return SpaceAttack{}, ErrNotAWeapon
+	}
+	item := &s.Weapons[weapon]
+	switch {
+	case item.Disabled:
+		return SpaceAttack{}, ErrWeaponDisabled
+	case item.Fired:
+		return SpaceAttack{}, ErrWeaponUsed
+	case item.Damage == "":
+		return SpaceAttack{}, ErrNotAWeapon
+	}
+
+	damage, err := dice.ParseExpression(item.Damage)
+	if err != nil {
+		return SpaceAttack{}, err
+	}
+
+	band := Range(e, s.Id, target.Id)
+	if bandIndex(band) > bandIndex(item.Range) {
+		return SpaceAttack{}, ErrOutOfRange
+	}
+
+	result := SpaceAttack{Weapon: item.Name, Band: band, Criticals: []model.Critical{}}
+	result.DM = crew(s, model.CrewRole_Gunner) + RangeBands[bandIndex(band)].DM - target.Evasion - s.Severity(Location_Computer) + dm
+	if locked(s, target.Id) {
+		result.DM += lockDM
+	}
+
+	item.Fired = true
+	result.Total, result.Hit = r.Check(8, result.DM)
+	result.Roll = result.Total - result.DM
+	result.Effect = result.Total - 8
+	if !result.Hit {
+		return result, nil
+	}
+
+	armour := max(target.Armour-target.Severity(Location_Armour), 0)
+	result.Damage = max(damage.Roll(r)+result.Effect-armour, 0)
+	if result.Damage == 0 {
+		return result, nil
+	}
+
+	if result.Effect >= 6 {
+		severity := int(math.Ceil(float64(result.Damage) / 10))
+		result.Criticals = append(result.Criticals, ApplyCritical(r, target, severity))
+	}
+	result.Criticals = append(result.Criticals, DamageHull(r, target, result.Damage)...)
+	result.Destroyed = target.Status == model.SpaceCombatantStatus_Destroyed
+
+	return result, nil
+}
+
+// DamageHull takes damage off the hull of the ship, rolling a severity 1 critical for every
+// tenth of its hull points lost. A ship with no hull left is destroyed.
+func DamageHull(r *dice.Roller, s *model.SpaceCombatant, damage int) []model.Critical {
+	criticals := []model.Critical{}
+	if s.HullPoints <= 0 {
+		return criticals
+	}
+
+	before := (s.HullPoints - s.Hull) * 10 / s.HullPoints
+	s.Hull = max(s.Hull-damage, 0)
+	after := (s.HullPoints - s.Hull) * 10 / s.HullPoints
+
+	if s.Hull == 0 {
+		s.Status = model.SpaceCombatantStatus_Destroyed
+		return criticals
+	}
+
+	for i := before; i < after; i++ {
+		criticals = append(criticals, ApplyCritical(r, s, 1))
+	}
+	return criticals
+}
+
+// ApplyCritical rolls the location of a critical hit. A location that is hit again takes the higher
+// severity or one more than it had. Hull criticals do 1D damage per severity and weapon criticals
+// disable a weapon, the rest are felt through Severity.
+func ApplyCritical(r *dice.Roller, s *model.SpaceCombatant, severity int) model.Critical {
+	location := criticalLocations[r.D6(2)-2]
+
+	critical := model.Critical{Location: location, Severity: min(severity, maxSeverity)}
+	found := false
+	for i, item := range s.Criticals {
+		if item.Location == location {
+			critical.Severity = min(max(severity, item.Severity+1), maxSeverity)
+			s.Criticals[i] = critical
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.Criticals = append(s.Criticals, critical)
+	}
+
+	switch location {
+	case Location_Hull:
+		s.Hull = max(s.Hull-r.D6(critical.Severity), 0)
+		if s.Hull == 0 {
+			s.Status = model.SpaceCombatantStatus_Destroyed
+		}
+	case Location_Weapon:
+		working := []int{}
+		for i, weapon := range s.Weapons {
+			if !weapon.Disabled {
+				working = append(working, i)
+			}
+		}
+		if len(working) > 0 {
+			s.Weapons[working[r.Pick(len(working))]].Disabled = true
+		}
+	case Location_Sensors:
+		s.Locks = []string{}
+	}
+
+	return critical
+}
+
+// Repair has the engineer patch up a critical, an Engineer check at 8+ less the severity that
+// lowers the severity by one. Weapons come back online once the weapon location is clear.
+func Repair(r *dice.Roller, s *model.SpaceCombatant, location string) (int, bool, error) {
+	index := -1
+	for i, item := range s.Criticals {
+		if item.Location == location {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, false, ErrNoCritical
+	}
+
+	total, success := r.Check(8, crew(s, model.CrewRole_Engineer)-s.Criticals[index].Severity)
+	if !success {
+		return total, false, nil
+	}
+
+	s.Criticals[index].Severity--
+	if s.Criticals[index].Severity == 0 {
+		s.Criticals = append(s.Criticals[:index], s.Criticals[index+1:]...)
+		if location == Location_Weapon {
+			for i := range s.Weapons {
+				s.Weapons[i].Disabled = false
+			}
+		}
+	}
+	return total, true, nil
+}
+
+type Boarding struct {
+	Attacker int  `json:"attacker"`
+	Defender int  `json:"defender"`
+	Success  bool `json:"success"`
+}
+
+// Board rolls an opposed boarding action against an adjacent ship, 2D plus the DM of each side.
+// The boarders take the ship when they beat the defenders.
+func Board(r *dice.Roller, e *model.SpaceEncounter, s *model.SpaceCombatant, target *model.SpaceCombatant, dm int, defenderDM int) (Boarding, error) {
+	if Range(e, s.Id, target.Id) != RangeBands[0].Name {
+		return Boarding{}, ErrNotAdjacent
+	}
+
+	result := Boarding{Attacker: r.D6(2) + dm, Defender: r.D6(2) + defenderDM}
+	result.Success = result.Attacker > result.Defender
+	if result.Success {
+		target.Status = model.SpaceCombatantStatus_Boarded
+	}
+	return result, nil
+}
+
+// Describe is the log line for an attack
+func (a SpaceAttack) Describe(attacker string, target string) string {
+	if !a.Hit {
+		return fmt.Sprintf("%s fires %s at %s at %s range and misses (%d)", attacker, a.Weapon, target, a.Band, a.Total)
+	}
+	message := fmt.Sprintf("%s hits %s with %s at %s range (%d) for %d hull damage", attacker, target, a.Weapon, a.Band, a.Total, a.Damage)
+	for _, critical := range a.Criticals {
+		message += fmt.Sprintf(", critical %s severity %d", critical.Location, critical.Severity)
+	}
+	if a.Destroyed {
+		message += fmt.Sprintf(", %s is destroyed", target)
+	}
+	return message
+}
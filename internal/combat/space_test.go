@@ -0,0 +1,316 @@
+package combat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+// scriptedSource hands out die faces in order so each roll is known, Intn gets one less than the face
+type scriptedSource struct {
+	t     *testing.T
+	faces []int
+}
+
+func (s *scriptedSource) Intn(n int) int {
+	s.t.Helper()
+	if len(s.faces) == 0 {
+		s.t.Fatal("ran out of scripted rolls")
+	}
+	face := s.faces[0]
+	s.faces = s.faces[1:]
+	if face < 1 || face > n {
+		s.t.Fatalf("scripted face %d is not on a d%d", face, n)
+	}
+	return face - 1
+}
+
+// scripted is a roller that rolls the faces given, the test fails when they are not all used
+func scripted(t *testing.T, faces ...int) *dice.Roller {
+	src := &scriptedSource{t: t, faces: faces}
+	t.Cleanup(func() {
+		if len(src.faces) > 0 {
+			t.Errorf("%d scripted rolls were not used", len(src.faces))
+		}
+	})
+	return dice.New(src)
+}
+
+func spaceShip(id string, hull int) model.SpaceCombatant {
+	return model.SpaceCombatant{
+		Id:         id,
+		Name:       id,
+		Crew:       map[string]int{},
+		HullPoints: hull,
+		Hull:       hull,
+		Thrust:     2,
+		Weapons:    []model.ShipWeapon{{Name: "Pulse Laser", Range: "long", Damage: "2D"}, {Name: "Sandcaster", Range: "close"}},
+		Criticals:  []model.Critical{},
+		Locks:      []string{},
+		Status:     model.SpaceCombatantStatus_Active,
+	}
+}
+
+func spaceEncounter(ships ...model.SpaceCombatant) *model.SpaceEncounter {
+	return &model.SpaceEncounter{Status: model.EncounterStatus_Active, Round: 1, Ships: ships, Ranges: []model.SpaceRange{}}
+}
+
+func TestStartSpaceOrdersByInitiative(t *testing.T) {
+	slow, fast := spaceShip("slow", 40), spaceShip("fast", 40)
+	slow.Thrust, fast.Thrust = 1, 4
+	fast.Crew[model.CrewRole_Pilot] = 2
+	e := spaceEncounter(slow, fast)
+	e.Status = model.EncounterStatus_Setup
+
+	// slow rolls 12 -3 unskilled +1 thrust, fast rolls 5 +2 pilot +4 thrust
+	StartSpace(scripted(t, 6, 6, 2, 3), e)
+
+	if e.Ships[0].Id != "fast" || e.Ships[0].Initiative != 11 || e.Ships[1].Initiative != 10 {
+		t.Fatalf("ships are %s %d and %s %d", e.Ships[0].Id, e.Ships[0].Initiative, e.Ships[1].Id, e.Ships[1].Initiative)
+	}
+}
+
+func TestMoveSpendsThrustOfTheBand(t *testing.T) {
+	a, b := spaceShip("a", 40), spaceShip("b", 40)
+	e := spaceEncounter(a, b)
+	ship, target := &e.Ships[0], &e.Ships[1]
+
+	// long to medium costs the 2 thrust of the medium band
+	band, err := Move(e, ship, target, true)
+	if err != nil || band != "medium" || ship.ThrustUsed != 2 {
+		t.Fatalf("moved to %s with %d thrust used: %v", band, ship.ThrustUsed, err)
+	}
+	if _, err := Move(e, ship, target, true); !errors.Is(err, ErrNoThrust) {
+		t.Fatalf("moving with no thrust left gave %v", err)
+	}
+	if Range(e, "b", "a") != "medium" {
+		t.Fatalf("range is %s from the other side", Range(e, "b", "a"))
+	}
+
+	NextRound(e)
+	if ship.ThrustUsed != 0 || e.Round != 2 {
+		t.Fatalf("new round left %d thrust used", ship.ThrustUsed)
+	}
+
+	if err := SetRange(e, "a", "b", "adjacent"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Move(e, ship, target, true); !errors.Is(err, ErrRangeLimit) {
+		t.Fatalf("closing from adjacent gave %v", err)
+	}
+}
+
+func TestThrustLostToCriticals(t *testing.T) {
+	s := spaceShip("a", 40)
+	s.Thrust = 4
+	s.Criticals = []model.Critical{{Location: Location_MDrive, Severity: 1}, {Location: Location_PowerPlant, Severity: 2}}
+	if Thrust(&s) != 1 {
+		t.Fatalf("thrust is %d", Thrust(&s))
+	}
+
+	if err := Evade(&s, 2); !errors.Is(err, ErrNoThrust) {
+		t.Fatalf("evading beyond the thrust left gave %v", err)
+	}
+	if err := Evade(&s, 1); err != nil || s.Evasion != 1 {
+		t.Fatalf("evasion %d: %v", s.Evasion, err)
+	}
+}
+
+func TestFireMisses(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	ship, target := &e.Ships[0], &e.Ships[1]
+	ship.Crew[model.CrewRole_Gunner] = 1
+
+	// 2D of 5, gunner +1 and long range -2
+	attack, err := Fire(scripted(t, 2, 3), e, ship, 0, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attack.Hit || attack.Roll != 5 || attack.DM != -1 || attack.Total != 4 || target.Hull != 40 {
+		t.Fatalf("attack is %+v", attack)
+	}
+	if !ship.Weapons[0].Fired {
+		t.Fatal("weapon is not marked as fired")
+	}
+	if _, err := Fire(scripted(t), e, ship, 0, target, 0); !errors.Is(err, ErrWeaponUsed) {
+		t.Fatalf("firing twice gave %v", err)
+	}
+	if !strings.Contains(attack.Describe("a", "b"), "misses (4)") {
+		t.Fatalf("log is %q", attack.Describe("a", "b"))
+	}
+}
+
+func TestFireHitsThroughArmour(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	ship, target := &e.Ships[0], &e.Ships[1]
+	ship.Crew[model.CrewRole_Gunner] = 2
+	ship.Locks = []string{"b"}
+	target.Armour = 2
+	target.Evasion = 1
+	target.Locks = []string{"a"}
+	if err := SetRange(e, "a", "b", "short"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2D of 8 with gunner +2, short +1, lock +2 and evasion -1 is 12, effect 4.
+	// 2D damage of 6 plus the effect less armour is 8, a fifth of the hull so two
+	// severity 1 criticals, rolled on sensors and the computer.
+	attack, err := Fire(scripted(t, 4, 4, 3, 3, 1, 1, 6, 6), e, ship, 0, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !attack.Hit || attack.DM != 4 || attack.Total != 12 || attack.Effect != 4 || attack.Damage != 8 {
+		t.Fatalf("attack is %+v", attack)
+	}
+	if target.Hull != 32 {
+		t.Fatalf("hull is %d", target.Hull)
+	}
+	want := []model.Critical{{Location: Location_Sensors, Severity: 1}, {Location: Location_Computer, Severity: 1}}
+	if len(attack.Criticals) != 2 || attack.Criticals[0] != want[0] || attack.Criticals[1] != want[1] {
+		t.Fatalf("criticals are %v", attack.Criticals)
+	}
+	if len(target.Locks) != 0 {
+		t.Fatal("a sensors critical should break the locks of the target")
+	}
+}
+
+func TestFireCriticalOnHighEffect(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 100), spaceShip("b", 100))
+	ship, target := &e.Ships[0], &e.Ships[1]
+	ship.Crew[model.CrewRole_Gunner] = 3
+	ship.Weapons[0].Damage = "3D"
+	if err := SetRange(e, "a", "b", "short"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 12 +3 gunner +1 short is 16, effect 8. 15 damage plus the effect is 23,
+	// a severity 3 critical to the armour, then two for the hull lost: the
+	// armour again, going up one, and the power plant.
+	attack, err := Fire(scripted(t, 6, 6, 5, 5, 5, 3, 3, 3, 3, 1, 2), e, ship, 0, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attack.Effect != 8 || attack.Damage != 23 || target.Hull != 77 {
+		t.Fatalf("attack is %+v, hull %d", attack, target.Hull)
+	}
+	if target.Severity(Location_Armour) != 4 || target.Severity(Location_PowerPlant) != 1 || len(attack.Criticals) != 3 {
+		t.Fatalf("criticals are %v", target.Criticals)
+	}
+}
+
+func TestFireDestroys(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	ship, target := &e.Ships[0], &e.Ships[1]
+	target.Hull = 5
+
+	// 2D of 11 with unskilled -3 and long -2 is 6, hit with a +2 from the referee
+	attack, err := Fire(scripted(t, 5, 6, 4, 4), e, ship, 0, target, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attack.Destroyed || target.Hull != 0 || target.Status != model.SpaceCombatantStatus_Destroyed {
+		t.Fatalf("attack is %+v", attack)
+	}
+	if !strings.HasSuffix(attack.Describe("a", "b"), "b is destroyed") {
+		t.Fatalf("log is %q", attack.Describe("a", "b"))
+	}
+}
+
+func TestFireNeedsAWorkingWeaponInRange(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	ship, target := &e.Ships[0], &e.Ships[1]
+
+	ship.Weapons[1].Damage = "1D"
+	if _, err := Fire(scripted(t), e, ship, 1, target, 0); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("firing a close range weapon at long range gave %v", err)
+	}
+	ship.Weapons[0].Disabled = true
+	if _, err := Fire(scripted(t), e, ship, 0, target, 0); !errors.Is(err, ErrWeaponDisabled) {
+		t.Fatalf("firing a disabled weapon gave %v", err)
+	}
+	if _, err := Fire(scripted(t), e, ship, 5, target, 0); !errors.Is(err, ErrNotAWeapon) {
+		t.Fatalf("firing a missing weapon gave %v", err)
+	}
+}
+
+func TestWeaponCriticalAndRepair(t *testing.T) {
+	s := spaceShip("a", 40)
+	s.Crew[model.CrewRole_Engineer] = 2
+
+	// 2D of 5 is the weapon location, the second working weapon is disabled
+	critical := ApplyCritical(scripted(t, 2, 3, 2), &s, 2)
+	if critical.Location != Location_Weapon || critical.Severity != 2 || s.Weapons[0].Disabled || !s.Weapons[1].Disabled {
+		t.Fatalf("critical %+v left weapons %+v", critical, s.Weapons)
+	}
+
+	// engineer +2 less severity 2 needs 8 on the dice
+	if _, ok, err := Repair(scripted(t, 3, 4), &s, Location_Weapon); err != nil || ok {
+		t.Fatalf("repair on 7 succeeded: %v", err)
+	}
+	if _, ok, err := Repair(scripted(t, 4, 4), &s, Location_Weapon); err != nil || !ok || s.Severity(Location_Weapon) != 1 {
+		t.Fatalf("repair on 8 left severity %d: %v", s.Severity(Location_Weapon), err)
+	}
+	if !s.Weapons[1].Disabled {
+		t.Fatal("weapon came back before the location was clear")
+	}
+	if _, ok, err := Repair(scripted(t, 4, 3), &s, Location_Weapon); err != nil || !ok || len(s.Criticals) != 0 || s.Weapons[1].Disabled {
+		t.Fatalf("repair left %v and weapons %+v: %v", s.Criticals, s.Weapons, err)
+	}
+	if _, _, err := Repair(scripted(t), &s, Location_Weapon); !errors.Is(err, ErrNoCritical) {
+		t.Fatalf("repairing a clear location gave %v", err)
+	}
+}
+
+func TestHullCritical(t *testing.T) {
+	s := spaceShip("a", 40)
+
+	// 2D of 7 is the hull, severity 2 does 2D damage
+	critical := ApplyCritical(scripted(t, 3, 4, 6, 5), &s, 2)
+	if critical.Location != Location_Hull || s.Hull != 29 {
+		t.Fatalf("critical %+v left hull %d", critical, s.Hull)
+	}
+}
+
+func TestBoard(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	ship, target := &e.Ships[0], &e.Ships[1]
+
+	if _, err := Board(scripted(t), e, ship, target, 0, 0); !errors.Is(err, ErrNotAdjacent) {
+		t.Fatalf("boarding at long range gave %v", err)
+	}
+
+	if err := SetRange(e, "a", "b", "adjacent"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Board(scripted(t, 4, 4, 5, 2), e, ship, target, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || target.Status != model.SpaceCombatantStatus_Active {
+		t.Fatalf("boarders tied %d to %d and took the ship", result.Attacker, result.Defender)
+	}
+
+	result, err = Board(scripted(t, 5, 5, 3, 3), e, ship, target, 1, 2)
+	if err != nil || !result.Success || target.Status != model.SpaceCombatantStatus_Boarded {
+		t.Fatalf("boarding %+v: %v", result, err)
+	}
+}
+
+func TestRemoveSpaceDropsRanges(t *testing.T) {
+	e := spaceEncounter(spaceShip("a", 40), spaceShip("b", 40))
+	if err := JoinSpace(scripted(t, 1, 1), e, spaceShip("c", 40), "short"); err != nil {
+		t.Fatal(err)
+	}
+	if Range(e, "a", "c") != "short" || Range(e, "b", "c") != "short" || len(e.Ships) != 3 {
+		t.Fatalf("ranges are %v", e.Ranges)
+	}
+
+	RemoveSpace(e, "a")
+	if len(e.Ships) != 2 || len(e.Ranges) != 1 || e.Ranges[0].A != "b" {
+		t.Fatalf("ships %d, ranges %v", len(e.Ships), e.Ranges)
+	}
+}
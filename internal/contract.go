@@ -0,0 +1,21 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterContractPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/contracts")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListContracts).Name = "contracts"
+	g.POST("/generate", h.GenerateContracts).Name = "contract-generate"
+	g.POST("/publish", h.PublishContracts).Name = "contract-publish"
+	g.POST("/:contractId/accept", h.AcceptContract).Name = "contract-accept"
+	g.POST("/:contractId/deliver", h.DeliverContract).Name = "contract-deliver"
+	g.POST("/:contractId/cancel", h.CancelContract).Name = "contract-cancel"
+	g.DELETE("/:contractId", h.DeleteContract).Name = "contract-delete"
+}
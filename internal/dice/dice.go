@@ -0,0 +1,185 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is where a Roller gets its random numbers from, *rand.Rand satisfies it.
+// Tests and seeded generators can inject their own.
+type Source interface {
+	// Intn returns a number in [0,n)
+	Intn(n int) int
+}
+
+// lockedSource lets a single source be shared between requests
+type lockedSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (s *lockedSource) Intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Intn(n)
+}
+
+// NewSource returns a source seeded with seed that is safe to use from more than one goroutine
+func NewSource(seed int64) Source {
+	return &lockedSource{rng: rand.New(rand.NewSource(seed))}
+}
+
+type Roller struct {
+	src Source
+}
+
+func New(src Source) *Roller {
+	return &Roller{src: src}
+}
+
+// Seeded returns a roller that always produces the same rolls for the same seed
+func Seeded(seed int64) *Roller {
+	return New(rand.New(rand.NewSource(seed)))
+}
+
+// Default returns a roller seeded from the current time
+func Default() *Roller {
+	return New(NewSource(time.Now().UnixNano()))
+}
+
+// D rolls a single die with the given number of sides
+func (r *Roller) D(sides int) int {
+	if sides <= 0 {
+		return 0
+	}
+	return r.src.Intn(sides) + 1
+}
+
+// Roll rolls count dice with the given number of sides and adds them together
+func (r *Roller) Roll(count int, sides int) int {
+	total := 0
+	for i := 0; i < count; i++ {
+		total += r.D(sides)
+	}
+	return total
+}
+
+// D6 rolls count six sided dice, 2D in the rules is D6(2)
+func (r *Roller) D6(count int) int {
+	return r.Roll(count, 6)
+}
+
+// D66 rolls two dice reading the first as tens and the second as units, giving 11 to 66
+func (r *Roller) D66() int {
+	return r.D(6)*10 + r.D(6)
+}
+
+// Check rolls 2D plus the modifier and reports if it met the target number
+func (r *Roller) Check(target int, dm int) (int, bool) {
+	roll := r.D6(2) + dm
+	return roll, roll >= target
+}
+
+// Pick returns a random index into a list of n items
+func (r *Roller) Pick(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return r.src.Intn(n)
+}
+
+var ErrInvalidExpression = errors.New("invalid dice expression")
+
+// Expression is a roll written as in the rules, 2D, 1D+3, 3D6-1 or D66
+type Expression struct {
+	Count    int
+	Sides    int
+	Modifier int
+	// D66 reads two dice as tens and units
+	D66 bool
+}
+
+func ParseExpression(value string) (Expression, error) {
+	value = strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if value == "" {
+		return Expression{}, fmt.Errorf("%w: empty", ErrInvalidExpression)
+	}
+
+	if value == "D66" {
+		return Expression{D66: true}, nil
+	}
+
+	count, rest, ok := strings.Cut(value, "D")
+	if !ok {
+		// a plain number
+		modifier, err := strconv.Atoi(value)
+		if err != nil {
+			return Expression{}, fmt.Errorf("%w: '%s'", ErrInvalidExpression, value)
+		}
+		return Expression{Modifier: modifier}, nil
+	}
+
+	expr := Expression{Count: 1, Sides: 6}
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return Expression{}, fmt.Errorf("%w: '%s'", ErrInvalidExpression, value)
+		}
+		expr.Count = n
+	}
+
+	sides, modifier := rest, ""
+	if i := strings.IndexAny(rest, "+-"); i >= 0 {
+		sides, modifier = rest[:i], rest[i:]
+	}
+	if sides != "" {
+		n, err := strconv.Atoi(sides)
+		if err != nil || n <= 0 {
+			return Expression{}, fmt.Errorf("%w: '%s'", ErrInvalidExpression, value)
+		}
+		expr.Sides = n
+	}
+	if modifier != "" {
+		n, err := strconv.Atoi(modifier)
+		if err != nil {
+			return Expression{}, fmt.Errorf("%w: '%s'", ErrInvalidExpression, value)
+		}
+		expr.Modifier = n
+	}
+
+	return expr, nil
+}
+
+func (e Expression) Roll(r *Roller) int {
+	if e.D66 {
+		return r.D66() + e.Modifier
+	}
+	return r.Roll(e.Count, e.Sides) + e.Modifier
+}
+
+func (e Expression) String() string {
+	var value string
+	switch {
+	case e.D66:
+		value = "D66"
+	case e.Count == 0:
+		return strconv.Itoa(e.Modifier)
+	case e.Sides == 6:
+		value = fmt.Sprintf("%dD", e.Count)
+	default:
+		value = fmt.Sprintf("%dD%d", e.Count, e.Sides)
+	}
+
+	if e.Modifier > 0 {
+		return fmt.Sprintf("%s+%d", value, e.Modifier)
+	}
+	if e.Modifier < 0 {
+		return fmt.Sprintf("%s%d", value, e.Modifier)
+	}
+	return value
+}
@@ -0,0 +1,222 @@
+package equipment
+
+import (
+	"fmt"
+	"os"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Kind string
+
+const (
+	Kind_Weapon  Kind = "weapon"
+	Kind_Armour  Kind = "armour"
+	Kind_Gear    Kind = "gear"
+	Kind_Augment Kind = "augment"
+)
+
+func (k Kind) Valid() bool {
+	return k == Kind_Weapon || k == Kind_Armour || k == Kind_Gear || k == Kind_Augment
+}
+
+type Weapon struct {
+	Id    string `yaml:"id" json:"id"`
+	Name  string `yaml:"name" json:"name"`
+	TL    int    `yaml:"tl" json:"tl"`
+	Skill string `yaml:"skill" json:"skill"`
+	// range in metres, zero for melee weapons
+	Range        int      `yaml:"range" json:"range"`
+	Damage       string   `yaml:"damage" json:"damage"`
+	Mass         float64  `yaml:"mass" json:"mass"`
+	Cost         int64    `yaml:"cost" json:"cost"`
+	Magazine     int      `yaml:"magazine" json:"magazine"`
+	MagazineCost int64    `yaml:"magazine_cost" json:"magazineCost"`
+	Traits       []string `yaml:"traits" json:"traits"`
+
+	damage dice.Expression
+}
+
+// DamageRoll is the parsed damage of the weapon
+func (w Weapon) DamageRoll() dice.Expression {
+	return w.damage
+}
+
+type Armour struct {
+	Id         string  `yaml:"id" json:"id"`
+	Name       string  `yaml:"name" json:"name"`
+	TL         int     `yaml:"tl" json:"tl"`
+	Protection int     `yaml:"protection" json:"protection"`
+	Rad        int     `yaml:"rad" json:"rad"`
+	Mass       float64 `yaml:"mass" json:"mass"`
+	Cost       int64   `yaml:"cost" json:"cost"`
+	// skill needed to wear the armour without penalty
+	Skill string `yaml:"skill" json:"skill"`
+}
+
+type Gear struct {
+	Id          string  `yaml:"id" json:"id"`
+	Name        string  `yaml:"name" json:"name"`
+	TL          int     `yaml:"tl" json:"tl"`
+	Mass        float64 `yaml:"mass" json:"mass"`
+	Cost        int64   `yaml:"cost" json:"cost"`
+	Description string  `yaml:"description" json:"description"`
+}
+
+// Effect changes a characteristic or a skill of whoever has the augment installed
+type Effect struct {
+	Characteristic string `yaml:"characteristic" json:"characteristic,omitempty"`
+	Skill          string `yaml:"skill" json:"skill,omitempty"`
+	Value          int    `yaml:"value" json:"value"`
+}
+
+type Augment struct {
+	Id      string   `yaml:"id" json:"id"`
+	Name    string   `yaml:"name" json:"name"`
+	TL      int      `yaml:"tl" json:"tl"`
+	Cost    int64    `yaml:"cost" json:"cost"`
+	Effects []Effect `yaml:"effects" json:"effects"`
+	// armour built into the body, stacks with worn armour
+	Protection int `yaml:"protection" json:"protection"`
+}
+
+type EncumbranceLevel struct {
+	Name       string  `yaml:"name" json:"name"`
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"`
+	DM         int     `yaml:"dm" json:"dm"`
+}
+
+type EncumbranceTable struct {
+	WornArmourFactor float64            `yaml:"worn_armour_factor"`
+	Levels           []EncumbranceLevel `yaml:"levels"`
+	Over             EncumbranceLevel   `yaml:"over"`
+}
+
+type Catalog struct {
+	Weapons  []Weapon         `yaml:"weapons"`
+	Armour   []Armour         `yaml:"armour"`
+	Gear     []Gear           `yaml:"gear"`
+	Augments []Augment        `yaml:"augments"`
+	Loads    EncumbranceTable `yaml:"encumbrance"`
+}
+
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog Catalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+
+	for i := range catalog.Weapons {
+		weapon := &catalog.Weapons[i]
+		if weapon.damage, err = dice.ParseExpression(weapon.Damage); err != nil {
+			return nil, fmt.Errorf("weapon '%s': %w", weapon.Id, err)
+		}
+	}
+
+	for _, augment := range catalog.Augments {
+		for _, effect := range augment.Effects {
+			if effect.Characteristic != "" && !model.ValidCharacteristic(effect.Characteristic) {
+				return nil, fmt.Errorf("augment '%s': unknown characteristic '%s'", augment.Id, effect.Characteristic)
+			}
+		}
+	}
+
+	if len(catalog.Loads.Levels) == 0 {
+		return nil, fmt.Errorf("equipment catalog needs at least one encumbrance level")
+	}
+
+	return &catalog, nil
+}
+
+// Item is the part of a catalog entry every kind of carried equipment has
+type Item struct {
+	Id   string  `json:"id"`
+	Name string  `json:"name"`
+	Kind Kind    `json:"kind"`
+	TL   int     `json:"tl"`
+	Mass float64 `json:"mass"`
+	Cost int64   `json:"cost"`
+	// the full catalog entry
+	Details interface{} `json:"details"`
+}
+
+// Item finds a weapon, armour or gear entry by id. Augments are installed rather than
+// carried so they are looked up with Augment.
+func (c *Catalog) Item(id string) (Item, bool) {
+	for _, item := range c.Weapons {
+		if item.Id == id {
+			return Item{item.Id, item.Name, Kind_Weapon, item.TL, item.Mass, item.Cost, item}, true
+		}
+	}
+	for _, item := range c.Armour {
+		if item.Id == id {
+			return Item{item.Id, item.Name, Kind_Armour, item.TL, item.Mass, item.Cost, item}, true
+		}
+	}
+	for _, item := range c.Gear {
+		if item.Id == id {
+			return Item{item.Id, item.Name, Kind_Gear, item.TL, item.Mass, item.Cost, item}, true
+		}
+	}
+	return Item{}, false
+}
+
+func (c *Catalog) Weapon(id string) (Weapon, bool) {
+	for _, item := range c.Weapons {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Weapon{}, false
+}
+
+func (c *Catalog) ArmourType(id string) (Armour, bool) {
+	for _, item := range c.Armour {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Armour{}, false
+}
+
+func (c *Catalog) Augment(id string) (Augment, bool) {
+	for _, item := range c.Augments {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Augment{}, false
+}
+
+// Items lists the catalog entries of a kind, or every entry when kind is empty
+func (c *Catalog) Items(kind Kind) []Item {
+	items := []Item{}
+	if kind == "" || kind == Kind_Weapon {
+		for _, item := range c.Weapons {
+			items = append(items, Item{item.Id, item.Name, Kind_Weapon, item.TL, item.Mass, item.Cost, item})
+		}
+	}
+	if kind == "" || kind == Kind_Armour {
+		for _, item := range c.Armour {
+			items = append(items, Item{item.Id, item.Name, Kind_Armour, item.TL, item.Mass, item.Cost, item})
+		}
+	}
+	if kind == "" || kind == Kind_Gear {
+		for _, item := range c.Gear {
+			items = append(items, Item{item.Id, item.Name, Kind_Gear, item.TL, item.Mass, item.Cost, item})
+		}
+	}
+	if kind == "" || kind == Kind_Augment {
+		for _, item := range c.Augments {
+			items = append(items, Item{item.Id, item.Name, Kind_Augment, item.TL, 0, item.Cost, item})
+		}
+	}
+	return items
+}
@@ -0,0 +1,41 @@
+package equipment
+
+import (
+	"visualsource/traveller/internal/model"
+)
+
+type Encumbrance struct {
+	// Mass is the kg carried, worn armour counted at its reduced mass
+	Mass float64 `json:"mass"`
+	// Limit is STR + END, the kg that can be carried without penalty
+	Limit int    `json:"limit"`
+	Level string `json:"level"`
+	// DM applies to checks on STR, DEX and END and the skills based on them
+	DM int `json:"dm"`
+}
+
+// Encumbrance works out the load of a character from what they carry
+func (c *Catalog) Encumbrance(characteristics model.Characteristics, items []model.InventoryItem) Encumbrance {
+	result := Encumbrance{Limit: max(characteristics.STR+characteristics.END, 0)}
+	for _, inventory := range items {
+		item, ok := c.Item(inventory.ItemId)
+		if !ok {
+			continue
+		}
+		mass := item.Mass * float64(inventory.Quantity)
+		if item.Kind == Kind_Armour && inventory.Equipped {
+			mass *= c.Loads.WornArmourFactor
+		}
+		result.Mass += mass
+	}
+
+	result.Level, result.DM = c.Loads.Over.Name, c.Loads.Over.DM
+	for _, level := range c.Loads.Levels {
+		if result.Mass <= float64(result.Limit)*level.Multiplier {
+			result.Level, result.DM = level.Name, level.DM
+			break
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,131 @@
+package equipment
+
+import (
+	"visualsource/traveller/internal/model"
+)
+
+// Kinds of source that change a characteristic or skill
+const (
+	Source_Augment   = "augment"
+	Source_Injury    = "injury"
+	Source_Equipment = "equipment"
+)
+
+// Modifier is one contribution to an effective value. Equipment modifiers change the DM of a
+// characteristic rather than its score.
+type Modifier struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type CharacteristicStat struct {
+	Base      int `json:"base"`
+	Effective int `json:"effective"`
+	// DM of the effective score with any equipment modifiers
+	DM      int        `json:"dm"`
+	Sources []Modifier `json:"sources"`
+}
+
+type SkillStat struct {
+	Base      int        `json:"base"`
+	Effective int        `json:"effective"`
+	Sources   []Modifier `json:"sources"`
+}
+
+// Stats are the values a character uses for checks once everything affecting them is stacked
+type Stats struct {
+	Characteristics map[string]CharacteristicStat `json:"characteristics"`
+	Skills          map[string]SkillStat          `json:"skills"`
+	// armour of the best equipped suit plus any built into the body
+	Protection        int         `json:"protection"`
+	ProtectionSources []Modifier  `json:"protectionSources"`
+	Encumbrance       Encumbrance `json:"encumbrance"`
+}
+
+// Stats stacks the base characteristics and skills of a character with their augments,
+// injuries and what they carry. Augments missing from the catalog are skipped.
+func (c *Catalog) Stats(character model.Character, augments []model.CharacterAugment, items []model.InventoryItem) Stats {
+	stats := Stats{
+		Characteristics:   map[string]CharacteristicStat{},
+		Skills:            map[string]SkillStat{},
+		ProtectionSources: []Modifier{},
+	}
+
+	for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END, model.Characteristic_INT, model.Characteristic_EDU, model.Characteristic_SOC, model.Characteristic_PSI} {
+		base := character.Characteristics.Get(code)
+		stats.Characteristics[code] = CharacteristicStat{Base: base, Effective: base, Sources: []Modifier{}}
+	}
+	for skill, level := range character.Skills {
+		stats.Skills[skill] = SkillStat{Base: level, Effective: level, Sources: []Modifier{}}
+	}
+
+	for _, installed := range augments {
+		augment, ok := c.Augment(installed.AugmentId)
+		if !ok {
+			continue
+		}
+		for _, effect := range augment.Effects {
+			modifier := Modifier{Source_Augment, augment.Name, effect.Value}
+			if effect.Characteristic != "" {
+				stat := stats.Characteristics[effect.Characteristic]
+				stat.Effective += effect.Value
+				stat.Sources = append(stat.Sources, modifier)
+				stats.Characteristics[effect.Characteristic] = stat
+			}
+			if effect.Skill != "" {
+				// an augment can give a skill the character never trained
+				stat, ok := stats.Skills[effect.Skill]
+				if !ok {
+					stat.Sources = []Modifier{}
+				}
+				stat.Effective += effect.Value
+				stat.Sources = append(stat.Sources, modifier)
+				stats.Skills[effect.Skill] = stat
+			}
+		}
+		if augment.Protection != 0 {
+			stats.Protection += augment.Protection
+			stats.ProtectionSources = append(stats.ProtectionSources, Modifier{Source_Augment, augment.Name, augment.Protection})
+		}
+	}
+
+	var effective model.Characteristics
+	for code, stat := range stats.Characteristics {
+		if injury := character.Injuries.Get(code); injury != 0 {
+			stat.Effective -= injury
+			stat.Sources = append(stat.Sources, Modifier{Source_Injury, "injury", -injury})
+		}
+		stat.Effective = max(stat.Effective, 0)
+		effective.Add(code, stat.Effective)
+		stats.Characteristics[code] = stat
+	}
+
+	// what can be carried follows the STR and END the character has after augments and injuries
+	stats.Encumbrance = c.Encumbrance(effective, items)
+	for code, stat := range stats.Characteristics {
+		stat.DM = model.CharacteristicDM(stat.Effective)
+		if model.PhysicalCharacteristic(code) && stats.Encumbrance.DM != 0 {
+			stat.DM += stats.Encumbrance.DM
+			stat.Sources = append(stat.Sources, Modifier{Source_Equipment, stats.Encumbrance.Level, stats.Encumbrance.DM})
+		}
+		stats.Characteristics[code] = stat
+	}
+
+	// suits of armour do not stack, only the best one worn counts
+	var worn *Armour
+	for _, inventory := range items {
+		if !inventory.Equipped {
+			continue
+		}
+		if armour, ok := c.ArmourType(inventory.ItemId); ok && (worn == nil || armour.Protection > worn.Protection) {
+			worn = &armour
+		}
+	}
+	if worn != nil {
+		stats.Protection += worn.Protection
+		stats.ProtectionSources = append(stats.ProtectionSources, Modifier{Source_Equipment, worn.Name, worn.Protection})
+	}
+
+	return stats
+}
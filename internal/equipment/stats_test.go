@@ -0,0 +1,44 @@
+package equipment
+
+import (
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func TestStatsEncumbranceUsesEffectiveCharacteristics(t *testing.T) {
+	catalog, err := Load("../../configs/equipment.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 14 kg on a character with STR 6 and END 6
+	items := []model.InventoryItem{{ItemId: "tool_kit", Quantity: 1}, {ItemId: "rations", Quantity: 1}}
+	strength := []model.CharacterAugment{{AugmentId: "physical_augmentation_str"}, {AugmentId: "physical_augmentation_end"}}
+
+	tests := []struct {
+		name     string
+		augments []model.CharacterAugment
+		injuries model.Characteristics
+		limit    int
+		level    string
+		dm       int
+	}{
+		{"base", nil, model.Characteristics{}, 12, "encumbered", -1},
+		{"augmented", strength, model.Characteristics{}, 14, "unencumbered", 0},
+		{"injured", nil, model.Characteristics{END: 4}, 8, "encumbered", -1},
+		{"badly injured", nil, model.Characteristics{END: 6, STR: 1}, 5, "overloaded", -2},
+	}
+
+	for _, test := range tests {
+		character := model.Character{Characteristics: model.Characteristics{STR: 6, DEX: 7, END: 6}, Skills: map[string]int{}, Injuries: test.injuries}
+		stats := catalog.Stats(character, test.augments, items)
+
+		if stats.Encumbrance.Limit != test.limit || stats.Encumbrance.Level != test.level || stats.Encumbrance.DM != test.dm {
+			t.Errorf("%s: encumbrance is %+v", test.name, stats.Encumbrance)
+		}
+		// DEX 7 has no DM of its own, all of it comes from the load
+		if dex := stats.Characteristics[model.Characteristic_DEX]; dex.DM != test.dm {
+			t.Errorf("%s: DEX DM is %d", test.name, dex.DM)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterEventPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/events")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListEvents).Name = "events"
+	g.POST("", h.SendEvent).Name = "event-send"
+	g.POST("/:entryId/ack", h.AcknowledgeEvent).Name = "event-ack"
+}
@@ -0,0 +1,26 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterFinancePages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/finances")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.GetFinances).Name = "finances"
+	g.GET("/panel", h.SessionFinances).Name = "finances-panel"
+	g.POST("/transactions", h.CreateTransaction).Name = "finance-transaction"
+	g.POST("/advance", h.AdvanceMonth).Name = "finance-advance"
+
+	g.GET("/recurring", h.ListRecurringCharges).Name = "finance-recurring"
+	g.POST("/recurring", h.CreateRecurringCharge).Name = "finance-recurring-create"
+	g.PUT("/recurring/:chargeId", h.UpdateRecurringCharge).Name = "finance-recurring-update"
+	g.DELETE("/recurring/:chargeId", h.DeleteRecurringCharge).Name = "finance-recurring-delete"
+
+	g.GET("/:accountType/:accountId/ledger", h.GetLedger).Name = "finance-ledger"
+	g.GET("/:accountType/:accountId/history", h.GetBalanceHistory).Name = "finance-history"
+}
@@ -0,0 +1,21 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterGeneratorPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/generators")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListGeneratedResults).Name = "generated"
+	g.GET("/tables", h.ListGeneratorTables).Name = "generator-tables"
+	g.POST("/encounter", h.GenerateEncounter).Name = "generate-encounter"
+	g.POST("/mission", h.GenerateMission).Name = "generate-mission"
+	g.POST("/rumour", h.GenerateRumour).Name = "generate-rumour"
+	g.POST("/:resultId/reveal", h.RevealGeneratedResult).Name = "generated-reveal"
+	g.DELETE("/:resultId", h.DeleteGeneratedResult).Name = "generated-delete"
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"visualsource/traveller/internal/dice"
+)
+
+var ErrUnknownTerrain = errors.New("unknown terrain")
+
+// Result is an entry read off a table
+type Result struct {
+	Roll  int    `json:"roll"`
+	Text  string `json:"text"`
+	Count int    `json:"count,omitempty"`
+}
+
+// Lookup rolls the table, rolls outside every entry read the nearest one
+func (t *Table) Lookup(r *dice.Roller) Result {
+	roll := t.roll.Roll(r)
+
+	nearest, distance := t.Entries[0], -1
+	for _, entry := range t.Entries {
+		d := 0
+		if roll < entry.Min {
+			d = entry.Min - roll
+		} else if roll > entry.Max {
+			d = roll - entry.Max
+		}
+		if distance < 0 || d < distance {
+			nearest, distance = entry, d
+		}
+	}
+
+	result := Result{Roll: roll, Text: nearest.Text}
+	if nearest.count != nil {
+		result.Count = max(nearest.count.Roll(r), 1)
+	}
+	return result
+}
+
+func (r Result) String() string {
+	if r.Count > 0 {
+		return fmt.Sprintf("%s (%d)", r.Text, r.Count)
+	}
+	return r.Text
+}
+
+type Encounter struct {
+	Terrain string `json:"terrain"`
+	Result
+}
+
+func (e Encounter) Summary() string {
+	return fmt.Sprintf("%s: %s", e.Terrain, e.Result)
+}
+
+type Mission struct {
+	Patron       Result `json:"patron"`
+	Mission      Result `json:"mission"`
+	Target       Result `json:"target"`
+	Complication Result `json:"complication"`
+	// in credits
+	Reward int64 `json:"reward"`
+}
+
+// Brief is the mission as the patron tells it, without the complication
+func (m Mission) Brief() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Patron: %s\n", m.Patron.Text)
+	fmt.Fprintf(&b, "Mission: %s\n", m.Mission.Text)
+	fmt.Fprintf(&b, "Target: %s\n", m.Target.Text)
+	fmt.Fprintf(&b, "Reward: Cr%d", m.Reward)
+	return b.String()
+}
+
+func (m Mission) Summary() string {
+	return m.Brief() + "\nComplication: " + m.Complication.Text
+}
+
+type Rumour struct {
+	Result
+}
+
+func (r Rumour) Summary() string {
+	return r.Text
+}
+
+// Encounter rolls on the table of the terrain
+func (t *Tables) Encounter(r *dice.Roller, terrainId string) (Encounter, error) {
+	terrain, ok := t.Terrain(terrainId)
+	if !ok {
+		return Encounter{}, ErrUnknownTerrain
+	}
+	return Encounter{Terrain: terrain.Name, Result: terrain.Table.Lookup(r)}, nil
+}
+
+// WorldEncounter rolls on the table of the terrain the trade codes of a world point to
+func (t *Tables) WorldEncounter(r *dice.Roller, tradeCodes []string) Encounter {
+	terrain := t.WorldTerrain(tradeCodes)
+	return Encounter{Terrain: terrain.Name, Result: terrain.Table.Lookup(r)}
+}
+
+// Mission rolls a patron, what they want done, to what, the complication and the reward
+func (t *Tables) Mission(r *dice.Roller) Mission {
+	return Mission{
+		Patron:       t.Patron.Patrons.Lookup(r),
+		Mission:      t.Patron.Missions.Lookup(r),
+		Target:       t.Patron.Targets.Lookup(r),
+		Complication: t.Patron.Complications.Lookup(r),
+		Reward:       int64(max(t.Patron.reward.Roll(r), 1)) * t.Patron.RewardMultiplier,
+	}
+}
+
+func (t *Tables) Rumour(r *dice.Roller) Rumour {
+	return Rumour{t.Rumours.Lookup(r)}
+}
@@ -0,0 +1,189 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"visualsource/traveller/internal/dice"
+)
+
+// fixedSource returns the values given in order from Intn, a D6 face is one more than its value
+type fixedSource struct {
+	t      *testing.T
+	values []int
+}
+
+func (s *fixedSource) Intn(n int) int {
+	s.t.Helper()
+	if len(s.values) == 0 {
+		s.t.Fatal("ran out of rolls")
+	}
+	value := s.values[0]
+	s.values = s.values[1:]
+	if value < 0 || value >= n {
+		s.t.Fatalf("roll %d is out of [0,%d)", value, n)
+	}
+	return value
+}
+
+func rolls(t *testing.T, values ...int) *dice.Roller {
+	return dice.New(&fixedSource{t: t, values: values})
+}
+
+const testTables = `
+default_terrain: plains
+terrains:
+  - id: plains
+    name: Plains
+    table:
+      roll: 1D
+      entries:
+        - { min: 2, max: 3, text: Herd of grazers, count: 2D }
+        - { min: 5, max: 5, text: Lone hunter }
+  - id: ocean
+    name: Ocean
+    trade_codes: [Wa, Fl]
+    table:
+      roll: 1D
+      entries:
+        - { min: 1, max: 6, text: Swimmers, count: 1D-3 }
+patron:
+  reward: 1D
+  reward_multiplier: 1000
+  patrons:
+    roll: 1D
+    entries: [{ min: 1, max: 6, text: Noble }]
+  missions:
+    roll: 1D
+    entries: [{ min: 1, max: 6, text: Recover a data core }]
+  targets:
+    roll: 1D
+    entries: [{ min: 1, max: 6, text: A derelict }]
+  complications:
+    roll: 1D
+    entries: [{ min: 1, max: 6, text: The patron is lying }]
+rumours:
+  roll: 2D
+  entries: [{ min: 2, max: 12, text: The starport is closing }]
+`
+
+func loadTables(t *testing.T, data string) (*Tables, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "encounters.yml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLookup(t *testing.T) {
+	tables, err := loadTables(t, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plains, _ := tables.Terrain("plains")
+
+	// 3 is a herd, 2D of 4 of them
+	if result := plains.Table.Lookup(rolls(t, 2, 1, 1)); result.Roll != 3 || result.Text != "Herd of grazers" || result.Count != 4 {
+		t.Fatalf("rolled %+v", result)
+	}
+	// 6 is past every entry and reads the nearest, 4 is as near to both and reads the first
+	if result := plains.Table.Lookup(rolls(t, 5)); result.Text != "Lone hunter" || result.Count != 0 {
+		t.Fatalf("rolled %+v", result)
+	}
+	if result := plains.Table.Lookup(rolls(t, 3, 0, 0)); result.Text != "Herd of grazers" {
+		t.Fatalf("rolled %+v", result)
+	}
+
+	// counts are at least 1
+	ocean, _ := tables.Terrain("ocean")
+	if result := ocean.Table.Lookup(rolls(t, 0, 0)); result.Count != 1 || result.String() != "Swimmers (1)" {
+		t.Fatalf("rolled %+v", result)
+	}
+}
+
+func TestEncounterTerrain(t *testing.T) {
+	tables, err := loadTables(t, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tables.Encounter(rolls(t), "swamp"); !errors.Is(err, ErrUnknownTerrain) {
+		t.Fatalf("unknown terrain gave %v", err)
+	}
+	if encounter := tables.WorldEncounter(rolls(t, 2, 5), []string{"Ni", "Wa"}); encounter.Terrain != "Ocean" || encounter.Summary() != "Ocean: Swimmers (3)" {
+		t.Fatalf("water world rolled %+v", encounter)
+	}
+	if encounter := tables.WorldEncounter(rolls(t, 4), []string{"Ag"}); encounter.Terrain != "Plains" || encounter.Summary() != "Plains: Lone hunter" {
+		t.Fatalf("other worlds rolled %+v", encounter)
+	}
+}
+
+func TestMission(t *testing.T) {
+	tables, err := loadTables(t, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mission := tables.Mission(rolls(t, 0, 0, 0, 0, 3))
+	if mission.Reward != 4000 {
+		t.Fatalf("reward is %d", mission.Reward)
+	}
+	if strings.Contains(mission.Brief(), "lying") {
+		t.Fatalf("the brief gives away the complication: %q", mission.Brief())
+	}
+	if !strings.HasSuffix(mission.Summary(), "Complication: The patron is lying") {
+		t.Fatalf("summary is %q", mission.Summary())
+	}
+
+	if rumour := tables.Rumour(rolls(t, 0, 0)); rumour.Summary() != "The starport is closing" {
+		t.Fatalf("rumour is %+v", rumour)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		message string
+	}{
+		{"backwards entry", "{ min: 5, max: 5, text: Lone hunter }", "{ min: 5, max: 4, text: Lone hunter }", "ends before it starts"},
+		{"bad roll", "roll: 2D\n", "roll: 2X\n", "rumours"},
+		{"bad count", "count: 2D }", "count: lots }", "Herd of grazers"},
+		{"missing default", "default_terrain: plains", "default_terrain: swamp", "default terrain"},
+		{"bad reward", "reward: 1D", "reward: much", "patron reward"},
+	}
+
+	for _, test := range tests {
+		if !strings.Contains(testTables, test.from) {
+			t.Fatalf("%s: %q is not in the tables", test.name, test.from)
+		}
+		_, err := loadTables(t, strings.Replace(testTables, test.from, test.to, 1))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tables, err := Load("../../configs/encounters.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := dice.Seeded(1)
+	for _, terrain := range tables.Terrains {
+		for i := 0; i < 20; i++ {
+			if result := terrain.Table.Lookup(r); result.Text == "" {
+				t.Fatalf("%s rolled an empty entry", terrain.Id)
+			}
+		}
+	}
+	if mission := tables.Mission(r); mission.Reward <= 0 || mission.Patron.Text == "" {
+		t.Fatalf("mission is %+v", mission)
+	}
+}
@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"visualsource/traveller/internal/dice"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Entry struct {
+	Min  int    `yaml:"min" json:"min"`
+	Max  int    `yaml:"max" json:"max"`
+	Text string `yaml:"text" json:"text"`
+	// how many turn up, empty when it does not apply
+	Count string `yaml:"count" json:"count"`
+
+	count *dice.Expression
+}
+
+// Table is rolled with Roll and read with the entry covering the result
+type Table struct {
+	Roll    string  `yaml:"roll" json:"roll"`
+	Entries []Entry `yaml:"entries" json:"entries"`
+
+	roll dice.Expression
+}
+
+type Terrain struct {
+	Id   string `yaml:"id" json:"id"`
+	Name string `yaml:"name" json:"name"`
+	// worlds with any of these trade codes use the terrain
+	TradeCodes []string `yaml:"trade_codes" json:"tradeCodes"`
+	Table      Table    `yaml:"table" json:"table"`
+}
+
+type PatronTables struct {
+	Patrons          Table  `yaml:"patrons"`
+	Missions         Table  `yaml:"missions"`
+	Targets          Table  `yaml:"targets"`
+	Complications    Table  `yaml:"complications"`
+	Reward           string `yaml:"reward"`
+	RewardMultiplier int64  `yaml:"reward_multiplier"`
+
+	reward dice.Expression
+}
+
+type Tables struct {
+	DefaultTerrain string       `yaml:"default_terrain"`
+	Terrains       []Terrain    `yaml:"terrains"`
+	Patron         PatronTables `yaml:"patron"`
+	Rumours        Table        `yaml:"rumours"`
+}
+
+// parse checks the table and reads its dice expressions
+func (t *Table) parse(name string) error {
+	if len(t.Entries) == 0 {
+		return fmt.Errorf("table '%s' has no entries", name)
+	}
+
+	var err error
+	if t.roll, err = dice.ParseExpression(t.Roll); err != nil {
+		return fmt.Errorf("table '%s': %w", name, err)
+	}
+
+	for i := range t.Entries {
+		entry := &t.Entries[i]
+		if entry.Max < entry.Min {
+			return fmt.Errorf("table '%s': entry '%s' ends before it starts", name, entry.Text)
+		}
+		if entry.Count == "" {
+			continue
+		}
+		count, err := dice.ParseExpression(entry.Count)
+		if err != nil {
+			return fmt.Errorf("table '%s': entry '%s': %w", name, entry.Text, err)
+		}
+		entry.count = &count
+	}
+
+	return nil
+}
+
+func Load(path string) (*Tables, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables Tables
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+
+	for i := range tables.Terrains {
+		terrain := &tables.Terrains[i]
+		if err := terrain.Table.parse(terrain.Id); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := tables.Terrain(tables.DefaultTerrain); !ok {
+		return nil, fmt.Errorf("default terrain '%s' is not in the terrains", tables.DefaultTerrain)
+	}
+
+	for name, table := range map[string]*Table{
+		"patrons":       &tables.Patron.Patrons,
+		"missions":      &tables.Patron.Missions,
+		"targets":       &tables.Patron.Targets,
+		"complications": &tables.Patron.Complications,
+		"rumours":       &tables.Rumours,
+	} {
+		if err := table.parse(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if tables.Patron.reward, err = dice.ParseExpression(tables.Patron.Reward); err != nil {
+		return nil, fmt.Errorf("patron reward: %w", err)
+	}
+	if tables.Patron.RewardMultiplier <= 0 {
+		tables.Patron.RewardMultiplier = 1
+	}
+
+	return &tables, nil
+}
+
+func (t *Tables) Terrain(id string) (Terrain, bool) {
+	for _, item := range t.Terrains {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Terrain{}, false
+}
+
+// WorldTerrain picks the terrain for a world from its trade codes
+func (t *Tables) WorldTerrain(tradeCodes []string) Terrain {
+	for _, terrain := range t.Terrains {
+		for _, code := range terrain.TradeCodes {
+			for _, worldCode := range tradeCodes {
+				if code == worldCode {
+					return terrain
+				}
+			}
+		}
+	}
+	terrain, _ := t.Terrain(t.DefaultTerrain)
+	return terrain
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type installAugmentForm struct {
+	AugmentId string `json:"augmentId" form:"augmentId"`
+}
+
+type augmentEntry struct {
+	model.CharacterAugment
+	Augment equipment.Augment `json:"augment"`
+}
+
+// characterStats loads everything that changes the characteristics and skills of the character and stacks it
+func (h *Handler) characterStats(character *model.Character) (*equipment.Stats, error) {
+	augments, err := model.GetCharacterAugments(h.db, character.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := model.GetCharacterInventory(h.db, character.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := h.equipment.Stats(*character, augments, items)
+	return &stats, nil
+}
+
+func (h *Handler) ListAugments(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	augments, err := model.GetCharacterAugments(h.db, character.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load augments")
+	}
+
+	entries := []augmentEntry{}
+	for _, installed := range augments {
+		augment, _ := h.equipment.Augment(installed.AugmentId)
+		entries = append(entries, augmentEntry{installed, augment})
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (h *Handler) InstallAugment(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	var formData installAugmentForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if _, ok := h.equipment.Augment(formData.AugmentId); !ok {
+		return c.String(http.StatusBadRequest, "unknown augment")
+	}
+
+	augments, err := model.GetCharacterAugments(h.db, character.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load augments")
+	}
+	for _, installed := range augments {
+		if installed.AugmentId == formData.AugmentId {
+			return c.String(http.StatusConflict, "augment is already installed")
+		}
+	}
+
+	augment := model.CharacterAugment{
+		Id:          cuid.New(),
+		CharacterId: character.Id,
+		AugmentId:   formData.AugmentId,
+		InstalledAt: time.Now().UTC(),
+	}
+	if err := augment.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to install augment")
+	}
+
+	return c.JSON(http.StatusCreated, augment)
+}
+
+func (h *Handler) RemoveAugment(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	var augment model.CharacterAugment
+	if err := augment.GetCharacterAugment(h.db, c.Param("augmentId")); err != nil || augment.CharacterId != character.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load augment")
+		}
+		return c.String(http.StatusNotFound, "No augment found")
+	}
+
+	if err := augment.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to remove augment")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetCharacterStats returns the base and effective characteristics and skills of the character
+// with the augments, injuries and equipment that contribute to them.
+func (h *Handler) GetCharacterStats(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	stats, err := h.characterStats(character)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load character stats")
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type calendarDateForm struct {
+	Date model.ImperialDate `json:"date" form:"date"`
+}
+
+type calendarAdvanceForm struct {
+	Days  int `json:"days" form:"days"`
+	Weeks int `json:"weeks" form:"weeks"`
+	Jumps int `json:"jumps" form:"jumps"`
+}
+
+type scheduledEventForm struct {
+	Date        model.ImperialDate       `json:"date" form:"date"`
+	Kind        model.ScheduledEventKind `json:"kind" form:"kind"`
+	Title       string                   `json:"title" form:"title"`
+	Description string                   `json:"description" form:"description"`
+	UserId      string                   `json:"userId" form:"userId"`
+	RepeatDays  int                      `json:"repeatDays" form:"repeatDays"`
+}
+
+// visibleEvents filters out the events that are about other players, the admin sees everything
+func visibleEvents(item *model.Session, userId string, events []model.ScheduledEvent) []model.ScheduledEvent {
+	if item.Admin == userId {
+		return events
+	}
+	return slices.DeleteFunc(events, func(event model.ScheduledEvent) bool {
+		return event.UserId != "" && event.UserId != userId
+	})
+}
+
+// firedSummary lists the shared events that came due for the journal, events about a single player are left out
+func firedSummary(fired []model.ScheduledEvent) string {
+	lines := []string{}
+	for _, event := range fired {
+		if event.UserId == "" {
+			lines = append(lines, event.Date.String()+" "+event.Title)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// publishCalendar sends the new date to every client of the session, events about a
+// single player only go to that player and the admin.
+func (h *Handler) publishCalendar(item *model.Session, date model.ImperialDate, fired []model.ScheduledEvent) {
+	shared := []model.ScheduledEvent{}
+	targeted := map[string][]model.ScheduledEvent{}
+	for _, event := range fired {
+		if event.UserId == "" {
+			shared = append(shared, event)
+			continue
+		}
+		targeted[event.UserId] = append(targeted[event.UserId], event)
+	}
+
+	msg := socket.NewCalendarMessage(item.Id, date, shared)
+	if err := h.hub.Publish(item.Id, &msg); err != nil {
+		log.Println(err)
+	}
+
+	admin := []model.ScheduledEvent{}
+	for userId, events := range targeted {
+		if userId == item.Admin {
+			admin = append(admin, events...)
+			continue
+		}
+		msg := socket.NewCalendarMessage(item.Id, date, events)
+		if err := h.hub.PublishUser(item.Id, userId, &msg); err != nil {
+			log.Println(err)
+		}
+		admin = append(admin, events...)
+	}
+
+	if len(admin) > 0 {
+		msg := socket.NewCalendarMessage(item.Id, date, admin)
+		if err := h.hub.PublishUser(item.Id, item.Admin, &msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// GetCalendar returns the current date of the session and the events still to come
+func (h *Handler) GetCalendar(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load calendar")
+	}
+
+	events, err := model.GetScheduledEvents(h.db, item.Id, false)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load calendar")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"date":   date,
+		"events": visibleEvents(item, user, events),
+	})
+}
+
+// SessionDate renders the current date of the session for the header of the session view
+func (h *Handler) SessionDate(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load date")
+	}
+
+	return c.String(http.StatusOK, date.String())
+}
+
+func (h *Handler) SetCalendarDate(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData calendarDateForm
+	if err := c.Bind(&formData); err != nil {
+		if errors.Is(err, model.ErrInvalidImperialDate) {
+			return c.String(http.StatusBadRequest, "invalid date, dates are written as DDD-YYYY")
+		}
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if !formData.Date.Valid() {
+		return c.String(http.StatusBadRequest, "date is required")
+	}
+
+	fired, err := model.SetSessionDate(h.db, item.Id, formData.Date)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to set date")
+	}
+
+	h.publishCalendar(item, formData.Date, fired)
+	h.journal(item, user, "", model.JournalKind_Date, "The date is now "+formData.Date.String(), firedSummary(fired), formData)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"date":   formData.Date,
+		"events": fired,
+	})
+}
+
+// AdvanceCalendar moves the session forward by a number of days, weeks and jumps
+func (h *Handler) AdvanceCalendar(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData calendarAdvanceForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	days := formData.Days + formData.Weeks*model.DaysPerWeek + formData.Jumps*model.DaysPerJump
+	if formData.Days < 0 || formData.Weeks < 0 || formData.Jumps < 0 || days == 0 {
+		return c.String(http.StatusBadRequest, "time can only be advanced forward")
+	}
+
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load date")
+	}
+	date = date.AddDays(days)
+
+	fired, err := model.SetSessionDate(h.db, item.Id, date)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to advance date")
+	}
+
+	h.publishCalendar(item, date, fired)
+
+	kind, title := model.JournalKind_Date, fmt.Sprintf("%d days passed, the date is now %s", days, date)
+	if formData.Jumps > 0 {
+		kind, title = model.JournalKind_Jump, fmt.Sprintf("%d jumps made, arriving on %s", formData.Jumps, date)
+		if formData.Jumps == 1 {
+			title = "Jumped, arriving on " + date.String()
+		}
+	}
+	h.journal(item, user, "", kind, title, firedSummary(fired), formData)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"date":   date,
+		"events": fired,
+	})
+}
+
+func (h *Handler) ListScheduledEvents(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	events, err := model.GetScheduledEvents(h.db, item.Id, c.QueryParam("fired") == "true")
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load events")
+	}
+
+	return c.JSON(http.StatusOK, visibleEvents(item, user, events))
+}
+
+func (h *Handler) CreateScheduledEvent(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData scheduledEventForm
+	if err := c.Bind(&formData); err != nil {
+		if errors.Is(err, model.ErrInvalidImperialDate) {
+			return c.String(http.StatusBadRequest, "invalid date, dates are written as DDD-YYYY")
+		}
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Title == "" {
+		return c.String(http.StatusBadRequest, "event title is required")
+	}
+
+	if formData.Kind == "" {
+		formData.Kind = model.ScheduledEventKind_Custom
+	}
+	if !formData.Kind.Valid() {
+		return c.String(http.StatusBadRequest, "unknown event kind")
+	}
+
+	if formData.RepeatDays < 0 {
+		return c.String(http.StatusBadRequest, "repeat days can not be negative")
+	}
+
+	if formData.UserId != "" && formData.UserId != item.Admin && !slices.Contains(item.Players, formData.UserId) {
+		return c.String(http.StatusBadRequest, "user is not part of this session")
+	}
+
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load date")
+	}
+
+	if !formData.Date.Valid() {
+		return c.String(http.StatusBadRequest, "event date is required")
+	}
+	if formData.Date.Days() <= date.Days() {
+		return c.String(http.StatusBadRequest, "events must be scheduled after the current date")
+	}
+
+	event := model.ScheduledEvent{
+		Id:          cuid.New(),
+		SessionId:   item.Id,
+		Date:        formData.Date,
+		Kind:        formData.Kind,
+		Title:       formData.Title,
+		Description: formData.Description,
+		UserId:      formData.UserId,
+		RepeatDays:  formData.RepeatDays,
+	}
+	if err := event.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create event")
+	}
+
+	return c.JSON(http.StatusCreated, event)
+}
+
+func (h *Handler) DeleteScheduledEvent(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var event model.ScheduledEvent
+	if err := event.GetScheduledEvent(h.db, c.Param("eventId")); err != nil || event.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+		}
+		return c.String(http.StatusNotFound, "No event found")
+	}
+
+	if err := event.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete event")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+// skills above this are beyond what the character generation rules allow
+const maxSkillLevel = 6
+
+// unskilled is the DM for a check using a skill the character does not have
+const unskilled = -3
+
+type characterForm struct {
+	Name            string                 `json:"name" form:"name"`
+	Characteristics *model.Characteristics `json:"characteristics" form:"characteristics"`
+	Skills          map[string]int         `json:"skills" form:"skills"`
+	// damage taken to each characteristic
+	Injuries *model.Characteristics `json:"injuries" form:"injuries"`
+	// the admin can create characters for players
+	Owner string `json:"owner" form:"owner"`
+}
+
+type characterCheckForm struct {
+	Characteristic string `json:"characteristic" form:"characteristic"`
+	Skill          string `json:"skill" form:"skill"`
+	DM             int    `json:"dm" form:"dm"`
+	// defaults to an average check of 8+
+	Target int `json:"target" form:"target"`
+}
+
+type checkModifier struct {
+	Source string `json:"source"`
+	Value  int    `json:"value"`
+}
+
+// apply copies the set fields of the form onto the character
+func (f *characterForm) apply(character *model.Character) error {
+	if f.Name != "" {
+		character.Name = f.Name
+	}
+
+	if f.Characteristics != nil {
+		for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END, model.Characteristic_INT, model.Characteristic_EDU, model.Characteristic_SOC, model.Characteristic_PSI} {
+			if value := f.Characteristics.Get(code); value < 0 || value > 15 {
+				return fmt.Errorf("%s must be between 0 and 15", code)
+			}
+		}
+		character.Characteristics = *f.Characteristics
+	}
+
+	if f.Skills != nil {
+		for skill, level := range f.Skills {
+			if level < 0 || level > maxSkillLevel {
+				return fmt.Errorf("skill '%s' must be between 0 and %d", skill, maxSkillLevel)
+			}
+		}
+		character.Skills = f.Skills
+	}
+
+	if f.Injuries != nil {
+		for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END, model.Characteristic_INT, model.Characteristic_EDU, model.Characteristic_SOC, model.Characteristic_PSI} {
+			if f.Injuries.Get(code) < 0 {
+				return fmt.Errorf("injuries to %s can not be negative", code)
+			}
+		}
+		character.Injuries = *f.Injuries
+	}
+
+	return nil
+}
+
+// sessionCharacter loads the character named by the characterId path param and checks it belongs to the session
+func (h *Handler) sessionCharacter(c echo.Context, sessionId string) (*model.Character, error) {
+	var character model.Character
+	err := character.GetCharacter(h.db, c.Param("characterId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No character found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character")
+	}
+
+	if character.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No character found")
+	}
+
+	return &character, nil
+}
+
+// ownCharacter is sessionCharacter for end points only the owner of the character or the admin may use
+func (h *Handler) ownCharacter(c echo.Context) (*model.Session, *model.Character, error) {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if character.Owner != user && item.Admin != user {
+		return nil, nil, echo.NewHTTPError(http.StatusForbidden, "Only the owner of the character can do this")
+	}
+
+	return item, character, nil
+}
+
+func (h *Handler) ListCharacters(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	characters, err := model.GetSessionCharacters(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load characters")
+	}
+
+	return c.JSON(http.StatusOK, characters)
+}
+
+func (h *Handler) GetCharacter(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, character)
+}
+
+func (h *Handler) CreateCharacter(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	var formData characterForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "character name is required")
+	}
+
+	owner := user
+	if formData.Owner != "" && formData.Owner != user {
+		if item.Admin != user {
+			return c.String(http.StatusForbidden, "Only the session admin can create characters for other players")
+		}
+		if !slices.Contains(item.Players, formData.Owner) {
+			return c.String(http.StatusBadRequest, "owner is not part of this session")
+		}
+		owner = formData.Owner
+	}
+
+	character := model.Character{Id: cuid.New(), Owner: owner, SessionId: item.Id, Skills: map[string]int{}}
+	if err := formData.apply(&character); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := character.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create character")
+	}
+
+	return c.JSON(http.StatusCreated, character)
+}
+
+func (h *Handler) UpdateCharacter(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	var formData characterForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if err := formData.apply(character); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := character.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update character")
+	}
+
+	return c.JSON(http.StatusOK, character)
+}
+
+func (h *Handler) DeleteCharacter(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	if err := character.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete character")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RollCheck rolls 2D for a character, adding the DMs of the effective characteristic and skill
+// and the encumbrance of what they carry when the check is a physical one.
+func (h *Handler) RollCheck(c echo.Context) error {
+	item, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	var formData characterCheckForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Characteristic != "" && !model.ValidCharacteristic(formData.Characteristic) {
+		return c.String(http.StatusBadRequest, "unknown characteristic")
+	}
+	if formData.Target == 0 {
+		formData.Target = 8
+	}
+
+	stats, err := h.characterStats(character)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load character stats")
+	}
+
+	modifiers := []checkModifier{}
+	if formData.Characteristic != "" {
+		stat := stats.Characteristics[formData.Characteristic]
+		modifiers = append(modifiers, checkModifier{formData.Characteristic, model.CharacteristicDM(stat.Effective)})
+	}
+	if formData.Skill != "" {
+		level := unskilled
+		if stat, ok := stats.Skills[formData.Skill]; ok {
+			level = stat.Effective
+		}
+		modifiers = append(modifiers, checkModifier{formData.Skill, level})
+	}
+	if model.PhysicalCharacteristic(formData.Characteristic) && stats.Encumbrance.DM != 0 {
+		modifiers = append(modifiers, checkModifier{stats.Encumbrance.Level, stats.Encumbrance.DM})
+	}
+	if formData.DM != 0 {
+		modifiers = append(modifiers, checkModifier{"dm", formData.DM})
+	}
+
+	dm := 0
+	for _, modifier := range modifiers {
+		dm += modifier.Value
+	}
+	total, success := h.dice.Check(formData.Target, dm)
+
+	result := map[string]interface{}{
+		"roll":      total - dm,
+		"total":     total,
+		"target":    formData.Target,
+		"effect":    total - formData.Target,
+		"success":   success,
+		"modifiers": modifiers,
+	}
+
+	check := strings.TrimSpace(formData.Characteristic + " " + formData.Skill)
+	if check == "" {
+		check = "2D"
+	}
+	outcome := "failed"
+	if success {
+		outcome = "succeeded"
+	}
+	h.journal(item, character.Owner, character.Id, model.JournalKind_Roll,
+		fmt.Sprintf("%s rolled %s %d+ and %s with %d", character.Name, check, formData.Target, outcome, total), "", result)
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,530 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"visualsource/traveller/internal/combat"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type encounterForm struct {
+	Name string `json:"name" form:"name"`
+}
+
+// combatantForm adds a character of the session, or an NPC when no character is given
+type combatantForm struct {
+	CharacterId string `json:"characterId" form:"characterId"`
+	// inventory item of the character to fight with, the first equipped weapon when empty
+	InventoryId     string                `json:"inventoryId" form:"inventoryId"`
+	Name            string                `json:"name" form:"name"`
+	Characteristics model.Characteristics `json:"characteristics" form:"characteristics"`
+	Skills          map[string]int        `json:"skills" form:"skills"`
+	WeaponId        string                `json:"weaponId" form:"weaponId"`
+	Protection      int                   `json:"protection" form:"protection"`
+}
+
+type attackForm struct {
+	AttackerId string `json:"attackerId" form:"attackerId"`
+	TargetId   string `json:"targetId" form:"targetId"`
+	DM         int    `json:"dm" form:"dm"`
+}
+
+type damageForm struct {
+	CombatantId string `json:"combatantId" form:"combatantId"`
+	Damage      int    `json:"damage" form:"damage"`
+}
+
+// sessionEncounter loads the encounter named by the encounterId path param and checks it belongs to the session
+func (h *Handler) sessionEncounter(c echo.Context, sessionId string) (*model.Encounter, error) {
+	var encounter model.Encounter
+	err := encounter.GetEncounter(h.db, c.Param("encounterId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No encounter found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load encounter")
+	}
+
+	if encounter.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No encounter found")
+	}
+
+	return &encounter, nil
+}
+
+// saveEncounter stores the encounter and sends its new state to everyone in the session
+func (h *Handler) saveEncounter(c echo.Context, encounter *model.Encounter) error {
+	if err := encounter.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update encounter")
+	}
+
+	msg := socket.NewCombatMessage(encounter.SessionId, *encounter)
+	if err := h.hub.Publish(encounter.SessionId, &msg); err != nil {
+		log.Println(err)
+	}
+
+	return c.JSON(http.StatusOK, encounter)
+}
+
+// recordInjuries adds the damage a character took in the encounter to their character sheet
+func (h *Handler) recordInjuries(combatant *model.Combatant, before model.Characteristics) error {
+	if combatant.CharacterId == "" {
+		return nil
+	}
+
+	var character model.Character
+	if err := character.GetCharacter(h.db, combatant.CharacterId); err != nil {
+		// the character was deleted mid fight
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END} {
+		character.Injuries.Add(code, combatant.Damage.Get(code)-before.Get(code))
+	}
+	return character.Update(h.db)
+}
+
+// characterCombatant copies a character into a combatant with their effective stats, armour and weapon
+func (h *Handler) characterCombatant(character *model.Character, inventoryId string) (*model.Combatant, error) {
+	stats, err := h.characterStats(character)
+	if err != nil {
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character stats")
+	}
+
+	items, err := model.GetCharacterInventory(h.db, character.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load inventory")
+	}
+
+	combatant := model.Combatant{
+		Id:          cuid.New(),
+		CharacterId: character.Id,
+		Name:        character.Name,
+		Skills:      map[string]int{},
+		Protection:  stats.Protection,
+		Encumbrance: stats.Encumbrance.DM,
+		Status:      model.CombatantStatus_Active,
+	}
+	for code, stat := range stats.Characteristics {
+		combatant.Characteristics.Add(code, stat.Effective)
+	}
+	for skill, stat := range stats.Skills {
+		combatant.Skills[skill] = stat.Effective
+	}
+
+	for _, item := range items {
+		if _, ok := h.equipment.Weapon(item.ItemId); !ok {
+			continue
+		}
+		if item.Id == inventoryId || (inventoryId == "" && item.Equipped) {
+			combatant.WeaponId = item.ItemId
+			break
+		}
+	}
+	if inventoryId != "" && combatant.WeaponId == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "the character does not carry that weapon")
+	}
+
+	return &combatant, nil
+}
+
+func (h *Handler) ListEncounters(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounters, err := model.GetSessionEncounters(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load encounters")
+	}
+
+	return c.JSON(http.StatusOK, encounters)
+}
+
+func (h *Handler) GetEncounter(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, encounter)
+}
+
+func (h *Handler) CreateEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData encounterForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "encounter name is required")
+	}
+
+	encounter := model.Encounter{
+		Id:         cuid.New(),
+		SessionId:  item.Id,
+		Name:       formData.Name,
+		Status:     model.EncounterStatus_Setup,
+		Combatants: []model.Combatant{},
+		Log:        []model.CombatLogEntry{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := encounter.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create encounter")
+	}
+
+	return c.JSON(http.StatusCreated, encounter)
+}
+
+func (h *Handler) DeleteEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := encounter.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete encounter")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddCombatant puts a character or NPC into the encounter. Players can bring in their own characters,
+// only the referee can add NPCs or other players' characters.
+func (h *Handler) AddCombatant(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status == model.EncounterStatus_Ended {
+		return c.String(http.StatusConflict, "encounter has ended")
+	}
+
+	var formData combatantForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	var combatant *model.Combatant
+	if formData.CharacterId != "" {
+		for _, existing := range encounter.Combatants {
+			if existing.CharacterId == formData.CharacterId {
+				return c.String(http.StatusConflict, "character is already in the encounter")
+			}
+		}
+
+		var character model.Character
+		if err := character.GetCharacter(h.db, formData.CharacterId); err != nil || character.SessionId != item.Id {
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Println(err)
+				return c.String(http.StatusInternalServerError, "Failed to load character")
+			}
+			return c.String(http.StatusNotFound, "No character found")
+		}
+		if character.Owner != user && item.Admin != user {
+			return c.String(http.StatusForbidden, "Only the owner of the character can do this")
+		}
+
+		if combatant, err = h.characterCombatant(&character, formData.InventoryId); err != nil {
+			return err
+		}
+	} else {
+		if item.Admin != user {
+			return c.String(http.StatusForbidden, "Only the session admin can add NPCs")
+		}
+		if formData.Name == "" {
+			return c.String(http.StatusBadRequest, "NPC name is required")
+		}
+		if formData.WeaponId != "" {
+			if _, ok := h.equipment.Weapon(formData.WeaponId); !ok {
+				return c.String(http.StatusBadRequest, "unknown weapon")
+			}
+		}
+		if formData.Skills == nil {
+			formData.Skills = map[string]int{}
+		}
+
+		combatant = &model.Combatant{
+			Id:              cuid.New(),
+			Name:            formData.Name,
+			Characteristics: formData.Characteristics,
+			Skills:          formData.Skills,
+			WeaponId:        formData.WeaponId,
+			Protection:      max(formData.Protection, 0),
+			Status:          model.CombatantStatus_Active,
+		}
+	}
+
+	combat.Join(h.dice, encounter, *combatant)
+	encounter.Logf("%s joins the fight", combatant.Name)
+
+	return h.saveEncounter(c, encounter)
+}
+
+func (h *Handler) RemoveCombatant(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	combatant := encounter.Combatant(c.Param("combatantId"))
+	if combatant == nil {
+		return c.String(http.StatusNotFound, "No combatant found")
+	}
+
+	encounter.Logf("%s leaves the fight", combatant.Name)
+	combat.Remove(encounter, combatant.Id)
+
+	return h.saveEncounter(c, encounter)
+}
+
+// StartEncounter rolls initiative for everyone and begins the first round
+func (h *Handler) StartEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status != model.EncounterStatus_Setup {
+		return c.String(http.StatusConflict, "encounter has already started")
+	}
+	if len(encounter.Combatants) == 0 {
+		return c.String(http.StatusBadRequest, "encounter has no combatants")
+	}
+
+	combat.Start(h.dice, encounter)
+	for _, combatant := range encounter.Combatants {
+		encounter.Logf("%s rolls %d for initiative", combatant.Name, combatant.Initiative)
+	}
+
+	return h.saveEncounter(c, encounter)
+}
+
+// NextTurn ends the turn of the acting combatant, the referee or the player of the acting character can pass it on
+func (h *Handler) NextTurn(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status != model.EncounterStatus_Active {
+		return c.String(http.StatusConflict, "encounter is not running")
+	}
+	// the referee can remove everyone from a running encounter
+	if len(encounter.Combatants) == 0 {
+		return c.String(http.StatusConflict, "encounter has no combatants left")
+	}
+	if err := h.actingPlayer(item, user, &encounter.Combatants[encounter.Turn]); err != nil {
+		return err
+	}
+
+	round := encounter.Round
+	combat.Next(encounter)
+	if encounter.Round != round {
+		encounter.Logf("Round %d begins", encounter.Round)
+	}
+
+	return h.saveEncounter(c, encounter)
+}
+
+// actingPlayer checks the user is the referee or plays the character of the combatant
+func (h *Handler) actingPlayer(item *model.Session, user string, combatant *model.Combatant) error {
+	if item.Admin == user {
+		return nil
+	}
+	if combatant.CharacterId == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the session admin can act for NPCs")
+	}
+
+	var character model.Character
+	if err := character.GetCharacter(h.db, combatant.CharacterId); err != nil || character.Owner != user {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character")
+		}
+		return echo.NewHTTPError(http.StatusForbidden, "It is not your turn")
+	}
+
+	return nil
+}
+
+// Attack resolves an attack by the acting combatant, the referee can have anyone attack out of turn
+func (h *Handler) Attack(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status != model.EncounterStatus_Active {
+		return c.String(http.StatusConflict, "encounter is not running")
+	}
+
+	var formData attackForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	attacker, target := encounter.Combatant(formData.AttackerId), encounter.Combatant(formData.TargetId)
+	if attacker == nil || target == nil {
+		return c.String(http.StatusNotFound, "No combatant found")
+	}
+	if attacker.Status != model.CombatantStatus_Active {
+		return c.String(http.StatusConflict, "attacker can not act")
+	}
+	if item.Admin != user && attacker.Id != encounter.Combatants[encounter.Turn].Id {
+		return c.String(http.StatusForbidden, "It is not your turn")
+	}
+	if target.Status == model.CombatantStatus_Dead {
+		return c.String(http.StatusConflict, "target is already dead")
+	}
+	if err := h.actingPlayer(item, user, attacker); err != nil {
+		return err
+	}
+
+	before, status := target.Damage, target.Status
+	attack := combat.ResolveAttack(h.dice, h.equipment, attacker, target, formData.DM)
+	encounter.Logf("%s", attack.Describe(attacker.Name, target.Name))
+	if target.Status != status {
+		encounter.Logf("%s is %s", target.Name, target.Status)
+	}
+
+	if err := h.recordInjuries(target, before); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to record injuries")
+	}
+
+	return h.saveEncounter(c, encounter)
+}
+
+// DamageCombatant lets the referee apply damage from falls, fire and the like
+func (h *Handler) DamageCombatant(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData damageForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	combatant := encounter.Combatant(formData.CombatantId)
+	if combatant == nil {
+		return c.String(http.StatusNotFound, "No combatant found")
+	}
+	if formData.Damage <= 0 {
+		return c.String(http.StatusBadRequest, "damage must be positive")
+	}
+
+	before, status := combatant.Damage, combatant.Status
+	combat.ApplyDamage(combatant, formData.Damage)
+	encounter.Logf("%s takes %d damage", combatant.Name, formData.Damage)
+	if combatant.Status != status {
+		encounter.Logf("%s is %s", combatant.Name, combatant.Status)
+	}
+
+	if err := h.recordInjuries(combatant, before); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to record injuries")
+	}
+
+	return h.saveEncounter(c, encounter)
+}
+
+func (h *Handler) EndEncounter(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status == model.EncounterStatus_Ended {
+		return c.String(http.StatusConflict, "encounter has already ended")
+	}
+
+	encounter.Status = model.EncounterStatus_Ended
+	encounter.Logf("The fight is over")
+
+	// the encounter is only journaled once it has been saved
+	if err := h.saveEncounter(c, encounter); err != nil || c.Response().Status != http.StatusOK {
+		return err
+	}
+
+	lines := []string{}
+	for _, combatant := range encounter.Combatants {
+		lines = append(lines, fmt.Sprintf("%s: %s", combatant.Name, combatant.Status))
+	}
+	h.journal(item, user, "", model.JournalKind_Combat, fmt.Sprintf("%s ended after %d rounds", encounter.Name, encounter.Round), strings.Join(lines, "\n"), encounter.Combatants)
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+	"visualsource/traveller/internal/trade"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type contractGenerateForm struct {
+	SourceId      string `json:"sourceId" form:"sourceId"`
+	DestinationId string `json:"destinationId" form:"destinationId"`
+	// worked out from the hexes when both worlds are in the same sector
+	Parsecs int `json:"parsecs" form:"parsecs"`
+	// characters looking for freight and for passengers, their Broker and Steward skills are used
+	BrokerId  string `json:"brokerId" form:"brokerId"`
+	StewardId string `json:"stewardId" form:"stewardId"`
+	// replace the skills of the characters
+	Broker  *int `json:"broker" form:"broker"`
+	Steward *int `json:"steward" form:"steward"`
+}
+
+type contractPublishForm struct {
+	// contracts to publish, every offered contract when empty
+	ContractIds []string `json:"contractIds" form:"contractIds"`
+}
+
+type contractAcceptForm struct {
+	ShipId string `json:"shipId" form:"shipId"`
+}
+
+// sessionContract loads the contract named by the contractId path param and checks it belongs to the session
+func (h *Handler) sessionContract(c echo.Context, sessionId string) (*model.Contract, error) {
+	var contract model.Contract
+	err := contract.GetContract(h.db, c.Param("contractId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No contract found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load contract")
+	}
+
+	if contract.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No contract found")
+	}
+
+	return &contract, nil
+}
+
+// publishContracts lets the players know contracts are up for grabs or changed status
+func (h *Handler) publishContracts(sessionId string, contracts []model.Contract) {
+	msg := socket.NewContractsMessage(sessionId, contracts)
+	if err := h.hub.Publish(sessionId, &msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// ListContracts returns the freight, mail and passengers of the session, the referee also sees the offered ones
+func (h *Handler) ListContracts(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	contracts, err := model.GetSessionContracts(h.db, item.Id, item.Admin == user)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load contracts")
+	}
+
+	if status := model.ContractStatus(c.QueryParam("status")); status != "" {
+		contracts = slices.DeleteFunc(contracts, func(contract model.Contract) bool {
+			return contract.Status != status
+		})
+	}
+
+	return c.JSON(http.StatusOK, contracts)
+}
+
+// GenerateContracts rolls the freight, mail and passengers on a world bound for another.
+// The contracts are only seen by the referee until they are published.
+func (h *Handler) GenerateContracts(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData contractGenerateForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.SourceId == "" || formData.DestinationId == "" || formData.SourceId == formData.DestinationId {
+		return c.String(http.StatusBadRequest, "a source and a different destination world are required")
+	}
+
+	// without a crew member the rolls are made before any skill
+	broker, steward := 0, 0
+	if formData.Broker != nil || formData.BrokerId != "" {
+		if broker, err = h.traderSkill(item, user, formData.BrokerId, brokerSkill, formData.Broker); err != nil {
+			return err
+		}
+	}
+	if formData.Steward != nil || formData.StewardId != "" {
+		if steward, err = h.traderSkill(item, user, formData.StewardId, stewardSkill, formData.Steward); err != nil {
+			return err
+		}
+	}
+
+	source, err := h.tradeWorld(item.Id, formData.SourceId)
+	if err != nil {
+		return err
+	}
+	destination, err := h.tradeWorld(item.Id, formData.DestinationId)
+	if err != nil {
+		return err
+	}
+
+	parsecs := formData.Parsecs
+	if parsecs == 0 && source.SectorId != "" && source.SectorId == destination.SectorId {
+		if parsecs, err = model.HexDistance(source.Hex, destination.Hex); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if parsecs <= 0 {
+		return c.String(http.StatusBadRequest, "parsecs between the worlds is required")
+	}
+
+	contracts := h.trade.Contracts(h.dice, trade.Traffic{
+		Source:      *source,
+		Destination: *destination,
+		Parsecs:     parsecs,
+		Broker:      broker,
+		Steward:     steward,
+	})
+	for i := range contracts {
+		contracts[i].Id = cuid.New()
+		contracts[i].SessionId = item.Id
+		contracts[i].Status = model.ContractStatus_Offered
+	}
+
+	if err := model.InsertContracts(h.db, contracts); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create contracts")
+	}
+
+	return c.JSON(http.StatusCreated, contracts)
+}
+
+// PublishContracts shows offered contracts to the players
+func (h *Handler) PublishContracts(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData contractPublishForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	contracts, err := model.GetSessionContracts(h.db, item.Id, true)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load contracts")
+	}
+
+	published := []model.Contract{}
+	for _, contract := range contracts {
+		if contract.Status != model.ContractStatus_Offered {
+			continue
+		}
+		if len(formData.ContractIds) > 0 && !slices.Contains(formData.ContractIds, contract.Id) {
+			continue
+		}
+		if err := contract.Publish(h.db); err != nil {
+			if errors.Is(err, model.ErrContractStatus) {
+				continue
+			}
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to publish contracts")
+		}
+		published = append(published, contract)
+	}
+
+	if len(published) > 0 {
+		h.publishContracts(item.Id, published)
+	}
+
+	return c.JSON(http.StatusOK, published)
+}
+
+// AcceptContract signs a published contract to a ship with the space to carry it
+func (h *Handler) AcceptContract(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	contract, err := h.sessionContract(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData contractAcceptForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	var ship model.Ship
+	if err := ship.GetShip(h.db, formData.ShipId); err != nil || ship.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+		}
+		return c.String(http.StatusNotFound, "No ship found")
+	}
+
+	if contract.Kind == model.ContractKind_Passenger {
+		passengers, err := model.ShipPassengers(h.db, ship.Id)
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load passengers")
+		}
+		if contract.Class == model.ContractClass_Low {
+			if free := ship.Design.Staterooms.LowBerths - passengers[model.ContractClass_Low]; contract.Passengers > free {
+				return c.String(http.StatusBadRequest, fmt.Sprintf("only %d low berths free", max(free, 0)))
+			}
+		} else {
+			used := passengers[model.ContractClass_High] + passengers[model.ContractClass_Middle] + passengers[model.ContractClass_Basic]
+			if free := ship.Design.Staterooms.Standard - used; contract.Passengers > free {
+				return c.String(http.StatusBadRequest, fmt.Sprintf("only %d staterooms free", max(free, 0)))
+			}
+		}
+	} else {
+		used, err := model.CargoTons(h.db, ship.Id)
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load cargo")
+		}
+		if float64(used+contract.Tons) > ship.Design.CargoTons {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("only %g tons of cargo space left", ship.Design.CargoTons-float64(used)))
+		}
+	}
+
+	if err := contract.Accept(h.db, ship.Id); err != nil {
+		if errors.Is(err, model.ErrContractStatus) {
+			return c.String(http.StatusConflict, "contract is not open to accept")
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to accept contract")
+	}
+
+	h.publishContracts(item.Id, []model.Contract{*contract})
+
+	return c.JSON(http.StatusOK, contract)
+}
+
+// DeliverContract completes an accepted contract and pays the ship that carried it
+func (h *Handler) DeliverContract(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	contract, err := h.sessionContract(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var destination model.World
+	if err := destination.GetWorld(h.db, contract.DestinationId); err != nil {
+		// the world may have been removed since, the payment still stands
+		destination.Name = "destination"
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+		}
+	}
+
+	category := model.ChargeCategory_Freight
+	description := fmt.Sprintf("Delivered %d tons of %s to %s", contract.Tons, contract.Kind, destination.Name)
+	if contract.Kind == model.ContractKind_Passenger {
+		category = model.ChargeCategory_Passage
+		description = fmt.Sprintf("Delivered %d %s passengers to %s", contract.Passengers, contract.Class, destination.Name)
+	}
+
+	transaction, err := contract.Deliver(h.db, category, description)
+	if err != nil {
+		if errors.Is(err, model.ErrContractStatus) {
+			return c.String(http.StatusConflict, "only accepted contracts can be delivered")
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to deliver contract")
+	}
+
+	h.publishContracts(item.Id, []model.Contract{*contract})
+	h.publishFinance(item.Id, []model.Transaction{transaction})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"contract":    contract,
+		"transaction": transaction,
+	})
+}
+
+// CancelContract withdraws a contract, freeing the space it took up on a ship
+func (h *Handler) CancelContract(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	contract, err := h.sessionContract(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	offered := contract.Status == model.ContractStatus_Offered
+	if err := contract.Cancel(h.db); err != nil {
+		if errors.Is(err, model.ErrContractStatus) {
+			return c.String(http.StatusConflict, "contract is already closed")
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to cancel contract")
+	}
+
+	// the players never saw an offered contract so there is nothing to tell them
+	if !offered {
+		h.publishContracts(item.Id, []model.Contract{*contract})
+	}
+
+	return c.JSON(http.StatusOK, contract)
+}
+
+func (h *Handler) DeleteContract(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	contract, err := h.sessionContract(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if contract.Status == model.ContractStatus_Accepted {
+		return c.String(http.StatusConflict, "cancel the contract before deleting it")
+	}
+
+	if err := contract.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete contract")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
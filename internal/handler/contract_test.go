@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/trade"
+)
+
+func TestGenerateContractsUsesCrewSkills(t *testing.T) {
+	h := newTestHandler(t)
+	testSession(t, h, "session", "referee", "alice")
+	testCharacter(t, h, "trader", "session", "alice", map[string]int{brokerSkill: 2, stewardSkill: 1})
+	testCharacter(t, h, "pilot", "session", "alice", map[string]int{"pilot": 1})
+
+	var worlds []model.World
+	for _, id := range []string{"regina", "efate"} {
+		world := model.World{Id: id, SessionId: "session", Name: id, UWP: model.UWP{Starport: "A", Size: 7, Atmosphere: 8, Hydrographics: 8, Population: 8, Government: 9, LawLevel: 9, TechLevel: 12}, Bases: []string{}}
+		if err := world.Insert(h.db); err != nil {
+			t.Fatal(err)
+		}
+		worlds = append(worlds, world)
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		crew    string
+		code    int
+		broker  int
+		steward int
+	}{
+		{"no crew", "referee", ``, http.StatusCreated, 0, 0},
+		{"trained crew", "referee", `"brokerId":"trader","stewardId":"trader",`, http.StatusCreated, 2, 1},
+		{"untrained crew", "referee", `"brokerId":"pilot","stewardId":"pilot",`, http.StatusCreated, -3, -3},
+		{"one of each", "referee", `"brokerId":"trader","stewardId":"pilot",`, http.StatusCreated, 2, -3},
+		{"referee override", "referee", `"brokerId":"pilot","broker":4,"steward":3,`, http.StatusCreated, 4, 3},
+		{"override out of range", "referee", `"steward":7,`, http.StatusBadRequest, 0, 0},
+		{"unknown character", "referee", `"brokerId":"nobody",`, http.StatusNotFound, 0, 0},
+		{"player", "alice", `"brokerId":"trader",`, http.StatusForbidden, 0, 0},
+	}
+
+	for _, test := range tests {
+		body := `{` + test.crew + `"sourceId":"regina","destinationId":"efate","parsecs":2}`
+		h.dice = dice.Seeded(7)
+		rec := serve(t, test.user, http.MethodPost, "/", body, h.GenerateContracts, "sessionId", "session")
+		if rec.Code != test.code {
+			t.Errorf("%s: got %d %s, want %d", test.name, rec.Code, rec.Body.String(), test.code)
+			continue
+		}
+		if rec.Code != http.StatusCreated {
+			continue
+		}
+
+		var contracts []model.Contract
+		if err := json.Unmarshal(rec.Body.Bytes(), &contracts); err != nil {
+			t.Fatal(err)
+		}
+		// only the rolls are compared, not what was filled in when they were saved
+		for i := range contracts {
+			contracts[i] = model.Contract{Kind: contracts[i].Kind, Class: contracts[i].Class, SourceId: contracts[i].SourceId, DestinationId: contracts[i].DestinationId, Parsecs: contracts[i].Parsecs, Tons: contracts[i].Tons, Passengers: contracts[i].Passengers, Payment: contracts[i].Payment}
+		}
+		want := h.trade.Contracts(dice.Seeded(7), trade.Traffic{Source: worlds[0], Destination: worlds[1], Parsecs: 2, Broker: test.broker, Steward: test.steward})
+		if !slices.Equal(contracts, want) {
+			t.Errorf("%s: contracts were not rolled with Broker %d and Steward %d", test.name, test.broker, test.steward)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type eventForm struct {
+	Kind     model.EventKind     `json:"kind" form:"kind"`
+	Severity model.EventSeverity `json:"severity" form:"severity"`
+	Title    string              `json:"title" form:"title"`
+	Body     string              `json:"body" form:"body"`
+	// players to send the event to, everyone when empty
+	Targets []string `json:"targets" form:"targets"`
+	ShipId  string   `json:"shipId" form:"shipId"`
+}
+
+// playerEvent is the event as a player sees it, only their own acknowledgement is kept
+func playerEvent(event model.SessionEvent, userId string) model.SessionEvent {
+	event.Targets = []string{}
+	event.Acks = slices.DeleteFunc(slices.Clone(event.Acks), func(ack model.EventAck) bool { return ack.UserId != userId })
+	return event
+}
+
+// sessionEvent loads the event named by the entryId path param, players only find the events sent to them
+func (h *Handler) sessionEvent(c echo.Context, item *model.Session, user string) (*model.SessionEvent, error) {
+	var entry model.JournalEntry
+	err := entry.GetJournalEntry(h.db, c.Param("entryId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No event found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load event")
+	}
+
+	if entry.SessionId != item.Id || entry.Kind != model.JournalKind_Event || !entry.For(item, user) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No event found")
+	}
+
+	event, err := model.LoadEvent(h.db, entry)
+	if err != nil {
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load event")
+	}
+
+	return &event, nil
+}
+
+// publishEvent sends the event to the players it is for and always to the referee
+func (h *Handler) publishEvent(item *model.Session, event model.SessionEvent) {
+	if len(event.Targets) == 0 {
+		msg := socket.NewEventMessage(item.Id, playerEvent(event, ""))
+		if err := h.hub.Publish(item.Id, &msg); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	for _, userId := range event.Targets {
+		msg := socket.NewEventMessage(item.Id, playerEvent(event, userId))
+		if err := h.hub.PublishUser(item.Id, userId, &msg); err != nil {
+			log.Println(err)
+		}
+	}
+
+	msg := socket.NewEventMessage(item.Id, event)
+	if err := h.hub.PublishUser(item.Id, item.Admin, &msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// ListEvents returns the events of the session, players only get the ones sent to them.
+// ?pending=true leaves out the events the player has acknowledged.
+func (h *Handler) ListEvents(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := model.GetSessionJournal(h.db, item.Id, model.JournalFilter{Kind: model.JournalKind_Event})
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load events")
+	}
+
+	pending := c.QueryParam("pending") == "true"
+	events := []model.SessionEvent{}
+	for _, entry := range entries {
+		if !entry.For(item, user) {
+			continue
+		}
+		event, err := model.LoadEvent(h.db, entry)
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load events")
+		}
+		if item.Admin != user {
+			if pending && event.Acknowledged(user) {
+				continue
+			}
+			event = playerEvent(event, user)
+		}
+		events = append(events, event)
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// SendEvent records an event in the journal and pushes it to the players it targets
+func (h *Handler) SendEvent(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData eventForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if !formData.Kind.Valid() {
+		return c.String(http.StatusBadRequest, "kind must be alert, sensor_contact, ship_damage or news")
+	}
+	if formData.Severity == "" {
+		formData.Severity = model.EventSeverity_Info
+	}
+	if !formData.Severity.Valid() {
+		return c.String(http.StatusBadRequest, "severity must be info, warning or critical")
+	}
+	if formData.Title == "" {
+		return c.String(http.StatusBadRequest, "event title is required")
+	}
+
+	targets := []string{}
+	for _, target := range formData.Targets {
+		if !slices.Contains(item.Players, target) {
+			return c.String(http.StatusBadRequest, "events can only be sent to players of the session")
+		}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	if formData.ShipId != "" {
+		var ship model.Ship
+		if err := ship.GetShip(h.db, formData.ShipId); err != nil || ship.SessionId != item.Id {
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Println(err)
+				return c.String(http.StatusInternalServerError, "Failed to load ship")
+			}
+			return c.String(http.StatusNotFound, "No ship found")
+		}
+	}
+
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load calendar")
+	}
+
+	details := model.Event{Kind: formData.Kind, Severity: formData.Severity, ShipId: formData.ShipId}
+	entry, err := model.NewEventEntry(details)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to send event")
+	}
+	entry.Id = cuid.New()
+	entry.SessionId = item.Id
+	entry.Date = date
+	entry.Author = user
+	entry.Title = formData.Title
+	entry.Body = formData.Body
+	entry.Targets = targets
+	entry.CreatedAt = time.Now().UTC()
+
+	if err := entry.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to send event")
+	}
+
+	event := model.SessionEvent{
+		JournalEntry: entry,
+		Event:        details,
+		Acks:         []model.EventAck{},
+	}
+	h.publishEvent(item, event)
+
+	return c.JSON(http.StatusCreated, event)
+}
+
+// AcknowledgeEvent lets a player tell the referee they have seen an event
+func (h *Handler) AcknowledgeEvent(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	if item.Admin == user {
+		return c.String(http.StatusBadRequest, "only players acknowledge events")
+	}
+
+	event, err := h.sessionEvent(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	added, err := event.Acknowledge(h.db, user)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to acknowledge event")
+	}
+
+	if added {
+		ack := event.Acks[len(event.Acks)-1]
+		msg := socket.NewEventAckMessage(item.Id, ack)
+		if err := h.hub.PublishUser(item.Id, item.Admin, &msg); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, playerEvent(*event, user))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func TestPlayerEvent(t *testing.T) {
+	event := model.SessionEvent{
+		JournalEntry: model.JournalEntry{Id: "entry", Title: "Hull breach", Targets: []string{"alice", "bob"}},
+		Acks:         []model.EventAck{{EntryId: "entry", UserId: "alice"}, {EntryId: "entry", UserId: "bob"}},
+	}
+
+	view := playerEvent(event, "alice")
+	if len(view.Targets) != 0 || view.Targets == nil {
+		t.Fatalf("players are shown who else the event was for: %v", view.Targets)
+	}
+	if len(view.Acks) != 1 || view.Acks[0].UserId != "alice" {
+		t.Fatalf("alice sees the acks %v", view.Acks)
+	}
+	if view.Title != event.Title {
+		t.Fatalf("view is %+v", view)
+	}
+
+	// the event itself still goes to the referee in full
+	if len(event.Targets) != 2 || len(event.Acks) != 2 || event.Acks[1].UserId != "bob" {
+		t.Fatalf("the view changed the event: %+v", event)
+	}
+
+	// broadcasts are sent with no user and carry no acks
+	if view := playerEvent(event, ""); len(view.Acks) != 0 {
+		t.Fatalf("broadcast carries the acks %v", view.Acks)
+	}
+}
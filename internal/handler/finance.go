@@ -0,0 +1,428 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type transactionForm struct {
+	AccountType model.AccountType    `json:"accountType" form:"accountType"`
+	AccountId   string               `json:"accountId" form:"accountId"`
+	Amount      int64                `json:"amount" form:"amount"`
+	Category    model.ChargeCategory `json:"category" form:"category"`
+	Description string               `json:"description" form:"description"`
+}
+
+type recurringChargeForm struct {
+	AccountType model.AccountType    `json:"accountType" form:"accountType"`
+	AccountId   string               `json:"accountId" form:"accountId"`
+	Category    model.ChargeCategory `json:"category" form:"category"`
+	Description string               `json:"description" form:"description"`
+	Amount      int64                `json:"amount" form:"amount"`
+	Active      *bool                `json:"active" form:"active"`
+}
+
+type financeAccount struct {
+	model.AccountSummary
+	Name string `json:"name"`
+}
+
+// checkAccount makes sure the account belongs to the session
+func (h *Handler) checkAccount(sessionId string, accountType model.AccountType, accountId string) error {
+	switch accountType {
+	case model.AccountType_Session:
+		if accountId != sessionId {
+			return echo.NewHTTPError(http.StatusBadRequest, "account is not part of this session")
+		}
+	case model.AccountType_Character:
+		var character model.Character
+		if err := character.GetCharacter(h.db, accountId); err != nil || character.SessionId != sessionId {
+			return echo.NewHTTPError(http.StatusBadRequest, "character is not part of this session")
+		}
+	case model.AccountType_Ship:
+		var ship model.Ship
+		if err := ship.GetShip(h.db, accountId); err != nil || ship.SessionId != sessionId {
+			return echo.NewHTTPError(http.StatusBadRequest, "ship is not part of this session")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown account type")
+	}
+	return nil
+}
+
+// publishFinance lets the players know the ledger changed
+func (h *Handler) publishFinance(sessionId string, transactions []model.Transaction) {
+	month, err := model.GetFinanceMonth(h.db, sessionId)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	msg := socket.NewFinanceMessage(sessionId, month, transactions)
+	if err := h.hub.Publish(sessionId, &msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// financeAccounts returns the account summaries of the session with a display name for each
+func (h *Handler) financeAccounts(item *model.Session) ([]financeAccount, error) {
+	summaries, err := model.GetAccountSummaries(h.db, item.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ships, err := model.GetSessionShips(h.db, item.Id)
+	if err != nil {
+		return nil, err
+	}
+	shipNames := map[string]string{}
+	for _, ship := range ships {
+		shipNames[ship.Id] = ship.Name
+	}
+
+	owners := map[string]string{}
+	for _, summary := range summaries {
+		if summary.AccountType != model.AccountType_Character {
+			continue
+		}
+		var character model.Character
+		if err := character.GetCharacter(h.db, summary.AccountId); err == nil {
+			owners[summary.AccountId] = character.Owner
+		}
+	}
+	ownerIds := []string{}
+	for _, owner := range owners {
+		ownerIds = append(ownerIds, owner)
+	}
+	usernames, err := model.GetUsernames(h.db, ownerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]financeAccount, 0, len(summaries))
+	for _, summary := range summaries {
+		account := financeAccount{AccountSummary: summary}
+		switch summary.AccountType {
+		case model.AccountType_Session:
+			account.Name = item.Name
+		case model.AccountType_Ship:
+			account.Name = shipNames[summary.AccountId]
+		case model.AccountType_Character:
+			account.Name = usernames[owners[summary.AccountId]]
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// GetFinances returns the balance and monthly charges of every account in the session
+func (h *Handler) GetFinances(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	month, err := model.GetFinanceMonth(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load finances")
+	}
+
+	accounts, err := h.financeAccounts(item)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load finances")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"month":    month,
+		"accounts": accounts,
+	})
+}
+
+// SessionFinances renders the finances panel of the session view
+func (h *Handler) SessionFinances(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	month, err := model.GetFinanceMonth(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.HTML(http.StatusInternalServerError, "<span>Failed to load finances</span>")
+	}
+
+	accounts, err := h.financeAccounts(item)
+	if err != nil {
+		log.Println(err)
+		return c.HTML(http.StatusInternalServerError, "<span>Failed to load finances</span>")
+	}
+
+	return c.Render(http.StatusOK, "session_finances.html", map[string]interface{}{
+		"month":    month,
+		"accounts": accounts,
+	})
+}
+
+func (h *Handler) GetLedger(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	accountType := model.AccountType(c.Param("accountType"))
+	if err := h.checkAccount(item.Id, accountType, c.Param("accountId")); err != nil {
+		return err
+	}
+
+	limit := 100
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return c.String(http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	items, err := model.GetLedger(h.db, item.Id, accountType, c.Param("accountId"), limit)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load ledger")
+	}
+
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) GetBalanceHistory(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	accountType := model.AccountType(c.Param("accountType"))
+	if err := h.checkAccount(item.Id, accountType, c.Param("accountId")); err != nil {
+		return err
+	}
+
+	items, err := model.GetBalanceHistory(h.db, item.Id, accountType, c.Param("accountId"))
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load balance history")
+	}
+
+	return c.JSON(http.StatusOK, items)
+}
+
+// CreateTransaction posts a one off entry to an account
+func (h *Handler) CreateTransaction(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData transactionForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if err := h.checkAccount(item.Id, formData.AccountType, formData.AccountId); err != nil {
+		return err
+	}
+
+	if formData.Category == "" {
+		formData.Category = model.ChargeCategory_Other
+	}
+	if !formData.Category.Valid() {
+		return c.String(http.StatusBadRequest, "unknown category")
+	}
+
+	if formData.Amount == 0 {
+		return c.String(http.StatusBadRequest, "amount is required")
+	}
+
+	transaction := model.Transaction{
+		SessionId:   item.Id,
+		AccountType: formData.AccountType,
+		AccountId:   formData.AccountId,
+		Amount:      formData.Amount,
+		Category:    formData.Category,
+		Description: formData.Description,
+	}
+	if err := transaction.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to post transaction")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{transaction})
+
+	return c.JSON(http.StatusCreated, transaction)
+}
+
+func (h *Handler) ListRecurringCharges(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	items, err := model.GetRecurringCharges(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load recurring charges")
+	}
+
+	return c.JSON(http.StatusOK, items)
+}
+
+// sessionCharge loads the recurring charge named by the chargeId path param and checks it belongs to the session
+func (h *Handler) sessionCharge(c echo.Context, sessionId string) (*model.RecurringCharge, error) {
+	var charge model.RecurringCharge
+	err := charge.GetRecurringCharge(h.db, c.Param("chargeId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No recurring charge found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load recurring charge")
+	}
+
+	if charge.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No recurring charge found")
+	}
+
+	return &charge, nil
+}
+
+func (h *Handler) CreateRecurringCharge(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData recurringChargeForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if err := h.checkAccount(item.Id, formData.AccountType, formData.AccountId); err != nil {
+		return err
+	}
+
+	if !formData.Category.Valid() {
+		return c.String(http.StatusBadRequest, "unknown category")
+	}
+
+	if formData.Amount == 0 {
+		return c.String(http.StatusBadRequest, "amount is required")
+	}
+
+	charge := model.RecurringCharge{
+		Id:          cuid.New(),
+		SessionId:   item.Id,
+		AccountType: formData.AccountType,
+		AccountId:   formData.AccountId,
+		Category:    formData.Category,
+		Description: formData.Description,
+		Amount:      formData.Amount,
+		Active:      formData.Active == nil || *formData.Active,
+	}
+	if err := charge.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create recurring charge")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{})
+
+	return c.JSON(http.StatusCreated, charge)
+}
+
+func (h *Handler) UpdateRecurringCharge(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	charge, err := h.sessionCharge(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData recurringChargeForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Category != "" {
+		if !formData.Category.Valid() {
+			return c.String(http.StatusBadRequest, "unknown category")
+		}
+		charge.Category = formData.Category
+	}
+	if formData.Description != "" {
+		charge.Description = formData.Description
+	}
+	if formData.Amount != 0 {
+		charge.Amount = formData.Amount
+	}
+	if formData.Active != nil {
+		charge.Active = *formData.Active
+	}
+
+	if err := charge.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update recurring charge")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{})
+
+	return c.JSON(http.StatusOK, charge)
+}
+
+func (h *Handler) DeleteRecurringCharge(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	charge, err := h.sessionCharge(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := charge.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete recurring charge")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AdvanceMonth posts every active recurring charge of the session and moves it into the next month
+func (h *Handler) AdvanceMonth(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	month, posted, err := model.AdvanceFinanceMonth(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to advance month")
+	}
+
+	h.publishFinance(item.Id, posted)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"month":        month,
+		"transactions": posted,
+	})
+}
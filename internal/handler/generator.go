@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"visualsource/traveller/internal/generator"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/npc"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type generateForm struct {
+	// terrain to roll an encounter on, or a world to pick it from
+	Terrain string `json:"terrain" form:"terrain"`
+	WorldId string `json:"worldId" form:"worldId"`
+	// keep the result, revealing it keeps it as well
+	Save   bool `json:"save" form:"save"`
+	Reveal bool `json:"reveal" form:"reveal"`
+	// save the patron of a mission as an NPC
+	NPC bool `json:"npc" form:"npc"`
+}
+
+// sessionGeneratedResult loads the result named by the resultId path param and checks it belongs to the session
+func (h *Handler) sessionGeneratedResult(c echo.Context, sessionId string) (*model.GeneratedResult, error) {
+	var item model.GeneratedResult
+	err := item.GetGeneratedResult(h.db, c.Param("resultId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No result found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load result")
+	}
+
+	if item.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No result found")
+	}
+
+	return &item, nil
+}
+
+func (h *Handler) publishGeneratedResult(result *model.GeneratedResult) {
+	msg := socket.NewGeneratedMessage(result.SessionId, result.PlayerView())
+	if err := h.hub.Publish(result.SessionId, &msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// keepGeneratedResult saves and reveals the result when the form asks for it, unsaved results are
+// returned to the referee without an id
+func (h *Handler) keepGeneratedResult(c echo.Context, formData *generateForm, result *model.GeneratedResult) error {
+	if !formData.Save && !formData.Reveal {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	result.Id = cuid.New()
+	result.Revealed = formData.Reveal
+	if err := result.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to save result")
+	}
+
+	if result.Revealed {
+		h.publishGeneratedResult(result)
+	}
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+func newGeneratedResult(sessionId string, kind model.GeneratedKind, summary string, details interface{}) (*model.GeneratedResult, error) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.GeneratedResult{
+		SessionId: sessionId,
+		Kind:      kind,
+		Summary:   summary,
+		Details:   data,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// ListGeneratorTables returns the terrains encounters can be rolled on
+func (h *Handler) ListGeneratorTables(c echo.Context) error {
+	if _, _, err := h.sessionAdmin(c); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"defaultTerrain": h.generators.DefaultTerrain,
+		"terrains":       h.generators.Terrains,
+	})
+}
+
+// GenerateEncounter rolls an encounter for a terrain, for the terrain of a world or for the default terrain
+func (h *Handler) GenerateEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData generateForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	var encounter generator.Encounter
+	switch {
+	case formData.Terrain != "":
+		encounter, err = h.generators.Encounter(h.dice, formData.Terrain)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	case formData.WorldId != "":
+		world, err := h.tradeWorld(item.Id, formData.WorldId)
+		if err != nil {
+			return err
+		}
+		encounter = h.generators.WorldEncounter(h.dice, world.TradeCodes)
+	default:
+		encounter = h.generators.WorldEncounter(h.dice, nil)
+	}
+
+	result, err := newGeneratedResult(item.Id, model.GeneratedKind_Encounter, encounter.Summary(), encounter)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to roll encounter")
+	}
+
+	return h.keepGeneratedResult(c, &formData, result)
+}
+
+// GenerateMission rolls a patron mission, the patron can be saved as an NPC along with it
+func (h *Handler) GenerateMission(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData generateForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	mission := h.generators.Mission(h.dice)
+	result, err := newGeneratedResult(item.Id, model.GeneratedKind_Mission, mission.Brief(), mission)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to roll mission")
+	}
+
+	if formData.NPC && (formData.Save || formData.Reveal) {
+		patron, err := h.npcs.Generate(h.dice, npc.Options{})
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to roll patron")
+		}
+		patron.Id = cuid.New()
+		patron.SessionId = item.Id
+		patron.Description = mission.Patron.Text
+		patron.Notes = mission.Summary()
+		patron.Visible = formData.Reveal
+		patron.CreatedAt = result.CreatedAt
+		if err := patron.Insert(h.db); err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to save patron")
+		}
+		result.NPCId = patron.Id
+		if patron.Visible {
+			h.publishNPCs(item.Id)
+		}
+	}
+
+	return h.keepGeneratedResult(c, &formData, result)
+}
+
+func (h *Handler) GenerateRumour(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData generateForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	rumour := h.generators.Rumour(h.dice)
+	result, err := newGeneratedResult(item.Id, model.GeneratedKind_Rumour, rumour.Summary(), rumour)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to roll rumour")
+	}
+
+	return h.keepGeneratedResult(c, &formData, result)
+}
+
+// ListGeneratedResults returns the kept results, the players only see the revealed ones. ?kind= filters them.
+func (h *Handler) ListGeneratedResults(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	admin := item.Admin == user
+	results, err := model.GetSessionGeneratedResults(h.db, item.Id, model.GeneratedKind(c.QueryParam("kind")), admin)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load results")
+	}
+
+	if !admin {
+		for i := range results {
+			results[i] = results[i].PlayerView()
+		}
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// RevealGeneratedResult shows a kept result to the players
+func (h *Handler) RevealGeneratedResult(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.sessionGeneratedResult(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if result.Revealed {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	if err := result.Reveal(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to reveal result")
+	}
+
+	h.publishGeneratedResult(result)
+
+	// the players meet the patron along with the mission
+	if result.NPCId != "" {
+		var patron model.NPC
+		if err := patron.GetNPC(h.db, result.NPCId); err == nil && !patron.Visible {
+			patron.Visible = true
+			if err := patron.Update(h.db); err != nil {
+				log.Println(err)
+			} else {
+				h.publishNPCs(item.Id)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) DeleteGeneratedResult(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.sessionGeneratedResult(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := result.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete result")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -2,7 +2,14 @@ package handler
 
 import (
 	"database/sql"
+	"os"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/generator"
+	"visualsource/traveller/internal/npc"
+	"visualsource/traveller/internal/shipyard"
 	"visualsource/traveller/internal/socket"
+	"visualsource/traveller/internal/trade"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -10,35 +17,132 @@ import (
 type Handler struct {
 	// DB connection here
 	db  *sql.DB
+	bus socket.Bus
 	hub *socket.Hub
+	// ship construction rules
+	shipyard *shipyard.Tables
+	// speculative trade goods and prices
+	trade *trade.Tables
+	// weapons, armour, gear and augments
+	equipment *equipment.Catalog
+	// species, names, careers and skills for generating NPCs
+	npcs *npc.Tables
+	// encounter, patron mission and rumour tables
+	generators *generator.Tables
+	// rolls for generators that are not given a seed
+	dice *dice.Roller
 }
 
 func (h *Handler) Close() {
+	h.bus.Close()
 	h.db.Close()
 }
 
 func New() (*Handler, error) {
-	db, err := sql.Open("sqlite3", "./database.db")
+	tables, err := shipyard.Load("configs/ship_components.yml")
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-	CREATE TABLE IF NOT EXISTS user (id TEXT PRIMARY KEY, username TEXT UNIQUE NOT NULL, password TEXT NOT NULL, sessions jsonb NOT NULL DEFAULT '[]');
-	CREATE TABLE IF NOT EXISTS session (id TEXT PRIMARY KEY, name TEXT NOT NULL, admin TEXT NOT NULL, players jsonb NOT NULL DEFAULT '[]');
-	CREATE TABLE IF NOT EXISTS character (id TEXT PRIMARY key, owner TEXT NOT NULL, session_id TEXT NOT NULL);
-	CREATE TABLE IF NOT EXISTS ship (id TEXT PRIMARY KEY, name TEXT NOT NULL);
-	`)
+	goods, err := trade.Load("configs/trade_goods.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := equipment.Load("configs/equipment.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	npcs, err := npc.Load("configs/npcs.yml", "configs/careers.yml", "configs/skils.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	generators, err := generator.Load("configs/encounters.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "./database.db?_busy_timeout=5000")
 	if err != nil {
 		return nil, err
 	}
 
-	hub := socket.NewHub()
+	if err := setupDatabase(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	go hub.Run()
+	// Set REDIS_URL when running more than one instance so socket messages reach clients on every instance
+	var bus socket.Bus = socket.NewLocalBus()
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		bus, err = socket.NewRedisBus(url)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	hub := socket.NewHub(db, bus)
 
 	return &Handler{
-		db:  db,
-		hub: hub,
+		db:         db,
+		bus:        bus,
+		hub:        hub,
+		shipyard:   tables,
+		trade:      goods,
+		equipment:  catalog,
+		npcs:       npcs,
+		generators: generators,
+		dice:       dice.Default(),
 	}, nil
 }
+
+// setupDatabase creates the tables of a new database and adds what is missing to one made by an older version
+func setupDatabase(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS user (id TEXT PRIMARY KEY, username TEXT UNIQUE NOT NULL, password TEXT NOT NULL, sessions jsonb NOT NULL DEFAULT '[]');
+	CREATE TABLE IF NOT EXISTS session (id TEXT PRIMARY KEY, name TEXT NOT NULL, admin TEXT NOT NULL, players jsonb NOT NULL DEFAULT '[]');
+	CREATE TABLE IF NOT EXISTS character (id TEXT PRIMARY key, owner TEXT NOT NULL, session_id TEXT NOT NULL, name TEXT NOT NULL DEFAULT '', characteristics jsonb NOT NULL DEFAULT '{}', skills jsonb NOT NULL DEFAULT '{}', injuries jsonb NOT NULL DEFAULT '{}');
+	CREATE TABLE IF NOT EXISTS ship (id TEXT PRIMARY KEY, name TEXT NOT NULL, session_id TEXT NOT NULL, design jsonb NOT NULL DEFAULT '{}');
+	CREATE TABLE IF NOT EXISTS ship_crew (ship_id TEXT NOT NULL, character_id TEXT NOT NULL, role TEXT NOT NULL, PRIMARY KEY (ship_id, character_id));
+	CREATE TABLE IF NOT EXISTS chat_message (session_id TEXT NOT NULL, seq INTEGER NOT NULL, kind TEXT NOT NULL, from_user TEXT NOT NULL, to_user TEXT NOT NULL DEFAULT '', target TEXT NOT NULL DEFAULT '', content TEXT NOT NULL, created_at DATETIME NOT NULL, PRIMARY KEY (session_id, seq));
+	CREATE TABLE IF NOT EXISTS ledger (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, account_type TEXT NOT NULL, account_id TEXT NOT NULL, seq INTEGER NOT NULL, month INTEGER NOT NULL, amount INTEGER NOT NULL, balance INTEGER NOT NULL, category TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', charge_id TEXT NOT NULL DEFAULT '', created_at DATETIME NOT NULL, UNIQUE (session_id, account_type, account_id, seq));
+	CREATE TABLE IF NOT EXISTS recurring_charge (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, account_type TEXT NOT NULL, account_id TEXT NOT NULL, category TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', amount INTEGER NOT NULL, active BOOLEAN NOT NULL DEFAULT 1);
+	CREATE TABLE IF NOT EXISTS finance_month (session_id TEXT PRIMARY KEY, month INTEGER NOT NULL);
+	CREATE TABLE IF NOT EXISTS world (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, sector_id TEXT NOT NULL DEFAULT '', hex TEXT NOT NULL DEFAULT '', name TEXT NOT NULL, uwp TEXT NOT NULL, bases jsonb NOT NULL DEFAULT '[]', zone TEXT NOT NULL DEFAULT 'green', gas_giants INTEGER NOT NULL DEFAULT 0, belts INTEGER NOT NULL DEFAULT 0, notes TEXT NOT NULL DEFAULT '');
+	CREATE TABLE IF NOT EXISTS sector (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, name TEXT NOT NULL, width INTEGER NOT NULL, height INTEGER NOT NULL, seed INTEGER NOT NULL, density TEXT NOT NULL, created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS session_calendar (session_id TEXT PRIMARY KEY, date INTEGER NOT NULL);
+	CREATE TABLE IF NOT EXISTS scheduled_event (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, date INTEGER NOT NULL, kind TEXT NOT NULL, title TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', user_id TEXT NOT NULL DEFAULT '', repeat_days INTEGER NOT NULL DEFAULT 0, fired BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS market (world_id TEXT NOT NULL, session_id TEXT NOT NULL, week INTEGER NOT NULL, goods jsonb NOT NULL DEFAULT '[]', created_at DATETIME NOT NULL, PRIMARY KEY (world_id, week));
+	CREATE TABLE IF NOT EXISTS cargo (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, ship_id TEXT NOT NULL, good_id TEXT NOT NULL, name TEXT NOT NULL, tons INTEGER NOT NULL, purchase_price INTEGER NOT NULL, world_id TEXT NOT NULL, created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS contract (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, kind TEXT NOT NULL, class TEXT NOT NULL, source_id TEXT NOT NULL, destination_id TEXT NOT NULL, parsecs INTEGER NOT NULL, tons INTEGER NOT NULL DEFAULT 0, passengers INTEGER NOT NULL DEFAULT 0, payment INTEGER NOT NULL, status TEXT NOT NULL, ship_id TEXT NOT NULL DEFAULT '', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS inventory_item (id TEXT PRIMARY KEY, character_id TEXT NOT NULL, item_id TEXT NOT NULL, quantity INTEGER NOT NULL DEFAULT 1, equipped BOOLEAN NOT NULL DEFAULT 0, ammo INTEGER NOT NULL DEFAULT 0);
+	CREATE TABLE IF NOT EXISTS character_augment (id TEXT PRIMARY KEY, character_id TEXT NOT NULL, augment_id TEXT NOT NULL, installed_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS encounter (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, name TEXT NOT NULL, status TEXT NOT NULL, round INTEGER NOT NULL DEFAULT 0, turn INTEGER NOT NULL DEFAULT 0, combatants jsonb NOT NULL DEFAULT '[]', log jsonb NOT NULL DEFAULT '[]', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS space_encounter (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, name TEXT NOT NULL, status TEXT NOT NULL, round INTEGER NOT NULL DEFAULT 0, ships jsonb NOT NULL DEFAULT '[]', ranges jsonb NOT NULL DEFAULT '[]', log jsonb NOT NULL DEFAULT '[]', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS npc (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, name TEXT NOT NULL, species TEXT NOT NULL DEFAULT '', career TEXT NOT NULL DEFAULT '', assignment TEXT NOT NULL DEFAULT '', rank TEXT NOT NULL DEFAULT '', age INTEGER NOT NULL DEFAULT 0, description TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '', characteristics jsonb NOT NULL DEFAULT 'null', skills jsonb NOT NULL DEFAULT '{}', visible BOOLEAN NOT NULL DEFAULT 0, reveal jsonb NOT NULL DEFAULT '{}', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS npc_relationship (npc_id TEXT NOT NULL, character_id TEXT NOT NULL, kind TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', PRIMARY KEY (npc_id, character_id));
+	CREATE TABLE IF NOT EXISTS generated_result (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, kind TEXT NOT NULL, summary TEXT NOT NULL, details jsonb NOT NULL DEFAULT '{}', npc_id TEXT NOT NULL DEFAULT '', revealed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS note (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, title TEXT NOT NULL, body TEXT NOT NULL DEFAULT '', created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS handout (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, title TEXT NOT NULL, kind TEXT NOT NULL, body TEXT NOT NULL DEFAULT '', file TEXT NOT NULL DEFAULT '', content_type TEXT NOT NULL DEFAULT '', shared_all BOOLEAN NOT NULL DEFAULT 0, shared_with jsonb NOT NULL DEFAULT '[]', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS explored_hex (session_id TEXT NOT NULL, sector_id TEXT NOT NULL, hex TEXT NOT NULL, user_id TEXT NOT NULL DEFAULT '', PRIMARY KEY (sector_id, hex, user_id));
+	CREATE TABLE IF NOT EXISTS world_knowledge (session_id TEXT NOT NULL, world_id TEXT NOT NULL, user_id TEXT NOT NULL DEFAULT '', fields jsonb NOT NULL DEFAULT '[]', PRIMARY KEY (world_id, user_id));
+	CREATE TABLE IF NOT EXISTS journal_entry (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, kind TEXT NOT NULL, date INTEGER NOT NULL, author TEXT NOT NULL, character_id TEXT NOT NULL DEFAULT '', title TEXT NOT NULL, body TEXT NOT NULL DEFAULT '', targets jsonb NOT NULL DEFAULT '[]', data jsonb NOT NULL DEFAULT '{}', created_at DATETIME NOT NULL);
+	CREATE TABLE IF NOT EXISTS event_ack (entry_id TEXT NOT NULL, user_id TEXT NOT NULL, created_at DATETIME NOT NULL, PRIMARY KEY (entry_id, user_id));
+	`)
+	if err != nil {
+		return err
+	}
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	// indexes on added columns can only be made once they are there
+	_, err = db.Exec(`
+	CREATE UNIQUE INDEX IF NOT EXISTS world_sector_hex ON world (sector_id, hex) WHERE sector_id != '';
+	`)
+	return err
+}
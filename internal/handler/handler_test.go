@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/trade"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestHandler is a handler on a new database with the equipment and trade goods of the configs
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := setupDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := equipment.Load("../../configs/equipment.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goods, err := trade.Load("../../configs/trade_goods.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Handler{db: db, equipment: catalog, trade: goods, dice: dice.Seeded(1)}
+}
+
+// testSession adds a session run by the referee with the players
+func testSession(t *testing.T, h *Handler, id string, referee string, players ...string) {
+	t.Helper()
+
+	data, err := json.Marshal(players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.db.Exec("INSERT INTO session (id,name,admin,players) VALUES (?,?,?,?);", id, id, referee, string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve calls the end point as the logged in user. params are the path params, name then value.
+func serve(t *testing.T, user string, method string, target string, body string, endpoint echo.HandlerFunc, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	names, values := []string{}, []string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	c.Set("_session_store", sessions.NewCookieStore([]byte("test")))
+	sess, err := session.Get(SESSION_NAME, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Values[Session_ID] = user
+
+	if err := endpoint(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+// testCharacter adds a character of the session owned by the user
+func testCharacter(t *testing.T, h *Handler, id string, sessionId string, owner string, skills map[string]int) {
+	t.Helper()
+
+	character := model.Character{Id: id, Owner: owner, SessionId: sessionId, Name: id, Characteristics: model.Characteristics{STR: 7, DEX: 7, END: 7, INT: 7, EDU: 7, SOC: 7}, Skills: skills}
+	if err := character.Insert(h.db); err != nil {
+		t.Fatal(err)
+	}
+}
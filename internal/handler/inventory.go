@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"visualsource/traveller/internal/equipment"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type inventoryForm struct {
+	ItemId   string `json:"itemId" form:"itemId"`
+	Quantity int    `json:"quantity" form:"quantity"`
+	Equipped bool   `json:"equipped" form:"equipped"`
+	// rounds loaded, a new weapon comes with a full magazine when not given
+	Ammo *int `json:"ammo" form:"ammo"`
+}
+
+type updateInventoryForm struct {
+	Quantity *int  `json:"quantity" form:"quantity"`
+	Equipped *bool `json:"equipped" form:"equipped"`
+	Ammo     *int  `json:"ammo" form:"ammo"`
+}
+
+type inventoryEntry struct {
+	model.InventoryItem
+	Item equipment.Item `json:"item"`
+}
+
+// validAmmo checks the rounds fit in the magazine of the item, anything that is not a weapon holds none
+func (h *Handler) validAmmo(itemId string, ammo int) bool {
+	weapon, ok := h.equipment.Weapon(itemId)
+	if !ok {
+		return ammo == 0
+	}
+	return ammo >= 0 && ammo <= weapon.Magazine
+}
+
+// characterInventory loads the inventory item named by the inventoryId path param and checks it belongs to the character
+func (h *Handler) characterInventory(c echo.Context, characterId string) (*model.InventoryItem, error) {
+	var item model.InventoryItem
+	err := item.GetInventoryItem(h.db, c.Param("inventoryId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No inventory item found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load inventory item")
+	}
+
+	if item.CharacterId != characterId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No inventory item found")
+	}
+
+	return &item, nil
+}
+
+func (h *Handler) ListEquipment(c echo.Context) error {
+	kind := equipment.Kind(c.QueryParam("kind"))
+	if kind != "" && !kind.Valid() {
+		return c.String(http.StatusBadRequest, "unknown equipment kind")
+	}
+
+	return c.JSON(http.StatusOK, h.equipment.Items(kind))
+}
+
+// GetInventory returns what the character carries with the catalog entry of each item and their encumbrance
+func (h *Handler) GetInventory(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	items, err := model.GetCharacterInventory(h.db, character.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load inventory")
+	}
+
+	stats, err := h.characterStats(character)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load character stats")
+	}
+
+	entries := []inventoryEntry{}
+	for _, inventory := range items {
+		// entries removed from the catalog are still listed so they can be deleted
+		catalog, _ := h.equipment.Item(inventory.ItemId)
+		entries = append(entries, inventoryEntry{inventory, catalog})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"items":       entries,
+		"encumbrance": stats.Encumbrance,
+	})
+}
+
+func (h *Handler) AddInventoryItem(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	var formData inventoryForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if _, ok := h.equipment.Augment(formData.ItemId); ok {
+		return c.String(http.StatusBadRequest, "augments are installed, not carried")
+	}
+	if _, ok := h.equipment.Item(formData.ItemId); !ok {
+		return c.String(http.StatusBadRequest, "unknown equipment")
+	}
+
+	if formData.Quantity == 0 {
+		formData.Quantity = 1
+	}
+	if formData.Quantity < 0 {
+		return c.String(http.StatusBadRequest, "quantity must be positive")
+	}
+
+	ammo := 0
+	if formData.Ammo != nil {
+		ammo = *formData.Ammo
+	} else if weapon, ok := h.equipment.Weapon(formData.ItemId); ok {
+		ammo = weapon.Magazine
+	}
+	if !h.validAmmo(formData.ItemId, ammo) {
+		return c.String(http.StatusBadRequest, "ammo does not fit the magazine")
+	}
+
+	inventory := model.InventoryItem{
+		Id:          cuid.New(),
+		CharacterId: character.Id,
+		ItemId:      formData.ItemId,
+		Quantity:    formData.Quantity,
+		Equipped:    formData.Equipped,
+		Ammo:        ammo,
+	}
+	if err := inventory.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to add inventory item")
+	}
+
+	return c.JSON(http.StatusCreated, inventory)
+}
+
+func (h *Handler) UpdateInventoryItem(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	inventory, err := h.characterInventory(c, character.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData updateInventoryForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Quantity != nil {
+		if *formData.Quantity <= 0 {
+			return c.String(http.StatusBadRequest, "quantity must be positive")
+		}
+		inventory.Quantity = *formData.Quantity
+	}
+	if formData.Equipped != nil {
+		inventory.Equipped = *formData.Equipped
+	}
+	if formData.Ammo != nil {
+		if !h.validAmmo(inventory.ItemId, *formData.Ammo) {
+			return c.String(http.StatusBadRequest, "ammo does not fit the magazine")
+		}
+		inventory.Ammo = *formData.Ammo
+	}
+
+	if err := inventory.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update inventory item")
+	}
+
+	return c.JSON(http.StatusOK, inventory)
+}
+
+func (h *Handler) DeleteInventoryItem(c echo.Context) error {
+	_, character, err := h.ownCharacter(c)
+	if err != nil {
+		return err
+	}
+
+	inventory, err := h.characterInventory(c, character.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := inventory.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to remove inventory item")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
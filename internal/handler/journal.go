@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type journalForm struct {
+	Title *string `json:"title" form:"title"`
+	Body  *string `json:"body" form:"body"`
+	// the character the entry is about, empty when it is about the party
+	CharacterId *string `json:"characterId" form:"characterId"`
+}
+
+func (f *journalForm) apply(entry *model.JournalEntry) {
+	if f.Title != nil {
+		entry.Title = *f.Title
+	}
+	if f.Body != nil {
+		entry.Body = *f.Body
+	}
+	if f.CharacterId != nil {
+		entry.CharacterId = *f.CharacterId
+	}
+}
+
+// journalFilter reads the kind, characterId, q, from, to and since query params
+func journalFilter(c echo.Context) (model.JournalFilter, error) {
+	filter := model.JournalFilter{
+		Kind:        model.JournalKind(c.QueryParam("kind")),
+		CharacterId: c.QueryParam("characterId"),
+		Search:      c.QueryParam("q"),
+	}
+	if filter.Kind != "" && !filter.Kind.Valid() {
+		return filter, echo.NewHTTPError(http.StatusBadRequest, "unknown journal entry kind")
+	}
+
+	for _, param := range []struct {
+		name string
+		to   **model.ImperialDate
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := c.QueryParam(param.name)
+		if value == "" {
+			continue
+		}
+		date, err := model.ParseImperialDate(value)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		*param.to = &date
+	}
+
+	if value := c.QueryParam("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "since must be an RFC 3339 time")
+		}
+		filter.Since = since.UTC()
+	}
+
+	return filter, nil
+}
+
+// filteredJournal loads the entries matching the query params that are meant for the user
+func (h *Handler) filteredJournal(c echo.Context, item *model.Session, user string) ([]model.JournalEntry, error) {
+	filter, err := journalFilter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := model.GetSessionJournal(h.db, item.Id, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load journal")
+	}
+
+	visible := []model.JournalEntry{}
+	for _, entry := range entries {
+		if entry.For(item, user) {
+			visible = append(visible, entry)
+		}
+	}
+
+	return visible, nil
+}
+
+// sessionJournalEntry loads the entry named by the entryId path param, players only find the entries meant for them
+func (h *Handler) sessionJournalEntry(c echo.Context, item *model.Session, user string) (*model.JournalEntry, error) {
+	var entry model.JournalEntry
+	err := entry.GetJournalEntry(h.db, c.Param("entryId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No journal entry found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load journal entry")
+	}
+
+	if entry.SessionId != item.Id || !entry.For(item, user) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No journal entry found")
+	}
+
+	return &entry, nil
+}
+
+// checkJournalCharacter makes sure an entry is about a character of the session, players can only write about their own
+func (h *Handler) checkJournalCharacter(item *model.Session, user string, characterId string) error {
+	if characterId == "" {
+		return nil
+	}
+
+	var character model.Character
+	if err := character.GetCharacter(h.db, characterId); err != nil || character.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character")
+		}
+		return echo.NewHTTPError(http.StatusNotFound, "No character found")
+	}
+
+	if character.Owner != user && item.Admin != user {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner of the character can do this")
+	}
+
+	return nil
+}
+
+// publishJournal sends a new, changed or deleted entry to everyone it is meant for
+func (h *Handler) publishJournal(item *model.Session, entry model.JournalEntry, deleted bool) {
+	msg := socket.NewJournalMessage(item.Id, entry, deleted)
+	if len(entry.Targets) == 0 {
+		if err := h.hub.Publish(item.Id, &msg); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	for _, userId := range append([]string{item.Admin}, entry.Targets...) {
+		if err := h.hub.PublishUser(item.Id, userId, &msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// journal writes an automatic entry to the session journal on the current in game date. Failing to
+// write it is only logged so the action that caused it still goes through.
+func (h *Handler) journal(item *model.Session, author string, characterId string, kind model.JournalKind, title string, body string, data any) {
+	date, err := model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	details, err := json.Marshal(data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	entry := model.JournalEntry{
+		Id:          cuid.New(),
+		SessionId:   item.Id,
+		Kind:        kind,
+		Date:        date,
+		Author:      author,
+		CharacterId: characterId,
+		Title:       title,
+		Body:        body,
+		Data:        details,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := entry.Insert(h.db); err != nil {
+		log.Println(err)
+		return
+	}
+
+	h.publishJournal(item, entry, false)
+}
+
+// ListJournal returns the session journal oldest first, filtered by the kind, characterId, q, from, to and since query params
+func (h *Handler) ListJournal(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.filteredJournal(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"kinds":   model.JournalKinds,
+		"entries": entries,
+	})
+}
+
+// ExportJournal downloads the journal as Markdown, taking the same filters as ListJournal
+func (h *Handler) ExportJournal(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.filteredJournal(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	names, err := model.GetUsernames(h.db, append([]string{item.Admin}, item.Players...))
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load players")
+	}
+	characters, err := model.GetSessionCharacters(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load characters")
+	}
+	for _, character := range characters {
+		names[character.Id] = character.Name
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", item.Name+" journal.md"))
+	return c.Blob(http.StatusOK, "text/markdown; charset=UTF-8", []byte(model.JournalMarkdown(item.Name+" Journal", entries, names)))
+}
+
+// CreateJournalEntry adds a written entry to the journal, dated on the current in game date
+func (h *Handler) CreateJournalEntry(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	var formData journalForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	entry := model.JournalEntry{Id: cuid.New(), SessionId: item.Id, Kind: model.JournalKind_Manual, Author: user, CreatedAt: time.Now().UTC()}
+	formData.apply(&entry)
+	if entry.Title == "" {
+		return c.String(http.StatusBadRequest, "journal entry title is required")
+	}
+	if err := h.checkJournalCharacter(item, user, entry.CharacterId); err != nil {
+		return err
+	}
+
+	entry.Date, err = model.GetSessionDate(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load calendar")
+	}
+
+	if err := entry.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create journal entry")
+	}
+
+	h.publishJournal(item, entry, false)
+
+	return c.JSON(http.StatusCreated, entry)
+}
+
+// UpdateJournalEntry edits a written entry, only its author or the referee can. Automatic entries can not be changed.
+func (h *Handler) UpdateJournalEntry(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.sessionJournalEntry(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	if entry.Kind != model.JournalKind_Manual {
+		return c.String(http.StatusBadRequest, "only written entries can be edited")
+	}
+	if entry.Author != user && item.Admin != user {
+		return c.String(http.StatusForbidden, "Only the author of the entry can do this")
+	}
+
+	var formData journalForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	formData.apply(entry)
+	if entry.Title == "" {
+		return c.String(http.StatusBadRequest, "journal entry title is required")
+	}
+	if formData.CharacterId != nil {
+		if err := h.checkJournalCharacter(item, user, entry.CharacterId); err != nil {
+			return err
+		}
+	}
+
+	if err := entry.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update journal entry")
+	}
+
+	h.publishJournal(item, *entry, false)
+
+	return c.JSON(http.StatusOK, entry)
+}
+
+// DeleteJournalEntry removes an entry, players can only remove what they wrote
+func (h *Handler) DeleteJournalEntry(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.sessionJournalEntry(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	if item.Admin != user && (entry.Kind != model.JournalKind_Manual || entry.Author != user) {
+		return c.String(http.StatusForbidden, "Only the author of the entry can do this")
+	}
+
+	if err := entry.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete journal entry")
+	}
+
+	h.publishJournal(item, *entry, true)
+
+	return c.NoContent(http.StatusNoContent)
+}
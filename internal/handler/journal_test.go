@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+func queryContext(query string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/journal?"+query, nil)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestJournalFilter(t *testing.T) {
+	filter, err := journalFilter(queryContext("kind=trade&characterId=char&q=spice&from=005-1105&to=010-1105&since=2026-03-14T20:00:00%2B01:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Kind != model.JournalKind_Trade || filter.CharacterId != "char" || filter.Search != "spice" {
+		t.Fatalf("filter is %+v", filter)
+	}
+	if filter.From == nil || *filter.From != (model.ImperialDate{Day: 5, Year: 1105}) || filter.To == nil || *filter.To != (model.ImperialDate{Day: 10, Year: 1105}) {
+		t.Fatalf("dates are %v to %v", filter.From, filter.To)
+	}
+	if !filter.Since.Equal(time.Date(2026, 3, 14, 19, 0, 0, 0, time.UTC)) || filter.Since.Location() != time.UTC {
+		t.Fatalf("since is %v", filter.Since)
+	}
+
+	filter, err = journalFilter(queryContext(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Kind != "" || filter.From != nil || filter.To != nil || !filter.Since.IsZero() {
+		t.Fatalf("no params gave %+v", filter)
+	}
+}
+
+func TestJournalFilterRejects(t *testing.T) {
+	for _, query := range []string{"kind=gossip", "from=400-1105", "to=yesterday", "since=2026-03-14"} {
+		_, err := journalFilter(queryContext(query))
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %v", query, err)
+		}
+	}
+}
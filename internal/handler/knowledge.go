@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+)
+
+type exploreForm struct {
+	SectorId string   `json:"sectorId" form:"sectorId"`
+	Hexes    []string `json:"hexes" form:"hexes"`
+	// explore every hex of a subsector, A to P
+	Subsector string `json:"subsector" form:"subsector"`
+	// the player who explored the hexes, empty for the whole party
+	UserId   string `json:"userId" form:"userId"`
+	Explored bool   `json:"explored" form:"explored"`
+}
+
+type worldKnowledgeForm struct {
+	// the player who learnt about the world, empty for the whole party
+	UserId string   `json:"userId" form:"userId"`
+	Fields []string `json:"fields" form:"fields"`
+	// reveal every field
+	All bool `json:"all" form:"all"`
+}
+
+// playerKnowledge loads what the user knows of the map, the referee knows everything and gets nil
+func (h *Handler) playerKnowledge(item *model.Session, user string) (*model.Knowledge, error) {
+	if item.Admin == user {
+		return nil, nil
+	}
+
+	knowledge, err := model.GetKnowledge(h.db, item.Id, user)
+	if err != nil {
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load map")
+	}
+
+	return knowledge, nil
+}
+
+// knownWorld fails with Not Found when the world is one the player has not found yet
+func (h *Handler) knownWorld(item *model.Session, user string, world *model.World) error {
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+	if knowledge != nil && !knowledge.Knows(world) {
+		return echo.NewHTTPError(http.StatusNotFound, "No world found")
+	}
+	return nil
+}
+
+// publishMap tells a player, or every player when userId is empty, their map changed
+func (h *Handler) publishMap(sessionId string, userId string) {
+	msg := socket.NewMapMessage(sessionId)
+	var err error
+	if userId == "" {
+		err = h.hub.Publish(sessionId, &msg)
+	} else {
+		err = h.hub.PublishUser(sessionId, userId, &msg)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// GetMapKnowledge returns the explored hexes and known worlds of every player, ?userId= narrows it to one
+func (h *Handler) GetMapKnowledge(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	hexes, err := model.GetExploredHexes(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load map")
+	}
+
+	worlds, err := model.GetWorldKnowledge(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load map")
+	}
+
+	if userId := c.QueryParam("userId"); userId != "" {
+		hexes = slices.DeleteFunc(hexes, func(hex model.ExploredHex) bool { return hex.UserId != userId })
+		worlds = slices.DeleteFunc(worlds, func(world model.WorldKnowledge) bool { return world.UserId != userId })
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"fields": model.WorldFields,
+		"hexes":  hexes,
+		"worlds": worlds,
+	})
+}
+
+// ExploreHexes reveals hexes of a sector to the party or a player, or hides them again
+func (h *Handler) ExploreHexes(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData exploreForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.UserId != "" && !slices.Contains(item.Players, formData.UserId) {
+		return c.String(http.StatusBadRequest, "userId must be a player of the session")
+	}
+
+	var sector model.Sector
+	if err := sector.GetSector(h.db, formData.SectorId); err != nil || sector.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load sector")
+		}
+		return c.String(http.StatusNotFound, "No sector found")
+	}
+
+	hexes := []string{}
+	for _, hex := range formData.Hexes {
+		if !sector.Contains(hex) {
+			return c.String(http.StatusBadRequest, "hexes must be on the sector")
+		}
+		hexes = append(hexes, hex)
+	}
+	if formData.Subsector != "" {
+		found := false
+		for column := 1; column <= sector.Width; column++ {
+			for row := 1; row <= sector.Height; row++ {
+				if model.Subsector(column, row) == formData.Subsector {
+					hexes = append(hexes, model.Hex(column, row))
+					found = true
+				}
+			}
+		}
+		if !found {
+			return c.String(http.StatusBadRequest, "subsector is not on the sector")
+		}
+	}
+
+	if len(hexes) == 0 {
+		return c.String(http.StatusBadRequest, "hexes or a subsector are required")
+	}
+
+	if err := model.ExploreHexes(h.db, item.Id, sector.Id, formData.UserId, hexes, formData.Explored); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update map")
+	}
+
+	h.publishMap(item.Id, formData.UserId)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"sectorId": sector.Id,
+		"userId":   formData.UserId,
+		"hexes":    hexes,
+		"explored": formData.Explored,
+	})
+}
+
+// SetWorldKnowledge sets the details of a world the party or a player knows, the world itself becomes known
+// even if its hex has not been explored
+func (h *Handler) SetWorldKnowledge(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData worldKnowledgeForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.UserId != "" && !slices.Contains(item.Players, formData.UserId) {
+		return c.String(http.StatusBadRequest, "userId must be a player of the session")
+	}
+
+	knowledge := model.WorldKnowledge{WorldId: world.Id, UserId: formData.UserId, Fields: []string{}}
+	if formData.All {
+		knowledge.Fields = model.WorldFields
+	} else {
+		for _, field := range formData.Fields {
+			if !model.ValidWorldField(field) {
+				return c.String(http.StatusBadRequest, "unknown world field '"+field+"'")
+			}
+			if !slices.Contains(knowledge.Fields, field) {
+				knowledge.Fields = append(knowledge.Fields, field)
+			}
+		}
+	}
+
+	if err := model.SetWorldKnowledge(h.db, item.Id, knowledge); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update map")
+	}
+
+	h.publishMap(item.Id, formData.UserId)
+
+	return c.JSON(http.StatusOK, knowledge)
+}
+
+// ForgetWorld hides the details of a world from the party, or from the player given with ?userId=
+func (h *Handler) ForgetWorld(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	userId := c.QueryParam("userId")
+	if err := model.ForgetWorld(h.db, world.Id, userId); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update map")
+	}
+
+	h.publishMap(item.Id, userId)
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// column is a column added to a table after the table was first created. CREATE TABLE IF NOT EXISTS
+// leaves tables of an existing database as they were, so these are added to them with ALTER TABLE.
+type column struct {
+	table string
+	name  string
+	// type and constraints, a NOT NULL column needs a default for the rows already there
+	definition string
+}
+
+// addedColumns in the order they were added
+var addedColumns = []column{
+	{"ship", "session_id", "TEXT NOT NULL DEFAULT ''"},
+	{"ship", "design", "jsonb NOT NULL DEFAULT '{}'"},
+	{"world", "sector_id", "TEXT NOT NULL DEFAULT ''"},
+	{"world", "hex", "TEXT NOT NULL DEFAULT ''"},
+	{"world", "gas_giants", "INTEGER NOT NULL DEFAULT 0"},
+	{"world", "belts", "INTEGER NOT NULL DEFAULT 0"},
+	{"character", "name", "TEXT NOT NULL DEFAULT ''"},
+	{"character", "characteristics", "jsonb NOT NULL DEFAULT '{}'"},
+	{"character", "skills", "jsonb NOT NULL DEFAULT '{}'"},
+	{"character", "injuries", "jsonb NOT NULL DEFAULT '{}'"},
+}
+
+// hasColumn reports whether the table already has the column
+func hasColumn(db *sql.DB, table string, name string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`, table, name).Scan(&count)
+	return count > 0, err
+}
+
+// migrate adds the columns missing from tables created by an older version
+func migrate(db *sql.DB) error {
+	for _, col := range addedColumns {
+		exists, err := hasColumn(db, col.table, col.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, col.table, col.name, col.definition)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", col.table, col.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMigrateAddsMissingColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the tables as the oldest release created them
+	_, err = db.Exec(`
+	CREATE TABLE ship (id TEXT PRIMARY KEY, name TEXT NOT NULL);
+	CREATE TABLE character (id TEXT PRIMARY key, owner TEXT NOT NULL, session_id TEXT NOT NULL);
+	CREATE TABLE world (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, name TEXT NOT NULL, uwp TEXT NOT NULL, bases jsonb NOT NULL DEFAULT '[]', zone TEXT NOT NULL DEFAULT 'green', notes TEXT NOT NULL DEFAULT '');
+	INSERT INTO ship (id, name) VALUES ('ship', 'Beowulf');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// running it again on a migrated database does nothing
+	for range 2 {
+		if err := migrate(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, col := range addedColumns {
+		exists, err := hasColumn(db, col.table, col.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("%s.%s was not added", col.table, col.name)
+		}
+	}
+
+	var sessionId, design string
+	if err := db.QueryRow(`SELECT session_id, design FROM ship WHERE id = 'ship';`).Scan(&sessionId, &design); err != nil {
+		t.Fatal(err)
+	}
+	if sessionId != "" || design != "{}" {
+		t.Fatalf("existing ship got session_id %q and design %q", sessionId, design)
+	}
+}
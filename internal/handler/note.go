@@ -0,0 +1,455 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+// handoutDir is where uploaded handout images are kept
+const handoutDir = "data/handouts"
+
+// maxHandoutSize is the largest image that can be uploaded, in bytes
+const maxHandoutSize = 10 << 20
+
+// handoutImageTypes maps the image types that can be uploaded to their file extension
+var handoutImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type noteForm struct {
+	Title string  `json:"title" form:"title"`
+	Body  *string `json:"body" form:"body"`
+}
+
+type handoutForm struct {
+	Title string  `json:"title" form:"title"`
+	Body  *string `json:"body" form:"body"`
+}
+
+type shareForm struct {
+	All     bool     `json:"all" form:"all"`
+	Players []string `json:"players" form:"players"`
+}
+
+func (h *Handler) sessionNote(c echo.Context, sessionId string) (*model.Note, error) {
+	var item model.Note
+	err := item.GetNote(h.db, c.Param("noteId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No note found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load note")
+	}
+
+	if item.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No note found")
+	}
+
+	return &item, nil
+}
+
+func (h *Handler) ListNotes(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	notes, err := model.GetSessionNotes(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load notes")
+	}
+
+	return c.JSON(http.StatusOK, notes)
+}
+
+func (h *Handler) GetNote(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	note, err := h.sessionNote(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+func (h *Handler) CreateNote(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData noteForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Title == "" {
+		return c.String(http.StatusBadRequest, "note title is required")
+	}
+
+	now := time.Now().UTC()
+	note := model.Note{Id: cuid.New(), SessionId: item.Id, Title: formData.Title, CreatedAt: now, UpdatedAt: now}
+	if formData.Body != nil {
+		note.Body = *formData.Body
+	}
+
+	if err := note.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create note")
+	}
+
+	return c.JSON(http.StatusCreated, note)
+}
+
+func (h *Handler) UpdateNote(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	note, err := h.sessionNote(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData noteForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Title != "" {
+		note.Title = formData.Title
+	}
+	if formData.Body != nil {
+		note.Body = *formData.Body
+	}
+	note.UpdatedAt = time.Now().UTC()
+
+	if err := note.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update note")
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+func (h *Handler) DeleteNote(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	note, err := h.sessionNote(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := note.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete note")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// sessionHandout loads the handout named by the handoutId path param. Players only find the handouts shared with them.
+func (h *Handler) sessionHandout(c echo.Context, item *model.Session, user string) (*model.Handout, error) {
+	var handout model.Handout
+	err := handout.GetHandout(h.db, c.Param("handoutId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No handout found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load handout")
+	}
+
+	if handout.SessionId != item.Id || (item.Admin != user && !handout.SharedTo(user)) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No handout found")
+	}
+
+	return &handout, nil
+}
+
+// publishHandout sends the handout to the players who can now see it and withdraws it from those who no longer can
+func (h *Handler) publishHandout(item *model.Session, before *model.Handout, after *model.Handout) {
+	for _, player := range item.Players {
+		had := before != nil && before.SharedTo(player)
+		has := after != nil && after.SharedTo(player)
+
+		var msg socket.HandoutMessage
+		switch {
+		case has:
+			msg = socket.NewHandoutMessage(item.Id, after.PlayerView(), false)
+		case had:
+			msg = socket.NewHandoutMessage(item.Id, model.Handout{Id: before.Id, SessionId: item.Id}, true)
+		default:
+			continue
+		}
+
+		if err := h.hub.PublishUser(item.Id, player, &msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// ListHandouts returns every handout to the referee and the ones shared with them to the players
+func (h *Handler) ListHandouts(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	handouts, err := model.GetSessionHandouts(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load handouts")
+	}
+
+	if item.Admin == user {
+		return c.JSON(http.StatusOK, handouts)
+	}
+
+	return c.JSON(http.StatusOK, model.SharedHandouts(handouts, user))
+}
+
+func (h *Handler) GetHandout(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	handout, err := h.sessionHandout(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	if item.Admin != user {
+		return c.JSON(http.StatusOK, handout.PlayerView())
+	}
+
+	return c.JSON(http.StatusOK, handout)
+}
+
+// HandoutImage sends the image of an image handout to the referee or a player it was shared with
+func (h *Handler) HandoutImage(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	handout, err := h.sessionHandout(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	if handout.Kind != model.HandoutKind_Image {
+		return c.String(http.StatusNotFound, "Handout has no image")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, handout.ContentType)
+	return c.File(filepath.Join(handoutDir, handout.File))
+}
+
+// CreateHandout creates a text handout, or an image handout when a file is uploaded with it.
+// New handouts are not shared with anyone.
+func (h *Handler) CreateHandout(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData handoutForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Title == "" {
+		return c.String(http.StatusBadRequest, "handout title is required")
+	}
+
+	handout := model.Handout{
+		Id:         cuid.New(),
+		SessionId:  item.Id,
+		Title:      formData.Title,
+		Kind:       model.HandoutKind_Text,
+		SharedWith: []string{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	if formData.Body != nil {
+		handout.Body = *formData.Body
+	}
+
+	if upload, err := c.FormFile("file"); err == nil {
+		if upload.Size > maxHandoutSize {
+			return c.String(http.StatusRequestEntityTooLarge, "handout images can be at most 10MB")
+		}
+
+		file, err := upload.Open()
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to read handout image")
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxHandoutSize+1))
+		if err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to read handout image")
+		}
+
+		contentType := http.DetectContentType(data)
+		ext, ok := handoutImageTypes[contentType]
+		if !ok {
+			return c.String(http.StatusBadRequest, "handout images must be png, jpeg, gif or webp")
+		}
+
+		if err := os.MkdirAll(handoutDir, 0o755); err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to save handout image")
+		}
+
+		handout.Kind = model.HandoutKind_Image
+		handout.File = handout.Id + ext
+		handout.ContentType = contentType
+		if err := os.WriteFile(filepath.Join(handoutDir, handout.File), data, 0o644); err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to save handout image")
+		}
+	} else if formData.Body == nil {
+		return c.String(http.StatusBadRequest, "handout needs a body or an image")
+	}
+
+	if err := handout.Insert(h.db); err != nil {
+		log.Println(err)
+		if handout.File != "" {
+			os.Remove(filepath.Join(handoutDir, handout.File))
+		}
+		return c.String(http.StatusInternalServerError, "Failed to create handout")
+	}
+
+	return c.JSON(http.StatusCreated, handout)
+}
+
+func (h *Handler) UpdateHandout(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	handout, err := h.sessionHandout(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	var formData handoutForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Title != "" {
+		handout.Title = formData.Title
+	}
+	if formData.Body != nil {
+		handout.Body = *formData.Body
+	}
+
+	if err := handout.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update handout")
+	}
+
+	h.publishHandout(item, handout, handout)
+
+	return c.JSON(http.StatusOK, handout)
+}
+
+// ShareHandout sets who the handout is shared with, everyone or a list of players.
+// Players who gain the handout are sent it and those who lose it are told to drop it.
+func (h *Handler) ShareHandout(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	handout, err := h.sessionHandout(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	var formData shareForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	players := []string{}
+	for _, player := range formData.Players {
+		if !slices.Contains(item.Players, player) {
+			return c.String(http.StatusBadRequest, "handouts can only be shared with players of the session")
+		}
+		if !slices.Contains(players, player) {
+			players = append(players, player)
+		}
+	}
+
+	before := *handout
+	handout.SharedAll = formData.All
+	handout.SharedWith = players
+
+	if err := handout.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to share handout")
+	}
+
+	h.publishHandout(item, &before, handout)
+
+	return c.JSON(http.StatusOK, handout)
+}
+
+func (h *Handler) DeleteHandout(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	handout, err := h.sessionHandout(c, item, user)
+	if err != nil {
+		return err
+	}
+
+	if err := handout.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete handout")
+	}
+
+	if handout.File != "" {
+		if err := os.Remove(filepath.Join(handoutDir, handout.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println(err)
+		}
+	}
+
+	h.publishHandout(item, handout, nil)
+
+	return c.NoContent(http.StatusNoContent)
+}
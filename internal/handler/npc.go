@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/npc"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+// npcForm creates or updates an NPC, fields left out of an update are kept
+type npcForm struct {
+	Name            string                 `json:"name" form:"name"`
+	Species         *string                `json:"species" form:"species"`
+	Career          *string                `json:"career" form:"career"`
+	Assignment      *string                `json:"assignment" form:"assignment"`
+	Rank            *string                `json:"rank" form:"rank"`
+	Age             *int                   `json:"age" form:"age"`
+	Description     *string                `json:"description" form:"description"`
+	Notes           *string                `json:"notes" form:"notes"`
+	Characteristics *model.Characteristics `json:"characteristics" form:"characteristics"`
+	Skills          map[string]int         `json:"skills" form:"skills"`
+	Visible         *bool                  `json:"visible" form:"visible"`
+	Reveal          *model.NPCReveal       `json:"reveal" form:"reveal"`
+}
+
+type generateNPCForm struct {
+	Species string `json:"species" form:"species"`
+	Career  string `json:"career" form:"career"`
+	// store the NPC rather than only returning it
+	Save    bool `json:"save" form:"save"`
+	Visible bool `json:"visible" form:"visible"`
+}
+
+type relationshipForm struct {
+	Kind        string `json:"kind" form:"kind"`
+	Description string `json:"description" form:"description"`
+}
+
+// apply copies the set fields of the form onto the NPC
+func (f *npcForm) apply(item *model.NPC) {
+	if f.Name != "" {
+		item.Name = f.Name
+	}
+	for _, field := range []struct {
+		value *string
+		to    *string
+	}{{f.Species, &item.Species}, {f.Career, &item.Career}, {f.Assignment, &item.Assignment}, {f.Rank, &item.Rank}, {f.Description, &item.Description}, {f.Notes, &item.Notes}} {
+		if field.value != nil {
+			*field.to = *field.value
+		}
+	}
+	if f.Age != nil {
+		item.Age = max(*f.Age, 0)
+	}
+	if f.Characteristics != nil {
+		item.Characteristics = f.Characteristics
+	}
+	if f.Skills != nil {
+		item.Skills = f.Skills
+	}
+	if f.Visible != nil {
+		item.Visible = *f.Visible
+	}
+	if f.Reveal != nil {
+		item.Reveal = *f.Reveal
+	}
+}
+
+// sessionNPC loads the NPC named by the npcId path param and checks it belongs to the session
+func (h *Handler) sessionNPC(c echo.Context, sessionId string) (*model.NPC, error) {
+	var item model.NPC
+	err := item.GetNPC(h.db, c.Param("npcId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No NPC found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load NPC")
+	}
+
+	if item.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No NPC found")
+	}
+
+	return &item, nil
+}
+
+// publishNPCs tells the players to refresh their NPC list, the message carries no NPC so nothing hidden can leak
+func (h *Handler) publishNPCs(sessionId string) {
+	msg := socket.NewNPCsMessage(sessionId)
+	if err := h.hub.Publish(sessionId, &msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// ListNPCs returns every NPC to the referee and the visible ones as the players know them to everyone else.
+// ?characterId= narrows the list to the NPCs a character has a relationship with.
+func (h *Handler) ListNPCs(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	admin := item.Admin == user
+	npcs, err := model.GetSessionNPCs(h.db, item.Id, admin)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load NPCs")
+	}
+
+	characterId := c.QueryParam("characterId")
+	result := []model.NPC{}
+	for _, npc := range npcs {
+		if characterId != "" {
+			known := false
+			for _, relationship := range npc.Relationships {
+				if relationship.CharacterId == characterId {
+					known = true
+					break
+				}
+			}
+			if !known {
+				continue
+			}
+		}
+		if !admin {
+			npc = npc.PlayerView()
+		}
+		result = append(result, npc)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetNPC(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	npc, err := h.sessionNPC(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if item.Admin != user {
+		if !npc.Visible {
+			return c.String(http.StatusNotFound, "No NPC found")
+		}
+		return c.JSON(http.StatusOK, npc.PlayerView())
+	}
+
+	return c.JSON(http.StatusOK, npc)
+}
+
+func (h *Handler) CreateNPC(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData npcForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "NPC name is required")
+	}
+
+	npc := model.NPC{
+		Id:            cuid.New(),
+		SessionId:     item.Id,
+		Skills:        map[string]int{},
+		Relationships: []model.Relationship{},
+		CreatedAt:     time.Now().UTC(),
+	}
+	formData.apply(&npc)
+
+	if err := npc.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create NPC")
+	}
+
+	if npc.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.JSON(http.StatusCreated, npc)
+}
+
+// GenerateNPC rolls a quick NPC from the species, careers and skills tables
+func (h *Handler) GenerateNPC(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData generateNPCForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	generated, err := h.npcs.Generate(h.dice, npc.Options{Species: formData.Species, Career: formData.Career})
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	generated.Id = cuid.New()
+	generated.SessionId = item.Id
+	generated.Visible = formData.Visible
+	generated.Relationships = []model.Relationship{}
+	generated.CreatedAt = time.Now().UTC()
+
+	if !formData.Save {
+		return c.JSON(http.StatusOK, generated)
+	}
+
+	if err := generated.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create NPC")
+	}
+
+	if generated.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.JSON(http.StatusCreated, generated)
+}
+
+func (h *Handler) UpdateNPC(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	npc, err := h.sessionNPC(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData npcForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	wasVisible := npc.Visible
+	formData.apply(npc)
+
+	if err := npc.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update NPC")
+	}
+
+	if wasVisible || npc.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.JSON(http.StatusOK, npc)
+}
+
+func (h *Handler) DeleteNPC(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	npc, err := h.sessionNPC(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := npc.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete NPC")
+	}
+
+	if npc.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetRelationship makes the NPC a patron, ally, contact, rival or enemy of a character
+func (h *Handler) SetRelationship(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	npc, err := h.sessionNPC(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	character, err := h.sessionCharacter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData relationshipForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if !model.ValidRelationship(formData.Kind) {
+		return c.String(http.StatusBadRequest, "relationship must be patron, ally, contact, rival or enemy")
+	}
+
+	relationship := model.Relationship{NPCId: npc.Id, CharacterId: character.Id, Kind: formData.Kind, Description: formData.Description}
+	if err := npc.SetRelationship(h.db, relationship); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to set relationship")
+	}
+
+	if npc.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.JSON(http.StatusOK, relationship)
+}
+
+func (h *Handler) RemoveRelationship(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	npc, err := h.sessionNPC(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	removed, err := npc.RemoveRelationship(h.db, c.Param("characterId"))
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to remove relationship")
+	}
+	if !removed {
+		return c.String(http.StatusNotFound, "No relationship found")
+	}
+
+	if npc.Visible {
+		h.publishNPCs(item.Id)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListNPCTables returns the species and careers the generator can be pinned to
+func (h *Handler) ListNPCTables(c echo.Context) error {
+	if _, _, err := h.sessionAdmin(c); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"species": h.npcs.Species,
+		"careers": h.npcs.Careers,
+	})
+}
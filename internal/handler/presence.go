@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+type sessionPlayer struct {
+	Id       string
+	Username string
+	Admin    bool
+	Online   bool
+}
+
+// OnlineUsers returns the users currently connected to the session
+func (h *Handler) OnlineUsers(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, h.hub.Online(item.Id))
+}
+
+// SessionPlayers renders the player list of the session with each players online status
+func (h *Handler) SessionPlayers(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ids := append([]string{item.Admin}, item.Players...)
+	names, err := model.GetUsernames(h.db, ids)
+	if err != nil {
+		log.Println(err)
+		return c.HTML(http.StatusInternalServerError, "<span>Failed to load players</span>")
+	}
+
+	online := map[string]bool{}
+	for _, user := range h.hub.Online(item.Id) {
+		online[user.UserId] = true
+	}
+
+	players := make([]sessionPlayer, 0, len(ids))
+	for _, id := range ids {
+		players = append(players, sessionPlayer{Id: id, Username: names[id], Admin: id == item.Admin, Online: online[id]})
+	}
+
+	return c.Render(http.StatusOK, "session_players.html", map[string]interface{}{
+		"players": players,
+		"empty":   len(item.Players) == 0,
+	})
+}
@@ -0,0 +1,450 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/route"
+	"visualsource/traveller/internal/sectorfile"
+	"visualsource/traveller/internal/worldgen"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type sectorForm struct {
+	Name string `json:"name" form:"name"`
+	// "sector" for a full 32x40 sector or "subsector" for 8x10
+	Size    string              `json:"size" form:"size"`
+	Density model.SectorDensity `json:"density" form:"density"`
+	// optional seed so the same map can be generated again
+	Seed *int64 `json:"seed" form:"seed"`
+}
+
+type sectorHex struct {
+	Hex       string      `json:"hex"`
+	Column    int         `json:"column"`
+	Row       int         `json:"row"`
+	Subsector string      `json:"subsector"`
+	World     model.World `json:"world"`
+}
+
+// knownHex is an explored hex as the players see it, World is nil when they found nothing there
+type knownHex struct {
+	Hex       string            `json:"hex"`
+	Column    int               `json:"column"`
+	Row       int               `json:"row"`
+	Subsector string            `json:"subsector"`
+	World     *model.KnownWorld `json:"world"`
+}
+
+// sessionSector loads the sector named by the sectorId path param and checks it belongs to the session
+func (h *Handler) sessionSector(c echo.Context, sessionId string) (*model.Sector, error) {
+	var sector model.Sector
+	err := sector.GetSector(h.db, c.Param("sectorId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No sector found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load sector")
+	}
+
+	if sector.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No sector found")
+	}
+
+	return &sector, nil
+}
+
+func (h *Handler) ListSectors(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	sectors, err := model.GetSessionSectors(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load sectors")
+	}
+
+	// the seed would let players generate the whole map again
+	if item.Admin != user {
+		for i := range sectors {
+			sectors[i].Seed = 0
+		}
+	}
+
+	return c.JSON(http.StatusOK, sectors)
+}
+
+func (h *Handler) GetSector(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	sector, err := h.sessionSector(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if item.Admin != user {
+		sector.Seed = 0
+	}
+
+	return c.JSON(http.StatusOK, sector)
+}
+
+// GenerateSector rolls a new sector or subsector and saves it with its worlds
+func (h *Handler) GenerateSector(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData sectorForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "sector name is required")
+	}
+
+	sector := model.Sector{Id: cuid.New(), SessionId: item.Id, Name: formData.Name, Density: formData.Density}
+	switch formData.Size {
+	case "", "sector":
+		sector.Width, sector.Height = model.SectorWidth, model.SectorHeight
+	case "subsector":
+		sector.Width, sector.Height = model.SubsectorWidth, model.SubsectorHeight
+	default:
+		return c.String(http.StatusBadRequest, "size must be sector or subsector")
+	}
+
+	if sector.Density == "" {
+		sector.Density = model.SectorDensity_Standard
+	}
+	if _, _, ok := sector.Density.Presence(); !ok {
+		return c.String(http.StatusBadRequest, "unknown density")
+	}
+
+	sector.Seed = time.Now().UnixNano()
+	if formData.Seed != nil {
+		sector.Seed = *formData.Seed
+	}
+
+	worlds := worldgen.Sector(dice.Seeded(sector.Seed), sector.Width, sector.Height, sector.Density)
+	for i := range worlds {
+		worlds[i].Id = cuid.New()
+	}
+
+	if err := sector.Insert(h.db, worlds); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create sector")
+	}
+
+	return c.JSON(http.StatusCreated, sector)
+}
+
+func (h *Handler) DeleteSector(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	sector, err := h.sessionSector(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := sector.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete sector")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetSectorHexes returns the occupied hexes of a sector for the galaxy view,
+// pass ?subsector=A to only get the hexes of one subsector. Players get the hexes
+// they have explored with the worlds as they know them.
+func (h *Handler) GetSectorHexes(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	sector, err := h.sessionSector(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	worlds, err := model.GetSectorWorlds(h.db, sector.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load hexes")
+	}
+
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+
+	subsector := c.QueryParam("subsector")
+	if knowledge != nil {
+		sector.Seed = 0
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"sector": sector,
+			"hexes":  knownHexes(sector, worlds, knowledge, subsector),
+		})
+	}
+
+	hexes := make([]sectorHex, 0, len(worlds))
+	for _, world := range worlds {
+		column, row, err := model.ParseHex(world.Hex)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		hex := sectorHex{Hex: world.Hex, Column: column, Row: row, Subsector: model.Subsector(column, row), World: world}
+		if subsector != "" && hex.Subsector != subsector {
+			continue
+		}
+		hexes = append(hexes, hex)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"sector": sector,
+		"hexes":  hexes,
+	})
+}
+
+// knownHexes returns the explored hexes of the sector and the worlds the player knows of,
+// worlds can be known without their hex having been explored
+func knownHexes(sector *model.Sector, worlds []model.World, knowledge *model.Knowledge, subsector string) []knownHex {
+	byHex := map[string]*model.World{}
+	for i := range worlds {
+		byHex[worlds[i].Hex] = &worlds[i]
+	}
+
+	hexes := []knownHex{}
+	for column := 1; column <= sector.Width; column++ {
+		for row := 1; row <= sector.Height; row++ {
+			if subsector != "" && model.Subsector(column, row) != subsector {
+				continue
+			}
+
+			hex := knownHex{Hex: model.Hex(column, row), Column: column, Row: row, Subsector: model.Subsector(column, row)}
+			world, occupied := byHex[hex.Hex]
+			switch {
+			case occupied && knowledge.Knows(world):
+				view := knowledge.View(*world)
+				hex.World = &view
+			case !knowledge.Explored(sector.Id, hex.Hex):
+				continue
+			}
+			hexes = append(hexes, hex)
+		}
+	}
+
+	return hexes
+}
+
+// ImportSector creates a sector from an uploaded SEC or T5 file
+func (h *Handler) ImportSector(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	format := sectorfile.Format(c.FormValue("format"))
+	if format != sectorfile.Format_SEC && format != sectorfile.Format_T5 {
+		return c.String(http.StatusBadRequest, "format must be sec or t5")
+	}
+
+	upload, err := c.FormFile("file")
+	if err != nil {
+		return c.String(http.StatusBadRequest, "missing sector file")
+	}
+	file, err := upload.Open()
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to read sector file")
+	}
+	defer file.Close()
+
+	worlds, err := sectorfile.Parse(format, file)
+	if err != nil {
+		var errs sectorfile.ParseErrors
+		if errors.As(err, &errs) {
+			return c.JSON(http.StatusBadRequest, errs)
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to read sector file")
+	}
+
+	name := c.FormValue("name")
+	if name == "" {
+		name = strings.TrimSuffix(upload.Filename, filepath.Ext(upload.Filename))
+	}
+
+	sector := model.Sector{Id: cuid.New(), SessionId: item.Id, Name: name, Width: model.SectorWidth, Height: model.SectorHeight}
+	for i := range worlds {
+		worlds[i].Id = cuid.New()
+	}
+
+	if err := sector.Insert(h.db, worlds); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create sector")
+	}
+
+	return c.JSON(http.StatusCreated, sector)
+}
+
+// ExportSector downloads the worlds of a sector as a SEC or T5 file. Only the referee can export
+// as the file holds every world of the sector.
+func (h *Handler) ExportSector(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	sector, err := h.sessionSector(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	format := sectorfile.Format(c.QueryParam("format"))
+	if format == "" {
+		format = sectorfile.Format_T5
+	}
+	if format != sectorfile.Format_SEC && format != sectorfile.Format_T5 {
+		return c.String(http.StatusBadRequest, "format must be sec or t5")
+	}
+
+	worlds, err := model.GetSectorWorlds(h.db, sector.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load sector")
+	}
+
+	var buf bytes.Buffer
+	if err := sectorfile.Write(format, &buf, worlds); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to export sector")
+	}
+
+	ext := ".sec"
+	if format == sectorfile.Format_T5 {
+		ext = ".tab"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", sector.Name+ext))
+	return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, buf.Bytes())
+}
+
+// routeShip reads the drive and tanks for a route, either from a ship of the
+// session or from the jump, fuel and hull query params.
+func (h *Handler) routeShip(c echo.Context, sessionId string) (route.Ship, error) {
+	if c.QueryParam("shipId") != "" {
+		var ship model.Ship
+		if err := ship.GetShip(h.db, c.QueryParam("shipId")); err != nil || ship.SessionId != sessionId {
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Println(err)
+			}
+			return route.Ship{}, echo.NewHTTPError(http.StatusNotFound, "No ship found")
+		}
+		report := h.shipyard.Calculate(ship.Design)
+		return route.Ship{Jump: ship.Design.JumpDrive.Rating, FuelCapacity: ship.Design.FuelTons, FuelPerParsec: report.JumpFuelPerParsec}, nil
+	}
+
+	jump, err := strconv.Atoi(c.QueryParam("jump"))
+	if err != nil || jump <= 0 {
+		return route.Ship{}, echo.NewHTTPError(http.StatusBadRequest, "jump rating is required when no ship is given")
+	}
+	ship := route.Ship{Jump: jump}
+
+	// without fuel details the ship can always make its full jump
+	if value := c.QueryParam("fuel"); value != "" {
+		hull, err := strconv.ParseFloat(c.QueryParam("hull"), 64)
+		if err != nil || hull <= 0 {
+			return route.Ship{}, echo.NewHTTPError(http.StatusBadRequest, "hull tonnage is required with fuel")
+		}
+		if ship.FuelCapacity, err = strconv.ParseFloat(value, 64); err != nil || ship.FuelCapacity < 0 {
+			return route.Ship{}, echo.NewHTTPError(http.StatusBadRequest, "fuel must be a number of tons")
+		}
+		ship.FuelPerParsec = hull * h.shipyard.JumpDrive.FuelPercentPerParsec / 100
+	}
+
+	return ship, nil
+}
+
+// PlanRoute finds the shortest jump route between two hexes of a sector,
+// by fewest jumps or by time including the days spent refuelling.
+// Players can only route through the worlds they know of, using what they know about them.
+func (h *Handler) PlanRoute(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	sector, err := h.sessionSector(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	from, to := c.QueryParam("from"), c.QueryParam("to")
+	if !sector.Contains(from) || !sector.Contains(to) {
+		return c.String(http.StatusBadRequest, "from and to must be hexes of the sector")
+	}
+
+	ship, err := h.routeShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	mode := route.Mode(c.QueryParam("mode"))
+	if mode != "" && !mode.Valid() {
+		return c.String(http.StatusBadRequest, "mode must be jumps or time")
+	}
+
+	worlds, err := model.GetSectorWorlds(h.db, sector.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load sector")
+	}
+
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+	if knowledge != nil {
+		known := []model.World{}
+		for _, world := range worlds {
+			if knowledge.Knows(&world) {
+				known = append(known, knowledge.Mask(world))
+			}
+		}
+		worlds = known
+	}
+
+	result, err := route.Plan(worlds, from, to, route.Options{Ship: ship, Mode: mode, AvoidRed: c.QueryParam("avoidRed") == "true"})
+	if err != nil {
+		if errors.Is(err, route.ErrNoRoute) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
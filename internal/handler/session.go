@@ -66,7 +66,8 @@ func (h *Handler) View(c echo.Context) error {
 
 	if session.Admin == user {
 		return c.Render(http.StatusOK, "session_cmd.html", map[string]interface{}{
-			"user": user,
+			"user":    user,
+			"session": session.Id,
 		})
 	}
 
@@ -122,3 +123,43 @@ func (h *Handler) CreateSession(c echo.Context) error {
 
 	return c.String(http.StatusCreated, "Created")
 }
+
+// sessionMember loads the session named by the sessionId path param and
+// checks that the current user is its admin or one of its players.
+func (h *Handler) sessionMember(c echo.Context) (*model.Session, string, error) {
+	sess, err := session.Get(SESSION_NAME, c)
+	if err != nil {
+		return nil, "", echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	user, ok := sess.Values[Session_ID].(string)
+	if !ok {
+		return nil, "", echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var item model.Session
+	err = item.GetSession(h.db, c.Param("sessionId"))
+	if err != nil {
+		return nil, "", echo.NewHTTPError(http.StatusNotFound, "No session found")
+	}
+
+	if item.Admin != user && !slices.Contains(item.Players, user) {
+		return nil, "", echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	return &item, user, nil
+}
+
+// sessionAdmin is sessionMember for end points only the sessions referee may use
+func (h *Handler) sessionAdmin(c echo.Context) (*model.Session, string, error) {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if item.Admin != user {
+		return nil, "", echo.NewHTTPError(http.StatusForbidden, "Only the session admin can do this")
+	}
+
+	return item, user, nil
+}
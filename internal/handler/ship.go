@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"visualsource/traveller/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type shipForm struct {
+	Name   string           `json:"name"`
+	Design model.ShipDesign `json:"design"`
+}
+
+type shipCrewForm struct {
+	CharacterId string `json:"characterId"`
+	Role        string `json:"role"`
+}
+
+// sessionShip loads the ship named by the shipId path param and checks it belongs to the session
+func (h *Handler) sessionShip(c echo.Context, sessionId string) (*model.Ship, error) {
+	var ship model.Ship
+	err := ship.GetShip(h.db, c.Param("shipId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No ship found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load ship")
+	}
+
+	if ship.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No ship found")
+	}
+
+	return &ship, nil
+}
+
+func (h *Handler) ListShips(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ships, err := model.GetSessionShips(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load ships")
+	}
+
+	return c.JSON(http.StatusOK, ships)
+}
+
+func (h *Handler) GetShip(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ship)
+}
+
+func (h *Handler) CreateShip(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData shipForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "ship name is required")
+	}
+
+	if report := h.shipyard.Calculate(formData.Design); !report.Valid() {
+		return c.JSON(http.StatusBadRequest, report)
+	}
+
+	ship := model.Ship{Id: cuid.New(), Name: formData.Name, SessionId: item.Id, Design: formData.Design, Crew: []model.CrewMember{}}
+	if err := ship.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create ship")
+	}
+
+	return c.JSON(http.StatusCreated, ship)
+}
+
+func (h *Handler) UpdateShip(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData shipForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name != "" {
+		ship.Name = formData.Name
+	}
+
+	if report := h.shipyard.Calculate(formData.Design); !report.Valid() {
+		return c.JSON(http.StatusBadRequest, report)
+	}
+	ship.Design = formData.Design
+
+	if err := ship.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update ship")
+	}
+
+	return c.JSON(http.StatusOK, ship)
+}
+
+// CalculateShipDesign works out the cost, power and crew of a design without saving it
+func (h *Handler) CalculateShipDesign(c echo.Context) error {
+	if _, _, err := h.sessionMember(c); err != nil {
+		return err
+	}
+
+	var design model.ShipDesign
+	if err := c.Bind(&design); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	return c.JSON(http.StatusOK, h.shipyard.Calculate(design))
+}
+
+func (h *Handler) GetShipDesign(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, h.shipyard.Calculate(ship.Design))
+}
+
+func (h *Handler) DeleteShip(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := ship.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete ship")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) SetShipCrew(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData shipCrewForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	var character model.Character
+	if err := character.GetCharacter(h.db, formData.CharacterId); err != nil || character.SessionId != item.Id {
+		return c.String(http.StatusBadRequest, "character is not part of this session")
+	}
+
+	if formData.Role == "" {
+		return c.String(http.StatusBadRequest, "crew role is required")
+	}
+
+	if err := ship.SetCrew(h.db, character.Id, formData.Role); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update crew")
+	}
+
+	return c.JSON(http.StatusOK, ship)
+}
+
+func (h *Handler) RemoveShipCrew(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := ship.RemoveCrew(h.db, c.Param("characterId")); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update crew")
+	}
+
+	return c.JSON(http.StatusOK, ship)
+}
@@ -0,0 +1,593 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"visualsource/traveller/internal/combat"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/socket"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type spaceShipForm struct {
+	ShipId string `json:"shipId" form:"shipId"`
+	// skill levels keyed by crew role, taken from the characters crewing the ship when empty
+	Crew map[string]int `json:"crew" form:"crew"`
+	// range from the ships already in the encounter
+	Band string `json:"band" form:"band"`
+}
+
+type spaceRangeForm struct {
+	A    string `json:"a" form:"a"`
+	B    string `json:"b" form:"b"`
+	Band string `json:"band" form:"band"`
+}
+
+// spaceActionForm is shared by the actions a ship takes, each uses the fields it needs
+type spaceActionForm struct {
+	ShipId   string `json:"shipId" form:"shipId"`
+	TargetId string `json:"targetId" form:"targetId"`
+	// move towards the target rather than away
+	Closer   bool   `json:"closer" form:"closer"`
+	Thrust   int    `json:"thrust" form:"thrust"`
+	Weapon   int    `json:"weapon" form:"weapon"`
+	Location string `json:"location" form:"location"`
+	DM       int    `json:"dm" form:"dm"`
+	// DM of the crew fighting off boarders
+	DefenderDM int `json:"defenderDm" form:"defenderDm"`
+}
+
+// sessionSpaceEncounter loads the space encounter named by the encounterId path param and checks it belongs to the session
+func (h *Handler) sessionSpaceEncounter(c echo.Context, sessionId string) (*model.SpaceEncounter, error) {
+	var encounter model.SpaceEncounter
+	err := encounter.GetSpaceEncounter(h.db, c.Param("encounterId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No space encounter found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load space encounter")
+	}
+
+	if encounter.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No space encounter found")
+	}
+
+	return &encounter, nil
+}
+
+// saveSpaceEncounter stores the encounter and sends its new state to everyone in the session
+func (h *Handler) saveSpaceEncounter(c echo.Context, encounter *model.SpaceEncounter) error {
+	if err := encounter.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update space encounter")
+	}
+
+	msg := socket.NewSpaceCombatMessage(encounter.SessionId, *encounter)
+	if err := h.hub.Publish(encounter.SessionId, &msg); err != nil {
+		log.Println(err)
+	}
+
+	return c.JSON(http.StatusOK, encounter)
+}
+
+// shipCrewSkills works out the skill of each crew role from the characters crewing the ship,
+// the best character in a role is the one at the station
+func (h *Handler) shipCrewSkills(ship *model.Ship) (map[string]int, error) {
+	skills := map[string]int{}
+	for _, member := range ship.Crew {
+		role := strings.ToLower(strings.TrimSpace(member.Role))
+		skill, ok := combat.CrewSkill(role)
+		if !ok {
+			continue
+		}
+
+		var character model.Character
+		if err := character.GetCharacter(h.db, member.CharacterId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+
+		stats, err := h.characterStats(&character)
+		if err != nil {
+			return nil, err
+		}
+
+		level := unskilled
+		if stat, ok := stats.Skills[skill]; ok {
+			level = stat.Effective
+		}
+		if current, ok := skills[role]; !ok || level > current {
+			skills[role] = level
+		}
+	}
+	return skills, nil
+}
+
+// aboard checks the user is the referee or plays a character crewing the ship
+func (h *Handler) aboard(item *model.Session, user string, combatant *model.SpaceCombatant) error {
+	if item.Admin == user {
+		return nil
+	}
+
+	var ship model.Ship
+	if err := ship.GetShip(h.db, combatant.ShipId); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load ship")
+		}
+		return echo.NewHTTPError(http.StatusForbidden, "Only the crew of the ship can do this")
+	}
+
+	for _, member := range ship.Crew {
+		var character model.Character
+		if err := character.GetCharacter(h.db, member.CharacterId); err == nil && character.Owner == user {
+			return nil
+		}
+	}
+
+	return echo.NewHTTPError(http.StatusForbidden, "Only the crew of the ship can do this")
+}
+
+// spaceAction binds the form of a ship action and loads the running encounter, the acting ship and
+// the target when one is given
+func (h *Handler) spaceAction(c echo.Context, formData *spaceActionForm) (*model.SpaceEncounter, *model.SpaceCombatant, *model.SpaceCombatant, error) {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := c.Bind(formData); err != nil {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "missing form fields")
+	}
+
+	if encounter.Status != model.EncounterStatus_Active {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusConflict, "space encounter is not running")
+	}
+
+	ship := encounter.Ship(formData.ShipId)
+	if ship == nil {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusNotFound, "No ship found")
+	}
+	if ship.Status != model.SpaceCombatantStatus_Active {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusConflict, "ship can not act")
+	}
+	if err := h.aboard(item, user, ship); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var target *model.SpaceCombatant
+	if formData.TargetId != "" {
+		if target = encounter.Ship(formData.TargetId); target == nil || target.Id == ship.Id {
+			return nil, nil, nil, echo.NewHTTPError(http.StatusNotFound, "No target found")
+		}
+		if target.Status == model.SpaceCombatantStatus_Destroyed {
+			return nil, nil, nil, echo.NewHTTPError(http.StatusConflict, "target is destroyed")
+		}
+	}
+
+	return encounter, ship, target, nil
+}
+
+// spaceError turns a rule the action broke into a response
+func spaceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, combat.ErrNoThrust), errors.Is(err, combat.ErrWeaponUsed), errors.Is(err, combat.ErrWeaponDisabled):
+		return c.String(http.StatusConflict, err.Error())
+	default:
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+}
+
+func (h *Handler) ListSpaceEncounters(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounters, err := model.GetSessionSpaceEncounters(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load space encounters")
+	}
+
+	return c.JSON(http.StatusOK, encounters)
+}
+
+func (h *Handler) GetSpaceEncounter(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"encounter":  encounter,
+		"rangeBands": combat.RangeBands,
+	})
+}
+
+func (h *Handler) CreateSpaceEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData encounterForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "encounter name is required")
+	}
+
+	encounter := model.SpaceEncounter{
+		Id:        cuid.New(),
+		SessionId: item.Id,
+		Name:      formData.Name,
+		Status:    model.EncounterStatus_Setup,
+		Ships:     []model.SpaceCombatant{},
+		Ranges:    []model.SpaceRange{},
+		Log:       []model.CombatLogEntry{},
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := encounter.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create space encounter")
+	}
+
+	return c.JSON(http.StatusCreated, encounter)
+}
+
+func (h *Handler) DeleteSpaceEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := encounter.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete space encounter")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddSpaceShip brings a ship of the session into the encounter with its hull, armour, thrust and weapons
+func (h *Handler) AddSpaceShip(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status == model.EncounterStatus_Ended {
+		return c.String(http.StatusConflict, "space encounter has ended")
+	}
+
+	var formData spaceShipForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	var ship model.Ship
+	if err := ship.GetShip(h.db, formData.ShipId); err != nil || ship.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load ship")
+		}
+		return c.String(http.StatusNotFound, "No ship found")
+	}
+	for _, existing := range encounter.Ships {
+		if existing.ShipId == ship.Id {
+			return c.String(http.StatusConflict, "ship is already in the encounter")
+		}
+	}
+
+	crew := formData.Crew
+	if crew == nil {
+		if crew, err = h.shipCrewSkills(&ship); err != nil {
+			log.Println(err)
+			return c.String(http.StatusInternalServerError, "Failed to load ship crew")
+		}
+	}
+	for role := range crew {
+		if _, ok := combat.CrewSkill(role); !ok {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("unknown crew role '%s'", role))
+		}
+	}
+
+	if formData.Band == "" {
+		formData.Band = combat.StartBand
+	}
+
+	combatant := combat.NewShip(h.shipyard, ship, cuid.New(), crew)
+	if err := combat.JoinSpace(h.dice, encounter, combatant, formData.Band); err != nil {
+		return spaceError(c, err)
+	}
+	encounter.Logf("%s joins at %s range", combatant.Name, formData.Band)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+func (h *Handler) RemoveSpaceShip(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	ship := encounter.Ship(c.Param("combatantId"))
+	if ship == nil {
+		return c.String(http.StatusNotFound, "No ship found")
+	}
+
+	encounter.Logf("%s leaves the fight", ship.Name)
+	combat.RemoveSpace(encounter, ship.Id)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// SetSpaceRange lets the referee place two ships at a range band
+func (h *Handler) SetSpaceRange(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData spaceRangeForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	a, b := encounter.Ship(formData.A), encounter.Ship(formData.B)
+	if a == nil || b == nil || a.Id == b.Id {
+		return c.String(http.StatusNotFound, "No ship found")
+	}
+
+	if err := combat.SetRange(encounter, a.Id, b.Id, formData.Band); err != nil {
+		return spaceError(c, err)
+	}
+	encounter.Logf("%s and %s are at %s range", a.Name, b.Name, formData.Band)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+func (h *Handler) StartSpaceEncounter(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status != model.EncounterStatus_Setup {
+		return c.String(http.StatusConflict, "space encounter has already started")
+	}
+	if len(encounter.Ships) < 2 {
+		return c.String(http.StatusBadRequest, "space combat needs at least two ships")
+	}
+
+	combat.StartSpace(h.dice, encounter)
+	for _, ship := range encounter.Ships {
+		encounter.Logf("%s rolls %d for initiative", ship.Name, ship.Initiative)
+	}
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// NextSpaceRound ends the round, every ship gets its thrust back and its weapons can fire again
+func (h *Handler) NextSpaceRound(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status != model.EncounterStatus_Active {
+		return c.String(http.StatusConflict, "space encounter is not running")
+	}
+
+	combat.NextRound(encounter)
+	encounter.Logf("Round %d begins", encounter.Round)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+func (h *Handler) EndSpaceEncounter(c echo.Context) error {
+	item, user, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	encounter, err := h.sessionSpaceEncounter(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if encounter.Status == model.EncounterStatus_Ended {
+		return c.String(http.StatusConflict, "space encounter has already ended")
+	}
+
+	encounter.Status = model.EncounterStatus_Ended
+	encounter.Logf("The fight is over")
+
+	// the encounter is only journaled once it has been saved
+	if err := h.saveSpaceEncounter(c, encounter); err != nil || c.Response().Status != http.StatusOK {
+		return err
+	}
+
+	lines := []string{}
+	for _, ship := range encounter.Ships {
+		lines = append(lines, fmt.Sprintf("%s: %s, %d/%d hull", ship.Name, ship.Status, ship.Hull, ship.HullPoints))
+	}
+	h.journal(item, user, "", model.JournalKind_Combat, fmt.Sprintf("%s ended after %d rounds", encounter.Name, encounter.Round), strings.Join(lines, "\n"), encounter.Ships)
+
+	return nil
+}
+
+// Manoeuvre has the pilot spend thrust to close on or pull away from a target
+func (h *Handler) Manoeuvre(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, target, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return c.String(http.StatusBadRequest, "target is required")
+	}
+
+	band, err := combat.Move(encounter, ship, target, formData.Closer)
+	if err != nil {
+		return spaceError(c, err)
+	}
+	encounter.Logf("%s manoeuvres to %s range of %s", ship.Name, band, target.Name)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// Evade has the pilot spend thrust on evasive action
+func (h *Handler) Evade(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, _, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+
+	if err := combat.Evade(ship, formData.Thrust); err != nil {
+		return spaceError(c, err)
+	}
+	encounter.Logf("%s takes evasive action, attacks on it are at -%d", ship.Name, ship.Evasion)
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// SensorLock has the sensor operator try to lock on to a target
+func (h *Handler) SensorLock(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, target, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return c.String(http.StatusBadRequest, "target is required")
+	}
+
+	total, success := combat.Lock(h.dice, ship, target)
+	if success {
+		encounter.Logf("%s locks on to %s (%d)", ship.Name, target.Name, total)
+	} else {
+		encounter.Logf("%s fails to lock on to %s (%d)", ship.Name, target.Name, total)
+	}
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// FireWeapon has a gunner fire one weapon of the ship at a target
+func (h *Handler) FireWeapon(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, target, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return c.String(http.StatusBadRequest, "target is required")
+	}
+
+	attack, err := combat.Fire(h.dice, encounter, ship, formData.Weapon, target, formData.DM)
+	if err != nil {
+		return spaceError(c, err)
+	}
+	encounter.Logf("%s", attack.Describe(ship.Name, target.Name))
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// RepairCritical has the engineer work on the critical damage at a location
+func (h *Handler) RepairCritical(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, _, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+
+	total, success, err := combat.Repair(h.dice, ship, formData.Location)
+	if err != nil {
+		return spaceError(c, err)
+	}
+	if success {
+		encounter.Logf("%s repairs damage to the %s (%d)", ship.Name, formData.Location, total)
+	} else {
+		encounter.Logf("%s fails to repair the %s (%d)", ship.Name, formData.Location, total)
+	}
+
+	return h.saveSpaceEncounter(c, encounter)
+}
+
+// BoardShip sends a boarding party across to an adjacent ship
+func (h *Handler) BoardShip(c echo.Context) error {
+	var formData spaceActionForm
+	encounter, ship, target, err := h.spaceAction(c, &formData)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return c.String(http.StatusBadRequest, "target is required")
+	}
+
+	boarding, err := combat.Board(h.dice, encounter, ship, target, formData.DM, formData.DefenderDM)
+	if err != nil {
+		return spaceError(c, err)
+	}
+	if boarding.Success {
+		encounter.Logf("%s boards and takes %s (%d against %d)", ship.Name, target.Name, boarding.Attacker, boarding.Defender)
+	} else {
+		encounter.Logf("The crew of %s fight off boarders from %s (%d against %d)", target.Name, ship.Name, boarding.Attacker, boarding.Defender)
+	}
+
+	return h.saveSpaceEncounter(c, encounter)
+}
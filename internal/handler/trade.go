@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/trade"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+// Broker and Steward skills range from untrained to the best a character can realistically have
+const (
+	minBroker = -3
+	maxBroker = 6
+)
+
+// skill ids of the Broker and Steward skills on character sheets
+const (
+	brokerSkill  = "broker"
+	stewardSkill = "steward"
+)
+
+type buyCargoForm struct {
+	WorldId string `json:"worldId" form:"worldId"`
+	GoodId  string `json:"goodId" form:"goodId"`
+	Tons    int    `json:"tons" form:"tons"`
+	// character making the deal, their Broker skill is used
+	CharacterId string `json:"characterId" form:"characterId"`
+	// replaces the Broker skill of the character, only the referee can set it
+	Broker *int `json:"broker" form:"broker"`
+}
+
+type sellCargoForm struct {
+	WorldId string `json:"worldId" form:"worldId"`
+	CargoId string `json:"cargoId" form:"cargoId"`
+	// tons to sell, the whole lot when left at zero
+	Tons        int    `json:"tons" form:"tons"`
+	CharacterId string `json:"characterId" form:"characterId"`
+	Broker      *int   `json:"broker" form:"broker"`
+}
+
+// tradeWorld loads a world of the session by id, used where the world is part of the form rather than the path.
+// It does not check the player knows the world, see knownWorld.
+func (h *Handler) tradeWorld(sessionId string, worldId string) (*model.World, error) {
+	var world model.World
+	if err := world.GetWorld(h.db, worldId); err != nil || world.SessionId != sessionId {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load world")
+		}
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No world found")
+	}
+	return &world, nil
+}
+
+// worldMarket returns the market of the world for the current week of the session,
+// rolling a new one the first time it is looked at.
+func (h *Handler) worldMarket(world *model.World) (*model.Market, error) {
+	date, err := model.GetSessionDate(h.db, world.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	var market model.Market
+	err = market.GetMarket(h.db, world.Id, model.MarketWeek(date))
+	if err == nil {
+		return &market, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	market = model.Market{WorldId: world.Id, SessionId: world.SessionId, Week: model.MarketWeek(date), Goods: h.trade.Market(h.dice, *world)}
+	if err := market.Insert(h.db); err != nil {
+		return nil, err
+	}
+	return &market, nil
+}
+
+func validBroker(broker int) bool {
+	return broker >= minBroker && broker <= maxBroker
+}
+
+// traderSkill works out a skill for a deal from the stored skills of the character making it.
+// Only the referee may give the skill directly instead.
+func (h *Handler) traderSkill(item *model.Session, user string, characterId string, skill string, level *int) (int, error) {
+	if level != nil {
+		if item.Admin != user {
+			return 0, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("Only the referee can set the %s skill", skill))
+		}
+		if !validBroker(*level) {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be between %d and %d", skill, minBroker, maxBroker))
+		}
+		return *level, nil
+	}
+
+	if characterId == "" {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "the character making the deal is required")
+	}
+
+	var character model.Character
+	if err := character.GetCharacter(h.db, characterId); err != nil || character.SessionId != item.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+			return 0, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character")
+		}
+		return 0, echo.NewHTTPError(http.StatusNotFound, "No character found")
+	}
+	if character.Owner != user && item.Admin != user {
+		return 0, echo.NewHTTPError(http.StatusForbidden, "Only the owner of the character can do this")
+	}
+
+	stats, err := h.characterStats(&character)
+	if err != nil {
+		log.Println(err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load character stats")
+	}
+
+	value := unskilled
+	if stat, ok := stats.Skills[skill]; ok {
+		value = stat.Effective
+	}
+	return min(max(value, minBroker), maxBroker), nil
+}
+
+// traderBroker works out the Broker skill for a deal, see traderSkill
+func (h *Handler) traderBroker(item *model.Session, user string, characterId string, broker *int) (int, error) {
+	return h.traderSkill(item, user, characterId, brokerSkill, broker)
+}
+
+// ListTradeGoods returns the speculative trade goods table
+func (h *Handler) ListTradeGoods(c echo.Context) error {
+	if _, _, err := h.sessionMember(c); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, h.trade.Goods)
+}
+
+// GetMarket returns the prices of a world this week. Pass ?characterId= to apply the Broker skill of
+// the character trading, the referee can pass ?broker=N instead.
+func (h *Handler) GetMarket(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+	// players see the codes they have been shown of the world
+	codes := trade.Codes(*world)
+	if knowledge != nil {
+		if !knowledge.Knows(world) {
+			return c.String(http.StatusNotFound, "No world found")
+		}
+		view := knowledge.View(*world)
+		codes = trade.ZoneCodes(view.TradeCodes, view.Zone)
+	}
+
+	var override *int
+	if value := c.QueryParam("broker"); value != "" {
+		level, err := strconv.Atoi(value)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("broker must be between %d and %d", minBroker, maxBroker))
+		}
+		override = &level
+	}
+
+	// without a trader the prices are shown before any skill
+	broker := 0
+	if override != nil || c.QueryParam("characterId") != "" {
+		if broker, err = h.traderBroker(item, user, c.QueryParam("characterId"), override); err != nil {
+			return err
+		}
+	}
+
+	market, err := h.worldMarket(world)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load market")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"worldId": world.Id,
+		"week":    market.Week,
+		"codes":   codes,
+		"broker":  broker,
+		"prices":  h.trade.Prices(market, *world, broker),
+	})
+}
+
+// GetCargo returns the cargo lots in the hold of a ship and the space left
+func (h *Handler) GetCargo(c echo.Context) error {
+	item, _, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	cargo, err := model.GetShipCargo(h.db, ship.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load cargo")
+	}
+
+	used, err := model.CargoTons(h.db, ship.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load cargo")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"capacity": ship.Design.CargoTons,
+		"free":     ship.Design.CargoTons - float64(used),
+		"cargo":    cargo,
+	})
+}
+
+// BuyCargo buys speculative goods on a world, loading them into the ship and charging the ships account
+func (h *Handler) BuyCargo(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData buyCargoForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Tons <= 0 {
+		return c.String(http.StatusBadRequest, "tons must be more than zero")
+	}
+	broker, err := h.traderBroker(item, user, formData.CharacterId, formData.Broker)
+	if err != nil {
+		return err
+	}
+
+	good, ok := h.trade.Good(formData.GoodId)
+	if !ok {
+		return c.String(http.StatusBadRequest, "unknown trade good")
+	}
+
+	world, err := h.tradeWorld(item.Id, formData.WorldId)
+	if err != nil {
+		return err
+	}
+	if err := h.knownWorld(item, user, world); err != nil {
+		return err
+	}
+
+	market, err := h.worldMarket(world)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load market")
+	}
+
+	roll := 0
+	for _, offer := range market.Goods {
+		if offer.GoodId == good.Id {
+			roll = offer.PurchaseRoll
+		}
+	}
+	if roll == 0 {
+		return c.String(http.StatusBadRequest, good.Name+" is not sold on "+world.Name)
+	}
+
+	used, err := model.CargoTons(h.db, ship.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load cargo")
+	}
+	if float64(used+formData.Tons) > ship.Design.CargoTons {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("only %g tons of cargo space left", ship.Design.CargoTons-float64(used)))
+	}
+
+	lot := model.CargoLot{
+		Id:            cuid.New(),
+		SessionId:     item.Id,
+		ShipId:        ship.Id,
+		GoodId:        good.Id,
+		Name:          good.Name,
+		Tons:          formData.Tons,
+		PurchasePrice: h.trade.PurchasePrice(good, trade.Codes(*world), roll, broker),
+		WorldId:       world.Id,
+	}
+
+	transaction, err := model.BuyCargo(h.db, market, &lot, fmt.Sprintf("Bought %d tons of %s on %s", lot.Tons, good.Name, world.Name))
+	if err != nil {
+		if errors.Is(err, model.ErrNotEnoughGoods) {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to buy cargo")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{transaction})
+	h.journal(item, user, formData.CharacterId, model.JournalKind_Trade, transaction.Description,
+		fmt.Sprintf("Cr%d per ton aboard %s", lot.PurchasePrice, ship.Name), transaction)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"cargo":       lot,
+		"transaction": transaction,
+	})
+}
+
+// SellCargo sells tons of a cargo lot on a world at this weeks price and credits the ships account
+func (h *Handler) SellCargo(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	ship, err := h.sessionShip(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData sellCargoForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Tons < 0 {
+		return c.String(http.StatusBadRequest, "tons can not be negative")
+	}
+	broker, err := h.traderBroker(item, user, formData.CharacterId, formData.Broker)
+	if err != nil {
+		return err
+	}
+
+	var lot model.CargoLot
+	if err := lot.GetCargoLot(h.db, formData.CargoId); err != nil || lot.ShipId != ship.Id {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+		}
+		return c.String(http.StatusNotFound, "No cargo found")
+	}
+
+	good, ok := h.trade.Good(lot.GoodId)
+	if !ok {
+		return c.String(http.StatusBadRequest, "unknown trade good")
+	}
+
+	world, err := h.tradeWorld(item.Id, formData.WorldId)
+	if err != nil {
+		return err
+	}
+	if err := h.knownWorld(item, user, world); err != nil {
+		return err
+	}
+
+	market, err := h.worldMarket(world)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load market")
+	}
+
+	roll := 0
+	for _, offer := range market.Goods {
+		if offer.GoodId == good.Id {
+			roll = offer.SaleRoll
+		}
+	}
+	if roll == 0 {
+		return c.String(http.StatusBadRequest, "no buyers for "+good.Name+" on "+world.Name)
+	}
+
+	tons := formData.Tons
+	if tons == 0 {
+		tons = lot.Tons
+	}
+	price := h.trade.SalePrice(good, trade.Codes(*world), roll, broker)
+
+	transaction, err := model.SellCargo(h.db, &lot, tons, price, fmt.Sprintf("Sold %d tons of %s on %s", tons, good.Name, world.Name))
+	if err != nil {
+		if errors.Is(err, model.ErrNotEnoughCargo) {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to sell cargo")
+	}
+
+	h.publishFinance(item.Id, []model.Transaction{transaction})
+	h.journal(item, user, formData.CharacterId, model.JournalKind_Trade, transaction.Description,
+		fmt.Sprintf("Cr%d per ton aboard %s, Cr%d profit", price, ship.Name, (price-lot.PurchasePrice)*int64(tons)), transaction)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"cargo":       lot,
+		"pricePerTon": price,
+		"profit":      (price - lot.PurchasePrice) * int64(tons),
+		"transaction": transaction,
+	})
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/trade"
+)
+
+func TestGetMarketShowsPlayersTheCodesTheyKnow(t *testing.T) {
+	h := newTestHandler(t)
+	testSession(t, h, "session", "referee", "alice")
+
+	uwp, err := model.ParseUWP("A788899-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := model.World{Id: "regina", SessionId: "session", Name: "Regina", UWP: uwp, Bases: []string{}, Zone: model.TravelZone_Amber}
+	if err := world.Insert(h.db); err != nil {
+		t.Fatal(err)
+	}
+	all := trade.Codes(world)
+	if len(all) < 2 {
+		t.Fatalf("regina has the codes %v", all)
+	}
+
+	market := func(user string) (int, []string) {
+		t.Helper()
+
+		rec := serve(t, user, http.MethodGet, "/", "", h.GetMarket, "sessionId", "session", "worldId", "regina")
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		var body struct {
+			Codes []string `json:"codes"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body.Codes
+	}
+	know := func(fields ...string) {
+		t.Helper()
+		if err := model.SetWorldKnowledge(h.db, "session", model.WorldKnowledge{WorldId: "regina", UserId: "alice", Fields: fields}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code, codes := market("referee"); code != http.StatusOK || !slices.Equal(codes, all) {
+		t.Fatalf("referee got %d %v, want %v", code, codes, all)
+	}
+	if code, _ := market("alice"); code != http.StatusNotFound {
+		t.Fatalf("a world alice has not found gave %d", code)
+	}
+
+	tests := []struct {
+		fields []string
+		codes  []string
+	}{
+		{[]string{model.WorldField_Starport, model.WorldField_Population}, []string{}},
+		{[]string{model.WorldField_Zone}, []string{"Amber"}},
+		{[]string{model.WorldField_TradeCodes}, all[:len(all)-1]},
+		{[]string{model.WorldField_TradeCodes, model.WorldField_Zone}, all},
+	}
+	for _, test := range tests {
+		know(test.fields...)
+		if code, codes := market("alice"); code != http.StatusOK || !slices.Equal(codes, test.codes) {
+			t.Errorf("knowing %v alice got %d %v, want %v", test.fields, code, codes, test.codes)
+		}
+	}
+}
+
+// getPrices is the week and prices of the market of a world
+func getPrices(t *testing.T, h *Handler, user string, target string, worldId string) (int, int, []trade.Price) {
+	t.Helper()
+
+	rec := serve(t, user, http.MethodGet, target, "", h.GetMarket, "sessionId", "session", "worldId", worldId)
+	if rec.Code != http.StatusOK {
+		return rec.Code, 0, nil
+	}
+	var body struct {
+		Week   int           `json:"week"`
+		Prices []trade.Price `json:"prices"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body.Week, body.Prices
+}
+
+func TestGetMarketIsRolledOncePerWeek(t *testing.T) {
+	h := newTestHandler(t)
+	testSession(t, h, "session", "referee")
+
+	world := model.World{Id: "regina", SessionId: "session", Name: "Regina", UWP: model.UWP{Starport: "A", Size: 7, Atmosphere: 8, Hydrographics: 8, Population: 8, Government: 9, LawLevel: 9, TechLevel: 12}, Bases: []string{}}
+	if err := world.Insert(h.db); err != nil {
+		t.Fatal(err)
+	}
+	setDate := func(date model.ImperialDate) {
+		t.Helper()
+		if _, err := model.SetSessionDate(h.db, "session", date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first and last day of a market week
+	start := model.ImperialDateFromDays(model.MarketWeek(model.DefaultImperialDate) * model.DaysPerWeek)
+	setDate(start)
+	_, week, prices := getPrices(t, h, "referee", "/", "regina")
+	setDate(start.AddDays(model.DaysPerWeek - 1))
+	if _, again, same := getPrices(t, h, "referee", "/", "regina"); again != week || !slices.Equal(same, prices) {
+		t.Fatalf("the market changed within week %d: %+v then %+v", week, prices, same)
+	}
+
+	setDate(start.AddDays(model.DaysPerWeek))
+	if _, next, _ := getPrices(t, h, "referee", "/", "regina"); next != week+1 {
+		t.Fatalf("a week later the market is for week %d, want %d", next, week+1)
+	}
+	var markets int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM market WHERE world_id = ?;", "regina").Scan(&markets); err != nil {
+		t.Fatal(err)
+	}
+	if markets != 2 {
+		t.Fatalf("rolled %d markets over two weeks", markets)
+	}
+
+	// going back keeps the market that was rolled for that week
+	setDate(start.AddDays(2))
+	if _, again, same := getPrices(t, h, "referee", "/", "regina"); again != week || !slices.Equal(same, prices) {
+		t.Fatalf("week %d was rolled again: %+v then %+v", week, prices, same)
+	}
+}
+
+func TestGetMarketAppliesBroker(t *testing.T) {
+	h := newTestHandler(t)
+	testSession(t, h, "session", "referee", "alice", "bob")
+	testCharacter(t, h, "trader", "session", "alice", map[string]int{brokerSkill: 2})
+	testCharacter(t, h, "pilot", "session", "alice", map[string]int{"pilot": 1})
+
+	world := model.World{Id: "regina", SessionId: "session", Name: "Regina", UWP: model.UWP{Starport: "A", Size: 7, Atmosphere: 8, Hydrographics: 8, Population: 8, Government: 9, LawLevel: 9, TechLevel: 12}, Bases: []string{}}
+	if err := world.Insert(h.db); err != nil {
+		t.Fatal(err)
+	}
+	for _, player := range []string{"alice", "bob"} {
+		if err := model.SetWorldKnowledge(h.db, "session", model.WorldKnowledge{WorldId: "regina", UserId: player, Fields: []string{model.WorldField_Starport}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// roll the market before reading it back
+	if code, _, _ := getPrices(t, h, "referee", "/", "regina"); code != http.StatusOK {
+		t.Fatalf("referee got %d", code)
+	}
+	var stored model.Market
+	date, err := model.GetSessionDate(h.db, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stored.GetMarket(h.db, "regina", model.MarketWeek(date)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		target string
+		code   int
+		broker int
+	}{
+		{"no trader", "alice", "/", http.StatusOK, 0},
+		{"trained trader", "alice", "/?characterId=trader", http.StatusOK, 2},
+		{"untrained trader", "alice", "/?characterId=pilot", http.StatusOK, -3},
+		{"someone elses character", "bob", "/?characterId=trader", http.StatusForbidden, 0},
+		{"unknown character", "alice", "/?characterId=nobody", http.StatusNotFound, 0},
+		{"referee override", "referee", "/?broker=4", http.StatusOK, 4},
+		{"player override", "alice", "/?broker=4", http.StatusForbidden, 0},
+		{"override out of range", "referee", "/?broker=9", http.StatusBadRequest, 0},
+	}
+	for _, test := range tests {
+		code, _, prices := getPrices(t, h, test.user, test.target, "regina")
+		if code != test.code {
+			t.Errorf("%s: got %d, want %d", test.name, code, test.code)
+			continue
+		}
+		if code != http.StatusOK {
+			continue
+		}
+		if want := h.trade.Prices(&stored, world, test.broker); !slices.Equal(prices, want) {
+			t.Errorf("%s: prices are not at Broker %d", test.name, test.broker)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+	"visualsource/traveller/internal/worldgen"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucsky/cuid"
+)
+
+type worldForm struct {
+	Name  string           `json:"name" form:"name"`
+	UWP   string           `json:"uwp" form:"uwp"`
+	Bases []string         `json:"bases" form:"bases"`
+	Zone  model.TravelZone `json:"zone" form:"zone"`
+	Notes string           `json:"notes" form:"notes"`
+}
+
+type worldGenerateForm struct {
+	Name string `json:"name" form:"name"`
+	// optional seed so the same world can be rolled again
+	Seed *int64 `json:"seed" form:"seed"`
+}
+
+// apply copies the set fields of the form onto the world
+func (f *worldForm) apply(world *model.World) error {
+	if f.Name != "" {
+		world.Name = f.Name
+	}
+
+	if f.UWP != "" {
+		uwp, err := model.ParseUWP(f.UWP)
+		if err != nil {
+			return err
+		}
+		world.UWP = uwp
+	}
+
+	if f.Bases != nil {
+		for _, code := range f.Bases {
+			if !model.ValidBase(code) {
+				return errors.New("unknown base '" + code + "'")
+			}
+		}
+		world.Bases = f.Bases
+	}
+
+	if f.Zone != "" {
+		if !f.Zone.Valid() {
+			return errors.New("unknown travel zone")
+		}
+		world.Zone = f.Zone
+	}
+
+	if f.Notes != "" {
+		world.Notes = f.Notes
+	}
+
+	return nil
+}
+
+// sessionWorld loads the world named by the worldId path param and checks it belongs to the session
+func (h *Handler) sessionWorld(c echo.Context, sessionId string) (*model.World, error) {
+	var world model.World
+	err := world.GetWorld(h.db, c.Param("worldId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "No world found")
+		}
+		log.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load world")
+	}
+
+	if world.SessionId != sessionId {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "No world found")
+	}
+
+	return &world, nil
+}
+
+// ListWorlds returns every world to the referee and the worlds they have found, as they know them, to the players
+func (h *Handler) ListWorlds(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	worlds, err := model.GetSessionWorlds(h.db, item.Id)
+	if err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to load worlds")
+	}
+
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+	if knowledge == nil {
+		return c.JSON(http.StatusOK, worlds)
+	}
+
+	known := []model.KnownWorld{}
+	for _, world := range worlds {
+		if knowledge.Knows(&world) {
+			known = append(known, knowledge.View(world))
+		}
+	}
+
+	return c.JSON(http.StatusOK, known)
+}
+
+func (h *Handler) GetWorld(c echo.Context) error {
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	knowledge, err := h.playerKnowledge(item, user)
+	if err != nil {
+		return err
+	}
+	if knowledge == nil {
+		return c.JSON(http.StatusOK, world)
+	}
+
+	if !knowledge.Knows(world) {
+		return c.String(http.StatusNotFound, "No world found")
+	}
+
+	return c.JSON(http.StatusOK, knowledge.View(*world))
+}
+
+func (h *Handler) CreateWorld(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData worldForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" || formData.UWP == "" {
+		return c.String(http.StatusBadRequest, "world name and UWP are required")
+	}
+
+	world := model.World{Id: cuid.New(), SessionId: item.Id, Bases: []string{}, Zone: model.TravelZone_Green}
+	if err := formData.apply(&world); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := world.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create world")
+	}
+
+	return c.JSON(http.StatusCreated, world)
+}
+
+// GenerateWorld rolls a new world and saves it to the session
+func (h *Handler) GenerateWorld(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var formData worldGenerateForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if formData.Name == "" {
+		return c.String(http.StatusBadRequest, "world name is required")
+	}
+
+	roller := h.dice
+	if formData.Seed != nil {
+		roller = dice.Seeded(*formData.Seed)
+	}
+
+	world := worldgen.World(roller, formData.Name)
+	world.Id = cuid.New()
+	world.SessionId = item.Id
+
+	if err := world.Insert(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to create world")
+	}
+
+	return c.JSON(http.StatusCreated, world)
+}
+
+func (h *Handler) UpdateWorld(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	var formData worldForm
+	if err := c.Bind(&formData); err != nil {
+		return c.String(http.StatusBadRequest, "missing form fields")
+	}
+
+	if err := formData.apply(world); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := world.Update(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to update world")
+	}
+
+	return c.JSON(http.StatusOK, world)
+}
+
+func (h *Handler) DeleteWorld(c echo.Context) error {
+	item, _, err := h.sessionAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	world, err := h.sessionWorld(c, item.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := world.Delete(h.db); err != nil {
+		log.Println(err)
+		return c.String(http.StatusInternalServerError, "Failed to delete world")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
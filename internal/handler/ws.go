@@ -6,6 +6,12 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// WS upgrades the request to a socket connection for the session, only its admin and players may connect
 func (h *Handler) WS(c echo.Context) error {
-	return socket.ServeWs(h.hub, c)
+	item, user, err := h.sessionMember(c)
+	if err != nil {
+		return err
+	}
+
+	return socket.ServeWs(h.hub, c, item.Id, user)
 }
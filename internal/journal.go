@@ -0,0 +1,19 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterJournalPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/journal")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListJournal).Name = "journal"
+	g.POST("", h.CreateJournalEntry).Name = "journal-create"
+	g.GET("/export", h.ExportJournal).Name = "journal-export"
+	g.PUT("/:entryId", h.UpdateJournalEntry).Name = "journal-update"
+	g.DELETE("/:entryId", h.DeleteJournalEntry).Name = "journal-delete"
+}
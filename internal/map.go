@@ -0,0 +1,18 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterMapPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/map")
+
+	g.Use(sessionRequired)
+
+	g.GET("/knowledge", h.GetMapKnowledge).Name = "map-knowledge"
+	g.PUT("/hexes", h.ExploreHexes).Name = "map-explore"
+	g.PUT("/worlds/:worldId", h.SetWorldKnowledge).Name = "map-world"
+	g.DELETE("/worlds/:worldId", h.ForgetWorld).Name = "map-world-forget"
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CharacterAugment is an augment from the equipment catalog installed in a character
+type CharacterAugment struct {
+	Id          string    `json:"id"`
+	CharacterId string    `json:"characterId"`
+	AugmentId   string    `json:"augmentId"`
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+func (a *CharacterAugment) GetCharacterAugment(db *sql.DB, id string) error {
+	return db.QueryRow("SELECT id,character_id,augment_id,installed_at FROM character_augment WHERE id = ?;", id).
+		Scan(&a.Id, &a.CharacterId, &a.AugmentId, &a.InstalledAt)
+}
+
+func (a *CharacterAugment) Scan(row *sql.Rows) error {
+	return row.Scan(&a.Id, &a.CharacterId, &a.AugmentId, &a.InstalledAt)
+}
+
+func (a *CharacterAugment) Insert(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO character_augment (id,character_id,augment_id,installed_at) VALUES (?,?,?,?);", a.Id, a.CharacterId, a.AugmentId, a.InstalledAt)
+	return err
+}
+
+func (a *CharacterAugment) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM character_augment WHERE id = ?;", a.Id)
+	return err
+}
+
+// GetCharacterAugments returns the augments of a character in the order they were installed
+func GetCharacterAugments(db *sql.DB, characterId string) ([]CharacterAugment, error) {
+	rows, err := db.Query("SELECT id,character_id,augment_id,installed_at FROM character_augment WHERE character_id = ? ORDER BY installed_at;", characterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []CharacterAugment{}
+	for rows.Next() {
+		var item CharacterAugment
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
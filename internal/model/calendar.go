@@ -0,0 +1,268 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DaysPerYear = 365
+	DaysPerWeek = 7
+	// a jump takes about a week in jump space
+	DaysPerJump = 7
+)
+
+// ImperialDate is a day of the Imperial calendar written as DDD-YYYY, for example 001-1105
+type ImperialDate struct {
+	Day  int
+	Year int
+}
+
+// DefaultImperialDate is the date a session starts on
+var DefaultImperialDate = ImperialDate{Day: 1, Year: 1105}
+
+var ErrInvalidImperialDate = errors.New("invalid imperial date")
+
+func ParseImperialDate(value string) (ImperialDate, error) {
+	day, year, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return ImperialDate{}, fmt.Errorf("%w: '%s' is not in the form DDD-YYYY", ErrInvalidImperialDate, value)
+	}
+
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return ImperialDate{}, fmt.Errorf("%w: '%s' is not a day", ErrInvalidImperialDate, day)
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return ImperialDate{}, fmt.Errorf("%w: '%s' is not a year", ErrInvalidImperialDate, year)
+	}
+
+	date := ImperialDate{Day: d, Year: y}
+	if !date.Valid() {
+		return ImperialDate{}, fmt.Errorf("%w: day must be between 001 and %03d", ErrInvalidImperialDate, DaysPerYear)
+	}
+
+	return date, nil
+}
+
+// ImperialDateFromDays is the inverse of ImperialDate.Days
+func ImperialDateFromDays(days int) ImperialDate {
+	return ImperialDate{Day: days%DaysPerYear + 1, Year: days / DaysPerYear}
+}
+
+func (d ImperialDate) Valid() bool {
+	return d.Day >= 1 && d.Day <= DaysPerYear && d.Year >= 0
+}
+
+// Days is the number of days since 001-0000, used to order and store dates
+func (d ImperialDate) Days() int {
+	return d.Year*DaysPerYear + d.Day - 1
+}
+
+func (d ImperialDate) AddDays(days int) ImperialDate {
+	return ImperialDateFromDays(d.Days() + days)
+}
+
+func (d ImperialDate) String() string {
+	return fmt.Sprintf("%03d-%d", d.Day, d.Year)
+}
+
+func (d ImperialDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *ImperialDate) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	date, err := ParseImperialDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// UnmarshalParam lets echo bind a date from a form value
+func (d *ImperialDate) UnmarshalParam(value string) error {
+	date, err := ParseImperialDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// GetSessionDate returns the current in game date of the session
+func GetSessionDate(db *sql.DB, sessionId string) (ImperialDate, error) {
+	var days int
+	err := db.QueryRow("SELECT date FROM session_calendar WHERE session_id = ?;", sessionId).Scan(&days)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultImperialDate, nil
+	}
+	if err != nil {
+		return ImperialDate{}, err
+	}
+	return ImperialDateFromDays(days), nil
+}
+
+type ScheduledEventKind string
+
+const (
+	ScheduledEventKind_PaymentDue       ScheduledEventKind = "payment_due"
+	ScheduledEventKind_TrainingComplete ScheduledEventKind = "training_complete"
+	ScheduledEventKind_Custom           ScheduledEventKind = "custom"
+)
+
+func (k ScheduledEventKind) Valid() bool {
+	return k == ScheduledEventKind_PaymentDue || k == ScheduledEventKind_TrainingComplete || k == ScheduledEventKind_Custom
+}
+
+// ScheduledEvent fires once the session date reaches its date.
+// Events with RepeatDays are moved forward instead of being marked as fired.
+type ScheduledEvent struct {
+	Id          string             `json:"id"`
+	SessionId   string             `json:"sessionId"`
+	Date        ImperialDate       `json:"date"`
+	Kind        ScheduledEventKind `json:"kind"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	// user the event is about, empty for the whole session
+	UserId     string    `json:"userId"`
+	RepeatDays int       `json:"repeatDays"`
+	Fired      bool      `json:"fired"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (e *ScheduledEvent) GetScheduledEvent(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,session_id,date,kind,title,description,user_id,repeat_days,fired,created_at FROM scheduled_event WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var days int
+	err = stmt.QueryRow(id).Scan(&e.Id, &e.SessionId, &days, &e.Kind, &e.Title, &e.Description, &e.UserId, &e.RepeatDays, &e.Fired, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.Date = ImperialDateFromDays(days)
+	return nil
+}
+
+func (e *ScheduledEvent) Scan(row *sql.Rows) error {
+	var days int
+	err := row.Scan(&e.Id, &e.SessionId, &days, &e.Kind, &e.Title, &e.Description, &e.UserId, &e.RepeatDays, &e.Fired, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.Date = ImperialDateFromDays(days)
+	return nil
+}
+
+func (e *ScheduledEvent) Insert(db *sql.DB) error {
+	e.CreatedAt = time.Now().UTC()
+	_, err := db.Exec("INSERT INTO scheduled_event (id,session_id,date,kind,title,description,user_id,repeat_days,fired,created_at) VALUES (?,?,?,?,?,?,?,?,?,?);",
+		e.Id, e.SessionId, e.Date.Days(), e.Kind, e.Title, e.Description, e.UserId, e.RepeatDays, e.Fired, e.CreatedAt)
+	return err
+}
+
+func (e *ScheduledEvent) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM scheduled_event WHERE id = ?;", e.Id)
+	return err
+}
+
+// GetScheduledEvents returns the events of the session in date order, fired events are only included when asked for
+func GetScheduledEvents(db *sql.DB, sessionId string, includeFired bool) ([]ScheduledEvent, error) {
+	rows, err := db.Query("SELECT id,session_id,date,kind,title,description,user_id,repeat_days,fired,created_at FROM scheduled_event WHERE session_id = ? AND (fired = 0 OR ?) ORDER BY date,created_at;", sessionId, includeFired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ScheduledEvent{}
+	for rows.Next() {
+		var item ScheduledEvent
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// SetSessionDate moves the session to the date and fires every event that is now due.
+// The fired events are returned with the date they were due on.
+func SetSessionDate(db *sql.DB, sessionId string, date ImperialDate) ([]ScheduledEvent, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO session_calendar (session_id,date) VALUES (?,?) ON CONFLICT (session_id) DO UPDATE SET date = excluded.date;", sessionId, date.Days())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT id,session_id,date,kind,title,description,user_id,repeat_days,fired,created_at FROM scheduled_event WHERE session_id = ? AND fired = 0 AND date <= ? ORDER BY date,created_at;", sessionId, date.Days())
+	if err != nil {
+		return nil, err
+	}
+	due := []ScheduledEvent{}
+	for rows.Next() {
+		var item ScheduledEvent
+		if err := item.Scan(rows); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fired := []ScheduledEvent{}
+	for _, item := range due {
+		if item.RepeatDays <= 0 {
+			if _, err := tx.Exec("UPDATE scheduled_event SET fired = 1 WHERE id = ?;", item.Id); err != nil {
+				return nil, err
+			}
+			item.Fired = true
+			fired = append(fired, item)
+			continue
+		}
+
+		// a repeating event fires once for every time it came due
+		next := item.Date
+		for next.Days() <= date.Days() {
+			occurrence := item
+			occurrence.Date = next
+			fired = append(fired, occurrence)
+			next = next.AddDays(item.RepeatDays)
+		}
+		if _, err := tx.Exec("UPDATE scheduled_event SET date = ? WHERE id = ?;", next.Days(), item.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(fired, func(a ScheduledEvent, b ScheduledEvent) int {
+		return a.Date.Days() - b.Date.Days()
+	})
+
+	return fired, nil
+}
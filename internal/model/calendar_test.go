@@ -0,0 +1,135 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestParseImperialDate(t *testing.T) {
+	for _, value := range []string{"001-1105", "365-1105", "100-0"} {
+		date, err := ParseImperialDate(value)
+		if err != nil {
+			t.Errorf("ParseImperialDate(%q) failed: %v", value, err)
+			continue
+		}
+		if date.String() != value {
+			t.Errorf("ParseImperialDate(%q) = %s", value, date)
+		}
+	}
+
+	for _, value := range []string{"", "1105", "000-1105", "366-1105", "abc-1105", "001-abc", "001--5"} {
+		if _, err := ParseImperialDate(value); !errors.Is(err, ErrInvalidImperialDate) {
+			t.Errorf("ParseImperialDate(%q) = %v, want ErrInvalidImperialDate", value, err)
+		}
+	}
+}
+
+func TestImperialDateAddDays(t *testing.T) {
+	tests := []struct {
+		date string
+		days int
+		want string
+	}{
+		{"001-1105", 0, "001-1105"},
+		{"001-1105", 7, "008-1105"},
+		{"364-1105", 1, "365-1105"},
+		{"365-1105", 1, "001-1106"},
+		{"360-1105", 10, "005-1106"},
+		{"001-1106", -1, "365-1105"},
+		{"001-1105", 2 * DaysPerYear, "001-1107"},
+	}
+
+	for _, test := range tests {
+		date, err := ParseImperialDate(test.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := date.AddDays(test.days); got.String() != test.want {
+			t.Errorf("%s + %d days = %s, want %s", test.date, test.days, got, test.want)
+		}
+		if again := ImperialDateFromDays(date.Days()); again != date {
+			t.Errorf("%s went through days to %s", test.date, again)
+		}
+	}
+}
+
+func TestSetSessionDate(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE session_calendar (session_id TEXT PRIMARY KEY, date INTEGER NOT NULL);
+	CREATE TABLE scheduled_event (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, date INTEGER NOT NULL, kind TEXT NOT NULL, title TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', user_id TEXT NOT NULL DEFAULT '', repeat_days INTEGER NOT NULL DEFAULT 0, fired BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME NOT NULL);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if date, err := GetSessionDate(db, "session"); err != nil || date != DefaultImperialDate {
+		t.Fatalf("a new session is on %s, %v", date, err)
+	}
+
+	events := []ScheduledEvent{
+		{Id: "training", SessionId: "session", Date: ImperialDate{Day: 10, Year: 1105}, Kind: ScheduledEventKind_TrainingComplete},
+		{Id: "mortgage", SessionId: "session", Date: ImperialDate{Day: 360, Year: 1105}, Kind: ScheduledEventKind_PaymentDue, RepeatDays: 30},
+		{Id: "later", SessionId: "session", Date: ImperialDate{Day: 100, Year: 1106}, Kind: ScheduledEventKind_Custom},
+		{Id: "other", SessionId: "other", Date: ImperialDate{Day: 2, Year: 1105}, Kind: ScheduledEventKind_Custom},
+	}
+	for _, event := range events {
+		if err := event.Insert(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// over the new year the mortgage comes due on 360-1105 and 025-1106
+	fired, err := SetSessionDate(db, "session", ImperialDate{Day: 30, Year: 1106})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id   string
+		date string
+	}{{"training", "010-1105"}, {"mortgage", "360-1105"}, {"mortgage", "025-1106"}}
+	if len(fired) != len(want) {
+		t.Fatalf("fired %+v", fired)
+	}
+	for i, event := range fired {
+		if event.Id != want[i].id || event.Date.String() != want[i].date {
+			t.Errorf("event %d fired %s on %s, want %s on %s", i, event.Id, event.Date, want[i].id, want[i].date)
+		}
+	}
+
+	if date, err := GetSessionDate(db, "session"); err != nil || date.String() != "030-1106" {
+		t.Fatalf("the session is on %s, %v", date, err)
+	}
+
+	pending, err := GetScheduledEvents(db, "session", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Id != "mortgage" || pending[0].Date.String() != "055-1106" || pending[1].Id != "later" {
+		t.Fatalf("pending events are %+v", pending)
+	}
+	all, err := GetScheduledEvents(db, "session", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Id != "training" || !all[0].Fired {
+		t.Fatalf("all events are %+v", all)
+	}
+
+	// nothing fires twice
+	if fired, err := SetSessionDate(db, "session", ImperialDate{Day: 30, Year: 1106}); err != nil || len(fired) != 0 {
+		t.Fatalf("setting the same date fired %+v, %v", fired, err)
+	}
+
+	var event ScheduledEvent
+	if err := event.GetScheduledEvent(db, "other"); err != nil || event.Fired {
+		t.Fatalf("an event of another session is %+v, %v", event, err)
+	}
+}
@@ -1,8 +1,226 @@
 package model
 
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// Characteristic codes as written on a character sheet
+const (
+	Characteristic_STR = "STR"
+	Characteristic_DEX = "DEX"
+	Characteristic_END = "END"
+	Characteristic_INT = "INT"
+	Characteristic_EDU = "EDU"
+	Characteristic_SOC = "SOC"
+	Characteristic_PSI = "PSI"
+)
+
+var characteristicCodes = []string{Characteristic_STR, Characteristic_DEX, Characteristic_END, Characteristic_INT, Characteristic_EDU, Characteristic_SOC, Characteristic_PSI}
+
+func ValidCharacteristic(code string) bool {
+	for _, item := range characteristicCodes {
+		if code == item {
+			return true
+		}
+	}
+	return false
+}
+
+// PhysicalCharacteristic reports whether checks on the characteristic suffer from encumbrance
+func PhysicalCharacteristic(code string) bool {
+	return code == Characteristic_STR || code == Characteristic_DEX || code == Characteristic_END
+}
+
+// CharacteristicDM is the modifier a characteristic score gives to checks
+func CharacteristicDM(value int) int {
+	switch {
+	case value <= 0:
+		return -3
+	case value <= 2:
+		return -2
+	case value <= 5:
+		return -1
+	case value <= 8:
+		return 0
+	case value <= 11:
+		return 1
+	case value <= 14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+type Characteristics struct {
+	STR int `json:"STR"`
+	DEX int `json:"DEX"`
+	END int `json:"END"`
+	INT int `json:"INT"`
+	EDU int `json:"EDU"`
+	SOC int `json:"SOC"`
+	PSI int `json:"PSI"`
+}
+
+// field returns a pointer to the score of a characteristic code, nil for unknown codes
+func (c *Characteristics) field(code string) *int {
+	switch code {
+	case Characteristic_STR:
+		return &c.STR
+	case Characteristic_DEX:
+		return &c.DEX
+	case Characteristic_END:
+		return &c.END
+	case Characteristic_INT:
+		return &c.INT
+	case Characteristic_EDU:
+		return &c.EDU
+	case Characteristic_SOC:
+		return &c.SOC
+	case Characteristic_PSI:
+		return &c.PSI
+	}
+	return nil
+}
+
+func (c Characteristics) Get(code string) int {
+	if field := c.field(code); field != nil {
+		return *field
+	}
+	return 0
+}
+
+// Add changes the score of a characteristic by value, unknown codes are ignored
+func (c *Characteristics) Add(code string, value int) {
+	if field := c.field(code); field != nil {
+		*field += value
+	}
+}
+
 type Character struct {
-	Id        string
-	Owner     string
-	SessionId string
-	// Other character info
+	Id              string          `json:"id"`
+	Owner           string          `json:"owner"`
+	SessionId       string          `json:"sessionId"`
+	Name            string          `json:"name"`
+	Characteristics Characteristics `json:"characteristics"`
+	// skill levels keyed by the skill id from skils.yml
+	Skills map[string]int `json:"skills"`
+	// damage taken to each characteristic, healed back to the base score
+	Injuries Characteristics `json:"injuries"`
+}
+
+func (c *Character) GetCharacter(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,owner,session_id,name,characteristics,skills,injuries FROM character WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return c.ScanRow(stmt.QueryRow(id))
+}
+
+func (c *Character) load(characteristics string, skills string, injuries string) error {
+	if err := json.Unmarshal([]byte(characteristics), &c.Characteristics); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(skills), &c.Skills); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(injuries), &c.Injuries)
+}
+
+func (c *Character) ScanRow(row *sql.Row) error {
+	var characteristics, skills, injuries string
+	if err := row.Scan(&c.Id, &c.Owner, &c.SessionId, &c.Name, &characteristics, &skills, &injuries); err != nil {
+		return err
+	}
+	return c.load(characteristics, skills, injuries)
+}
+
+func (c *Character) Scan(row *sql.Rows) error {
+	var characteristics, skills, injuries string
+	if err := row.Scan(&c.Id, &c.Owner, &c.SessionId, &c.Name, &characteristics, &skills, &injuries); err != nil {
+		return err
+	}
+	return c.load(characteristics, skills, injuries)
+}
+
+// marshal returns the jsonb columns of the character
+func (c *Character) marshal() (string, string, string, error) {
+	characteristics, err := json.Marshal(c.Characteristics)
+	if err != nil {
+		return "", "", "", err
+	}
+	skills, err := json.Marshal(c.Skills)
+	if err != nil {
+		return "", "", "", err
+	}
+	injuries, err := json.Marshal(c.Injuries)
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(characteristics), string(skills), string(injuries), nil
+}
+
+func (c *Character) Insert(db *sql.DB) error {
+	characteristics, skills, injuries, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO character (id,owner,session_id,name,characteristics,skills,injuries) VALUES (?,?,?,?,?,?,?);", c.Id, c.Owner, c.SessionId, c.Name, characteristics, skills, injuries)
+	return err
+}
+
+func (c *Character) Update(db *sql.DB) error {
+	characteristics, skills, injuries, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE character SET name = ?, characteristics = ?, skills = ?, injuries = ? WHERE id = ?;", c.Name, characteristics, skills, injuries, c.Id)
+	return err
+}
+
+// Delete removes the character along with what they carry, their augments, the NPCs they know and their places on ships
+func (c *Character) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM inventory_item WHERE character_id = ?;",
+		"DELETE FROM character_augment WHERE character_id = ?;",
+		"DELETE FROM npc_relationship WHERE character_id = ?;",
+		"DELETE FROM ship_crew WHERE character_id = ?;",
+		"DELETE FROM character WHERE id = ?;",
+	} {
+		if _, err := tx.Exec(query, c.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSessionCharacters returns every character of the session
+func GetSessionCharacters(db *sql.DB, sessionId string) ([]Character, error) {
+	rows, err := db.Query("SELECT id,owner,session_id,name,characteristics,skills,injuries FROM character WHERE session_id = ? ORDER BY name;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Character{}
+	for rows.Next() {
+		var item Character
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
 }
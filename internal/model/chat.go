@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	ChatKind_Broadcast = "broadcast"
+	ChatKind_Private   = "private"
+)
+
+type ChatMessage struct {
+	SessionId string    `json:"sessionId"`
+	Seq       int64     `json:"seq"`
+	Kind      string    `json:"kind"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Target    string    `json:"target"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Insert stores the message and assigns it the next sequence number of its session.
+// The number comes from the stored messages, instances sharing a session must share the database.
+func (m *ChatMessage) Insert(db *sql.DB) error {
+	stmt, err := db.Prepare(`INSERT INTO chat_message (session_id,seq,kind,from_user,to_user,target,content,created_at)
+	VALUES (?,(SELECT COALESCE(MAX(seq),0) + 1 FROM chat_message WHERE session_id = ?),?,?,?,?,?,?) RETURNING seq;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+
+	return stmt.QueryRow(m.SessionId, m.SessionId, m.Kind, m.FromUser, m.ToUser, m.Target, m.Content, m.CreatedAt).Scan(&m.Seq)
+}
+
+func (m *ChatMessage) Scan(row *sql.Rows) error {
+	return row.Scan(&m.SessionId, &m.Seq, &m.Kind, &m.FromUser, &m.ToUser, &m.Target, &m.Content, &m.CreatedAt)
+}
+
+// GetChatHistory returns the messages of a session visible to the user.
+// When after is negative the last limit messages are returned, otherwise
+// every message with a sequence number greater than after.
+func GetChatHistory(db *sql.DB, sessionId string, userId string, after int64, limit int) ([]ChatMessage, error) {
+	var query string
+	var args []interface{}
+	if after < 0 {
+		query = `SELECT * FROM (
+			SELECT session_id,seq,kind,from_user,to_user,target,content,created_at FROM chat_message
+			WHERE session_id = ? AND (kind = ? OR from_user = ? OR to_user = ?) ORDER BY seq DESC LIMIT ?
+		) ORDER BY seq ASC;`
+		args = []interface{}{sessionId, ChatKind_Broadcast, userId, userId, limit}
+	} else {
+		query = `SELECT session_id,seq,kind,from_user,to_user,target,content,created_at FROM chat_message
+		WHERE session_id = ? AND seq > ? AND (kind = ? OR from_user = ? OR to_user = ?) ORDER BY seq ASC;`
+		args = []interface{}{sessionId, after, ChatKind_Broadcast, userId, userId}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ChatMessage{}
+	for rows.Next() {
+		var msg ChatMessage
+		if err := msg.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, msg)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EncounterStatus string
+
+const (
+	// combatants are still being added, nobody has rolled initiative
+	EncounterStatus_Setup  EncounterStatus = "setup"
+	EncounterStatus_Active EncounterStatus = "active"
+	EncounterStatus_Ended  EncounterStatus = "ended"
+)
+
+type CombatantStatus string
+
+const (
+	CombatantStatus_Active      CombatantStatus = "active"
+	CombatantStatus_Unconscious CombatantStatus = "unconscious"
+	CombatantStatus_Dead        CombatantStatus = "dead"
+)
+
+// Combatant is a character or NPC taking part in an encounter. Characters are copied in with their
+// effective stats when they join, NPCs are given them by the referee.
+type Combatant struct {
+	Id string `json:"id"`
+	// empty for NPCs
+	CharacterId     string          `json:"characterId"`
+	Name            string          `json:"name"`
+	Characteristics Characteristics `json:"characteristics"`
+	// damage taken during the encounter
+	Damage Characteristics `json:"damage"`
+	Skills map[string]int  `json:"skills"`
+	// catalog id of the weapon used for attacks, empty when unarmed
+	WeaponId   string `json:"weaponId"`
+	Protection int    `json:"protection"`
+	// DM for the load carried, it applies to attacks as they use STR or DEX
+	Encumbrance int             `json:"encumbrance"`
+	Initiative  int             `json:"initiative"`
+	Status      CombatantStatus `json:"status"`
+}
+
+// Current is the score of a characteristic after the damage taken
+func (c *Combatant) Current(code string) int {
+	return max(c.Characteristics.Get(code)-c.Damage.Get(code), 0)
+}
+
+type CombatLogEntry struct {
+	Round   int       `json:"round"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// Encounter is a personal combat of the session. Combatants are kept in initiative order
+// once it is rolled and Turn is the index of the one acting.
+type Encounter struct {
+	Id         string           `json:"id"`
+	SessionId  string           `json:"sessionId"`
+	Name       string           `json:"name"`
+	Status     EncounterStatus  `json:"status"`
+	Round      int              `json:"round"`
+	Turn       int              `json:"turn"`
+	Combatants []Combatant      `json:"combatants"`
+	Log        []CombatLogEntry `json:"log"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+func (e *Encounter) GetEncounter(db *sql.DB, id string) error {
+	var combatants, log string
+	err := db.QueryRow("SELECT id,session_id,name,status,round,turn,combatants,log,created_at FROM encounter WHERE id = ?;", id).
+		Scan(&e.Id, &e.SessionId, &e.Name, &e.Status, &e.Round, &e.Turn, &combatants, &log, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return e.load(combatants, log)
+}
+
+func (e *Encounter) load(combatants string, log string) error {
+	if err := json.Unmarshal([]byte(combatants), &e.Combatants); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(log), &e.Log)
+}
+
+func (e *Encounter) Scan(row *sql.Rows) error {
+	var combatants, log string
+	if err := row.Scan(&e.Id, &e.SessionId, &e.Name, &e.Status, &e.Round, &e.Turn, &combatants, &log, &e.CreatedAt); err != nil {
+		return err
+	}
+	return e.load(combatants, log)
+}
+
+func (e *Encounter) Insert(db *sql.DB) error {
+	combatants, err := json.Marshal(e.Combatants)
+	if err != nil {
+		return err
+	}
+	log, err := json.Marshal(e.Log)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO encounter (id,session_id,name,status,round,turn,combatants,log,created_at) VALUES (?,?,?,?,?,?,?,?,?);", e.Id, e.SessionId, e.Name, e.Status, e.Round, e.Turn, string(combatants), string(log), e.CreatedAt)
+	return err
+}
+
+func (e *Encounter) Update(db *sql.DB) error {
+	combatants, err := json.Marshal(e.Combatants)
+	if err != nil {
+		return err
+	}
+	log, err := json.Marshal(e.Log)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE encounter SET name = ?, status = ?, round = ?, turn = ?, combatants = ?, log = ? WHERE id = ?;", e.Name, e.Status, e.Round, e.Turn, string(combatants), string(log), e.Id)
+	return err
+}
+
+func (e *Encounter) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM encounter WHERE id = ?;", e.Id)
+	return err
+}
+
+// Combatant finds a combatant of the encounter by id
+func (e *Encounter) Combatant(id string) *Combatant {
+	for i := range e.Combatants {
+		if e.Combatants[i].Id == id {
+			return &e.Combatants[i]
+		}
+	}
+	return nil
+}
+
+// Logf formats an entry for the encounter log of the current round
+func (e *Encounter) Logf(format string, args ...any) {
+	e.Log = append(e.Log, CombatLogEntry{Round: e.Round, Message: fmt.Sprintf(format, args...), At: time.Now().UTC()})
+}
+
+// GetSessionEncounters returns the encounters of the session, newest first
+func GetSessionEncounters(db *sql.DB, sessionId string) ([]Encounter, error) {
+	rows, err := db.Query("SELECT id,session_id,name,status,round,turn,combatants,log,created_at FROM encounter WHERE session_id = ? ORDER BY created_at DESC;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Encounter{}
+	for rows.Next() {
+		var item Encounter
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
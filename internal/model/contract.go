@@ -0,0 +1,206 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrContractStatus = errors.New("contract can not be changed from its current status")
+
+type ContractKind string
+
+const (
+	ContractKind_Freight   ContractKind = "freight"
+	ContractKind_Mail      ContractKind = "mail"
+	ContractKind_Passenger ContractKind = "passenger"
+)
+
+// Classes of freight lot and passage
+const (
+	ContractClass_Major      = "major"
+	ContractClass_Minor      = "minor"
+	ContractClass_Incidental = "incidental"
+	ContractClass_High       = "high"
+	ContractClass_Middle     = "middle"
+	ContractClass_Basic      = "basic"
+	ContractClass_Low        = "low"
+)
+
+// ContractStatus follows a contract from the referee rolling it to the ship delivering it
+type ContractStatus string
+
+const (
+	// offered contracts have been rolled but are only seen by the referee
+	ContractStatus_Offered   ContractStatus = "offered"
+	ContractStatus_Published ContractStatus = "published"
+	ContractStatus_Accepted  ContractStatus = "accepted"
+	ContractStatus_Delivered ContractStatus = "delivered"
+	ContractStatus_Cancelled ContractStatus = "cancelled"
+)
+
+// Contract is a freight lot, a mail delivery or a group of passengers of one class
+// going between two worlds. Payment is the total paid once it is delivered.
+type Contract struct {
+	Id            string         `json:"id"`
+	SessionId     string         `json:"sessionId"`
+	Kind          ContractKind   `json:"kind"`
+	Class         string         `json:"class"`
+	SourceId      string         `json:"sourceId"`
+	DestinationId string         `json:"destinationId"`
+	Parsecs       int            `json:"parsecs"`
+	Tons          int            `json:"tons"`
+	Passengers    int            `json:"passengers"`
+	Payment       int64          `json:"payment"`
+	Status        ContractStatus `json:"status"`
+	ShipId        string         `json:"shipId"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+const contractColumns = "id,session_id,kind,class,source_id,destination_id,parsecs,tons,passengers,payment,status,ship_id,created_at"
+
+func (c *Contract) GetContract(db *sql.DB, id string) error {
+	return db.QueryRow("SELECT "+contractColumns+" FROM contract WHERE id = ?;", id).
+		Scan(&c.Id, &c.SessionId, &c.Kind, &c.Class, &c.SourceId, &c.DestinationId, &c.Parsecs, &c.Tons, &c.Passengers, &c.Payment, &c.Status, &c.ShipId, &c.CreatedAt)
+}
+
+func (c *Contract) Scan(row *sql.Rows) error {
+	return row.Scan(&c.Id, &c.SessionId, &c.Kind, &c.Class, &c.SourceId, &c.DestinationId, &c.Parsecs, &c.Tons, &c.Passengers, &c.Payment, &c.Status, &c.ShipId, &c.CreatedAt)
+}
+
+// InsertContracts saves a batch of rolled contracts
+func InsertContracts(db *sql.DB, contracts []Contract) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for i := range contracts {
+		c := &contracts[i]
+		c.CreatedAt = now
+		_, err := tx.Exec("INSERT INTO contract ("+contractColumns+") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?);",
+			c.Id, c.SessionId, c.Kind, c.Class, c.SourceId, c.DestinationId, c.Parsecs, c.Tons, c.Passengers, c.Payment, c.Status, c.ShipId, c.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setStatus moves the contract on if it is still in the status it was loaded with
+func (c *Contract) setStatus(db execer, from ContractStatus, to ContractStatus, shipId string) error {
+	result, err := db.Exec("UPDATE contract SET status = ?, ship_id = ? WHERE id = ? AND status = ?;", to, shipId, c.Id, from)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrContractStatus
+	}
+	c.Status, c.ShipId = to, shipId
+	return nil
+}
+
+// Publish lets the players see an offered contract
+func (c *Contract) Publish(db *sql.DB) error {
+	return c.setStatus(db, ContractStatus_Offered, ContractStatus_Published, "")
+}
+
+// Accept signs a published contract to a ship
+func (c *Contract) Accept(db *sql.DB, shipId string) error {
+	return c.setStatus(db, ContractStatus_Published, ContractStatus_Accepted, shipId)
+}
+
+// Cancel withdraws a contract that has not been delivered
+func (c *Contract) Cancel(db *sql.DB) error {
+	if c.Status == ContractStatus_Delivered || c.Status == ContractStatus_Cancelled {
+		return ErrContractStatus
+	}
+	return c.setStatus(db, c.Status, ContractStatus_Cancelled, c.ShipId)
+}
+
+// Deliver completes an accepted contract and pays the ship for it
+func (c *Contract) Deliver(db *sql.DB, category ChargeCategory, description string) (Transaction, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer tx.Rollback()
+
+	if err := c.setStatus(tx, ContractStatus_Accepted, ContractStatus_Delivered, c.ShipId); err != nil {
+		return Transaction{}, err
+	}
+
+	item := Transaction{
+		SessionId:   c.SessionId,
+		AccountType: AccountType_Ship,
+		AccountId:   c.ShipId,
+		Amount:      c.Payment,
+		Category:    category,
+		Description: description,
+	}
+	if item.Month, err = financeMonth(tx, item.SessionId); err != nil {
+		return Transaction{}, err
+	}
+	if err := item.insert(tx); err != nil {
+		return Transaction{}, err
+	}
+
+	return item, tx.Commit()
+}
+
+func (c *Contract) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM contract WHERE id = ?;", c.Id)
+	return err
+}
+
+// GetSessionContracts returns the contracts of the session, newest first.
+// Offered contracts are left out unless the referee is asking.
+func GetSessionContracts(db *sql.DB, sessionId string, offered bool) ([]Contract, error) {
+	query := "SELECT " + contractColumns + " FROM contract WHERE session_id = ? AND status != 'offered' ORDER BY created_at DESC,kind,class;"
+	if offered {
+		query = "SELECT " + contractColumns + " FROM contract WHERE session_id = ? ORDER BY created_at DESC,kind,class;"
+	}
+
+	rows, err := db.Query(query, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Contract{}
+	for rows.Next() {
+		var item Contract
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ShipPassengers counts the passengers a ship has accepted but not yet delivered, by class
+func ShipPassengers(db *sql.DB, shipId string) (map[string]int, error) {
+	rows, err := db.Query("SELECT class, SUM(passengers) FROM contract WHERE ship_id = ? AND status = 'accepted' AND kind = 'passenger' GROUP BY class;", shipId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var class string
+		var count int
+		if err := rows.Scan(&class, &count); err != nil {
+			return nil, err
+		}
+		counts[class] = count
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EventKind_Alert         EventKind = "alert"
+	EventKind_SensorContact EventKind = "sensor_contact"
+	EventKind_ShipDamage    EventKind = "ship_damage"
+	EventKind_News          EventKind = "news"
+)
+
+func (k EventKind) Valid() bool {
+	switch k {
+	case EventKind_Alert, EventKind_SensorContact, EventKind_ShipDamage, EventKind_News:
+		return true
+	}
+	return false
+}
+
+type EventSeverity string
+
+const (
+	EventSeverity_Info     EventSeverity = "info"
+	EventSeverity_Warning  EventSeverity = "warning"
+	EventSeverity_Critical EventSeverity = "critical"
+)
+
+func (s EventSeverity) Valid() bool {
+	return s == EventSeverity_Info || s == EventSeverity_Warning || s == EventSeverity_Critical
+}
+
+// Event is the data of an event journal entry
+type Event struct {
+	Kind     EventKind     `json:"kind"`
+	Severity EventSeverity `json:"severity"`
+	// the ship a sensor contact or damage report is for
+	ShipId string `json:"shipId"`
+}
+
+// EventAck records a player acknowledging an event
+type EventAck struct {
+	EntryId   string    `json:"entryId"`
+	UserId    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SessionEvent is an event journal entry with its details and who has acknowledged it
+type SessionEvent struct {
+	JournalEntry
+	Event Event      `json:"event"`
+	Acks  []EventAck `json:"acks"`
+}
+
+// Acknowledged reports if the user has acknowledged the event
+func (e *SessionEvent) Acknowledged(userId string) bool {
+	for _, ack := range e.Acks {
+		if ack.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEventEntry builds the journal entry for an event
+func NewEventEntry(event Event) (JournalEntry, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return JournalEntry{Kind: JournalKind_Event, Data: data}, nil
+}
+
+// Acknowledge records the user acknowledging the event, false when they already had
+func (e *SessionEvent) Acknowledge(db *sql.DB, userId string) (bool, error) {
+	ack := EventAck{EntryId: e.Id, UserId: userId, CreatedAt: time.Now().UTC()}
+	result, err := db.Exec("INSERT INTO event_ack (entry_id,user_id,created_at) VALUES (?,?,?) ON CONFLICT DO NOTHING;", ack.EntryId, ack.UserId, ack.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return false, err
+	}
+	e.Acks = append(e.Acks, ack)
+	return true, nil
+}
+
+// LoadEvent reads the event details of a journal entry along with its acknowledgements
+func LoadEvent(db *sql.DB, entry JournalEntry) (SessionEvent, error) {
+	event := SessionEvent{JournalEntry: entry, Acks: []EventAck{}}
+	if err := json.Unmarshal(entry.Data, &event.Event); err != nil {
+		return event, err
+	}
+
+	rows, err := db.Query("SELECT entry_id,user_id,created_at FROM event_ack WHERE entry_id = ? ORDER BY created_at;", entry.Id)
+	if err != nil {
+		return event, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ack EventAck
+		if err := rows.Scan(&ack.EntryId, &ack.UserId, &ack.CreatedAt); err != nil {
+			return event, err
+		}
+		event.Acks = append(event.Acks, ack)
+	}
+
+	return event, rows.Err()
+}
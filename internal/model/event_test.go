@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJournalEntryFor(t *testing.T) {
+	item := &Session{Id: "session", Admin: "referee", Players: []string{"alice", "bob"}}
+
+	everyone := JournalEntry{Targets: []string{}}
+	targeted := JournalEntry{Targets: []string{"alice"}}
+
+	tests := []struct {
+		name  string
+		entry JournalEntry
+		user  string
+		want  bool
+	}{
+		{"untargeted to a player", everyone, "bob", true},
+		{"untargeted to the referee", everyone, "referee", true},
+		{"targeted to the target", targeted, "alice", true},
+		{"targeted to another player", targeted, "bob", false},
+		{"targeted to the referee", targeted, "referee", true},
+	}
+
+	for _, test := range tests {
+		if got := test.entry.For(item, test.user); got != test.want {
+			t.Errorf("%s: got %v", test.name, got)
+		}
+	}
+}
+
+func TestEventAcknowledged(t *testing.T) {
+	event := SessionEvent{Acks: []EventAck{{UserId: "alice"}}}
+	if !event.Acknowledged("alice") || event.Acknowledged("bob") {
+		t.Fatalf("acks are %v", event.Acks)
+	}
+}
+
+func TestNewEventEntry(t *testing.T) {
+	entry, err := NewEventEntry(Event{Kind: EventKind_ShipDamage, Severity: EventSeverity_Critical, ShipId: "ship"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Kind != JournalKind_Event {
+		t.Fatalf("entry kind is %s", entry.Kind)
+	}
+
+	var event Event
+	if err := json.Unmarshal(entry.Data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Kind != EventKind_ShipDamage || event.Severity != EventSeverity_Critical || event.ShipId != "ship" {
+		t.Fatalf("data is %s", entry.Data)
+	}
+}
+
+func TestEventKindsAndSeverities(t *testing.T) {
+	for _, kind := range []EventKind{EventKind_Alert, EventKind_SensorContact, EventKind_ShipDamage, EventKind_News} {
+		if !kind.Valid() {
+			t.Errorf("%s is not valid", kind)
+		}
+	}
+	if EventKind("").Valid() || EventKind("combat").Valid() {
+		t.Error("unknown kinds are valid")
+	}
+	if !EventSeverity_Warning.Valid() || EventSeverity("").Valid() || EventSeverity("loud").Valid() {
+		t.Error("severities are checked wrongly")
+	}
+}
@@ -0,0 +1,351 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lucsky/cuid"
+)
+
+// AccountType is what a ledger account belongs to, the account id is the id of the session, character or ship
+type AccountType string
+
+const (
+	AccountType_Session   AccountType = "session"
+	AccountType_Character AccountType = "character"
+	AccountType_Ship      AccountType = "ship"
+)
+
+type ChargeCategory string
+
+const (
+	ChargeCategory_Mortgage    ChargeCategory = "mortgage"
+	ChargeCategory_Maintenance ChargeCategory = "maintenance"
+	ChargeCategory_LifeSupport ChargeCategory = "life_support"
+	ChargeCategory_Salary      ChargeCategory = "salary"
+	ChargeCategory_LivingCost  ChargeCategory = "living_cost"
+	ChargeCategory_Debt        ChargeCategory = "debt"
+	ChargeCategory_Pension     ChargeCategory = "pension"
+	ChargeCategory_Trade       ChargeCategory = "trade"
+	ChargeCategory_Freight     ChargeCategory = "freight"
+	ChargeCategory_Passage     ChargeCategory = "passage"
+	ChargeCategory_Other       ChargeCategory = "other"
+)
+
+var chargeCategories = []ChargeCategory{
+	ChargeCategory_Mortgage,
+	ChargeCategory_Maintenance,
+	ChargeCategory_LifeSupport,
+	ChargeCategory_Salary,
+	ChargeCategory_LivingCost,
+	ChargeCategory_Debt,
+	ChargeCategory_Pension,
+	ChargeCategory_Trade,
+	ChargeCategory_Freight,
+	ChargeCategory_Passage,
+	ChargeCategory_Other,
+}
+
+func (c ChargeCategory) Valid() bool {
+	for _, item := range chargeCategories {
+		if c == item {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AccountType) Valid() bool {
+	return a == AccountType_Session || a == AccountType_Character || a == AccountType_Ship
+}
+
+// Transaction is a single ledger entry, amounts are in credits with charges being negative
+type Transaction struct {
+	Id          string         `json:"id"`
+	SessionId   string         `json:"sessionId"`
+	AccountType AccountType    `json:"accountType"`
+	AccountId   string         `json:"accountId"`
+	Seq         int64          `json:"seq"`
+	Month       int            `json:"month"`
+	Amount      int64          `json:"amount"`
+	Balance     int64          `json:"balance"`
+	Category    ChargeCategory `json:"category"`
+	Description string         `json:"description"`
+	ChargeId    string         `json:"chargeId"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+func (t *Transaction) Scan(row *sql.Rows) error {
+	return row.Scan(&t.Id, &t.SessionId, &t.AccountType, &t.AccountId, &t.Seq, &t.Month, &t.Amount, &t.Balance, &t.Category, &t.Description, &t.ChargeId, &t.CreatedAt)
+}
+
+// insert adds the transaction to the end of its accounts ledger and works out the balance after it
+func (t *Transaction) insert(tx *sql.Tx) error {
+	if t.Id == "" {
+		t.Id = cuid.New()
+	}
+	t.CreatedAt = time.Now().UTC()
+
+	err := tx.QueryRow("SELECT COALESCE(MAX(seq),0)+1, COALESCE(SUM(amount),0) FROM ledger WHERE session_id = ? AND account_type = ? AND account_id = ?;", t.SessionId, t.AccountType, t.AccountId).Scan(&t.Seq, &t.Balance)
+	if err != nil {
+		return err
+	}
+	t.Balance += t.Amount
+
+	_, err = tx.Exec("INSERT INTO ledger (id,session_id,account_type,account_id,seq,month,amount,balance,category,description,charge_id,created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?);",
+		t.Id, t.SessionId, t.AccountType, t.AccountId, t.Seq, t.Month, t.Amount, t.Balance, t.Category, t.Description, t.ChargeId, t.CreatedAt)
+	return err
+}
+
+// Insert posts the transaction in the sessions current month
+func (t *Transaction) Insert(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if t.Month, err = financeMonth(tx, t.SessionId); err != nil {
+		return err
+	}
+
+	if err := t.insert(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecurringCharge is posted to its account every time the referee advances the month.
+// Amount is signed like a transaction so a pension is positive and a mortgage negative.
+type RecurringCharge struct {
+	Id          string         `json:"id"`
+	SessionId   string         `json:"sessionId"`
+	AccountType AccountType    `json:"accountType"`
+	AccountId   string         `json:"accountId"`
+	Category    ChargeCategory `json:"category"`
+	Description string         `json:"description"`
+	Amount      int64          `json:"amount"`
+	Active      bool           `json:"active"`
+}
+
+func (r *RecurringCharge) GetRecurringCharge(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,session_id,account_type,account_id,category,description,amount,active FROM recurring_charge WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return r.ScanRow(stmt.QueryRow(id))
+}
+
+func (r *RecurringCharge) ScanRow(row *sql.Row) error {
+	return row.Scan(&r.Id, &r.SessionId, &r.AccountType, &r.AccountId, &r.Category, &r.Description, &r.Amount, &r.Active)
+}
+
+func (r *RecurringCharge) Scan(row *sql.Rows) error {
+	return row.Scan(&r.Id, &r.SessionId, &r.AccountType, &r.AccountId, &r.Category, &r.Description, &r.Amount, &r.Active)
+}
+
+func (r *RecurringCharge) Insert(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO recurring_charge (id,session_id,account_type,account_id,category,description,amount,active) VALUES (?,?,?,?,?,?,?,?);",
+		r.Id, r.SessionId, r.AccountType, r.AccountId, r.Category, r.Description, r.Amount, r.Active)
+	return err
+}
+
+func (r *RecurringCharge) Update(db *sql.DB) error {
+	_, err := db.Exec("UPDATE recurring_charge SET category = ?, description = ?, amount = ?, active = ? WHERE id = ?;", r.Category, r.Description, r.Amount, r.Active, r.Id)
+	return err
+}
+
+func (r *RecurringCharge) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM recurring_charge WHERE id = ?;", r.Id)
+	return err
+}
+
+// GetRecurringCharges returns every recurring charge of the session
+func GetRecurringCharges(db *sql.DB, sessionId string) ([]RecurringCharge, error) {
+	rows, err := db.Query("SELECT id,session_id,account_type,account_id,category,description,amount,active FROM recurring_charge WHERE session_id = ? ORDER BY account_type,account_id,category;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []RecurringCharge{}
+	for rows.Next() {
+		var item RecurringCharge
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// financeMonth returns the number of months the referee has advanced the session by
+func financeMonth(tx *sql.Tx, sessionId string) (int, error) {
+	var month int
+	err := tx.QueryRow("SELECT month FROM finance_month WHERE session_id = ?;", sessionId).Scan(&month)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return month, err
+}
+
+// GetFinanceMonth returns the current finance month of the session, sessions start in month 0
+func GetFinanceMonth(db *sql.DB, sessionId string) (int, error) {
+	var month int
+	err := db.QueryRow("SELECT month FROM finance_month WHERE session_id = ?;", sessionId).Scan(&month)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return month, err
+}
+
+// AdvanceFinanceMonth moves the session into the next month and posts every active recurring charge.
+// The posted transactions are returned along with the new month.
+func AdvanceFinanceMonth(db *sql.DB, sessionId string) (int, []Transaction, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	month, err := financeMonth(tx, sessionId)
+	if err != nil {
+		return 0, nil, err
+	}
+	month++
+
+	_, err = tx.Exec("INSERT INTO finance_month (session_id,month) VALUES (?,?) ON CONFLICT (session_id) DO UPDATE SET month = excluded.month;", sessionId, month)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := tx.Query("SELECT id,session_id,account_type,account_id,category,description,amount,active FROM recurring_charge WHERE session_id = ? AND active = 1 ORDER BY account_type,account_id,category;", sessionId)
+	if err != nil {
+		return 0, nil, err
+	}
+	charges := []RecurringCharge{}
+	for rows.Next() {
+		var item RecurringCharge
+		if err := item.Scan(rows); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		charges = append(charges, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	posted := make([]Transaction, 0, len(charges))
+	for _, charge := range charges {
+		item := Transaction{
+			SessionId:   sessionId,
+			AccountType: charge.AccountType,
+			AccountId:   charge.AccountId,
+			Month:       month,
+			Amount:      charge.Amount,
+			Category:    charge.Category,
+			Description: charge.Description,
+			ChargeId:    charge.Id,
+		}
+		if err := item.insert(tx); err != nil {
+			return 0, nil, err
+		}
+		posted = append(posted, item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+
+	return month, posted, nil
+}
+
+// GetLedger returns the transactions of an account, newest first
+func GetLedger(db *sql.DB, sessionId string, accountType AccountType, accountId string, limit int) ([]Transaction, error) {
+	rows, err := db.Query("SELECT id,session_id,account_type,account_id,seq,month,amount,balance,category,description,charge_id,created_at FROM ledger WHERE session_id = ? AND account_type = ? AND account_id = ? ORDER BY seq DESC LIMIT ?;", sessionId, accountType, accountId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Transaction{}
+	for rows.Next() {
+		var item Transaction
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+type BalancePoint struct {
+	Month   int   `json:"month"`
+	Balance int64 `json:"balance"`
+}
+
+// GetBalanceHistory returns the balance of an account at the end of every month it had transactions in
+func GetBalanceHistory(db *sql.DB, sessionId string, accountType AccountType, accountId string) ([]BalancePoint, error) {
+	rows, err := db.Query(`SELECT l.month, l.balance FROM ledger l
+	WHERE l.session_id = ? AND l.account_type = ? AND l.account_id = ? AND l.seq = (
+		SELECT MAX(seq) FROM ledger WHERE session_id = l.session_id AND account_type = l.account_type AND account_id = l.account_id AND month = l.month
+	) ORDER BY l.month;`, sessionId, accountType, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []BalancePoint{}
+	for rows.Next() {
+		var item BalancePoint
+		if err := rows.Scan(&item.Month, &item.Balance); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// AccountSummary is the current state of an account in the session
+type AccountSummary struct {
+	AccountType AccountType `json:"accountType"`
+	AccountId   string      `json:"accountId"`
+	Balance     int64       `json:"balance"`
+	// total of the active recurring charges posted each month
+	Monthly int64 `json:"monthly"`
+}
+
+// GetAccountSummaries returns every account of the session that has transactions or recurring charges
+func GetAccountSummaries(db *sql.DB, sessionId string) ([]AccountSummary, error) {
+	rows, err := db.Query(`SELECT account_type, account_id, SUM(balance), SUM(monthly) FROM (
+		SELECT account_type, account_id, amount AS balance, 0 AS monthly FROM ledger WHERE session_id = ?
+		UNION ALL
+		SELECT account_type, account_id, 0 AS balance, amount AS monthly FROM recurring_charge WHERE session_id = ? AND active = 1
+		UNION ALL
+		SELECT account_type, account_id, 0, 0 FROM recurring_charge WHERE session_id = ? AND active = 0
+	) GROUP BY account_type, account_id ORDER BY account_type, account_id;`, sessionId, sessionId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []AccountSummary{}
+	for rows.Next() {
+		var item AccountSummary
+		if err := rows.Scan(&item.AccountType, &item.AccountId, &item.Balance, &item.Monthly); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func testFinanceDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE ledger (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, account_type TEXT NOT NULL, account_id TEXT NOT NULL, seq INTEGER NOT NULL, month INTEGER NOT NULL, amount INTEGER NOT NULL, balance INTEGER NOT NULL, category TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', charge_id TEXT NOT NULL DEFAULT '', created_at DATETIME NOT NULL, UNIQUE (session_id, account_type, account_id, seq));
+	CREATE TABLE recurring_charge (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, account_type TEXT NOT NULL, account_id TEXT NOT NULL, category TEXT NOT NULL, description TEXT NOT NULL DEFAULT '', amount INTEGER NOT NULL, active BOOLEAN NOT NULL DEFAULT 1);
+	CREATE TABLE finance_month (session_id TEXT PRIMARY KEY, month INTEGER NOT NULL);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLedgerBalance(t *testing.T) {
+	db := testFinanceDB(t)
+
+	post := func(sessionId string, accountType AccountType, accountId string, amount int64) Transaction {
+		t.Helper()
+		item := Transaction{SessionId: sessionId, AccountType: accountType, AccountId: accountId, Amount: amount, Category: ChargeCategory_Other}
+		if err := item.Insert(db); err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+
+	tests := []struct {
+		accountType AccountType
+		accountId   string
+		amount      int64
+		seq         int64
+		balance     int64
+	}{
+		{AccountType_Ship, "beowulf", 1_000_000, 1, 1_000_000},
+		{AccountType_Ship, "beowulf", -250_000, 2, 750_000},
+		{AccountType_Character, "jamison", 5_000, 1, 5_000},
+		// a ship and a character can share an id without sharing a ledger
+		{AccountType_Character, "beowulf", -100, 1, -100},
+		{AccountType_Ship, "beowulf", -800_000, 3, -50_000},
+	}
+	for _, test := range tests {
+		item := post("session", test.accountType, test.accountId, test.amount)
+		if item.Seq != test.seq || item.Balance != test.balance || item.Month != 0 {
+			t.Errorf("posting %d to %s %s gave seq %d balance %d month %d, want seq %d balance %d", test.amount, test.accountType, test.accountId, item.Seq, item.Balance, item.Month, test.seq, test.balance)
+		}
+	}
+	if item := post("other", AccountType_Ship, "beowulf", 10); item.Seq != 1 || item.Balance != 10 {
+		t.Errorf("another session shares the ledger: %+v", item)
+	}
+
+	ledger, err := GetLedger(db, "session", AccountType_Ship, "beowulf", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 2 || ledger[0].Seq != 3 || ledger[0].Balance != -50_000 || ledger[1].Seq != 2 {
+		t.Fatalf("ledger is %+v", ledger)
+	}
+}
+
+func TestAdvanceFinanceMonth(t *testing.T) {
+	db := testFinanceDB(t)
+
+	charges := []RecurringCharge{
+		{Id: "mortgage", SessionId: "session", AccountType: AccountType_Ship, AccountId: "beowulf", Category: ChargeCategory_Mortgage, Amount: -150_000, Active: true},
+		{Id: "maintenance", SessionId: "session", AccountType: AccountType_Ship, AccountId: "beowulf", Category: ChargeCategory_Maintenance, Amount: -3_813, Active: true},
+		{Id: "salary", SessionId: "session", AccountType: AccountType_Ship, AccountId: "beowulf", Category: ChargeCategory_Salary, Description: "Pilot", Amount: -6_000, Active: true},
+		{Id: "pension", SessionId: "session", AccountType: AccountType_Character, AccountId: "jamison", Category: ChargeCategory_Pension, Amount: 10_000, Active: true},
+		{Id: "paid off", SessionId: "session", AccountType: AccountType_Character, AccountId: "jamison", Category: ChargeCategory_Debt, Amount: -2_000, Active: false},
+		{Id: "other", SessionId: "other", AccountType: AccountType_Ship, AccountId: "beowulf", Category: ChargeCategory_Mortgage, Amount: -1, Active: true},
+	}
+	for _, charge := range charges {
+		if err := charge.Insert(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opening := Transaction{SessionId: "session", AccountType: AccountType_Ship, AccountId: "beowulf", Amount: 500_000, Category: ChargeCategory_Trade}
+	if err := opening.Insert(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		month, posted, err := AdvanceFinanceMonth(db, "session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if month != want {
+			t.Fatalf("advanced to month %d, want %d", month, want)
+		}
+
+		ids := []string{}
+		for _, item := range posted {
+			if item.Month != month {
+				t.Errorf("%s was posted in month %d", item.ChargeId, item.Month)
+			}
+			ids = append(ids, item.ChargeId)
+		}
+		if !slices.Equal(ids, []string{"pension", "maintenance", "mortgage", "salary"}) {
+			t.Fatalf("month %d posted %v", month, ids)
+		}
+	}
+
+	if month, err := GetFinanceMonth(db, "session"); err != nil || month != 2 {
+		t.Fatalf("the session is in month %d, %v", month, err)
+	}
+	if month, err := GetFinanceMonth(db, "other"); err != nil || month != 0 {
+		t.Fatalf("the other session is in month %d, %v", month, err)
+	}
+
+	// 500000 less 159813 a month
+	history, err := GetBalanceHistory(db, "session", AccountType_Ship, "beowulf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []BalancePoint{{0, 500_000}, {1, 340_187}, {2, 180_374}}; !slices.Equal(history, want) {
+		t.Fatalf("balance history is %+v, want %+v", history, want)
+	}
+
+	summaries, err := GetAccountSummaries(db, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AccountSummary{
+		{AccountType_Character, "jamison", 20_000, 10_000},
+		{AccountType_Ship, "beowulf", 180_374, -159_813},
+	}
+	if !slices.Equal(summaries, want) {
+		t.Fatalf("summaries are %+v, want %+v", summaries, want)
+	}
+}
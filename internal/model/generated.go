@@ -0,0 +1,102 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type GeneratedKind string
+
+const (
+	GeneratedKind_Encounter GeneratedKind = "encounter"
+	GeneratedKind_Mission   GeneratedKind = "mission"
+	GeneratedKind_Rumour    GeneratedKind = "rumour"
+)
+
+// GeneratedResult is an encounter, patron mission or rumour the referee rolled and kept.
+// Summary is what the players get once it is revealed, Details holds the rolls as the
+// generator returned them and is only for the referee.
+type GeneratedResult struct {
+	Id        string          `json:"id"`
+	SessionId string          `json:"sessionId"`
+	Kind      GeneratedKind   `json:"kind"`
+	Summary   string          `json:"summary"`
+	Details   json.RawMessage `json:"details"`
+	// the NPC saved for the patron of a mission
+	NPCId     string    `json:"npcId"`
+	Revealed  bool      `json:"revealed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const generatedColumns = "id,session_id,kind,summary,details,npc_id,revealed,created_at"
+
+func (g *GeneratedResult) GetGeneratedResult(db *sql.DB, id string) error {
+	var details string
+	err := db.QueryRow("SELECT "+generatedColumns+" FROM generated_result WHERE id = ?;", id).
+		Scan(&g.Id, &g.SessionId, &g.Kind, &g.Summary, &details, &g.NPCId, &g.Revealed, &g.CreatedAt)
+	g.Details = json.RawMessage(details)
+	return err
+}
+
+func (g *GeneratedResult) Scan(row *sql.Rows) error {
+	var details string
+	err := row.Scan(&g.Id, &g.SessionId, &g.Kind, &g.Summary, &details, &g.NPCId, &g.Revealed, &g.CreatedAt)
+	g.Details = json.RawMessage(details)
+	return err
+}
+
+func (g *GeneratedResult) Insert(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO generated_result ("+generatedColumns+") VALUES (?,?,?,?,?,?,?,?);", g.Id, g.SessionId, g.Kind, g.Summary, string(g.Details), g.NPCId, g.Revealed, g.CreatedAt)
+	return err
+}
+
+func (g *GeneratedResult) Reveal(db *sql.DB) error {
+	_, err := db.Exec("UPDATE generated_result SET revealed = 1 WHERE id = ?;", g.Id)
+	if err == nil {
+		g.Revealed = true
+	}
+	return err
+}
+
+// PlayerView is the result without the referee's details
+func (g GeneratedResult) PlayerView() GeneratedResult {
+	view := g
+	view.Details = json.RawMessage("null")
+	return view
+}
+
+func (g *GeneratedResult) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM generated_result WHERE id = ?;", g.Id)
+	return err
+}
+
+// GetSessionGeneratedResults returns the kept results of the session newest first, the players only get the revealed ones
+func GetSessionGeneratedResults(db *sql.DB, sessionId string, kind GeneratedKind, hidden bool) ([]GeneratedResult, error) {
+	query := "SELECT " + generatedColumns + " FROM generated_result WHERE session_id = ?"
+	args := []any{sessionId}
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, kind)
+	}
+	if !hidden {
+		query += " AND revealed = 1"
+	}
+
+	rows, err := db.Query(query+" ORDER BY created_at DESC;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []GeneratedResult{}
+	for rows.Next() {
+		var item GeneratedResult
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,58 @@
+package model
+
+import "database/sql"
+
+// InventoryItem is something from the equipment catalog a character owns, either equipped or stowed
+type InventoryItem struct {
+	Id          string `json:"id"`
+	CharacterId string `json:"characterId"`
+	ItemId      string `json:"itemId"`
+	Quantity    int    `json:"quantity"`
+	Equipped    bool   `json:"equipped"`
+	// rounds left in the magazine of a weapon
+	Ammo int `json:"ammo"`
+}
+
+func (i *InventoryItem) GetInventoryItem(db *sql.DB, id string) error {
+	return db.QueryRow("SELECT id,character_id,item_id,quantity,equipped,ammo FROM inventory_item WHERE id = ?;", id).
+		Scan(&i.Id, &i.CharacterId, &i.ItemId, &i.Quantity, &i.Equipped, &i.Ammo)
+}
+
+func (i *InventoryItem) Scan(row *sql.Rows) error {
+	return row.Scan(&i.Id, &i.CharacterId, &i.ItemId, &i.Quantity, &i.Equipped, &i.Ammo)
+}
+
+func (i *InventoryItem) Insert(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO inventory_item (id,character_id,item_id,quantity,equipped,ammo) VALUES (?,?,?,?,?,?);", i.Id, i.CharacterId, i.ItemId, i.Quantity, i.Equipped, i.Ammo)
+	return err
+}
+
+func (i *InventoryItem) Update(db *sql.DB) error {
+	_, err := db.Exec("UPDATE inventory_item SET quantity = ?, equipped = ?, ammo = ? WHERE id = ?;", i.Quantity, i.Equipped, i.Ammo, i.Id)
+	return err
+}
+
+func (i *InventoryItem) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM inventory_item WHERE id = ?;", i.Id)
+	return err
+}
+
+// GetCharacterInventory returns what a character carries, equipped items first
+func GetCharacterInventory(db *sql.DB, characterId string) ([]InventoryItem, error) {
+	rows, err := db.Query("SELECT id,character_id,item_id,quantity,equipped,ammo FROM inventory_item WHERE character_id = ? ORDER BY equipped DESC,item_id;", characterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []InventoryItem{}
+	for rows.Next() {
+		var item InventoryItem
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type JournalKind string
+
+const (
+	// JournalKind_Event is an alert, sensor contact, ship damage or news item the referee sent
+	JournalKind_Event JournalKind = "event"
+	// JournalKind_Roll is a check a character rolled
+	JournalKind_Roll JournalKind = "roll"
+	// JournalKind_Trade is speculative cargo bought or sold
+	JournalKind_Trade JournalKind = "trade"
+	// JournalKind_Jump is time spent in jump space
+	JournalKind_Jump JournalKind = "jump"
+	// JournalKind_Date is the referee setting or advancing the calendar
+	JournalKind_Date JournalKind = "date"
+	// JournalKind_Combat is the outcome of a personal or space combat encounter
+	JournalKind_Combat JournalKind = "combat"
+	// JournalKind_Manual is written by the referee or a player
+	JournalKind_Manual JournalKind = "manual"
+)
+
+// JournalKinds lists every kind of entry
+var JournalKinds = []JournalKind{
+	JournalKind_Event, JournalKind_Roll, JournalKind_Trade, JournalKind_Jump,
+	JournalKind_Date, JournalKind_Combat, JournalKind_Manual,
+}
+
+func (k JournalKind) Valid() bool {
+	return slices.Contains(JournalKinds, k)
+}
+
+// JournalEntry is a line of the session journal, dated both in game and in real time.
+// Data holds the details particular to the kind of entry.
+type JournalEntry struct {
+	Id        string       `json:"id"`
+	SessionId string       `json:"sessionId"`
+	Kind      JournalKind  `json:"kind"`
+	Date      ImperialDate `json:"date"`
+	// user who wrote or caused the entry
+	Author      string `json:"author"`
+	CharacterId string `json:"characterId"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	// players the entry is for, empty when it is for everyone
+	Targets   []string        `json:"targets"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+const journalColumns = "id,session_id,kind,date,author,character_id,title,body,targets,data,created_at"
+
+func (j *JournalEntry) load(days int, targets string, data string) error {
+	j.Date = ImperialDateFromDays(days)
+	j.Data = json.RawMessage(data)
+	return json.Unmarshal([]byte(targets), &j.Targets)
+}
+
+func (j *JournalEntry) GetJournalEntry(db *sql.DB, id string) error {
+	var days int
+	var targets, data string
+	err := db.QueryRow("SELECT "+journalColumns+" FROM journal_entry WHERE id = ?;", id).
+		Scan(&j.Id, &j.SessionId, &j.Kind, &days, &j.Author, &j.CharacterId, &j.Title, &j.Body, &targets, &data, &j.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return j.load(days, targets, data)
+}
+
+func (j *JournalEntry) Scan(row *sql.Rows) error {
+	var days int
+	var targets, data string
+	if err := row.Scan(&j.Id, &j.SessionId, &j.Kind, &days, &j.Author, &j.CharacterId, &j.Title, &j.Body, &targets, &data, &j.CreatedAt); err != nil {
+		return err
+	}
+	return j.load(days, targets, data)
+}
+
+func (j *JournalEntry) Insert(db *sql.DB) error {
+	if j.Targets == nil {
+		j.Targets = []string{}
+	}
+	if j.Data == nil {
+		j.Data = json.RawMessage("{}")
+	}
+	targets, err := json.Marshal(j.Targets)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO journal_entry ("+journalColumns+") VALUES (?,?,?,?,?,?,?,?,?,?,?);",
+		j.Id, j.SessionId, j.Kind, j.Date.Days(), j.Author, j.CharacterId, j.Title, j.Body, string(targets), string(j.Data), j.CreatedAt)
+	return err
+}
+
+func (j *JournalEntry) Update(db *sql.DB) error {
+	_, err := db.Exec("UPDATE journal_entry SET character_id = ?, title = ?, body = ? WHERE id = ?;", j.CharacterId, j.Title, j.Body, j.Id)
+	return err
+}
+
+func (j *JournalEntry) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM event_ack WHERE entry_id = ?;", j.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM journal_entry WHERE id = ?;", j.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// For reports if the entry is meant for the user, the referee sees every entry
+func (j *JournalEntry) For(item *Session, userId string) bool {
+	return item.Admin == userId || len(j.Targets) == 0 || slices.Contains(j.Targets, userId)
+}
+
+// JournalFilter narrows down the entries of a session journal, zero values match everything
+type JournalFilter struct {
+	Kind        JournalKind
+	CharacterId string
+	// text to look for in the title and body
+	Search string
+	// in game dates the entries fall between, inclusive
+	From *ImperialDate
+	To   *ImperialDate
+	// only entries written after this time, for the log of a single night's play
+	Since time.Time
+}
+
+// GetSessionJournal returns the entries of the session matching the filter, ordered by in game date
+// and then by when they were written
+func GetSessionJournal(db *sql.DB, sessionId string, filter JournalFilter) ([]JournalEntry, error) {
+	query := "SELECT " + journalColumns + " FROM journal_entry WHERE session_id = ?"
+	args := []any{sessionId}
+	if filter.Kind != "" {
+		query += " AND kind = ?"
+		args = append(args, filter.Kind)
+	}
+	if filter.CharacterId != "" {
+		query += " AND character_id = ?"
+		args = append(args, filter.CharacterId)
+	}
+	if filter.Search != "" {
+		search := "%" + filter.Search + "%"
+		query += " AND (title LIKE ? OR body LIKE ?)"
+		args = append(args, search, search)
+	}
+	if filter.From != nil {
+		query += " AND date >= ?"
+		args = append(args, filter.From.Days())
+	}
+	if filter.To != nil {
+		query += " AND date <= ?"
+		args = append(args, filter.To.Days())
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since)
+	}
+
+	rows, err := db.Query(query+" ORDER BY date, created_at;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []JournalEntry{}
+	for rows.Next() {
+		var item JournalEntry
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// JournalMarkdown writes the entries as a Markdown log with a heading for each in game day.
+// Authors and characters are looked up by id in names, falling back to the id.
+func JournalMarkdown(title string, entries []JournalEntry, names map[string]string) string {
+	name := func(id string) string {
+		if value, ok := names[id]; ok {
+			return value
+		}
+		return id
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n", title)
+
+	var day *ImperialDate
+	for _, entry := range entries {
+		if day == nil || *day != entry.Date {
+			date := entry.Date
+			day = &date
+			fmt.Fprintf(&out, "\n## %s\n\n", date)
+		}
+
+		fmt.Fprintf(&out, "- **%s** %s _(%s, %s", entry.CreatedAt.Local().Format("15:04"), entry.Title, entry.Kind, name(entry.Author))
+		if entry.CharacterId != "" {
+			fmt.Fprintf(&out, " as %s", name(entry.CharacterId))
+		}
+		out.WriteString(")_\n")
+
+		if entry.Body != "" {
+			for _, line := range strings.Split(strings.TrimSpace(entry.Body), "\n") {
+				fmt.Fprintf(&out, "  > %s\n", line)
+			}
+		}
+	}
+
+	return out.String()
+}
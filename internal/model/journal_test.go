@@ -0,0 +1,113 @@
+package model
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestJournalMarkdown(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2026, 3, 14, hour, minute, 0, 0, time.Local)
+	}
+	entries := []JournalEntry{
+		{Kind: JournalKind_Jump, Date: ImperialDate{Day: 3, Year: 1105}, Author: "referee", Title: "Jumped to Efate", CreatedAt: at(19, 5)},
+		{Kind: JournalKind_Roll, Date: ImperialDate{Day: 3, Year: 1105}, Author: "alice", CharacterId: "char", Title: "Pilot 9", CreatedAt: at(19, 30)},
+		{Kind: JournalKind_Manual, Date: ImperialDate{Day: 10, Year: 1105}, Author: "bob", Title: "Arrived", Body: "\nThe port is quiet.\nToo quiet.\n", CreatedAt: at(20, 0)},
+	}
+	names := map[string]string{"referee": "Referee", "alice": "Alice", "char": "Jamison"}
+
+	want := `# Spinward Run
+
+## 003-1105
+
+- **19:05** Jumped to Efate _(jump, Referee)_
+- **19:30** Pilot 9 _(roll, Alice as Jamison)_
+
+## 010-1105
+
+- **20:00** Arrived _(manual, bob)_
+  > The port is quiet.
+  > Too quiet.
+`
+	if got := JournalMarkdown("Spinward Run", entries, names); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := JournalMarkdown("Empty", nil, nil); got != "# Empty\n" {
+		t.Fatalf("an empty journal gave %q", got)
+	}
+}
+
+func TestGetSessionJournal(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE journal_entry (id TEXT PRIMARY KEY, session_id TEXT NOT NULL, kind TEXT NOT NULL, date INTEGER NOT NULL, author TEXT NOT NULL, character_id TEXT NOT NULL DEFAULT '', title TEXT NOT NULL, body TEXT NOT NULL DEFAULT '', targets jsonb NOT NULL DEFAULT '[]', data jsonb NOT NULL DEFAULT '{}', created_at DATETIME NOT NULL);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night := time.Date(2026, 3, 14, 19, 0, 0, 0, time.UTC)
+	entries := []JournalEntry{
+		{Id: "arrived", Kind: JournalKind_Manual, Date: ImperialDate{Day: 10, Year: 1105}, Title: "Arrived at Efate", CreatedAt: night.Add(-48 * time.Hour)},
+		{Id: "jump", Kind: JournalKind_Jump, Date: ImperialDate{Day: 3, Year: 1105}, Title: "Jumped", Body: "Misjump to Efate", CreatedAt: night.Add(-72 * time.Hour)},
+		{Id: "pilot", Kind: JournalKind_Roll, Date: ImperialDate{Day: 10, Year: 1105}, CharacterId: "char", Title: "Pilot 9", Targets: []string{"alice"}, CreatedAt: night.Add(time.Hour)},
+		{Id: "bought", Kind: JournalKind_Trade, Date: ImperialDate{Day: 20, Year: 1105}, CharacterId: "char", Title: "Bought spices", CreatedAt: night.Add(2 * time.Hour)},
+		{Id: "other", SessionId: "other", Kind: JournalKind_Manual, Date: ImperialDate{Day: 10, Year: 1105}, Title: "Efate", CreatedAt: night},
+	}
+	for _, entry := range entries {
+		if entry.SessionId == "" {
+			entry.SessionId = "session"
+		}
+		if err := entry.Insert(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := ImperialDate{Day: 5, Year: 1105}
+	to := ImperialDate{Day: 10, Year: 1105}
+	tests := []struct {
+		name   string
+		filter JournalFilter
+		ids    []string
+	}{
+		// by in game date and then by when they were written
+		{"everything", JournalFilter{}, []string{"jump", "arrived", "pilot", "bought"}},
+		{"kind", JournalFilter{Kind: JournalKind_Roll}, []string{"pilot"}},
+		{"character", JournalFilter{CharacterId: "char"}, []string{"pilot", "bought"}},
+		{"search title and body", JournalFilter{Search: "efate"}, []string{"jump", "arrived"}},
+		{"dates are inclusive", JournalFilter{From: &from, To: &to}, []string{"arrived", "pilot"}},
+		{"since", JournalFilter{Since: night}, []string{"pilot", "bought"}},
+		{"combined", JournalFilter{CharacterId: "char", To: &to}, []string{"pilot"}},
+	}
+
+	for _, test := range tests {
+		found, err := GetSessionJournal(db, "session", test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, entry := range found {
+			ids = append(ids, entry.Id)
+		}
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("%s: got %v, want %v", test.name, ids, test.ids)
+		}
+	}
+
+	var entry JournalEntry
+	if err := entry.GetJournalEntry(db, "pilot"); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Date != (ImperialDate{Day: 10, Year: 1105}) || !slices.Equal(entry.Targets, []string{"alice"}) || string(entry.Data) != "{}" {
+		t.Fatalf("entry read back as %+v", entry)
+	}
+}
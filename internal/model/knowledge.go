@@ -0,0 +1,304 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// World details the referee can reveal to the players, the name and hex of a world are known once it is found
+const (
+	WorldField_Starport      = "starport"
+	WorldField_Size          = "size"
+	WorldField_Atmosphere    = "atmosphere"
+	WorldField_Hydrographics = "hydrographics"
+	WorldField_Population    = "population"
+	WorldField_Government    = "government"
+	WorldField_LawLevel      = "lawLevel"
+	WorldField_TechLevel     = "techLevel"
+	WorldField_Bases         = "bases"
+	WorldField_Zone          = "zone"
+	WorldField_TradeCodes    = "tradeCodes"
+	WorldField_GasGiants     = "gasGiants"
+	WorldField_Belts         = "belts"
+	WorldField_Notes         = "notes"
+)
+
+// WorldFields lists every field in the order they are shown
+var WorldFields = []string{
+	WorldField_Starport, WorldField_Size, WorldField_Atmosphere, WorldField_Hydrographics, WorldField_Population,
+	WorldField_Government, WorldField_LawLevel, WorldField_TechLevel, WorldField_Bases, WorldField_Zone,
+	WorldField_TradeCodes, WorldField_GasGiants, WorldField_Belts, WorldField_Notes,
+}
+
+func ValidWorldField(field string) bool {
+	return slices.Contains(WorldFields, field)
+}
+
+// ExploredHex is a hex of a sector the players have been to or scanned, UserId is empty when the whole party knows it
+type ExploredHex struct {
+	SectorId string `json:"sectorId"`
+	Hex      string `json:"hex"`
+	UserId   string `json:"userId"`
+}
+
+// WorldKnowledge is what the players know about a world, UserId is empty when the whole party knows it
+type WorldKnowledge struct {
+	WorldId string   `json:"worldId"`
+	UserId  string   `json:"userId"`
+	Fields  []string `json:"fields"`
+}
+
+// KnownWorld is a world as the players know it. Unknown digits of the profile are written as ?
+// and unknown details are left empty.
+type KnownWorld struct {
+	Id         string     `json:"id"`
+	SessionId  string     `json:"sessionId"`
+	SectorId   string     `json:"sectorId"`
+	Hex        string     `json:"hex"`
+	Name       string     `json:"name"`
+	UWP        string     `json:"uwp"`
+	Known      []string   `json:"known"`
+	Bases      []string   `json:"bases"`
+	Zone       TravelZone `json:"zone"`
+	TradeCodes []string   `json:"tradeCodes"`
+	GasGiants  *int       `json:"gasGiants"`
+	Belts      *int       `json:"belts"`
+	Notes      string     `json:"notes"`
+}
+
+// Knowledge is everything one player knows about the map, their own discoveries along with the party's
+type Knowledge struct {
+	hexes  map[string]bool
+	worlds map[string][]string
+}
+
+func hexKey(sectorId string, hex string) string {
+	return sectorId + ":" + hex
+}
+
+// Explored reports if the player knows what is in the hex
+func (k *Knowledge) Explored(sectorId string, hex string) bool {
+	return k.hexes[hexKey(sectorId, hex)]
+}
+
+// Knows reports if the player knows the world exists
+func (k *Knowledge) Knows(world *World) bool {
+	if _, ok := k.worlds[world.Id]; ok {
+		return true
+	}
+	return world.SectorId != "" && k.Explored(world.SectorId, world.Hex)
+}
+
+// Fields returns the details of the world the player knows
+func (k *Knowledge) Fields(worldId string) []string {
+	return k.worlds[worldId]
+}
+
+// View is the world with only what the player knows of it
+func (k *Knowledge) View(world World) KnownWorld {
+	fields := k.Fields(world.Id)
+	known := func(field string) bool {
+		return slices.Contains(fields, field)
+	}
+
+	digits := []struct {
+		field string
+		value string
+	}{
+		{WorldField_Starport, world.UWP.Starport},
+		{WorldField_Size, EHex(world.UWP.Size)},
+		{WorldField_Atmosphere, EHex(world.UWP.Atmosphere)},
+		{WorldField_Hydrographics, EHex(world.UWP.Hydrographics)},
+		{WorldField_Population, EHex(world.UWP.Population)},
+		{WorldField_Government, EHex(world.UWP.Government)},
+		{WorldField_LawLevel, EHex(world.UWP.LawLevel)},
+		{WorldField_TechLevel, EHex(world.UWP.TechLevel)},
+	}
+	var uwp strings.Builder
+	for i, digit := range digits {
+		if i == len(digits)-1 {
+			uwp.WriteString("-")
+		}
+		if known(digit.field) {
+			uwp.WriteString(digit.value)
+		} else {
+			uwp.WriteString("?")
+		}
+	}
+
+	view := KnownWorld{
+		Id:         world.Id,
+		SessionId:  world.SessionId,
+		SectorId:   world.SectorId,
+		Hex:        world.Hex,
+		Name:       world.Name,
+		UWP:        uwp.String(),
+		Known:      []string{},
+		Bases:      []string{},
+		TradeCodes: []string{},
+	}
+	for _, field := range WorldFields {
+		if known(field) {
+			view.Known = append(view.Known, field)
+		}
+	}
+	if known(WorldField_Bases) {
+		view.Bases = world.Bases
+	}
+	if known(WorldField_Zone) {
+		view.Zone = world.Zone
+	}
+	if known(WorldField_TradeCodes) {
+		view.TradeCodes = world.TradeCodes
+	}
+	if known(WorldField_GasGiants) {
+		view.GasGiants = &world.GasGiants
+	}
+	if known(WorldField_Belts) {
+		view.Belts = &world.Belts
+	}
+	if known(WorldField_Notes) {
+		view.Notes = world.Notes
+	}
+
+	return view
+}
+
+// Mask returns the world with the details the player does not know replaced by what they would assume,
+// no starport, no gas giants and a Green zone. Used when planning routes for the players.
+func (k *Knowledge) Mask(world World) World {
+	fields := k.Fields(world.Id)
+	masked := World{Id: world.Id, SessionId: world.SessionId, SectorId: world.SectorId, Hex: world.Hex, Name: world.Name, UWP: UWP{Starport: "X"}, Zone: TravelZone_Green}
+	if slices.Contains(fields, WorldField_Starport) {
+		masked.UWP.Starport = world.UWP.Starport
+	}
+	if slices.Contains(fields, WorldField_GasGiants) {
+		masked.GasGiants = world.GasGiants
+	}
+	if slices.Contains(fields, WorldField_Zone) {
+		masked.Zone = world.Zone
+	}
+	return masked
+}
+
+// GetKnowledge loads what a player knows in the session, along with what the whole party knows
+func GetKnowledge(db *sql.DB, sessionId string, userId string) (*Knowledge, error) {
+	knowledge := &Knowledge{hexes: map[string]bool{}, worlds: map[string][]string{}}
+
+	hexes, err := GetExploredHexes(db, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, hex := range hexes {
+		if hex.UserId == "" || hex.UserId == userId {
+			knowledge.hexes[hexKey(hex.SectorId, hex.Hex)] = true
+		}
+	}
+
+	worlds, err := GetWorldKnowledge(db, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, world := range worlds {
+		if world.UserId != "" && world.UserId != userId {
+			continue
+		}
+		fields := knowledge.worlds[world.WorldId]
+		if fields == nil {
+			fields = []string{}
+		}
+		for _, field := range world.Fields {
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+		knowledge.worlds[world.WorldId] = fields
+	}
+
+	return knowledge, nil
+}
+
+// ExploreHexes marks the hexes of a sector as explored, or unexplored when explored is false
+func ExploreHexes(db *sql.DB, sessionId string, sectorId string, userId string, hexes []string, explored bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO explored_hex (session_id,sector_id,hex,user_id) VALUES (?,?,?,?) ON CONFLICT DO NOTHING;"
+	if !explored {
+		query = "DELETE FROM explored_hex WHERE session_id = ? AND sector_id = ? AND hex = ? AND user_id = ?;"
+	}
+	for _, hex := range hexes {
+		if _, err := tx.Exec(query, sessionId, sectorId, hex, userId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetExploredHexes returns every explored hex of the session
+func GetExploredHexes(db *sql.DB, sessionId string) ([]ExploredHex, error) {
+	rows, err := db.Query("SELECT sector_id,hex,user_id FROM explored_hex WHERE session_id = ? ORDER BY sector_id, hex;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ExploredHex{}
+	for rows.Next() {
+		var item ExploredHex
+		if err := rows.Scan(&item.SectorId, &item.Hex, &item.UserId); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// SetWorldKnowledge replaces what a player, or the party, knows about a world
+func SetWorldKnowledge(db *sql.DB, sessionId string, knowledge WorldKnowledge) error {
+	fields, err := json.Marshal(knowledge.Fields)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO world_knowledge (session_id,world_id,user_id,fields) VALUES (?,?,?,?) ON CONFLICT (world_id,user_id) DO UPDATE SET fields = excluded.fields;",
+		sessionId, knowledge.WorldId, knowledge.UserId, string(fields))
+	return err
+}
+
+// ForgetWorld removes what a player, or the party, knows about a world
+func ForgetWorld(db *sql.DB, worldId string, userId string) error {
+	_, err := db.Exec("DELETE FROM world_knowledge WHERE world_id = ? AND user_id = ?;", worldId, userId)
+	return err
+}
+
+// GetWorldKnowledge returns what is known about the worlds of the session
+func GetWorldKnowledge(db *sql.DB, sessionId string) ([]WorldKnowledge, error) {
+	rows, err := db.Query("SELECT world_id,user_id,fields FROM world_knowledge WHERE session_id = ?;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []WorldKnowledge{}
+	for rows.Next() {
+		var item WorldKnowledge
+		var fields string
+		if err := rows.Scan(&item.WorldId, &item.UserId, &fields); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(fields), &item.Fields); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func testKnowledge() *Knowledge {
+	return &Knowledge{
+		hexes: map[string]bool{hexKey("spinward", "1910"): true},
+		worlds: map[string][]string{
+			"regina": {WorldField_Starport, WorldField_TechLevel, WorldField_Zone, WorldField_TradeCodes},
+			"yori":   {},
+		},
+	}
+}
+
+func testWorld(t *testing.T, id string, sectorId string, hex string) World {
+	t.Helper()
+
+	uwp, err := ParseUWP("A788899-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return World{
+		Id: id, SessionId: "session", SectorId: sectorId, Hex: hex, Name: id, UWP: uwp,
+		Bases: []string{"N"}, Zone: TravelZone_Amber, TradeCodes: []string{"Ri"}, GasGiants: 2, Belts: 1, Notes: "secret",
+	}
+}
+
+func TestKnowledgeKnows(t *testing.T) {
+	knowledge := testKnowledge()
+
+	tests := []struct {
+		world World
+		knows bool
+	}{
+		// revealed by the referee
+		{testWorld(t, "regina", "", ""), true},
+		{testWorld(t, "yori", "spinward", "0101"), true},
+		// in an explored hex
+		{testWorld(t, "efate", "spinward", "1910"), true},
+		{testWorld(t, "efate", "trojan", "1910"), false},
+		{testWorld(t, "jenghe", "spinward", "0202"), false},
+		// a world outside any sector is only known when revealed
+		{testWorld(t, "lost", "", ""), false},
+	}
+
+	for _, test := range tests {
+		if knows := knowledge.Knows(&test.world); knows != test.knows {
+			t.Errorf("%s in %q %q: knows %v", test.world.Id, test.world.SectorId, test.world.Hex, knows)
+		}
+	}
+}
+
+func TestKnowledgeView(t *testing.T) {
+	knowledge := testKnowledge()
+
+	view := knowledge.View(testWorld(t, "regina", "spinward", "1910"))
+	if view.UWP != "A??????-C" {
+		t.Fatalf("profile is %s", view.UWP)
+	}
+	if !slices.Equal(view.Known, []string{WorldField_Starport, WorldField_TechLevel, WorldField_Zone, WorldField_TradeCodes}) {
+		t.Fatalf("known fields are %v", view.Known)
+	}
+	if view.Zone != TravelZone_Amber || !slices.Equal(view.TradeCodes, []string{"Ri"}) {
+		t.Fatalf("revealed details are missing: %+v", view)
+	}
+	if len(view.Bases) != 0 || view.GasGiants != nil || view.Belts != nil || view.Notes != "" {
+		t.Fatalf("hidden details are shown: %+v", view)
+	}
+
+	// found by exploring, only the name and hex are known
+	view = knowledge.View(testWorld(t, "efate", "spinward", "1910"))
+	if view.UWP != "???????-?" || view.Name != "efate" || view.Hex != "1910" || len(view.Known) != 0 || view.Zone != "" {
+		t.Fatalf("explored world shows %+v", view)
+	}
+}
+
+func TestKnowledgeMask(t *testing.T) {
+	knowledge := testKnowledge()
+
+	masked := knowledge.Mask(testWorld(t, "regina", "spinward", "1910"))
+	if masked.UWP.Starport != "A" || masked.Zone != TravelZone_Amber || masked.GasGiants != 0 {
+		t.Fatalf("regina is masked as %+v", masked)
+	}
+	if masked.UWP.Size != 0 || masked.Notes != "" || len(masked.TradeCodes) != 0 {
+		t.Fatalf("hidden details are kept: %+v", masked)
+	}
+
+	masked = knowledge.Mask(testWorld(t, "yori", "spinward", "0101"))
+	if masked.UWP.Starport != "X" || masked.Zone != TravelZone_Green || masked.GasGiants != 0 || masked.Name != "yori" {
+		t.Fatalf("an unknown world is assumed to have nothing, got %+v", masked)
+	}
+}
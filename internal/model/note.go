@@ -0,0 +1,176 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Note is a markdown note only the referee sees
+type Note struct {
+	Id        string    `json:"id"`
+	SessionId string    `json:"sessionId"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+const noteColumns = "id,session_id,title,body,created_at,updated_at"
+
+func (n *Note) GetNote(db *sql.DB, id string) error {
+	return db.QueryRow("SELECT "+noteColumns+" FROM note WHERE id = ?;", id).
+		Scan(&n.Id, &n.SessionId, &n.Title, &n.Body, &n.CreatedAt, &n.UpdatedAt)
+}
+
+func (n *Note) Scan(row *sql.Rows) error {
+	return row.Scan(&n.Id, &n.SessionId, &n.Title, &n.Body, &n.CreatedAt, &n.UpdatedAt)
+}
+
+func (n *Note) Insert(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO note ("+noteColumns+") VALUES (?,?,?,?,?,?);", n.Id, n.SessionId, n.Title, n.Body, n.CreatedAt, n.UpdatedAt)
+	return err
+}
+
+func (n *Note) Update(db *sql.DB) error {
+	_, err := db.Exec("UPDATE note SET title = ?, body = ?, updated_at = ? WHERE id = ?;", n.Title, n.Body, n.UpdatedAt, n.Id)
+	return err
+}
+
+func (n *Note) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM note WHERE id = ?;", n.Id)
+	return err
+}
+
+// GetSessionNotes returns the notes of the session, last edited first
+func GetSessionNotes(db *sql.DB, sessionId string) ([]Note, error) {
+	rows, err := db.Query("SELECT "+noteColumns+" FROM note WHERE session_id = ? ORDER BY updated_at DESC;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Note{}
+	for rows.Next() {
+		var item Note
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+type HandoutKind string
+
+const (
+	HandoutKind_Text  HandoutKind = "text"
+	HandoutKind_Image HandoutKind = "image"
+)
+
+// Handout is a text or an image the referee hands to the players. It is shown to every
+// player when SharedAll is set and otherwise only to the players in SharedWith.
+type Handout struct {
+	Id        string      `json:"id"`
+	SessionId string      `json:"sessionId"`
+	Title     string      `json:"title"`
+	Kind      HandoutKind `json:"kind"`
+	// the text of the handout or the caption of an image
+	Body string `json:"body"`
+	// name of the image on disk
+	File        string    `json:"-"`
+	ContentType string    `json:"contentType"`
+	SharedAll   bool      `json:"sharedAll"`
+	SharedWith  []string  `json:"sharedWith"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+const handoutColumns = "id,session_id,title,kind,body,file,content_type,shared_all,shared_with,created_at"
+
+func (h *Handout) GetHandout(db *sql.DB, id string) error {
+	var shared string
+	err := db.QueryRow("SELECT "+handoutColumns+" FROM handout WHERE id = ?;", id).
+		Scan(&h.Id, &h.SessionId, &h.Title, &h.Kind, &h.Body, &h.File, &h.ContentType, &h.SharedAll, &shared, &h.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(shared), &h.SharedWith)
+}
+
+func (h *Handout) Scan(row *sql.Rows) error {
+	var shared string
+	if err := row.Scan(&h.Id, &h.SessionId, &h.Title, &h.Kind, &h.Body, &h.File, &h.ContentType, &h.SharedAll, &shared, &h.CreatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(shared), &h.SharedWith)
+}
+
+func (h *Handout) Insert(db *sql.DB) error {
+	shared, err := json.Marshal(h.SharedWith)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO handout ("+handoutColumns+") VALUES (?,?,?,?,?,?,?,?,?,?);", h.Id, h.SessionId, h.Title, h.Kind, h.Body, h.File, h.ContentType, h.SharedAll, string(shared), h.CreatedAt)
+	return err
+}
+
+func (h *Handout) Update(db *sql.DB) error {
+	shared, err := json.Marshal(h.SharedWith)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE handout SET title = ?, body = ?, shared_all = ?, shared_with = ? WHERE id = ?;", h.Title, h.Body, h.SharedAll, string(shared), h.Id)
+	return err
+}
+
+func (h *Handout) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM handout WHERE id = ?;", h.Id)
+	return err
+}
+
+// SharedTo reports if the player has been handed the handout
+func (h *Handout) SharedTo(userId string) bool {
+	return h.SharedAll || slices.Contains(h.SharedWith, userId)
+}
+
+// PlayerView is the handout without the list of who else it was shared with
+func (h Handout) PlayerView() Handout {
+	view := h
+	view.SharedWith = []string{}
+	return view
+}
+
+// SharedHandouts returns the player's view of the handouts shared with them
+func SharedHandouts(handouts []Handout, userId string) []Handout {
+	shared := []Handout{}
+	for _, handout := range handouts {
+		if handout.SharedTo(userId) {
+			shared = append(shared, handout.PlayerView())
+		}
+	}
+	return shared
+}
+
+// GetSessionHandouts returns the handouts of the session oldest first
+func GetSessionHandouts(db *sql.DB, sessionId string) ([]Handout, error) {
+	rows, err := db.Query("SELECT "+handoutColumns+" FROM handout WHERE session_id = ? ORDER BY created_at;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Handout{}
+	for rows.Next() {
+		var item Handout
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHandoutSharedTo(t *testing.T) {
+	handout := Handout{Id: "map", SharedWith: []string{"alice"}}
+	if !handout.SharedTo("alice") || handout.SharedTo("bob") {
+		t.Fatal("a handout shared with alice is only shown to alice")
+	}
+
+	handout.SharedAll = true
+	if !handout.SharedTo("alice") || !handout.SharedTo("bob") {
+		t.Fatal("a handout shared with everyone is shown to everyone")
+	}
+
+	var unshared Handout
+	if unshared.SharedTo("alice") || unshared.SharedTo("") {
+		t.Fatal("a new handout is shown to nobody")
+	}
+}
+
+func TestHandoutPlayerView(t *testing.T) {
+	handout := Handout{Id: "letter", Title: "Letter", Body: "Meet at the docks", File: "letter.png", SharedWith: []string{"alice", "bob"}}
+
+	view := handout.PlayerView()
+	if len(view.SharedWith) != 0 || view.SharedWith == nil {
+		t.Fatalf("players are shown who else has it: %v", view.SharedWith)
+	}
+	if view.Id != handout.Id || view.Title != handout.Title || view.Body != handout.Body {
+		t.Fatalf("view is %+v", view)
+	}
+	if !slices.Equal(handout.SharedWith, []string{"alice", "bob"}) {
+		t.Fatalf("the view changed the handout, shared with %v", handout.SharedWith)
+	}
+}
+
+func TestSharedHandouts(t *testing.T) {
+	handouts := []Handout{
+		{Id: "notice", SharedAll: true, SharedWith: []string{}},
+		{Id: "secret", SharedWith: []string{"bob"}},
+		{Id: "letter", SharedWith: []string{"alice", "bob"}},
+		{Id: "draft", SharedWith: []string{}},
+	}
+
+	tests := []struct {
+		user string
+		ids  []string
+	}{
+		{"alice", []string{"notice", "letter"}},
+		{"bob", []string{"notice", "secret", "letter"}},
+		{"carol", []string{"notice"}},
+	}
+
+	for _, test := range tests {
+		ids := []string{}
+		for _, handout := range SharedHandouts(handouts, test.user) {
+			if len(handout.SharedWith) != 0 {
+				t.Errorf("%s sees %s is shared with %v", test.user, handout.Id, handout.SharedWith)
+			}
+			ids = append(ids, handout.Id)
+		}
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("%s sees %v, want %v", test.user, ids, test.ids)
+		}
+	}
+
+	if shared := SharedHandouts(nil, "alice"); shared == nil || len(shared) != 0 {
+		t.Fatalf("no handouts gave %v", shared)
+	}
+}
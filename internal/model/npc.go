@@ -0,0 +1,247 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Relationship kinds a career can hand out
+const (
+	Relationship_Patron  = "patron"
+	Relationship_Ally    = "ally"
+	Relationship_Contact = "contact"
+	Relationship_Rival   = "rival"
+	Relationship_Enemy   = "enemy"
+)
+
+func ValidRelationship(kind string) bool {
+	switch kind {
+	case Relationship_Patron, Relationship_Ally, Relationship_Contact, Relationship_Rival, Relationship_Enemy:
+		return true
+	}
+	return false
+}
+
+// NPCReveal flags the parts of an NPC the players know about, the name and description are
+// known to them once the NPC is visible
+type NPCReveal struct {
+	Species         bool `json:"species"`
+	Career          bool `json:"career"`
+	Characteristics bool `json:"characteristics"`
+	Skills          bool `json:"skills"`
+}
+
+// Relationship ties an NPC to a player character
+type Relationship struct {
+	NPCId       string `json:"npcId"`
+	CharacterId string `json:"characterId"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+type NPC struct {
+	Id          string `json:"id"`
+	SessionId   string `json:"sessionId"`
+	Name        string `json:"name"`
+	Species     string `json:"species"`
+	Career      string `json:"career"`
+	Assignment  string `json:"assignment"`
+	Rank        string `json:"rank"`
+	Age         int    `json:"age"`
+	Description string `json:"description"`
+	// only the referee sees the notes
+	Notes string `json:"notes"`
+	// nil for NPCs that do not need full stats
+	Characteristics *Characteristics `json:"characteristics"`
+	Skills          map[string]int   `json:"skills"`
+	// the players know the NPC exists
+	Visible       bool           `json:"visible"`
+	Reveal        NPCReveal      `json:"reveal"`
+	Relationships []Relationship `json:"relationships"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+const npcColumns = "id,session_id,name,species,career,assignment,rank,age,description,notes,characteristics,skills,visible,reveal,created_at"
+
+func (n *NPC) GetNPC(db *sql.DB, id string) error {
+	var characteristics, skills, reveal string
+	err := db.QueryRow("SELECT "+npcColumns+" FROM npc WHERE id = ?;", id).
+		Scan(&n.Id, &n.SessionId, &n.Name, &n.Species, &n.Career, &n.Assignment, &n.Rank, &n.Age, &n.Description, &n.Notes, &characteristics, &skills, &n.Visible, &reveal, &n.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := n.load(characteristics, skills, reveal); err != nil {
+		return err
+	}
+
+	n.Relationships, err = getNPCRelationships(db, "npc_id", n.Id)
+	return err
+}
+
+func (n *NPC) load(characteristics string, skills string, reveal string) error {
+	if err := json.Unmarshal([]byte(characteristics), &n.Characteristics); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(skills), &n.Skills); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(reveal), &n.Reveal)
+}
+
+func (n *NPC) Scan(row *sql.Rows) error {
+	var characteristics, skills, reveal string
+	if err := row.Scan(&n.Id, &n.SessionId, &n.Name, &n.Species, &n.Career, &n.Assignment, &n.Rank, &n.Age, &n.Description, &n.Notes, &characteristics, &skills, &n.Visible, &reveal, &n.CreatedAt); err != nil {
+		return err
+	}
+	return n.load(characteristics, skills, reveal)
+}
+
+// marshal returns the jsonb columns of the NPC
+func (n *NPC) marshal() (string, string, string, error) {
+	characteristics, err := json.Marshal(n.Characteristics)
+	if err != nil {
+		return "", "", "", err
+	}
+	skills, err := json.Marshal(n.Skills)
+	if err != nil {
+		return "", "", "", err
+	}
+	reveal, err := json.Marshal(n.Reveal)
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(characteristics), string(skills), string(reveal), nil
+}
+
+func (n *NPC) Insert(db *sql.DB) error {
+	characteristics, skills, reveal, err := n.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO npc ("+npcColumns+") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);", n.Id, n.SessionId, n.Name, n.Species, n.Career, n.Assignment, n.Rank, n.Age, n.Description, n.Notes, characteristics, skills, n.Visible, reveal, n.CreatedAt)
+	return err
+}
+
+func (n *NPC) Update(db *sql.DB) error {
+	characteristics, skills, reveal, err := n.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE npc SET name = ?, species = ?, career = ?, assignment = ?, rank = ?, age = ?, description = ?, notes = ?, characteristics = ?, skills = ?, visible = ?, reveal = ? WHERE id = ?;",
+		n.Name, n.Species, n.Career, n.Assignment, n.Rank, n.Age, n.Description, n.Notes, characteristics, skills, n.Visible, reveal, n.Id)
+	return err
+}
+
+// Delete removes the NPC and their relationships
+func (n *NPC) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM npc_relationship WHERE npc_id = ?;", n.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM npc WHERE id = ?;", n.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetRelationship links the NPC to a character, replacing any link they already had
+func (n *NPC) SetRelationship(db *sql.DB, relationship Relationship) error {
+	_, err := db.Exec("INSERT INTO npc_relationship (npc_id,character_id,kind,description) VALUES (?,?,?,?) ON CONFLICT (npc_id,character_id) DO UPDATE SET kind = excluded.kind, description = excluded.description;",
+		n.Id, relationship.CharacterId, relationship.Kind, relationship.Description)
+	return err
+}
+
+func (n *NPC) RemoveRelationship(db *sql.DB, characterId string) (bool, error) {
+	result, err := db.Exec("DELETE FROM npc_relationship WHERE npc_id = ? AND character_id = ?;", n.Id, characterId)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// PlayerView is the NPC as the players know them, without the notes or anything not revealed
+func (n NPC) PlayerView() NPC {
+	view := n
+	view.Notes = ""
+	if !n.Reveal.Species {
+		view.Species = ""
+	}
+	if !n.Reveal.Career {
+		view.Career, view.Assignment, view.Rank = "", "", ""
+	}
+	if !n.Reveal.Characteristics {
+		view.Characteristics = nil
+	}
+	if !n.Reveal.Skills {
+		view.Skills = map[string]int{}
+	}
+	return view
+}
+
+func getNPCRelationships(db *sql.DB, column string, id string) ([]Relationship, error) {
+	rows, err := db.Query("SELECT npc_id,character_id,kind,description FROM npc_relationship WHERE "+column+" = ? ORDER BY kind;", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Relationship{}
+	for rows.Next() {
+		var item Relationship
+		if err := rows.Scan(&item.NPCId, &item.CharacterId, &item.Kind, &item.Description); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetCharacterRelationships returns the NPCs a character knows and how
+func GetCharacterRelationships(db *sql.DB, characterId string) ([]Relationship, error) {
+	return getNPCRelationships(db, "character_id", characterId)
+}
+
+// GetSessionNPCs returns the NPCs of the session with their relationships, the players only get the visible ones
+func GetSessionNPCs(db *sql.DB, sessionId string, hidden bool) ([]NPC, error) {
+	query := "SELECT " + npcColumns + " FROM npc WHERE session_id = ? AND visible = 1 ORDER BY name;"
+	if hidden {
+		query = "SELECT " + npcColumns + " FROM npc WHERE session_id = ? ORDER BY name;"
+	}
+
+	rows, err := db.Query(query, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []NPC{}
+	for rows.Next() {
+		var item NPC
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if items[i].Relationships, err = getNPCRelationships(db, "npc_id", items[i].Id); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNPCPlayerView(t *testing.T) {
+	npc := NPC{
+		Name:            "Marek Voss",
+		Species:         "Vargr",
+		Career:          "Merchant",
+		Assignment:      "Broker",
+		Rank:            "Senior Broker",
+		Description:     "A scarred trader",
+		Notes:           "Works for the pirates",
+		Characteristics: &Characteristics{STR: 7},
+		Skills:          map[string]int{"broker": 2},
+		Visible:         true,
+	}
+
+	view := npc.PlayerView()
+	if view.Notes != "" || view.Species != "" || view.Career != "" || view.Assignment != "" || view.Rank != "" || view.Characteristics != nil || len(view.Skills) != 0 {
+		t.Fatalf("nothing revealed showed %+v", view)
+	}
+	if view.Name != npc.Name || view.Description != npc.Description {
+		t.Fatalf("the name and description are always known, got %+v", view)
+	}
+
+	npc.Reveal = NPCReveal{Species: true, Career: true, Characteristics: true, Skills: true}
+	view = npc.PlayerView()
+	want := npc
+	want.Notes = ""
+	if !reflect.DeepEqual(view, want) {
+		t.Fatalf("everything revealed showed %+v", view)
+	}
+	if npc.Notes == "" {
+		t.Fatal("PlayerView changed the NPC")
+	}
+}
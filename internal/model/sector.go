@@ -0,0 +1,204 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	SectorWidth     = 32
+	SectorHeight    = 40
+	SubsectorWidth  = 8
+	SubsectorHeight = 10
+)
+
+type SectorDensity string
+
+const (
+	SectorDensity_Rift      SectorDensity = "rift"
+	SectorDensity_Sparse    SectorDensity = "sparse"
+	SectorDensity_Scattered SectorDensity = "scattered"
+	SectorDensity_Standard  SectorDensity = "standard"
+	SectorDensity_Dense     SectorDensity = "dense"
+)
+
+// Presence returns the number of dice rolled for each hex and the total needed for it to hold a system
+func (d SectorDensity) Presence() (int, int, bool) {
+	switch d {
+	case SectorDensity_Rift:
+		return 2, 12, true
+	case SectorDensity_Sparse:
+		return 1, 6, true
+	case SectorDensity_Scattered:
+		return 1, 5, true
+	case SectorDensity_Standard:
+		return 1, 4, true
+	case SectorDensity_Dense:
+		return 1, 3, true
+	}
+	return 0, 0, false
+}
+
+// Hex formats a column and row as the four digit hex number used on sector maps, 0101 is the top left
+func Hex(column int, row int) string {
+	return fmt.Sprintf("%02d%02d", column, row)
+}
+
+// ParseHex is the inverse of Hex
+func ParseHex(value string) (int, int, error) {
+	if len(value) != 4 {
+		return 0, 0, fmt.Errorf("'%s' is not a hex", value)
+	}
+	column, err := strconv.Atoi(value[:2])
+	if err != nil {
+		return 0, 0, fmt.Errorf("'%s' is not a hex", value)
+	}
+	row, err := strconv.Atoi(value[2:])
+	if err != nil {
+		return 0, 0, fmt.Errorf("'%s' is not a hex", value)
+	}
+	return column, row, nil
+}
+
+// Subsector returns the letter of the subsector a hex is in, A to P reading across the sector
+func Subsector(column int, row int) string {
+	index := (row-1)/SubsectorHeight*(SectorWidth/SubsectorWidth) + (column-1)/SubsectorWidth
+	return string(rune('A' + index))
+}
+
+// Sector is a map of hexes, a full sector is 32x40 and a lone subsector 8x10
+type Sector struct {
+	Id        string        `json:"id"`
+	SessionId string        `json:"sessionId"`
+	Name      string        `json:"name"`
+	Width     int           `json:"width"`
+	Height    int           `json:"height"`
+	Seed      int64         `json:"seed"`
+	Density   SectorDensity `json:"density"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// Contains reports if the hex is on the map
+func (s *Sector) Contains(hex string) bool {
+	column, row, err := ParseHex(hex)
+	return err == nil && column >= 1 && column <= s.Width && row >= 1 && row <= s.Height
+}
+
+func (s *Sector) GetSector(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,session_id,name,width,height,seed,density,created_at FROM sector WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return s.ScanRow(stmt.QueryRow(id))
+}
+
+func (s *Sector) ScanRow(row *sql.Row) error {
+	return row.Scan(&s.Id, &s.SessionId, &s.Name, &s.Width, &s.Height, &s.Seed, &s.Density, &s.CreatedAt)
+}
+
+func (s *Sector) Scan(row *sql.Rows) error {
+	return row.Scan(&s.Id, &s.SessionId, &s.Name, &s.Width, &s.Height, &s.Seed, &s.Density, &s.CreatedAt)
+}
+
+// Insert saves the sector along with the worlds placed in it
+func (s *Sector) Insert(db *sql.DB, worlds []World) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s.CreatedAt = time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO sector (id,session_id,name,width,height,seed,density,created_at) VALUES (?,?,?,?,?,?,?,?);", s.Id, s.SessionId, s.Name, s.Width, s.Height, s.Seed, s.Density, s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range worlds {
+		worlds[i].SessionId = s.SessionId
+		worlds[i].SectorId = s.Id
+		if err := worlds[i].insert(tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the sector, every world on it and what the players knew of them
+func (s *Sector) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM world_knowledge WHERE world_id IN (SELECT id FROM world WHERE sector_id = ?);", s.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM explored_hex WHERE sector_id = ?;", s.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM world WHERE sector_id = ?;", s.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sector WHERE id = ?;", s.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetSessionSectors returns every sector that belongs to the session
+func GetSessionSectors(db *sql.DB, sessionId string) ([]Sector, error) {
+	rows, err := db.Query("SELECT id,session_id,name,width,height,seed,density,created_at FROM sector WHERE session_id = ? ORDER BY created_at;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Sector{}
+	for rows.Next() {
+		var item Sector
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// HexDistance is the number of parsecs between two hexes. Even columns sit half a
+// hex lower than odd ones, so the offsets are converted to cube coordinates first.
+func HexDistance(a string, b string) (int, error) {
+	ac, ar, err := ParseHex(a)
+	if err != nil {
+		return 0, err
+	}
+	bc, br, err := ParseHex(b)
+	if err != nil {
+		return 0, err
+	}
+
+	cube := func(column int, row int) (int, int, int) {
+		x := column - 1
+		z := row - (x-(x&1))/2
+		return x, -x - z, z
+	}
+	ax, ay, az := cube(ac, ar)
+	bx, by, bz := cube(bc, br)
+
+	return max(abs(ax-bx), abs(ay-by), abs(az-bz)), nil
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,49 @@
+package model
+
+import "testing"
+
+func TestHexDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0101", "0101", 0},
+		// odd columns sit higher, so 0101 touches 0201 but not 0202
+		{"0101", "0201", 1},
+		{"0101", "0202", 2},
+		{"0102", "0201", 1},
+		{"0102", "0202", 1},
+		// even columns sit lower, so 0202 touches 0302 and 0303 but not 0301
+		{"0202", "0302", 1},
+		{"0202", "0303", 1},
+		{"0202", "0301", 2},
+		{"0202", "0101", 2},
+		{"0101", "0105", 4},
+		{"0101", "0301", 2},
+		{"0101", "0801", 7},
+		{"0101", "0804", 7},
+		{"0101", "0808", 11},
+		{"3240", "0101", 39 + 16},
+	}
+
+	for _, test := range tests {
+		for _, pair := range [][2]string{{test.a, test.b}, {test.b, test.a}} {
+			got, err := HexDistance(pair[0], pair[1])
+			if err != nil {
+				t.Errorf("HexDistance(%s, %s) failed: %v", pair[0], pair[1], err)
+				continue
+			}
+			if got != test.want {
+				t.Errorf("HexDistance(%s, %s) = %d, want %d", pair[0], pair[1], got, test.want)
+			}
+		}
+	}
+}
+
+func TestHexDistanceRejectsBadHexes(t *testing.T) {
+	for _, hex := range []string{"", "101", "01a1", "01011"} {
+		if _, err := HexDistance(hex, "0101"); err == nil {
+			t.Errorf("HexDistance(%q, 0101) did not fail", hex)
+		}
+	}
+}
@@ -1,5 +1,320 @@
 package model
 
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type HullConfiguration string
+
+const (
+	HullConfiguration_Standard    HullConfiguration = "standard"
+	HullConfiguration_Streamlined HullConfiguration = "streamlined"
+	HullConfiguration_Sphere      HullConfiguration = "sphere"
+	HullConfiguration_Close       HullConfiguration = "close"
+	HullConfiguration_Dispersed   HullConfiguration = "dispersed"
+	HullConfiguration_Planetoid   HullConfiguration = "planetoid"
+)
+
+var hullConfigurations = []HullConfiguration{
+	HullConfiguration_Standard,
+	HullConfiguration_Streamlined,
+	HullConfiguration_Sphere,
+	HullConfiguration_Close,
+	HullConfiguration_Dispersed,
+	HullConfiguration_Planetoid,
+}
+
+type Hull struct {
+	Tons          float64           `json:"tons"`
+	Configuration HullConfiguration `json:"configuration"`
+}
+
+type Armour struct {
+	Type   string  `json:"type"`
+	Rating int     `json:"rating"`
+	Tons   float64 `json:"tons"`
+}
+
+type Drive struct {
+	Rating int     `json:"rating"`
+	Tons   float64 `json:"tons"`
+}
+
+type PowerPlant struct {
+	Type   string  `json:"type"`
+	Output int     `json:"output"`
+	Tons   float64 `json:"tons"`
+}
+
+type Bridge struct {
+	Type string  `json:"type"`
+	Tons float64 `json:"tons"`
+}
+
+type Computer struct {
+	Model    string     `json:"model"`
+	Rating   int        `json:"rating"`
+	Software []Software `json:"software"`
+}
+
+type Software struct {
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+}
+
+type Sensors struct {
+	Grade string  `json:"grade"`
+	Tons  float64 `json:"tons"`
+}
+
+type Staterooms struct {
+	Standard  int     `json:"standard"`
+	LowBerths int     `json:"lowBerths"`
+	Tons      float64 `json:"tons"`
+}
+
+type WeaponMount struct {
+	Mount   string   `json:"mount"`
+	Weapons []string `json:"weapons"`
+	Tons    float64  `json:"tons"`
+}
+
+type Craft struct {
+	Name string  `json:"name"`
+	Tons float64 `json:"tons"`
+}
+
+// ShipDesign holds the components of a ship with the tonnage each one takes up inside the hull
+type ShipDesign struct {
+	TechLevel     int           `json:"techLevel"`
+	Hull          Hull          `json:"hull"`
+	Armour        Armour        `json:"armour"`
+	ManeuverDrive Drive         `json:"maneuverDrive"`
+	JumpDrive     Drive         `json:"jumpDrive"`
+	PowerPlant    PowerPlant    `json:"powerPlant"`
+	FuelTons      float64       `json:"fuelTons"`
+	Bridge        Bridge        `json:"bridge"`
+	Computer      Computer      `json:"computer"`
+	Sensors       Sensors       `json:"sensors"`
+	Staterooms    Staterooms    `json:"staterooms"`
+	CargoTons     float64       `json:"cargoTons"`
+	Weapons       []WeaponMount `json:"weapons"`
+	Craft         []Craft       `json:"craft"`
+}
+
+var ErrShipOverTonnage = errors.New("allocated tonnage exceeds hull tonnage")
+
+// AllocatedTons is the total tonnage used by every component of the design
+func (d *ShipDesign) AllocatedTons() float64 {
+	tons := d.Armour.Tons + d.ManeuverDrive.Tons + d.JumpDrive.Tons + d.PowerPlant.Tons +
+		d.FuelTons + d.Bridge.Tons + d.Sensors.Tons + d.Staterooms.Tons + d.CargoTons
+	for _, mount := range d.Weapons {
+		tons += mount.Tons
+	}
+	for _, craft := range d.Craft {
+		tons += craft.Tons
+	}
+	return tons
+}
+
+// Validate checks the design is complete and fits inside its hull
+func (d *ShipDesign) Validate() error {
+	if d.Hull.Tons <= 0 {
+		return errors.New("hull tonnage must be greater than 0")
+	}
+
+	valid := false
+	for _, config := range hullConfigurations {
+		if d.Hull.Configuration == config {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unknown hull configuration '%s'", d.Hull.Configuration)
+	}
+
+	if d.Armour.Rating < 0 || d.ManeuverDrive.Rating < 0 || d.JumpDrive.Rating < 0 || d.PowerPlant.Output < 0 || d.Computer.Rating < 0 {
+		return errors.New("ratings can not be negative")
+	}
+
+	if d.Staterooms.Standard < 0 || d.Staterooms.LowBerths < 0 {
+		return errors.New("stateroom counts can not be negative")
+	}
+
+	tons := []float64{d.Armour.Tons, d.ManeuverDrive.Tons, d.JumpDrive.Tons, d.PowerPlant.Tons, d.FuelTons, d.Bridge.Tons, d.Sensors.Tons, d.Staterooms.Tons, d.CargoTons}
+	for _, mount := range d.Weapons {
+		tons = append(tons, mount.Tons)
+	}
+	for _, craft := range d.Craft {
+		tons = append(tons, craft.Tons)
+	}
+	for _, t := range tons {
+		if t < 0 {
+			return errors.New("component tonnage can not be negative")
+		}
+	}
+
+	if allocated := d.AllocatedTons(); allocated > d.Hull.Tons {
+		return fmt.Errorf("%w: %.1f of %.1f tons", ErrShipOverTonnage, allocated, d.Hull.Tons)
+	}
+
+	return nil
+}
+
+type CrewMember struct {
+	CharacterId string `json:"characterId"`
+	Role        string `json:"role"`
+}
+
 type Ship struct {
-	Id string
+	Id        string       `json:"id"`
+	Name      string       `json:"name"`
+	SessionId string       `json:"sessionId"`
+	Design    ShipDesign   `json:"design"`
+	Crew      []CrewMember `json:"crew"`
+}
+
+func (s *Ship) GetShip(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,name,session_id,design FROM ship WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = s.ScanRow(stmt.QueryRow(id))
+	if err != nil {
+		return err
+	}
+
+	return s.loadCrew(db)
+}
+
+func (s *Ship) ScanRow(row *sql.Row) error {
+	var design string
+	err := row.Scan(&s.Id, &s.Name, &s.SessionId, &design)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(design), &s.Design)
+}
+
+func (s *Ship) Scan(row *sql.Rows) error {
+	var design string
+	err := row.Scan(&s.Id, &s.Name, &s.SessionId, &design)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(design), &s.Design)
+}
+
+func (s *Ship) loadCrew(db *sql.DB) error {
+	rows, err := db.Query("SELECT character_id,role FROM ship_crew WHERE ship_id = ? ORDER BY role,character_id;", s.Id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.Crew = []CrewMember{}
+	for rows.Next() {
+		var member CrewMember
+		if err := rows.Scan(&member.CharacterId, &member.Role); err != nil {
+			return err
+		}
+		s.Crew = append(s.Crew, member)
+	}
+
+	return rows.Err()
+}
+
+func (s *Ship) Insert(db *sql.DB) error {
+	design, err := json.Marshal(s.Design)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO ship (id,name,session_id,design) VALUES (?,?,?,?);", s.Id, s.Name, s.SessionId, string(design))
+	return err
+}
+
+func (s *Ship) Update(db *sql.DB) error {
+	design, err := json.Marshal(s.Design)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE ship SET name = ?, design = ? WHERE id = ?;", s.Name, string(design), s.Id)
+	return err
+}
+
+func (s *Ship) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM ship_crew WHERE ship_id = ?;", s.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM cargo WHERE ship_id = ?;", s.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM ship WHERE id = ?;", s.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetCrew assigns a character to the ship, replacing the role they had before
+func (s *Ship) SetCrew(db *sql.DB, characterId string, role string) error {
+	_, err := db.Exec("INSERT INTO ship_crew (ship_id,character_id,role) VALUES (?,?,?) ON CONFLICT (ship_id,character_id) DO UPDATE SET role = excluded.role;", s.Id, characterId, strings.TrimSpace(role))
+	if err != nil {
+		return err
+	}
+	return s.loadCrew(db)
+}
+
+func (s *Ship) RemoveCrew(db *sql.DB, characterId string) error {
+	_, err := db.Exec("DELETE FROM ship_crew WHERE ship_id = ? AND character_id = ?;", s.Id, characterId)
+	if err != nil {
+		return err
+	}
+	return s.loadCrew(db)
+}
+
+// GetSessionShips returns every ship that belongs to the session
+func GetSessionShips(db *sql.DB, sessionId string) ([]Ship, error) {
+	rows, err := db.Query("SELECT id,name,session_id,design FROM ship WHERE session_id = ? ORDER BY name;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Ship{}
+	for rows.Next() {
+		var ship Ship
+		if err := ship.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, ship)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if err := items[i].loadCrew(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
 }
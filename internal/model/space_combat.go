@@ -0,0 +1,200 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type SpaceCombatantStatus string
+
+const (
+	SpaceCombatantStatus_Active    SpaceCombatantStatus = "active"
+	SpaceCombatantStatus_Boarded   SpaceCombatantStatus = "boarded"
+	SpaceCombatantStatus_Destroyed SpaceCombatantStatus = "destroyed"
+)
+
+// Crew positions that act in space combat
+const (
+	CrewRole_Pilot    = "pilot"
+	CrewRole_Gunner   = "gunner"
+	CrewRole_Engineer = "engineer"
+	CrewRole_Sensors  = "sensors"
+)
+
+// ShipWeapon is one weapon in a mount of a ship taking part in space combat
+type ShipWeapon struct {
+	WeaponId string `json:"weaponId"`
+	Name     string `json:"name"`
+	Mount    string `json:"mount"`
+	Range    string `json:"range"`
+	Damage   string `json:"damage"`
+	// each weapon fires once a round
+	Fired    bool `json:"fired"`
+	Disabled bool `json:"disabled"`
+}
+
+// Critical is damage to one location of a ship, repeated hits on a location raise its severity
+type Critical struct {
+	Location string `json:"location"`
+	Severity int    `json:"severity"`
+}
+
+// SpaceCombatant is a ship in a space encounter. The design values are copied in when it joins so
+// damage during the fight does not change the ship itself.
+type SpaceCombatant struct {
+	Id     string `json:"id"`
+	ShipId string `json:"shipId"`
+	Name   string `json:"name"`
+	// crew skill levels keyed by CrewRole
+	Crew       map[string]int `json:"crew"`
+	HullPoints int            `json:"hullPoints"`
+	Hull       int            `json:"hull"`
+	Armour     int            `json:"armour"`
+	Thrust     int            `json:"thrust"`
+	Weapons    []ShipWeapon   `json:"weapons"`
+	Criticals  []Critical     `json:"criticals"`
+	Initiative int            `json:"initiative"`
+	// thrust spent this round moving and on evasive action
+	ThrustUsed int `json:"thrustUsed"`
+	Evasion    int `json:"evasion"`
+	// ids of the ships the sensors are locked on to
+	Locks  []string             `json:"locks"`
+	Status SpaceCombatantStatus `json:"status"`
+}
+
+// Severity is the severity of the critical at a location, zero when it has not been hit
+func (s *SpaceCombatant) Severity(location string) int {
+	for _, item := range s.Criticals {
+		if item.Location == location {
+			return item.Severity
+		}
+	}
+	return 0
+}
+
+// SpaceRange is the range band between two ships of the encounter
+type SpaceRange struct {
+	A    string `json:"a"`
+	B    string `json:"b"`
+	Band string `json:"band"`
+}
+
+// SpaceEncounter is a ship to ship fight of the session, ships are kept in initiative order once it starts
+type SpaceEncounter struct {
+	Id        string           `json:"id"`
+	SessionId string           `json:"sessionId"`
+	Name      string           `json:"name"`
+	Status    EncounterStatus  `json:"status"`
+	Round     int              `json:"round"`
+	Ships     []SpaceCombatant `json:"ships"`
+	Ranges    []SpaceRange     `json:"ranges"`
+	Log       []CombatLogEntry `json:"log"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+func (e *SpaceEncounter) GetSpaceEncounter(db *sql.DB, id string) error {
+	var ships, ranges, log string
+	err := db.QueryRow("SELECT id,session_id,name,status,round,ships,ranges,log,created_at FROM space_encounter WHERE id = ?;", id).
+		Scan(&e.Id, &e.SessionId, &e.Name, &e.Status, &e.Round, &ships, &ranges, &log, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return e.load(ships, ranges, log)
+}
+
+func (e *SpaceEncounter) load(ships string, ranges string, log string) error {
+	if err := json.Unmarshal([]byte(ships), &e.Ships); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(ranges), &e.Ranges); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(log), &e.Log)
+}
+
+func (e *SpaceEncounter) Scan(row *sql.Rows) error {
+	var ships, ranges, log string
+	if err := row.Scan(&e.Id, &e.SessionId, &e.Name, &e.Status, &e.Round, &ships, &ranges, &log, &e.CreatedAt); err != nil {
+		return err
+	}
+	return e.load(ships, ranges, log)
+}
+
+// marshal returns the jsonb columns of the encounter
+func (e *SpaceEncounter) marshal() (string, string, string, error) {
+	ships, err := json.Marshal(e.Ships)
+	if err != nil {
+		return "", "", "", err
+	}
+	ranges, err := json.Marshal(e.Ranges)
+	if err != nil {
+		return "", "", "", err
+	}
+	log, err := json.Marshal(e.Log)
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(ships), string(ranges), string(log), nil
+}
+
+func (e *SpaceEncounter) Insert(db *sql.DB) error {
+	ships, ranges, log, err := e.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO space_encounter (id,session_id,name,status,round,ships,ranges,log,created_at) VALUES (?,?,?,?,?,?,?,?,?);", e.Id, e.SessionId, e.Name, e.Status, e.Round, ships, ranges, log, e.CreatedAt)
+	return err
+}
+
+func (e *SpaceEncounter) Update(db *sql.DB) error {
+	ships, ranges, log, err := e.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE space_encounter SET name = ?, status = ?, round = ?, ships = ?, ranges = ?, log = ? WHERE id = ?;", e.Name, e.Status, e.Round, ships, ranges, log, e.Id)
+	return err
+}
+
+func (e *SpaceEncounter) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM space_encounter WHERE id = ?;", e.Id)
+	return err
+}
+
+// Ship finds a ship of the encounter by combatant id
+func (e *SpaceEncounter) Ship(id string) *SpaceCombatant {
+	for i := range e.Ships {
+		if e.Ships[i].Id == id {
+			return &e.Ships[i]
+		}
+	}
+	return nil
+}
+
+// Logf formats an entry for the encounter log of the current round
+func (e *SpaceEncounter) Logf(format string, args ...any) {
+	e.Log = append(e.Log, CombatLogEntry{Round: e.Round, Message: fmt.Sprintf(format, args...), At: time.Now().UTC()})
+}
+
+// GetSessionSpaceEncounters returns the space encounters of the session, newest first
+func GetSessionSpaceEncounters(db *sql.DB, sessionId string) ([]SpaceEncounter, error) {
+	rows, err := db.Query("SELECT id,session_id,name,status,round,ships,ranges,log,created_at FROM space_encounter WHERE session_id = ? ORDER BY created_at DESC;", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []SpaceEncounter{}
+	for rows.Next() {
+		var item SpaceEncounter
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
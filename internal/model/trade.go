@@ -0,0 +1,218 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrNotEnoughGoods = errors.New("not enough tons of the good on sale")
+var ErrNotEnoughCargo = errors.New("not enough tons in the cargo lot")
+
+// MarketGood is the rolled part of a good in a market, prices are worked out from the
+// rolls when they are shown so the Broker skill of whoever is looking can be applied.
+type MarketGood struct {
+	GoodId string `json:"goodId"`
+	// tons left to buy, zero when the good is not sold on the world
+	Tons         int `json:"tons"`
+	PurchaseRoll int `json:"purchaseRoll"`
+	SaleRoll     int `json:"saleRoll"`
+}
+
+// Market is the snapshot of what a world is buying and selling during one week of the session
+type Market struct {
+	WorldId   string       `json:"worldId"`
+	SessionId string       `json:"sessionId"`
+	Week      int          `json:"week"`
+	Goods     []MarketGood `json:"goods"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// MarketWeek is the week of the session a date falls in
+func MarketWeek(date ImperialDate) int {
+	return date.Days() / DaysPerWeek
+}
+
+func (m *Market) GetMarket(db *sql.DB, worldId string, week int) error {
+	var goods string
+	err := db.QueryRow("SELECT world_id,session_id,week,goods,created_at FROM market WHERE world_id = ? AND week = ?;", worldId, week).Scan(&m.WorldId, &m.SessionId, &m.Week, &goods, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(goods), &m.Goods)
+}
+
+// Insert saves the snapshot, if another request rolled the same week first that snapshot is loaded instead
+func (m *Market) Insert(db *sql.DB) error {
+	goods, err := json.Marshal(m.Goods)
+	if err != nil {
+		return err
+	}
+	m.CreatedAt = time.Now().UTC()
+
+	_, err = db.Exec("INSERT INTO market (world_id,session_id,week,goods,created_at) VALUES (?,?,?,?,?) ON CONFLICT (world_id,week) DO NOTHING;", m.WorldId, m.SessionId, m.Week, string(goods), m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return m.GetMarket(db, m.WorldId, m.Week)
+}
+
+// CargoLot is a purchase of speculative goods sitting in a ships hold
+type CargoLot struct {
+	Id        string `json:"id"`
+	SessionId string `json:"sessionId"`
+	ShipId    string `json:"shipId"`
+	GoodId    string `json:"goodId"`
+	Name      string `json:"name"`
+	Tons      int    `json:"tons"`
+	// price per ton paid for the goods
+	PurchasePrice int64     `json:"purchasePrice"`
+	WorldId       string    `json:"worldId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (c *CargoLot) GetCargoLot(db *sql.DB, id string) error {
+	return db.QueryRow("SELECT id,session_id,ship_id,good_id,name,tons,purchase_price,world_id,created_at FROM cargo WHERE id = ?;", id).
+		Scan(&c.Id, &c.SessionId, &c.ShipId, &c.GoodId, &c.Name, &c.Tons, &c.PurchasePrice, &c.WorldId, &c.CreatedAt)
+}
+
+func (c *CargoLot) Scan(row *sql.Rows) error {
+	return row.Scan(&c.Id, &c.SessionId, &c.ShipId, &c.GoodId, &c.Name, &c.Tons, &c.PurchasePrice, &c.WorldId, &c.CreatedAt)
+}
+
+// GetShipCargo returns the cargo lots in the hold of a ship, oldest first
+func GetShipCargo(db *sql.DB, shipId string) ([]CargoLot, error) {
+	rows, err := db.Query("SELECT id,session_id,ship_id,good_id,name,tons,purchase_price,world_id,created_at FROM cargo WHERE ship_id = ? ORDER BY created_at;", shipId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []CargoLot{}
+	for rows.Next() {
+		var item CargoLot
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// CargoTons is the tons of the hold taken up by speculative cargo and the freight and mail the ship has accepted
+func CargoTons(db *sql.DB, shipId string) (int, error) {
+	var tons int
+	err := db.QueryRow("SELECT (SELECT COALESCE(SUM(tons),0) FROM cargo WHERE ship_id = ?) + (SELECT COALESCE(SUM(tons),0) FROM contract WHERE ship_id = ? AND status = 'accepted' AND kind != 'passenger');", shipId, shipId).Scan(&tons)
+	return tons, err
+}
+
+// BuyCargo takes the tons of the lot out of the market, loads the lot into the ship
+// and charges the ships account for it in one transaction.
+func BuyCargo(db *sql.DB, market *Market, lot *CargoLot, description string) (Transaction, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer tx.Rollback()
+
+	var goods string
+	if err := tx.QueryRow("SELECT goods FROM market WHERE world_id = ? AND week = ?;", market.WorldId, market.Week).Scan(&goods); err != nil {
+		return Transaction{}, err
+	}
+	if err := json.Unmarshal([]byte(goods), &market.Goods); err != nil {
+		return Transaction{}, err
+	}
+
+	found := false
+	for i := range market.Goods {
+		if market.Goods[i].GoodId != lot.GoodId {
+			continue
+		}
+		if market.Goods[i].Tons < lot.Tons {
+			return Transaction{}, ErrNotEnoughGoods
+		}
+		market.Goods[i].Tons -= lot.Tons
+		found = true
+	}
+	if !found {
+		return Transaction{}, ErrNotEnoughGoods
+	}
+
+	data, err := json.Marshal(market.Goods)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if _, err := tx.Exec("UPDATE market SET goods = ? WHERE world_id = ? AND week = ?;", string(data), market.WorldId, market.Week); err != nil {
+		return Transaction{}, err
+	}
+
+	lot.CreatedAt = time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO cargo (id,session_id,ship_id,good_id,name,tons,purchase_price,world_id,created_at) VALUES (?,?,?,?,?,?,?,?,?);",
+		lot.Id, lot.SessionId, lot.ShipId, lot.GoodId, lot.Name, lot.Tons, lot.PurchasePrice, lot.WorldId, lot.CreatedAt)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	item := Transaction{
+		SessionId:   lot.SessionId,
+		AccountType: AccountType_Ship,
+		AccountId:   lot.ShipId,
+		Amount:      -lot.PurchasePrice * int64(lot.Tons),
+		Category:    ChargeCategory_Trade,
+		Description: description,
+	}
+	if item.Month, err = financeMonth(tx, item.SessionId); err != nil {
+		return Transaction{}, err
+	}
+	if err := item.insert(tx); err != nil {
+		return Transaction{}, err
+	}
+
+	return item, tx.Commit()
+}
+
+// SellCargo unloads tons from a cargo lot and credits the ships account with the sale,
+// the lot is removed once it is empty.
+func SellCargo(db *sql.DB, lot *CargoLot, tons int, pricePerTon int64, description string) (Transaction, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("SELECT tons FROM cargo WHERE id = ?;", lot.Id).Scan(&lot.Tons); err != nil {
+		return Transaction{}, err
+	}
+	if tons > lot.Tons {
+		return Transaction{}, ErrNotEnoughCargo
+	}
+	lot.Tons -= tons
+
+	if lot.Tons == 0 {
+		_, err = tx.Exec("DELETE FROM cargo WHERE id = ?;", lot.Id)
+	} else {
+		_, err = tx.Exec("UPDATE cargo SET tons = ? WHERE id = ?;", lot.Tons, lot.Id)
+	}
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	item := Transaction{
+		SessionId:   lot.SessionId,
+		AccountType: AccountType_Ship,
+		AccountId:   lot.ShipId,
+		Amount:      pricePerTon * int64(tons),
+		Category:    ChargeCategory_Trade,
+		Description: description,
+	}
+	if item.Month, err = financeMonth(tx, item.SessionId); err != nil {
+		return Transaction{}, err
+	}
+	if err := item.insert(tx); err != nil {
+		return Transaction{}, err
+	}
+
+	return item, tx.Commit()
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"database/sql"
 	"encoding/json"
+	"strings"
 )
 
 type User struct {
@@ -56,3 +57,32 @@ func (u *User) Scan(row *sql.Rows) error {
 
 	return nil
 }
+
+// GetUsernames maps each of the given user ids to their username
+func GetUsernames(db *sql.DB, ids []string) (map[string]string, error) {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := db.Query("SELECT id,username FROM user WHERE id IN (?"+strings.Repeat(",?", len(ids)-1)+");", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		names[id] = username
+	}
+
+	return names, rows.Err()
+}
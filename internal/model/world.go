@@ -0,0 +1,339 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ehex digits skip I and O so they are not mistaken for 1 and 0
+const ehexDigits = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// EHex formats a value as an extended hex digit
+func EHex(value int) string {
+	if value < 0 || value >= len(ehexDigits) {
+		return "?"
+	}
+	return string(ehexDigits[value])
+}
+
+// ParseEHex reads a single extended hex digit
+func ParseEHex(value byte) (int, error) {
+	i := strings.IndexByte(ehexDigits, strings.ToUpper(string(value))[0])
+	if i < 0 {
+		return 0, fmt.Errorf("'%c' is not an ehex digit", value)
+	}
+	return i, nil
+}
+
+var ErrInvalidUWP = errors.New("invalid UWP")
+
+// starports A to E and X, the spaceports F, G, H and Y of secondary worlds, and ? when it is not known
+var starports = "ABCDEFGHXY?"
+
+// UWP_Unknown is the value of a profile digit written as ?
+const UWP_Unknown = -1
+
+// parseUWPDigit reads a profile digit, ? is allowed for a value that is not known
+func parseUWPDigit(value byte) (int, error) {
+	if value == '?' {
+		return UWP_Unknown, nil
+	}
+	return ParseEHex(value)
+}
+
+// UWP is the Universal World Profile of a world, written as A788899-C. Digits that are not known are ?.
+type UWP struct {
+	Starport      string `json:"starport"`
+	Size          int    `json:"size"`
+	Atmosphere    int    `json:"atmosphere"`
+	Hydrographics int    `json:"hydrographics"`
+	Population    int    `json:"population"`
+	Government    int    `json:"government"`
+	LawLevel      int    `json:"lawLevel"`
+	TechLevel     int    `json:"techLevel"`
+}
+
+func ParseUWP(value string) (UWP, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) != 9 || value[7] != '-' {
+		return UWP{}, fmt.Errorf("%w: '%s' is not in the form A788899-C", ErrInvalidUWP, value)
+	}
+
+	uwp := UWP{Starport: value[0:1]}
+	if !strings.Contains(starports, uwp.Starport) {
+		return UWP{}, fmt.Errorf("%w: unknown starport '%s'", ErrInvalidUWP, uwp.Starport)
+	}
+
+	fields := []*int{&uwp.Size, &uwp.Atmosphere, &uwp.Hydrographics, &uwp.Population, &uwp.Government, &uwp.LawLevel}
+	for i, field := range fields {
+		digit, err := parseUWPDigit(value[i+1])
+		if err != nil {
+			return UWP{}, fmt.Errorf("%w: %s", ErrInvalidUWP, err.Error())
+		}
+		*field = digit
+	}
+
+	tl, err := parseUWPDigit(value[8])
+	if err != nil {
+		return UWP{}, fmt.Errorf("%w: %s", ErrInvalidUWP, err.Error())
+	}
+	uwp.TechLevel = tl
+
+	return uwp, uwp.Validate()
+}
+
+// Validate checks each value is in the range the rules allow
+func (u UWP) Validate() error {
+	if !strings.Contains(starports, u.Starport) || len(u.Starport) != 1 {
+		return fmt.Errorf("%w: unknown starport '%s'", ErrInvalidUWP, u.Starport)
+	}
+	limits := []struct {
+		name  string
+		value int
+		max   int
+	}{
+		{"size", u.Size, 15},
+		{"atmosphere", u.Atmosphere, 15},
+		{"hydrographics", u.Hydrographics, 10},
+		{"population", u.Population, 15},
+		{"government", u.Government, 15},
+		{"law level", u.LawLevel, 20},
+		{"tech level", u.TechLevel, 33},
+	}
+	for _, limit := range limits {
+		if limit.value == UWP_Unknown {
+			continue
+		}
+		if limit.value < 0 || limit.value > limit.max {
+			return fmt.Errorf("%w: %s must be between 0 and %s", ErrInvalidUWP, limit.name, EHex(limit.max))
+		}
+	}
+	return nil
+}
+
+func (u UWP) String() string {
+	return u.Starport + EHex(u.Size) + EHex(u.Atmosphere) + EHex(u.Hydrographics) + EHex(u.Population) + EHex(u.Government) + EHex(u.LawLevel) + "-" + EHex(u.TechLevel)
+}
+
+// MarshalJSON includes the written form of the profile alongside its values
+func (u UWP) MarshalJSON() ([]byte, error) {
+	type values UWP
+	return json.Marshal(struct {
+		values
+		Code string `json:"code"`
+	}{values(u), u.String()})
+}
+
+func between(value int, min int, max int) bool {
+	return value >= min && value <= max
+}
+
+func oneOf(value int, values ...int) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// TradeCodes derives the trade classifications of the world from its profile
+func (u UWP) TradeCodes() []string {
+	codes := []string{}
+	add := func(code string, ok bool) {
+		if ok {
+			codes = append(codes, code)
+		}
+	}
+
+	add("Ag", between(u.Atmosphere, 4, 9) && between(u.Hydrographics, 4, 8) && between(u.Population, 5, 7))
+	add("As", u.Size == 0 && u.Atmosphere == 0 && u.Hydrographics == 0)
+	add("Ba", u.Population == 0 && u.Government == 0 && u.LawLevel == 0)
+	add("De", between(u.Atmosphere, 2, 9) && u.Hydrographics == 0)
+	add("Fl", u.Atmosphere >= 10 && u.Hydrographics >= 1)
+	add("Ga", between(u.Size, 6, 8) && oneOf(u.Atmosphere, 5, 6, 8) && between(u.Hydrographics, 5, 7))
+	add("Hi", u.Population >= 9)
+	add("Ht", u.TechLevel >= 12)
+	add("Ic", between(u.Atmosphere, 0, 1) && u.Hydrographics >= 1)
+	add("In", oneOf(u.Atmosphere, 0, 1, 2, 4, 7, 9, 10, 11, 12) && u.Population >= 9)
+	add("Lo", between(u.Population, 1, 3))
+	add("Lt", u.Population >= 1 && u.TechLevel != UWP_Unknown && u.TechLevel <= 5)
+	add("Na", between(u.Atmosphere, 0, 3) && between(u.Hydrographics, 0, 3) && u.Population >= 6)
+	add("Ni", between(u.Population, 4, 6))
+	add("Po", between(u.Atmosphere, 2, 5) && between(u.Hydrographics, 0, 3))
+	add("Ri", oneOf(u.Atmosphere, 6, 8) && between(u.Population, 6, 8) && between(u.Government, 4, 9))
+	add("Va", u.Atmosphere == 0)
+	add("Wa", (between(u.Atmosphere, 3, 9) || u.Atmosphere == 13) && u.Hydrographics == 10)
+
+	return codes
+}
+
+// Base codes as used in sector data
+const (
+	Base_Naval    = "N"
+	Base_Scout    = "S"
+	Base_Military = "M"
+	Base_Corsair  = "C"
+	Base_Way      = "W"
+)
+
+var baseCodes = []string{Base_Naval, Base_Scout, Base_Military, Base_Corsair, Base_Way}
+
+func ValidBase(code string) bool {
+	for _, item := range baseCodes {
+		if code == item {
+			return true
+		}
+	}
+	return false
+}
+
+type TravelZone string
+
+const (
+	TravelZone_Green TravelZone = "green"
+	TravelZone_Amber TravelZone = "amber"
+	TravelZone_Red   TravelZone = "red"
+)
+
+func (z TravelZone) Valid() bool {
+	return z == TravelZone_Green || z == TravelZone_Amber || z == TravelZone_Red
+}
+
+type World struct {
+	Id        string `json:"id"`
+	SessionId string `json:"sessionId"`
+	// sector and hex the world sits in, empty for worlds that are not on a map
+	SectorId   string     `json:"sectorId"`
+	Hex        string     `json:"hex"`
+	Name       string     `json:"name"`
+	UWP        UWP        `json:"uwp"`
+	Bases      []string   `json:"bases"`
+	Zone       TravelZone `json:"zone"`
+	TradeCodes []string   `json:"tradeCodes"`
+	GasGiants  int        `json:"gasGiants"`
+	Belts      int        `json:"belts"`
+	Notes      string     `json:"notes"`
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (w *World) GetWorld(db *sql.DB, id string) error {
+	stmt, err := db.Prepare("SELECT id,session_id,sector_id,hex,name,uwp,bases,zone,gas_giants,belts,notes FROM world WHERE id = ?;")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return w.ScanRow(stmt.QueryRow(id))
+}
+
+func (w *World) load(uwp string, bases string) error {
+	var err error
+	if w.UWP, err = ParseUWP(uwp); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(bases), &w.Bases); err != nil {
+		return err
+	}
+	w.TradeCodes = w.UWP.TradeCodes()
+	return nil
+}
+
+func (w *World) ScanRow(row *sql.Row) error {
+	var uwp, bases string
+	err := row.Scan(&w.Id, &w.SessionId, &w.SectorId, &w.Hex, &w.Name, &uwp, &bases, &w.Zone, &w.GasGiants, &w.Belts, &w.Notes)
+	if err != nil {
+		return err
+	}
+	return w.load(uwp, bases)
+}
+
+func (w *World) Scan(row *sql.Rows) error {
+	var uwp, bases string
+	err := row.Scan(&w.Id, &w.SessionId, &w.SectorId, &w.Hex, &w.Name, &uwp, &bases, &w.Zone, &w.GasGiants, &w.Belts, &w.Notes)
+	if err != nil {
+		return err
+	}
+	return w.load(uwp, bases)
+}
+
+func (w *World) insert(db execer) error {
+	bases, err := json.Marshal(w.Bases)
+	if err != nil {
+		return err
+	}
+	w.TradeCodes = w.UWP.TradeCodes()
+
+	_, err = db.Exec("INSERT INTO world (id,session_id,sector_id,hex,name,uwp,bases,zone,gas_giants,belts,notes) VALUES (?,?,?,?,?,?,?,?,?,?,?);",
+		w.Id, w.SessionId, w.SectorId, w.Hex, w.Name, w.UWP.String(), string(bases), w.Zone, w.GasGiants, w.Belts, w.Notes)
+	return err
+}
+
+func (w *World) Insert(db *sql.DB) error {
+	return w.insert(db)
+}
+
+func (w *World) Update(db *sql.DB) error {
+	bases, err := json.Marshal(w.Bases)
+	if err != nil {
+		return err
+	}
+	w.TradeCodes = w.UWP.TradeCodes()
+
+	_, err = db.Exec("UPDATE world SET name = ?, uwp = ?, bases = ?, zone = ?, gas_giants = ?, belts = ?, notes = ? WHERE id = ?;", w.Name, w.UWP.String(), string(bases), w.Zone, w.GasGiants, w.Belts, w.Notes, w.Id)
+	return err
+}
+
+// Delete removes the world and what the players knew of it
+func (w *World) Delete(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM world_knowledge WHERE world_id = ?;", w.Id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM world WHERE id = ?;", w.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func queryWorlds(db *sql.DB, query string, args ...any) ([]World, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []World{}
+	for rows.Next() {
+		var item World
+		if err := item.Scan(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetSessionWorlds returns every world that belongs to the session
+func GetSessionWorlds(db *sql.DB, sessionId string) ([]World, error) {
+	return queryWorlds(db, "SELECT id,session_id,sector_id,hex,name,uwp,bases,zone,gas_giants,belts,notes FROM world WHERE session_id = ? ORDER BY name;", sessionId)
+}
+
+// GetSectorWorlds returns the worlds of a sector in hex order
+func GetSectorWorlds(db *sql.DB, sectorId string) ([]World, error) {
+	return queryWorlds(db, "SELECT id,session_id,sector_id,hex,name,uwp,bases,zone,gas_giants,belts,notes FROM world WHERE sector_id = ? ORDER BY hex;", sectorId)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseUWP(t *testing.T) {
+	tests := []struct {
+		value string
+		want  UWP
+	}{
+		{"A788899-C", UWP{Starport: "A", Size: 7, Atmosphere: 8, Hydrographics: 8, Population: 8, Government: 9, LawLevel: 9, TechLevel: 12}},
+		{"x000000-0", UWP{Starport: "X"}},
+		{"F6A4300-8", UWP{Starport: "F", Size: 6, Atmosphere: 10, Hydrographics: 4, Population: 3, TechLevel: 8}},
+		{"G200000-0", UWP{Starport: "G", Size: 2}},
+		{"H532200-5", UWP{Starport: "H", Size: 5, Atmosphere: 3, Hydrographics: 2, Population: 2, TechLevel: 5}},
+		{"Y300000-0", UWP{Starport: "Y", Size: 3}},
+		{"?7??5??-?", UWP{Starport: "?", Size: 7, Atmosphere: UWP_Unknown, Hydrographics: UWP_Unknown, Population: 5, Government: UWP_Unknown, LawLevel: UWP_Unknown, TechLevel: UWP_Unknown}},
+	}
+
+	for _, test := range tests {
+		uwp, err := ParseUWP(test.value)
+		if err != nil {
+			t.Errorf("ParseUWP(%q) failed: %v", test.value, err)
+			continue
+		}
+		if uwp != test.want {
+			t.Errorf("ParseUWP(%q) = %+v, want %+v", test.value, uwp, test.want)
+		}
+	}
+}
+
+func TestParseUWPRejects(t *testing.T) {
+	for _, value := range []string{"", "A788899C", "I788899-C", "Z788899-C", "A788899-", "A7888!9-C", "A78B899-C", "A7888L9-C"} {
+		if _, err := ParseUWP(value); !errors.Is(err, ErrInvalidUWP) {
+			t.Errorf("ParseUWP(%q) = %v, want ErrInvalidUWP", value, err)
+		}
+	}
+}
+
+func TestUWPStringRoundTrips(t *testing.T) {
+	for _, value := range []string{"A788899-C", "E000000-0", "F6A4300-8", "H532200-5", "Y300000-0", "???????-?"} {
+		uwp, err := ParseUWP(value)
+		if err != nil {
+			t.Fatalf("ParseUWP(%q) failed: %v", value, err)
+		}
+		if uwp.String() != value {
+			t.Errorf("%q was written back as %q", value, uwp.String())
+		}
+	}
+}
+
+func TestTradeCodesIgnoreUnknownValues(t *testing.T) {
+	tests := []struct {
+		uwp   string
+		codes []string
+	}{
+		// only the known size is used, an unknown atmosphere is not a vacuum
+		{"?0?0???-?", []string{}},
+		// an unknown tech level is not a low one
+		{"A788899-?", []string{"Ri"}},
+		{"A78889?-3", []string{"Lt", "Ri"}},
+		// an unknown population is neither low nor high
+		{"A788?99-3", []string{}},
+	}
+
+	for _, test := range tests {
+		uwp, err := ParseUWP(test.uwp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codes := uwp.TradeCodes(); !slices.Equal(codes, test.codes) {
+			t.Errorf("%s has trade codes %v, want %v", test.uwp, codes, test.codes)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterNotePages(e *echo.Echo, h *handler.Handler) {
+	notes := e.Group("/session/:sessionId/notes")
+
+	notes.Use(sessionRequired)
+
+	notes.GET("", h.ListNotes).Name = "notes"
+	notes.POST("", h.CreateNote).Name = "note-create"
+	notes.GET("/:noteId", h.GetNote).Name = "note"
+	notes.PUT("/:noteId", h.UpdateNote).Name = "note-update"
+	notes.DELETE("/:noteId", h.DeleteNote).Name = "note-delete"
+
+	handouts := e.Group("/session/:sessionId/handouts")
+
+	handouts.Use(sessionRequired)
+
+	handouts.GET("", h.ListHandouts).Name = "handouts"
+	handouts.POST("", h.CreateHandout).Name = "handout-create"
+	handouts.GET("/:handoutId", h.GetHandout).Name = "handout"
+	handouts.GET("/:handoutId/image", h.HandoutImage).Name = "handout-image"
+	handouts.PUT("/:handoutId", h.UpdateHandout).Name = "handout-update"
+	handouts.PUT("/:handoutId/share", h.ShareHandout).Name = "handout-share"
+	handouts.DELETE("/:handoutId", h.DeleteHandout).Name = "handout-delete"
+}
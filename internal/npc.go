@@ -0,0 +1,23 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterNPCPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/npcs")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListNPCs).Name = "npcs"
+	g.POST("", h.CreateNPC).Name = "npc-create"
+	g.GET("/tables", h.ListNPCTables).Name = "npc-tables"
+	g.POST("/generate", h.GenerateNPC).Name = "npc-generate"
+	g.GET("/:npcId", h.GetNPC).Name = "npc"
+	g.PUT("/:npcId", h.UpdateNPC).Name = "npc-update"
+	g.DELETE("/:npcId", h.DeleteNPC).Name = "npc-delete"
+	g.PUT("/:npcId/relationships/:characterId", h.SetRelationship).Name = "npc-relationship"
+	g.DELETE("/:npcId/relationships/:characterId", h.RemoveRelationship).Name = "npc-relationship-delete"
+}
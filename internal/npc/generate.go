@@ -0,0 +1,96 @@
+package npc
+
+import (
+	"errors"
+	"strings"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+var (
+	ErrUnknownSpecies = errors.New("unknown species")
+	ErrUnknownCareer  = errors.New("unknown career")
+)
+
+// Options pin parts of a generated NPC, anything left empty is rolled
+type Options struct {
+	Species string
+	Career  string
+}
+
+// skillId turns the name of a skill in the career tables into the id used on character sheets
+func (t *Tables) skillId(name string) string {
+	if skill, ok := t.skillByName(name); ok {
+		return skill.Id
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+// Generate rolls up an NPC: 2D for each characteristic with the species modifiers, a career and
+// assignment, a rank from the terms served with its bonuses and a skill for each term.
+func (t *Tables) Generate(r *dice.Roller, opts Options) (model.NPC, error) {
+	var species Species
+	if opts.Species == "" {
+		species = t.Species[r.Pick(len(t.Species))]
+	} else if item, ok := t.SpeciesById(opts.Species); ok {
+		species = item
+	} else {
+		return model.NPC{}, ErrUnknownSpecies
+	}
+
+	characteristics := model.Characteristics{}
+	for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END, model.Characteristic_INT, model.Characteristic_EDU, model.Characteristic_SOC} {
+		characteristics.Add(code, max(r.D6(2)+species.Modifiers[code], 1))
+	}
+
+	terms := 1 + r.Pick(t.MaxTerms)
+	result := model.NPC{
+		Name:            t.FirstNames[r.Pick(len(t.FirstNames))] + " " + t.LastNames[r.Pick(len(t.LastNames))],
+		Species:         species.Name,
+		Age:             18 + terms*4,
+		Characteristics: &characteristics,
+		Skills:          map[string]int{},
+	}
+
+	if opts.Career != "" || len(t.Careers) > 0 {
+		var career Career
+		if opts.Career == "" {
+			career = t.Careers[r.Pick(len(t.Careers))]
+		} else if item, ok := t.Career(opts.Career); ok {
+			career = item
+		} else {
+			return model.NPC{}, ErrUnknownCareer
+		}
+		result.Career = career.Name
+
+		if len(career.Assignments) > 0 {
+			assignment := career.Assignments[r.Pick(len(career.Assignments))]
+			result.Assignment = assignment.Name
+
+			// rank bonuses are gained on the way up, not just at the final rank
+			level := r.Pick(terms + 1)
+			for _, rank := range career.Ranks[assignment.Id] {
+				if rank.Level > level {
+					break
+				}
+				result.Rank = rank.Name
+				if rank.Bonus == nil {
+					continue
+				}
+				if model.ValidCharacteristic(rank.Bonus.Skill) {
+					characteristics.Add(rank.Bonus.Skill, rank.Bonus.Value)
+				} else {
+					id := t.skillId(rank.Bonus.Skill)
+					result.Skills[id] = max(result.Skills[id], rank.Bonus.Value)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < terms && len(t.Skills) > 0; i++ {
+		skill := t.Skills[r.Pick(len(t.Skills))]
+		result.Skills[skill.Id]++
+	}
+
+	return result, nil
+}
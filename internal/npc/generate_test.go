@@ -0,0 +1,128 @@
+package npc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+// fixedSource returns the values given in order from Intn, a D6 face is one more than its value
+type fixedSource struct {
+	t      *testing.T
+	values []int
+}
+
+func (s *fixedSource) Intn(n int) int {
+	s.t.Helper()
+	if len(s.values) == 0 {
+		s.t.Fatal("ran out of rolls")
+	}
+	value := s.values[0]
+	s.values = s.values[1:]
+	if value < 0 || value >= n {
+		s.t.Fatalf("roll %d is out of [0,%d)", value, n)
+	}
+	return value
+}
+
+func testTables() *Tables {
+	return &Tables{
+		Species: []Species{
+			{Id: "human", Name: "Human", Modifiers: map[string]int{}},
+			{Id: "aslan", Name: "Aslan", Modifiers: map[string]int{model.Characteristic_STR: 2, model.Characteristic_DEX: -2}},
+		},
+		FirstNames: []string{"Adan", "Bel"},
+		LastNames:  []string{"Cole", "Dane"},
+		MaxTerms:   3,
+		Careers: []Career{{
+			Id:          "agent",
+			Name:        "Agent",
+			Assignments: []Assignment{{Id: "law_enforcement_agent", Name: "Law Enforcement"}},
+			Ranks: map[string][]Rank{"law_enforcement_agent": {
+				{Name: "Rookie", Level: 0},
+				{Name: "Corporal", Level: 1, Bonus: &Bonus{Skill: "Streetwise", Value: 1}},
+				{Name: "Sergeant", Level: 2, Bonus: &Bonus{Skill: model.Characteristic_END, Value: 1}},
+				{Name: "Lieutenant", Level: 3, Bonus: &Bonus{Skill: "Heavy Weapons", Value: 2}},
+				{Name: "Captain", Level: 4, Bonus: &Bonus{Skill: "Leadership", Value: 1}},
+			}},
+		}},
+		Skills: []Skill{{Id: "streetwise", Name: "Streetwise"}, {Id: "admin", Name: "Admin"}},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	r := dice.New(&fixedSource{t: t, values: []int{
+		// 2D for STR, DEX, END, INT, EDU and SOC
+		5, 5, 0, 0, 2, 3, 3, 3, 4, 4, 1, 1,
+		// 3 terms, Bel Cole, the only assignment and rank level 3
+		2, 1, 0, 0, 3,
+		// a skill for each term
+		0, 1, 0,
+	}})
+
+	npc, err := testTables().Generate(r, Options{Species: "aslan", Career: "agent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if npc.Name != "Bel Cole" || npc.Species != "Aslan" || npc.Age != 30 {
+		t.Fatalf("rolled %s, %s aged %d", npc.Name, npc.Species, npc.Age)
+	}
+	if npc.Career != "Agent" || npc.Assignment != "Law Enforcement" || npc.Rank != "Lieutenant" {
+		t.Fatalf("career is %s, %s, %s", npc.Career, npc.Assignment, npc.Rank)
+	}
+
+	// the aslan modifiers, with DEX kept at 1, and END +1 from the Sergeant rank
+	want := model.Characteristics{STR: 14, DEX: 1, END: 8, INT: 8, EDU: 10, SOC: 4}
+	if *npc.Characteristics != want {
+		t.Fatalf("characteristics are %+v, want %+v", *npc.Characteristics, want)
+	}
+
+	// rank skills use the ids of skils.yml, or are made from the name when it is not there
+	skills := map[string]int{"streetwise": 3, "admin": 1, "heavy_weapons": 2}
+	if !reflect.DeepEqual(npc.Skills, skills) {
+		t.Fatalf("skills are %v, want %v", npc.Skills, skills)
+	}
+}
+
+func TestGenerateUnknownOptions(t *testing.T) {
+	tables := testTables()
+	if _, err := tables.Generate(dice.Seeded(1), Options{Species: "hiver"}); !errors.Is(err, ErrUnknownSpecies) {
+		t.Fatalf("unknown species gave %v", err)
+	}
+	if _, err := tables.Generate(dice.Seeded(1), Options{Career: "noble"}); !errors.Is(err, ErrUnknownCareer) {
+		t.Fatalf("unknown career gave %v", err)
+	}
+}
+
+func TestGenerateFromConfig(t *testing.T) {
+	tables, err := Load("../../configs/npcs.yml", "../../configs/careers.yml", "../../configs/skils.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for seed := int64(0); seed < 50; seed++ {
+		npc, err := tables.Generate(dice.Seeded(seed), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := tables.Generate(dice.Seeded(seed), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(npc, again) {
+			t.Fatalf("seed %d rolled %+v then %+v", seed, npc, again)
+		}
+
+		if npc.Name == "" || npc.Species == "" || npc.Career == "" || npc.Age < 22 {
+			t.Fatalf("seed %d rolled %+v", seed, npc)
+		}
+		for _, code := range []string{model.Characteristic_STR, model.Characteristic_DEX, model.Characteristic_END, model.Characteristic_INT, model.Characteristic_EDU, model.Characteristic_SOC} {
+			if npc.Characteristics.Get(code) < 1 {
+				t.Fatalf("seed %d rolled %s %d", seed, code, npc.Characteristics.Get(code))
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package npc
+
+import (
+	"fmt"
+	"os"
+	"visualsource/traveller/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Species struct {
+	Id   string `yaml:"id" json:"id"`
+	Name string `yaml:"name" json:"name"`
+	// added to the roll for each characteristic
+	Modifiers map[string]int `yaml:"modifiers" json:"modifiers"`
+}
+
+type Assignment struct {
+	Id   string `yaml:"id" json:"id"`
+	Name string `yaml:"name" json:"name"`
+}
+
+// Bonus is granted on reaching a rank, Skill is either the name of a skill or a characteristic code
+type Bonus struct {
+	Skill string `yaml:"skill" json:"skill"`
+	Value int    `yaml:"value" json:"value"`
+}
+
+type Rank struct {
+	Name  string `yaml:"name" json:"name"`
+	Level int    `yaml:"level" json:"level"`
+	Bonus *Bonus `yaml:"bonus" json:"bonus"`
+}
+
+// Career is the part of an entry in careers.yml the generator uses
+type Career struct {
+	Id          string            `yaml:"id" json:"id"`
+	Name        string            `yaml:"name" json:"name"`
+	Assignments []Assignment      `yaml:"assignments" json:"assignments"`
+	Ranks       map[string][]Rank `yaml:"ranks_and_bonues" json:"ranks"`
+}
+
+type Skill struct {
+	Id                    string   `yaml:"id" json:"id"`
+	Name                  string   `yaml:"name" json:"name"`
+	AllowsSpecification   bool     `yaml:"allows_specification" json:"allowsSpecification"`
+	AllowedSpecifications []string `yaml:"allowed_specifications" json:"allowedSpecifications"`
+}
+
+type Tables struct {
+	Species    []Species `yaml:"species"`
+	FirstNames []string  `yaml:"first_names"`
+	LastNames  []string  `yaml:"last_names"`
+	MaxTerms   int       `yaml:"max_terms"`
+	Careers    []Career  `yaml:"-"`
+	Skills     []Skill   `yaml:"-"`
+}
+
+func readYaml(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// Load reads the NPC tables along with the careers and skills character generation uses
+func Load(path string, careersPath string, skillsPath string) (*Tables, error) {
+	var tables Tables
+	if err := readYaml(path, &tables); err != nil {
+		return nil, err
+	}
+
+	var careers struct {
+		Careers []Career `yaml:"careers"`
+	}
+	if err := readYaml(careersPath, &careers); err != nil {
+		return nil, err
+	}
+	tables.Careers = careers.Careers
+
+	var skills struct {
+		Skills []Skill `yaml:"skils"`
+	}
+	if err := readYaml(skillsPath, &skills); err != nil {
+		return nil, err
+	}
+	tables.Skills = skills.Skills
+
+	if len(tables.Species) == 0 || len(tables.FirstNames) == 0 || len(tables.LastNames) == 0 {
+		return nil, fmt.Errorf("npc tables need species and names")
+	}
+	for _, species := range tables.Species {
+		for code := range species.Modifiers {
+			if !model.ValidCharacteristic(code) {
+				return nil, fmt.Errorf("species '%s': unknown characteristic '%s'", species.Id, code)
+			}
+		}
+	}
+	if tables.MaxTerms <= 0 {
+		tables.MaxTerms = 1
+	}
+
+	return &tables, nil
+}
+
+func (t *Tables) SpeciesById(id string) (Species, bool) {
+	for _, item := range t.Species {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Species{}, false
+}
+
+func (t *Tables) Career(id string) (Career, bool) {
+	for _, item := range t.Careers {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Career{}, false
+}
+
+// skillByName finds a skill by its id or its name as written in the career tables
+func (t *Tables) skillByName(name string) (Skill, bool) {
+	for _, item := range t.Skills {
+		if item.Id == name || item.Name == name {
+			return item, true
+		}
+	}
+	return Skill{}, false
+}
@@ -0,0 +1,285 @@
+package route
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"visualsource/traveller/internal/model"
+)
+
+var ErrNoRoute = errors.New("no route between the worlds")
+
+// days spent refuelling before the next jump, skimming a gas giant takes
+// longer than buying fuel at the starport
+const (
+	StarportRefuelDays = 1
+	GasGiantRefuelDays = 2
+)
+
+type Mode string
+
+const (
+	// Mode_Jumps finds the route with the fewest jumps
+	Mode_Jumps Mode = "jumps"
+	// Mode_Time finds the quickest route, counting the days spent refuelling
+	Mode_Time Mode = "time"
+)
+
+func (m Mode) Valid() bool {
+	return m == Mode_Jumps || m == Mode_Time
+}
+
+type Ship struct {
+	// Jump is the rating of the jump drive, the most parsecs of a single jump
+	Jump int `json:"jump"`
+	// FuelCapacity is the tons of fuel the ship can carry
+	FuelCapacity float64 `json:"fuelCapacity"`
+	// FuelPerParsec is the tons of fuel used by each parsec jumped
+	FuelPerParsec float64 `json:"fuelPerParsec"`
+}
+
+// parsecs is how far the ship can jump on full tanks
+func (s Ship) parsecs() int {
+	if s.FuelPerParsec <= 0 {
+		return s.Jump
+	}
+	return int(math.Floor(s.FuelCapacity/s.FuelPerParsec + 1e-9))
+}
+
+type Options struct {
+	Ship Ship
+	Mode Mode
+	// AvoidRed keeps the route out of Red zones
+	AvoidRed bool
+}
+
+type Leg struct {
+	From     string  `json:"from"`
+	FromName string  `json:"fromName"`
+	To       string  `json:"to"`
+	ToName   string  `json:"toName"`
+	Parsecs  int     `json:"parsecs"`
+	Fuel     float64 `json:"fuel"`
+	// Refuel is set when the ship takes on fuel at the start of the leg
+	Refuel     bool `json:"refuel"`
+	RefuelDays int  `json:"refuelDays"`
+	Days       int  `json:"days"`
+}
+
+type Route struct {
+	Mode    Mode    `json:"mode"`
+	Legs    []Leg   `json:"legs"`
+	Jumps   int     `json:"jumps"`
+	Parsecs int     `json:"parsecs"`
+	Fuel    float64 `json:"fuel"`
+	Days    int     `json:"days"`
+}
+
+// refuelDays is how long it takes to fill the tanks at a world, false when there is nowhere to get fuel
+func refuelDays(world *model.World) (int, bool) {
+	switch world.UWP.Starport {
+	// the spaceports F and G sell unrefined fuel too
+	case "A", "B", "C", "D", "F", "G":
+		return StarportRefuelDays, true
+	}
+	if world.GasGiants > 0 {
+		return GasGiantRefuelDays, true
+	}
+	return 0, false
+}
+
+// state is a world and the parsecs of fuel left in the tanks
+type state struct {
+	world int
+	fuel  int
+}
+
+type cost struct {
+	jumps int
+	days  int
+}
+
+type step struct {
+	prev   state
+	parsec int
+	refuel bool
+}
+
+type entry struct {
+	state state
+	key   [2]int
+}
+
+type queue []entry
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return lessKey(q[i].key, q[j].key) }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(entry)) }
+func (q *queue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func (o Options) key(c cost) [2]int {
+	if o.Mode == Mode_Time {
+		return [2]int{c.days, c.jumps}
+	}
+	return [2]int{c.jumps, c.days}
+}
+
+// Plan finds the shortest route between two hexes of a map. The ship leaves
+// with full tanks and can only refuel at worlds with a starport or a gas giant.
+func Plan(worlds []model.World, from string, to string, opts Options) (*Route, error) {
+	if opts.Mode == "" {
+		opts.Mode = Mode_Jumps
+	}
+	if !opts.Mode.Valid() {
+		return nil, fmt.Errorf("unknown route mode '%s'", opts.Mode)
+	}
+	if opts.Ship.Jump <= 0 {
+		return nil, errors.New("ship has no jump drive")
+	}
+
+	start, end := -1, -1
+	for i := range worlds {
+		switch worlds[i].Hex {
+		case from:
+			start = i
+		case to:
+			end = i
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no world at hex %s", from)
+	}
+	if from == to {
+		return &Route{Mode: opts.Mode, Legs: []Leg{}}, nil
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("no world at hex %s", to)
+	}
+	if opts.AvoidRed && worlds[end].Zone == model.TravelZone_Red {
+		return nil, fmt.Errorf("%w: %s is a Red zone", ErrNoRoute, to)
+	}
+
+	capacity := opts.Ship.parsecs()
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w: not enough fuel for a jump", ErrNoRoute)
+	}
+	reach := min(opts.Ship.Jump, capacity)
+
+	type neighbour struct {
+		world   int
+		parsecs int
+	}
+	neighbours := make([][]neighbour, len(worlds))
+	for i := range worlds {
+		if opts.AvoidRed && i != start && worlds[i].Zone == model.TravelZone_Red {
+			continue
+		}
+		for j := range worlds {
+			if i == j || (opts.AvoidRed && worlds[j].Zone == model.TravelZone_Red) {
+				continue
+			}
+			d, err := model.HexDistance(worlds[i].Hex, worlds[j].Hex)
+			if err != nil {
+				return nil, err
+			}
+			if d <= reach {
+				neighbours[i] = append(neighbours[i], neighbour{j, d})
+			}
+		}
+	}
+
+	best := map[state]cost{}
+	steps := map[state]step{}
+	q := &queue{}
+
+	origin := state{start, capacity}
+	best[origin] = cost{}
+	heap.Push(q, entry{origin, opts.key(cost{})})
+
+	relax := func(next state, c cost, s step) {
+		if old, ok := best[next]; ok && !lessKey(opts.key(c), opts.key(old)) {
+			return
+		}
+		best[next] = c
+		steps[next] = s
+		heap.Push(q, entry{next, opts.key(c)})
+	}
+
+	var found *state
+	for q.Len() > 0 {
+		current := heap.Pop(q).(entry)
+		if current.key != opts.key(best[current.state]) {
+			continue
+		}
+		if current.state.world == end {
+			found = &current.state
+			break
+		}
+		c := best[current.state]
+
+		if days, ok := refuelDays(&worlds[current.state.world]); ok && current.state.fuel < capacity {
+			relax(state{current.state.world, capacity}, cost{c.jumps, c.days + days}, step{prev: current.state, refuel: true})
+		}
+
+		for _, n := range neighbours[current.state.world] {
+			if n.parsecs > current.state.fuel {
+				continue
+			}
+			relax(state{n.world, current.state.fuel - n.parsecs}, cost{c.jumps + 1, c.days + model.DaysPerJump}, step{prev: current.state, parsec: n.parsecs})
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNoRoute
+	}
+
+	// walk back from the destination, folding each refuel into the jump that follows it
+	route := &Route{Mode: opts.Mode, Legs: []Leg{}}
+	var legs []Leg
+	current := *found
+	for current != origin {
+		s := steps[current]
+		if s.refuel {
+			days, _ := refuelDays(&worlds[current.world])
+			legs[len(legs)-1].Refuel = true
+			legs[len(legs)-1].RefuelDays += days
+			legs[len(legs)-1].Days += days
+		} else {
+			from, to := &worlds[s.prev.world], &worlds[current.world]
+			legs = append(legs, Leg{
+				From:     from.Hex,
+				FromName: from.Name,
+				To:       to.Hex,
+				ToName:   to.Name,
+				Parsecs:  s.parsec,
+				Fuel:     float64(s.parsec) * opts.Ship.FuelPerParsec,
+				Days:     model.DaysPerJump,
+			})
+		}
+		current = s.prev
+	}
+
+	for i := len(legs) - 1; i >= 0; i-- {
+		route.Legs = append(route.Legs, legs[i])
+		route.Jumps++
+		route.Parsecs += legs[i].Parsecs
+		route.Fuel += legs[i].Fuel
+		route.Days += legs[i].Days
+	}
+
+	return route, nil
+}
+
+func lessKey(a [2]int, b [2]int) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
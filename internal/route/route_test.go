@@ -0,0 +1,194 @@
+package route
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func world(hex string, starport string, gasGiants int) model.World {
+	return model.World{Hex: hex, Name: "w" + hex, UWP: model.UWP{Starport: starport}, GasGiants: gasGiants, Zone: model.TravelZone_Green}
+}
+
+// jump-2 with fuel for two parsecs
+var scout = Ship{Jump: 2, FuelCapacity: 20, FuelPerParsec: 10}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		worlds []model.World
+		from   string
+		to     string
+		opts   Options
+		// want lists the hexes the route passes through, refuel the hexes it stops to refuel at
+		want   []string
+		refuel []string
+		days   int
+		err    error
+	}{
+		{
+			name:   "adjacent across an odd and an even column",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 0)},
+			from:   "0101", to: "0201",
+			opts: Options{Ship: scout},
+			want: []string{"0101", "0201"}, days: 7,
+		},
+		{
+			name:   "adjacent across an even and an odd column",
+			worlds: []model.World{world("0202", "A", 0), world("0303", "X", 0)},
+			from:   "0202", to: "0303",
+			opts: Options{Ship: scout},
+			want: []string{"0202", "0303"}, days: 7,
+		},
+		{
+			name:   "same hex",
+			worlds: []model.World{world("0101", "A", 0)},
+			from:   "0101", to: "0101",
+			opts: Options{Ship: scout},
+			want: []string{"0101"},
+		},
+		{
+			name:   "within jump range",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 0), world("0301", "X", 0)},
+			from:   "0101", to: "0301",
+			opts: Options{Ship: scout},
+			want: []string{"0101", "0301"}, days: 7,
+		},
+		{
+			name:   "beyond jump range takes two jumps",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 0), world("0301", "X", 0)},
+			from:   "0101", to: "0301",
+			opts: Options{Ship: Ship{Jump: 1, FuelCapacity: 40, FuelPerParsec: 10}},
+			want: []string{"0101", "0201", "0301"}, days: 14,
+		},
+		{
+			name:   "beyond jump range with no stepping stone",
+			worlds: []model.World{world("0101", "A", 0), world("0401", "A", 0)},
+			from:   "0101", to: "0401",
+			opts: Options{Ship: scout},
+			err:  ErrNoRoute,
+		},
+		{
+			name:   "fuel limits the jump below the drive rating",
+			worlds: []model.World{world("0101", "A", 0), world("0301", "A", 0)},
+			from:   "0101", to: "0301",
+			opts: Options{Ship: Ship{Jump: 2, FuelCapacity: 10, FuelPerParsec: 10}},
+			err:  ErrNoRoute,
+		},
+		{
+			name:   "stops at a starport to refuel",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "C", 0), world("0401", "X", 0)},
+			from:   "0101", to: "0401",
+			opts: Options{Ship: scout},
+			want: []string{"0101", "0201", "0401"}, refuel: []string{"0201"}, days: 14 + StarportRefuelDays,
+		},
+		{
+			name:   "skims a gas giant to refuel",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 1), world("0401", "X", 0)},
+			from:   "0101", to: "0401",
+			opts: Options{Ship: scout},
+			want: []string{"0101", "0201", "0401"}, refuel: []string{"0201"}, days: 14 + GasGiantRefuelDays,
+		},
+		{
+			name:   "nowhere to refuel on the way",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 0), world("0401", "A", 0)},
+			from:   "0101", to: "0401",
+			opts: Options{Ship: scout},
+			err:  ErrNoRoute,
+		},
+		{
+			name:   "fuel for one parsec cannot cross an empty hex",
+			worlds: []model.World{world("0101", "A", 0), world("0201", "X", 0), world("0301", "X", 0)},
+			from:   "0101", to: "0301",
+			opts: Options{Ship: Ship{Jump: 1, FuelCapacity: 10, FuelPerParsec: 10}},
+			err:  ErrNoRoute,
+		},
+		{
+			name: "quickest route refuels at a starport over a gas giant",
+			worlds: []model.World{
+				world("0101", "A", 0),
+				// one jump then a gas giant, or a starport
+				world("0201", "X", 1), world("0202", "B", 0),
+				world("0401", "X", 0),
+			},
+			from: "0101", to: "0401",
+			opts: Options{Ship: scout, Mode: Mode_Time},
+			want: []string{"0101", "0202", "0401"}, refuel: []string{"0202"}, days: 14 + StarportRefuelDays,
+		},
+		{
+			name: "avoids a Red zone",
+			worlds: []model.World{
+				world("0101", "A", 0),
+				{Hex: "0201", UWP: model.UWP{Starport: "A"}, Zone: model.TravelZone_Red},
+				world("0202", "B", 0),
+				world("0401", "X", 0),
+			},
+			from: "0101", to: "0401",
+			opts: Options{Ship: scout, AvoidRed: true},
+			want: []string{"0101", "0202", "0401"}, refuel: []string{"0202"}, days: 14 + StarportRefuelDays,
+		},
+		{
+			name:   "unreachable target",
+			worlds: []model.World{world("0101", "A", 1), world("0201", "A", 1), world("0801", "A", 1)},
+			from:   "0101", to: "0801",
+			opts: Options{Ship: scout},
+			err:  ErrNoRoute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, err := Plan(test.worlds, test.from, test.to, test.opts)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("Plan = %+v, %v, want %v", route, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hexes := []string{test.from}
+			refuel := []string{}
+			parsecs := 0
+			for _, leg := range route.Legs {
+				if leg.From != hexes[len(hexes)-1] {
+					t.Fatalf("leg %+v does not start where the last one ended", leg)
+				}
+				if leg.Refuel {
+					refuel = append(refuel, leg.From)
+				}
+				hexes = append(hexes, leg.To)
+				parsecs += leg.Parsecs
+			}
+			if !slices.Equal(hexes, test.want) || !slices.Equal(refuel, test.refuel) {
+				t.Fatalf("route went %v refuelling at %v, want %v refuelling at %v", hexes, refuel, test.want, test.refuel)
+			}
+			if route.Jumps != len(test.want)-1 || route.Parsecs != parsecs || route.Days != test.days {
+				t.Fatalf("route took %d jumps, %d parsecs and %d days, want %d jumps, %d parsecs and %d days", route.Jumps, route.Parsecs, route.Days, len(test.want)-1, parsecs, test.days)
+			}
+			if route.Fuel != float64(parsecs)*test.opts.Ship.FuelPerParsec {
+				t.Fatalf("route used %v tons of fuel for %d parsecs", route.Fuel, parsecs)
+			}
+		})
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	worlds := []model.World{world("0101", "A", 0), world("0201", "A", 0)}
+
+	if _, err := Plan(worlds, "0909", "0201", Options{Ship: scout}); err == nil || errors.Is(err, ErrNoRoute) {
+		t.Fatalf("unknown start: %v", err)
+	}
+	if _, err := Plan(worlds, "0101", "0909", Options{Ship: scout}); err == nil || errors.Is(err, ErrNoRoute) {
+		t.Fatalf("unknown destination: %v", err)
+	}
+	if _, err := Plan(worlds, "0101", "0201", Options{Ship: Ship{FuelCapacity: 20, FuelPerParsec: 10}}); err == nil {
+		t.Fatal("planned a route without a jump drive")
+	}
+	if _, err := Plan(worlds, "0101", "0201", Options{Ship: scout, Mode: "scenic"}); err == nil {
+		t.Fatal("planned a route with an unknown mode")
+	}
+}
@@ -0,0 +1,22 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterSectorPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/sectors")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListSectors).Name = "sectors"
+	g.POST("/generate", h.GenerateSector).Name = "sector-generate"
+	g.POST("/import", h.ImportSector).Name = "sector-import"
+	g.GET("/:sectorId", h.GetSector).Name = "sector"
+	g.DELETE("/:sectorId", h.DeleteSector).Name = "sector-delete"
+	g.GET("/:sectorId/hexes", h.GetSectorHexes).Name = "sector-hexes"
+	g.GET("/:sectorId/export", h.ExportSector).Name = "sector-export"
+	g.GET("/:sectorId/route", h.PlanRoute).Name = "sector-route"
+}
@@ -0,0 +1,135 @@
+package sectorfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+	"visualsource/traveller/internal/model"
+)
+
+// the hex and UWP columns anchor a SEC line, everything else is found relative to them
+var secLine = regexp.MustCompile(`^(.*?)\s*(\d{4})\s+(\S{7}-\S)`)
+
+var pbgField = regexp.MustCompile(`^\d{3}$`)
+
+// ParseSEC reads the legacy fixed column SEC format
+//
+//	#PlanetName   Loc. UPP Code   B   Notes         Z  PBG Al LRX *
+//	Aramis        3110 A5A0556-B  N   Hi In Cp      A  703 Im F4 V M0 V
+func ParseSEC(r io.Reader) ([]model.World, error) {
+	worlds := []model.World{}
+	errs := ParseErrors{}
+	seen := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "$") || strings.HasPrefix(text, "@") {
+			continue
+		}
+
+		match := secLine.FindStringSubmatchIndex(text)
+		if match == nil {
+			errs.add(line, "expected a name followed by a hex and UWP")
+			continue
+		}
+
+		name := strings.TrimSpace(text[match[2]:match[3]])
+		hex := text[match[4]:match[5]]
+		if name == "" {
+			errs.add(line, "world in hex %s has no name", hex)
+			continue
+		}
+		if !checkHex(&errs, seen, line, hex) {
+			continue
+		}
+
+		uwp, err := model.ParseUWP(text[match[6]:match[7]])
+		if err != nil {
+			errs.add(line, "%s", err.Error())
+			continue
+		}
+
+		world := model.World{Name: name, Hex: hex, UWP: uwp, Bases: []string{}, Zone: model.TravelZone_Green}
+
+		// the base column starts two places after the UWP, it is one letter wide
+		// unless a world has more bases than one letter can stand for
+		rest := text[match[7]:]
+		switch {
+		case len(rest) > 2 && rest[2] != ' ' && rest[2] != '\t':
+			end := strings.IndexAny(rest[2:], " \t")
+			if end < 0 {
+				end = len(rest) - 2
+			}
+			world.Bases = parseBases(rest[2 : 2+end])
+			rest = rest[2+end:]
+		case len(rest) > 3:
+			rest = rest[3:]
+		default:
+			rest = ""
+		}
+
+		// remarks are two letter codes, the zone is a lone letter before the PBG
+		fields := strings.Fields(rest)
+		found := false
+		for i, field := range fields {
+			if !pbgField.MatchString(field) {
+				continue
+			}
+			found = true
+			belts, giants, _ := parsePBG(field)
+			world.Belts, world.GasGiants = belts, giants
+			if i > 0 && len(fields[i-1]) == 1 {
+				zone, ok := parseZone(fields[i-1])
+				if !ok {
+					errs.add(line, "unknown travel zone '%s'", fields[i-1])
+					break
+				}
+				world.Zone = zone
+			}
+			break
+		}
+		if !found && len(fields) > 0 {
+			errs.add(line, "missing PBG after the remarks")
+			continue
+		}
+
+		worlds = append(worlds, world)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return worlds, nil
+}
+
+// WriteSEC writes the worlds in the legacy fixed column SEC format. The name, base and
+// remarks columns are widened when a world does not fit the usual widths.
+func WriteSEC(w io.Writer, worlds []model.World) error {
+	nameWidth, baseWidth, remarksWidth := 13, 1, 15
+	for i := range worlds {
+		world := &worlds[i]
+		nameWidth = max(nameWidth, utf8.RuneCountInString(world.Name))
+		baseWidth = max(baseWidth, len(legacyBase(world.Bases)))
+		remarksWidth = max(remarksWidth, len(strings.Join(world.UWP.TradeCodes(), " ")))
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "#--------1---------2---------3---------4---------5---------6---")
+	fmt.Fprintf(out, "#%-*s Loc. UPP Code   %-*s   %-*s Z  PBG Al LRX *\n", nameWidth-1, "PlanetName", baseWidth, "B", remarksWidth-1, "Notes")
+	fmt.Fprintf(out, "#%s   ---- ---------  %s %s -  --- -- ---\n", strings.Repeat("-", nameWidth-3), strings.Repeat("-", baseWidth), strings.Repeat("-", remarksWidth))
+	for i := range worlds {
+		world := &worlds[i]
+		fmt.Fprintf(out, "%-*s %s %s  %-*s %-*s %s  %s Na\n",
+			nameWidth, world.Name, world.Hex, world.UWP.String(), baseWidth, legacyBase(world.Bases), remarksWidth, strings.Join(world.UWP.TradeCodes(), " "), zoneCode(world.Zone), pbg(world))
+	}
+	return out.Flush()
+}
@@ -0,0 +1,174 @@
+// Package sectorfile reads and writes the sector data formats used by Traveller Map,
+// the legacy fixed column SEC format and the T5 tab delimited format.
+package sectorfile
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"visualsource/traveller/internal/model"
+)
+
+type Format string
+
+const (
+	Format_SEC Format = "sec"
+	Format_T5  Format = "t5"
+)
+
+// stop collecting errors after this many so a file in the wrong format does not produce thousands
+const maxErrors = 50
+
+// ParseError is a problem on one line of a sector file
+type ParseError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ParseErrors is every problem found in a file
+type ParseErrors []ParseError
+
+func (e ParseErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ParseErrors) add(line int, format string, args ...interface{}) {
+	if len(*e) < maxErrors {
+		*e = append(*e, ParseError{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Parse reads the worlds of a sector file in the given format
+func Parse(format Format, r io.Reader) ([]model.World, error) {
+	switch format {
+	case Format_SEC:
+		return ParseSEC(r)
+	case Format_T5:
+		return ParseT5(r)
+	}
+	return nil, fmt.Errorf("unknown sector format '%s'", format)
+}
+
+// Write writes the worlds to a sector file in the given format
+func Write(format Format, w io.Writer, worlds []model.World) error {
+	switch format {
+	case Format_SEC:
+		return WriteSEC(w, worlds)
+	case Format_T5:
+		return WriteT5(w, worlds)
+	}
+	return fmt.Errorf("unknown sector format '%s'", format)
+}
+
+// checkHex makes sure a hex is on a sector map and has not been used by an earlier line
+func checkHex(errs *ParseErrors, seen map[string]int, line int, hex string) bool {
+	column, row, err := model.ParseHex(hex)
+	if err != nil {
+		errs.add(line, "%s", err.Error())
+		return false
+	}
+	if column < 1 || column > model.SectorWidth || row < 1 || row > model.SectorHeight {
+		errs.add(line, "hex %s is outside of the sector", hex)
+		return false
+	}
+	if first, ok := seen[hex]; ok {
+		errs.add(line, "hex %s is already used on line %d", hex, first)
+		return false
+	}
+	seen[hex] = line
+	return true
+}
+
+// parseBases reads a base code, the legacy combined codes A and B are split into their parts.
+// Codes the world model does not track are skipped.
+func parseBases(value string) []string {
+	bases := []string{}
+	for _, code := range strings.Split(strings.ToUpper(value), "") {
+		switch code {
+		case "A":
+			bases = append(bases, model.Base_Naval, model.Base_Scout)
+		case "B":
+			bases = append(bases, model.Base_Naval, model.Base_Way)
+		default:
+			if model.ValidBase(code) && !slices.Contains(bases, code) {
+				bases = append(bases, code)
+			}
+		}
+	}
+	return bases
+}
+
+// legacyBase writes the bases of a world for the SEC base column. A single base and the pairs the
+// legacy codes A and B stand for take one letter, any other mix is written out code by code.
+func legacyBase(bases []string) string {
+	naval := slices.Contains(bases, model.Base_Naval)
+	switch {
+	case len(bases) == 0:
+		return " "
+	case len(bases) == 1:
+		return bases[0]
+	case len(bases) == 2 && naval && slices.Contains(bases, model.Base_Scout):
+		return "A"
+	case len(bases) == 2 && naval && slices.Contains(bases, model.Base_Way):
+		return "B"
+	}
+	return strings.Join(bases, "")
+}
+
+func parseZone(value string) (model.TravelZone, bool) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "", "-", "G":
+		return model.TravelZone_Green, true
+	case "A":
+		return model.TravelZone_Amber, true
+	case "R":
+		return model.TravelZone_Red, true
+	}
+	return "", false
+}
+
+func zoneCode(zone model.TravelZone) string {
+	switch zone {
+	case model.TravelZone_Amber:
+		return "A"
+	case model.TravelZone_Red:
+		return "R"
+	}
+	return " "
+}
+
+// parsePBG reads the population multiplier, belts and gas giants digits
+func parsePBG(value string) (int, int, bool) {
+	if len(value) != 3 {
+		return 0, 0, false
+	}
+	digits := [3]int{}
+	for i := range digits {
+		digit, err := strconv.Atoi(value[i : i+1])
+		if err != nil {
+			return 0, 0, false
+		}
+		digits[i] = digit
+	}
+	return digits[1], digits[2], true
+}
+
+// pbg writes the population multiplier, belts and gas giants digits. The multiplier
+// is not part of the world model so populated worlds are written with a 1.
+func pbg(world *model.World) string {
+	multiplier := 1
+	if world.UWP.Population == 0 {
+		multiplier = 0
+	}
+	return fmt.Sprintf("%d%d%d", multiplier, min(world.Belts, 9), min(world.GasGiants, 9))
+}
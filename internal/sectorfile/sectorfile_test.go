@@ -0,0 +1,200 @@
+package sectorfile
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func mustUWP(t *testing.T, value string) model.UWP {
+	t.Helper()
+
+	uwp, err := model.ParseUWP(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uwp
+}
+
+func testWorlds(t *testing.T) []model.World {
+	return []model.World{
+		{Name: "Aramis", Hex: "3110", UWP: mustUWP(t, "A5A0556-B"), Bases: []string{model.Base_Naval}, Zone: model.TravelZone_Amber, Belts: 0, GasGiants: 3},
+		// longer than the usual name column
+		{Name: "Mora Highport Orbital", Hex: "3124", UWP: mustUWP(t, "A99AA99-F"), Bases: []string{model.Base_Naval, model.Base_Scout}, Zone: model.TravelZone_Green, Belts: 1, GasGiants: 2},
+		// more bases than one letter stands for
+		{Name: "Regina", Hex: "1910", UWP: mustUWP(t, "A788899-C"), Bases: []string{model.Base_Naval, model.Base_Scout, model.Base_Military}, Zone: model.TravelZone_Green, Belts: 2, GasGiants: 3},
+		{Name: "Ruie", Hex: "0203", UWP: mustUWP(t, "F6A4300-8"), Bases: []string{model.Base_Naval, model.Base_Way}, Zone: model.TravelZone_Red, Belts: 0, GasGiants: 1},
+		{Name: "Unknown World", Hex: "3240", UWP: mustUWP(t, "???????-?"), Bases: []string{}, Zone: model.TravelZone_Green},
+		{Name: "Y Outpost", Hex: "0101", UWP: mustUWP(t, "Y300000-0"), Bases: []string{model.Base_Corsair}, Zone: model.TravelZone_Green, Belts: 0, GasGiants: 0},
+	}
+}
+
+// comparable leaves out what the files do not carry
+func comparable(worlds []model.World) []model.World {
+	out := make([]model.World, len(worlds))
+	for i, world := range worlds {
+		out[i] = model.World{Name: world.Name, Hex: world.Hex, UWP: world.UWP, Bases: world.Bases, Zone: world.Zone, Belts: world.Belts, GasGiants: world.GasGiants}
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{Format_SEC, Format_T5} {
+		t.Run(string(format), func(t *testing.T) {
+			worlds := testWorlds(t)
+
+			var buf bytes.Buffer
+			if err := Write(format, &buf, worlds); err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := Parse(format, &buf)
+			if err != nil {
+				t.Fatalf("reading back what was written failed: %v\n%s", err, buf.String())
+			}
+			if got, want := comparable(parsed), comparable(worlds); !reflect.DeepEqual(got, want) {
+				t.Fatalf("read back\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSEC(t *testing.T) {
+	file := `#--------1---------2---------3---------4---------5---------6---
+#PlanetName   Loc. UPP Code   B   Notes         Z  PBG Al LRX *
+#----------   ---- ---------  - --------------- -  --- -- ---
+Aramis        3110 A5A0556-B  N   Hi In Cp      A  703 Im F4 V M0 V
+Natoko        3209 B565776-A  A   Ag Ri         R  503 Im M0 V
+
+Reacher       3210 E655731-9                       900 Im
+`
+	worlds, err := ParseSEC(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []model.World{
+		{Name: "Aramis", Hex: "3110", UWP: mustUWP(t, "A5A0556-B"), Bases: []string{model.Base_Naval}, Zone: model.TravelZone_Amber, GasGiants: 3},
+		{Name: "Natoko", Hex: "3209", UWP: mustUWP(t, "B565776-A"), Bases: []string{model.Base_Naval, model.Base_Scout}, Zone: model.TravelZone_Red, GasGiants: 3},
+		{Name: "Reacher", Hex: "3210", UWP: mustUWP(t, "E655731-9"), Bases: []string{}, Zone: model.TravelZone_Green},
+	}
+	if got := comparable(worlds); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseT5Spaceports(t *testing.T) {
+	file := "Hex\tName\tUWP\tBases\tZone\tPBG\n" +
+		"0101\tGood\tF6A4300-8\t\t\t001\n" +
+		"0102\tPoor\tG200000-0\t\t\t000\n" +
+		"0103\tBasic\tH532200-5\t\t\t000\n" +
+		"0104\tNone\tY300000-0\t\t\t000\n" +
+		"0105\tUncharted\t???????-?\t\t\t\n"
+
+	worlds, err := ParseT5(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, world := range worlds {
+		got = append(got, world.UWP.String())
+	}
+	want := []string{"F6A4300-8", "G200000-0", "H532200-5", "Y300000-0", "???????-?"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func parseErrors(t *testing.T, err error) ParseErrors {
+	t.Helper()
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want ParseErrors", err)
+	}
+	return errs
+}
+
+func TestParseSECErrors(t *testing.T) {
+	file := `# a comment
+Aramis        3110 A5A0556-B  N   Hi In Cp      A  703 Im
+Natoko        3110 B565776-A  A   Ag Ri         R  503 Im
+not a world line
+Far Away      3341 B565776-A                       503 Im
+Broken        0101 Q565776-A                       503 Im
+Lost          0102 B565776-A  N   Ag            Q  503 Im
+              0103 B565776-A                       503 Im
+`
+	_, err := ParseSEC(strings.NewReader(file))
+	errs := parseErrors(t, err)
+
+	want := []struct {
+		line    int
+		message string
+	}{
+		{3, "already used on line 2"},
+		{4, "expected a name"},
+		{5, "outside of the sector"},
+		{6, "unknown starport"},
+		{7, "unknown travel zone"},
+		{8, "has no name"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d\n%v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Line != w.line || !strings.Contains(errs[i].Message, w.message) {
+			t.Errorf("error %d is %q, want line %d: ...%s...", i, errs[i].Error(), w.line, w.message)
+		}
+	}
+}
+
+func TestParseT5Errors(t *testing.T) {
+	file := "Hex\tName\tUWP\tZone\tPBG\n" +
+		"0101\tAramis\tA5A0556-B\tA\t703\n" +
+		"0102\tNatoko\tB565776-A\tR\t503\textra\n" +
+		"0103\t\tB565776-A\t\t503\n" +
+		"0104\tRegina\tA788899\t\t503\n" +
+		"0105\tRuie\tF6A4300-8\tX\t503\n" +
+		"0106\tReacher\tE655731-9\t\t9\n" +
+		"0101\tAgain\tE655731-9\t\t900\n"
+
+	_, err := ParseT5(strings.NewReader(file))
+	errs := parseErrors(t, err)
+
+	want := []struct {
+		line    int
+		message string
+	}{
+		{3, "expected 5 tab separated columns but found 6"},
+		{4, "has no name"},
+		{5, "not in the form"},
+		{6, "unknown travel zone 'X'"},
+		{7, "PBG '9' must be three digits"},
+		{8, "hex 0101 is already used on line 2"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d\n%v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Line != w.line || !strings.Contains(errs[i].Message, w.message) {
+			t.Errorf("error %d is %q, want line %d: ...%s...", i, errs[i].Error(), w.line, w.message)
+		}
+	}
+}
+
+func TestParseT5Header(t *testing.T) {
+	_, err := ParseT5(strings.NewReader("# only a comment\n"))
+	if errs := parseErrors(t, err); len(errs) != 1 || errs[0].Line != 1 || errs[0].Message != "file has no header" {
+		t.Fatalf("got %v", errs)
+	}
+
+	_, err = ParseT5(strings.NewReader("\nHex\tWorld\tUWP\n"))
+	if errs := parseErrors(t, err); len(errs) != 1 || errs[0].Line != 2 || errs[0].Message != "header is missing the Name column" {
+		t.Fatalf("got %v", errs)
+	}
+}
@@ -0,0 +1,129 @@
+package sectorfile
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"visualsource/traveller/internal/model"
+)
+
+var t5Columns = []string{"Hex", "Name", "UWP", "Remarks", "{Ix}", "(Ex)", "[Cx]", "Nobility", "Bases", "Zone", "PBG", "W", "Allegiance", "Stars"}
+
+// ParseT5 reads the T5 tab delimited format, the first line names the columns
+func ParseT5(r io.Reader) ([]model.World, error) {
+	worlds := []model.World{}
+	errs := ParseErrors{}
+	seen := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	var columns map[string]int
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if columns == nil {
+			columns = map[string]int{}
+			for i, name := range fields {
+				columns[strings.TrimSpace(name)] = i
+			}
+			for _, required := range []string{"Hex", "Name", "UWP"} {
+				if _, ok := columns[required]; !ok {
+					errs.add(line, "header is missing the %s column", required)
+				}
+			}
+			if len(errs) > 0 {
+				return nil, errs
+			}
+			continue
+		}
+
+		// trailing empty columns are sometimes trimmed
+		if len(fields) > len(columns) {
+			errs.add(line, "expected %d tab separated columns but found %d", len(columns), len(fields))
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		hex := field("Hex")
+		if !checkHex(&errs, seen, line, hex) {
+			continue
+		}
+
+		name := field("Name")
+		if name == "" {
+			errs.add(line, "world in hex %s has no name", hex)
+			continue
+		}
+
+		uwp, err := model.ParseUWP(field("UWP"))
+		if err != nil {
+			errs.add(line, "%s", err.Error())
+			continue
+		}
+
+		zone, ok := parseZone(field("Zone"))
+		if !ok {
+			errs.add(line, "unknown travel zone '%s'", field("Zone"))
+			continue
+		}
+
+		world := model.World{Name: name, Hex: hex, UWP: uwp, Bases: parseBases(field("Bases")), Zone: zone}
+
+		if value := field("PBG"); value != "" {
+			belts, giants, ok := parsePBG(value)
+			if !ok {
+				errs.add(line, "PBG '%s' must be three digits", value)
+				continue
+			}
+			world.Belts, world.GasGiants = belts, giants
+		}
+
+		worlds = append(worlds, world)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if columns == nil {
+		errs.add(line, "file has no header")
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return worlds, nil
+}
+
+// WriteT5 writes the worlds in the T5 tab delimited format
+func WriteT5(w io.Writer, worlds []model.World) error {
+	out := bufio.NewWriter(w)
+	out.WriteString(strings.Join(t5Columns, "\t") + "\n")
+	for i := range worlds {
+		world := &worlds[i]
+		zone := strings.TrimSpace(zoneCode(world.Zone))
+		row := []string{
+			world.Hex,
+			world.Name,
+			world.UWP.String(),
+			strings.Join(world.UWP.TradeCodes(), " "),
+			"", "", "", "",
+			strings.Join(world.Bases, ""),
+			zone,
+			pbg(world),
+			"",
+			"Na",
+			"",
+		}
+		out.WriteString(strings.Join(row, "\t") + "\n")
+	}
+	return out.Flush()
+}
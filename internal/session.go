@@ -42,4 +42,6 @@ func RegisterSessionPages(e *echo.Echo, h *handler.Handler) {
 	g.POST("/cmd/create", h.CreateSession).Name = "create-session"
 
 	g.GET("/:sessionId/ws", h.WS).Name = "ws"
+	g.GET("/:sessionId/online", h.OnlineUsers).Name = "session-online"
+	g.GET("/:sessionId/players", h.SessionPlayers).Name = "session-players"
 }
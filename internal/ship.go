@@ -0,0 +1,24 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterShipPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/ships")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListShips).Name = "ships"
+	g.POST("", h.CreateShip).Name = "ship-create"
+	g.POST("/design", h.CalculateShipDesign).Name = "ship-design-calculate"
+	g.GET("/:shipId", h.GetShip).Name = "ship"
+	g.PUT("/:shipId", h.UpdateShip).Name = "ship-update"
+	g.DELETE("/:shipId", h.DeleteShip).Name = "ship-delete"
+	g.GET("/:shipId/design", h.GetShipDesign).Name = "ship-design"
+
+	g.POST("/:shipId/crew", h.SetShipCrew).Name = "ship-crew"
+	g.DELETE("/:shipId/crew/:characterId", h.RemoveShipCrew).Name = "ship-crew-remove"
+}
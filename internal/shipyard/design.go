@@ -0,0 +1,409 @@
+package shipyard
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"visualsource/traveller/internal/model"
+)
+
+type Severity string
+
+const (
+	Severity_Error   Severity = "error"
+	Severity_Warning Severity = "warning"
+)
+
+// Codes of the problems a design can have
+const (
+	Code_Unknown   = "unknown"
+	Code_TechLevel = "tech_level"
+	Code_Tonnage   = "tonnage"
+	Code_Power     = "power"
+	Code_Rating    = "rating"
+	Code_Bandwidth = "bandwidth"
+	Code_Software  = "software"
+	Code_Fuel      = "fuel"
+	Code_Mount     = "mount"
+)
+
+type ValidationError struct {
+	Component string   `json:"component"`
+	Code      string   `json:"code"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return strings.Join([]string{e.Component, e.Message}, ": ")
+}
+
+// Component is a line of the design report, cost is in MCr
+type Component struct {
+	Component string  `json:"component"`
+	Name      string  `json:"name"`
+	Tons      float64 `json:"tons"`
+	Cost      float64 `json:"cost"`
+	Power     float64 `json:"power"`
+}
+
+type CrewRequirement struct {
+	Role  string `json:"role"`
+	Count int    `json:"count"`
+}
+
+// Report is the result of working out a design, costs are in MCr unless stated otherwise
+type Report struct {
+	Components    []Component `json:"components"`
+	HullPoints    float64     `json:"hullPoints"`
+	AllocatedTons float64     `json:"allocatedTons"`
+	FreeTons      float64     `json:"freeTons"`
+	Cost          float64     `json:"cost"`
+	// power used while manoeuvring and while jumping, the drives are not run at the same time
+	PowerAvailable         float64           `json:"powerAvailable"`
+	PowerRequired          float64           `json:"powerRequired"`
+	JumpPowerRequired      float64           `json:"jumpPowerRequired"`
+	JumpFuelPerParsec      float64           `json:"jumpFuelPerParsec"`
+	PowerPlantFuelPerMonth float64           `json:"powerPlantFuelPerMonth"`
+	MaxJump                int               `json:"maxJump"`
+	MaintenanceCrPerMonth  float64           `json:"maintenanceCrPerMonth"`
+	Crew                   []CrewRequirement `json:"crew"`
+	Errors                 []ValidationError `json:"errors"`
+}
+
+// Valid reports if the design has no errors, warnings are allowed
+func (r *Report) Valid() bool {
+	for _, err := range r.Errors {
+		if err.Severity == Severity_Error {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) fail(component string, code string, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ValidationError{Component: component, Code: code, Severity: Severity_Error, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warn(component string, code string, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ValidationError{Component: component, Code: code, Severity: Severity_Warning, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) add(c Component) {
+	r.Components = append(r.Components, c)
+	r.Cost += c.Cost
+}
+
+// checkTL records an error when a component needs a higher tech level than the design is built at
+func (r *Report) checkTL(design *model.ShipDesign, component string, name string, tl int) {
+	if design.TechLevel > 0 && tl > design.TechLevel {
+		r.fail(component, Code_TechLevel, "%s requires TL%d but the design is TL%d", name, tl, design.TechLevel)
+	}
+}
+
+// checkTons records an error when less tonnage was set aside for a component than it needs
+func (r *Report) checkTons(component string, name string, allocated float64, required float64) {
+	if allocated+0.0001 < required {
+		r.fail(component, Code_Tonnage, "%s needs %.2f tons but only %.2f are allocated", name, required, allocated)
+	}
+}
+
+// Calculate works out the cost, power, fuel, maintenance and crew of a design
+// and checks it against the construction tables.
+func (t *Tables) Calculate(design model.ShipDesign) Report {
+	report := Report{Components: []Component{}, Crew: []CrewRequirement{}, Errors: []ValidationError{}}
+	hull := design.Hull.Tons
+
+	if err := design.Validate(); err != nil {
+		report.fail("design", Code_Tonnage, "%s", err.Error())
+	}
+
+	if design.TechLevel <= 0 {
+		report.fail("design", Code_TechLevel, "design tech level must be set")
+	}
+
+	if hull < t.Hull.MinTons {
+		report.fail("hull", Code_Tonnage, "hull must be at least %.0f tons", t.Hull.MinTons)
+	}
+
+	// Hull
+	config, ok := t.hullConfiguration(string(design.Hull.Configuration))
+	if !ok {
+		report.fail("hull", Code_Unknown, "unknown hull configuration '%s'", design.Hull.Configuration)
+		config = HullConfiguration{Id: string(design.Hull.Configuration), Name: string(design.Hull.Configuration), CostModifier: 1}
+	}
+	if t.Hull.TonsPerHullPoint > 0 {
+		report.HullPoints = math.Floor(hull / t.Hull.TonsPerHullPoint)
+	}
+	report.add(Component{Component: "hull", Name: config.Name, Tons: hull, Cost: hull * t.Hull.CostPerTon * config.CostModifier})
+
+	basicPower := math.Ceil(hull * t.BasicPowerPercent / 100)
+	report.add(Component{Component: "basic systems", Name: "Basic Ship Systems", Power: basicPower})
+
+	var drivesTons float64
+
+	// Armour
+	if design.Armour.Rating > 0 || design.Armour.Type != "" {
+		armour, ok := t.armour(design.Armour.Type)
+		if !ok {
+			report.fail("armour", Code_Unknown, "unknown armour type '%s'", design.Armour.Type)
+		} else {
+			report.checkTL(&design, "armour", armour.Name, armour.TL)
+			maxPoints := armour.MaxPoints
+			if design.TechLevel > 0 && design.TechLevel < maxPoints {
+				maxPoints = design.TechLevel
+			}
+			if design.Armour.Rating > maxPoints {
+				report.fail("armour", Code_Rating, "%s can have at most %d points", armour.Name, maxPoints)
+			}
+			tons := hull * armour.HullPercentPerPoint / 100 * float64(design.Armour.Rating)
+			report.checkTons("armour", armour.Name, design.Armour.Tons, tons)
+			report.add(Component{Component: "armour", Name: armour.Name, Tons: design.Armour.Tons, Cost: tons * armour.CostPerTon})
+		}
+	}
+
+	// Manoeuvre drive
+	var maneuverPower float64
+	if design.ManeuverDrive.Rating > 0 {
+		drive := t.ManeuverDrive
+		name := fmt.Sprintf("Thrust %d", design.ManeuverDrive.Rating)
+		tl, ok := drive.driveTL(design.ManeuverDrive.Rating)
+		if !ok {
+			report.fail("maneuver drive", Code_Rating, "no manoeuvre drive of thrust %d", design.ManeuverDrive.Rating)
+		} else {
+			report.checkTL(&design, "maneuver drive", name, tl)
+		}
+		tons := math.Max(hull*drive.HullPercentPerRating/100*float64(design.ManeuverDrive.Rating), drive.MinTons)
+		report.checkTons("maneuver drive", name, design.ManeuverDrive.Tons, tons)
+		maneuverPower = math.Ceil(hull * drive.PowerPercentPerRating / 100 * float64(design.ManeuverDrive.Rating))
+		drivesTons += design.ManeuverDrive.Tons
+		report.add(Component{Component: "maneuver drive", Name: name, Tons: design.ManeuverDrive.Tons, Cost: tons * drive.CostPerTon, Power: maneuverPower})
+	}
+
+	// Jump drive
+	var jumpPower float64
+	report.JumpFuelPerParsec = hull * t.JumpDrive.FuelPercentPerParsec / 100
+	if design.JumpDrive.Rating > 0 {
+		drive := t.JumpDrive
+		name := fmt.Sprintf("Jump-%d", design.JumpDrive.Rating)
+		tl, ok := drive.driveTL(design.JumpDrive.Rating)
+		if !ok {
+			report.fail("jump drive", Code_Rating, "no jump drive of rating %d", design.JumpDrive.Rating)
+		} else {
+			report.checkTL(&design, "jump drive", name, tl)
+		}
+		tons := hull*drive.HullPercentPerRating/100*float64(design.JumpDrive.Rating) + drive.MinTons
+		report.checkTons("jump drive", name, design.JumpDrive.Tons, tons)
+		jumpPower = math.Ceil(hull * drive.PowerPercentPerRating / 100 * float64(design.JumpDrive.Rating))
+		drivesTons += design.JumpDrive.Tons
+		report.add(Component{Component: "jump drive", Name: name, Tons: design.JumpDrive.Tons, Cost: tons * drive.CostPerTon, Power: jumpPower})
+	}
+
+	// Power plant
+	if design.PowerPlant.Type != "" || design.PowerPlant.Output > 0 {
+		plant, ok := t.powerPlant(design.PowerPlant.Type)
+		if !ok {
+			report.fail("power plant", Code_Unknown, "unknown power plant type '%s'", design.PowerPlant.Type)
+		} else {
+			report.checkTL(&design, "power plant", plant.Name, plant.TL)
+			output := float64(design.PowerPlant.Output)
+			if output == 0 {
+				output = math.Floor(design.PowerPlant.Tons * plant.PowerPerTon)
+			}
+			tons := output / plant.PowerPerTon
+			report.checkTons("power plant", plant.Name, design.PowerPlant.Tons, tons)
+			report.PowerAvailable = output
+			report.PowerPlantFuelPerMonth = math.Ceil(design.PowerPlant.Tons * plant.FuelPercentPerMonth / 100)
+			drivesTons += design.PowerPlant.Tons
+			report.add(Component{Component: "power plant", Name: plant.Name, Tons: design.PowerPlant.Tons, Cost: design.PowerPlant.Tons * plant.CostPerTon})
+		}
+	}
+
+	// Fuel
+	report.add(Component{Component: "fuel", Name: "Fuel Tanks", Tons: design.FuelTons})
+	if report.JumpFuelPerParsec > 0 {
+		report.MaxJump = int(math.Floor((design.FuelTons - report.PowerPlantFuelPerMonth) / report.JumpFuelPerParsec))
+		if report.MaxJump > design.JumpDrive.Rating {
+			report.MaxJump = design.JumpDrive.Rating
+		}
+		if report.MaxJump < 0 {
+			report.MaxJump = 0
+		}
+	}
+	if design.JumpDrive.Rating > 0 && report.MaxJump < design.JumpDrive.Rating {
+		report.warn("fuel", Code_Fuel, "a jump-%d with a month of power plant fuel needs %.1f tons of fuel", design.JumpDrive.Rating, report.JumpFuelPerParsec*float64(design.JumpDrive.Rating)+report.PowerPlantFuelPerMonth)
+	}
+	if design.PowerPlant.Type != "" && design.FuelTons < report.PowerPlantFuelPerMonth {
+		report.warn("fuel", Code_Fuel, "the power plant needs %.0f tons of fuel a month", report.PowerPlantFuelPerMonth)
+	}
+
+	// Bridge
+	bridgeTons := t.Bridge.bridgeTons(hull)
+	report.checkTons("bridge", "Bridge", design.Bridge.Tons, bridgeTons)
+	report.add(Component{Component: "bridge", Name: "Bridge", Tons: design.Bridge.Tons, Cost: math.Ceil(hull/100) * t.Bridge.CostPer100Tons})
+
+	// Computer and software
+	bandwidth := 0
+	if design.Computer.Model != "" {
+		computer, ok := t.computer(design.Computer.Model)
+		if !ok {
+			report.fail("computer", Code_Unknown, "unknown computer '%s'", design.Computer.Model)
+		} else {
+			report.checkTL(&design, "computer", computer.Name, computer.TL)
+			bandwidth = computer.Bandwidth
+			report.add(Component{Component: "computer", Name: computer.Name, Cost: computer.Cost})
+		}
+	}
+
+	usedBandwidth := 0
+	jumpControl := 0
+	for _, item := range design.Computer.Software {
+		pkg, rating, ok := t.software(item.Name, item.Rating)
+		if !ok {
+			report.fail("software", Code_Unknown, "unknown software '%s/%d'", item.Name, item.Rating)
+			continue
+		}
+		name := fmt.Sprintf("%s/%d", pkg.Name, rating.Rating)
+		report.checkTL(&design, "software", name, rating.TL)
+		usedBandwidth += rating.Bandwidth
+		if pkg.Id == "jump_control" && rating.Rating > jumpControl {
+			jumpControl = rating.Rating
+		}
+		report.add(Component{Component: "software", Name: name, Cost: rating.Cost})
+	}
+	if usedBandwidth > bandwidth {
+		report.fail("software", Code_Bandwidth, "software needs %d bandwidth but the computer has %d", usedBandwidth, bandwidth)
+	}
+	if design.JumpDrive.Rating > jumpControl {
+		report.fail("software", Code_Software, "a jump-%d drive needs Jump Control/%d", design.JumpDrive.Rating, design.JumpDrive.Rating)
+	}
+
+	// Sensors
+	var sensorPower float64
+	if design.Sensors.Grade != "" {
+		sensors, ok := t.sensors(design.Sensors.Grade)
+		if !ok {
+			report.fail("sensors", Code_Unknown, "unknown sensors '%s'", design.Sensors.Grade)
+		} else {
+			report.checkTL(&design, "sensors", sensors.Name, sensors.TL)
+			report.checkTons("sensors", sensors.Name, design.Sensors.Tons, sensors.Tons)
+			sensorPower = sensors.Power
+			report.add(Component{Component: "sensors", Name: sensors.Name, Tons: design.Sensors.Tons, Cost: sensors.Cost, Power: sensors.Power})
+		}
+	}
+
+	// Staterooms
+	rooms := t.Staterooms
+	roomTons := float64(design.Staterooms.Standard)*rooms.StateroomTons + float64(design.Staterooms.LowBerths)*rooms.LowBerthTons
+	report.checkTons("staterooms", "Staterooms", design.Staterooms.Tons, roomTons)
+	berthPower := math.Ceil(float64(design.Staterooms.LowBerths) * rooms.LowBerthPower)
+	report.add(Component{
+		Component: "staterooms",
+		Name:      fmt.Sprintf("%d Staterooms, %d Low Berths", design.Staterooms.Standard, design.Staterooms.LowBerths),
+		Tons:      design.Staterooms.Tons,
+		Cost:      float64(design.Staterooms.Standard)*rooms.StateroomCost + float64(design.Staterooms.LowBerths)*rooms.LowBerthCost,
+		Power:     berthPower,
+	})
+
+	// Weapons
+	var weaponPower float64
+	weaponCount := 0
+	hardpoints := int(math.Floor(hull / 100))
+	if len(design.Weapons) > hardpoints {
+		report.fail("weapons", Code_Mount, "a %.0f ton hull has %d hardpoints but %d mounts are fitted", hull, hardpoints, len(design.Weapons))
+	}
+	for _, item := range design.Weapons {
+		mount, ok := t.mount(item.Mount)
+		if !ok {
+			report.fail("weapons", Code_Unknown, "unknown mount '%s'", item.Mount)
+			continue
+		}
+		report.checkTL(&design, "weapons", mount.Name, mount.TL)
+		report.checkTons("weapons", mount.Name, item.Tons, mount.Tons)
+		if len(item.Weapons) > mount.Weapons {
+			report.fail("weapons", Code_Mount, "%s holds %d weapons but %d are fitted", mount.Name, mount.Weapons, len(item.Weapons))
+		}
+
+		line := Component{Component: "weapons", Name: mount.Name, Tons: item.Tons, Cost: mount.Cost, Power: mount.Power}
+		names := []string{}
+		for _, id := range item.Weapons {
+			weapon, ok := t.Weapon(id)
+			if !ok {
+				report.fail("weapons", Code_Unknown, "unknown weapon '%s'", id)
+				continue
+			}
+			report.checkTL(&design, "weapons", weapon.Name, weapon.TL)
+			line.Cost += weapon.Cost
+			line.Power += weapon.Power
+			names = append(names, weapon.Name)
+			weaponCount++
+		}
+		if len(names) > 0 {
+			line.Name = strings.Join([]string{line.Name, " (", strings.Join(names, ", "), ")"}, "")
+		}
+		weaponPower += line.Power
+		report.add(line)
+	}
+
+	// Craft
+	for _, craft := range design.Craft {
+		report.add(Component{Component: "craft", Name: craft.Name, Tons: craft.Tons, Cost: craft.Tons * t.Craft.CostPerTon})
+	}
+
+	// Cargo
+	report.add(Component{Component: "cargo", Name: "Cargo", Tons: design.CargoTons})
+
+	// Power
+	report.PowerRequired = basicPower + maneuverPower + sensorPower + weaponPower + berthPower
+	report.JumpPowerRequired = basicPower + jumpPower + sensorPower + berthPower
+	if report.PowerRequired > report.PowerAvailable {
+		report.fail("power plant", Code_Power, "manoeuvring needs %.0f power but the power plant makes %.0f", report.PowerRequired, report.PowerAvailable)
+	}
+	if jumpPower > 0 && report.JumpPowerRequired > report.PowerAvailable {
+		report.fail("power plant", Code_Power, "jumping needs %.0f power but the power plant makes %.0f", report.JumpPowerRequired, report.PowerAvailable)
+	}
+
+	report.AllocatedTons = design.AllocatedTons()
+	report.FreeTons = hull - report.AllocatedTons
+	report.MaintenanceCrPerMonth = math.Round(report.Cost * 1_000_000 * t.MaintenancePercentPerYear / 100 / 12)
+	report.Crew = t.crew(&design, drivesTons, weaponCount)
+
+	return report
+}
+
+// crew works out the crew a commercial ship needs
+func (t *Tables) crew(design *model.ShipDesign, drivesTons float64, weapons int) []CrewRequirement {
+	crew := []CrewRequirement{{Role: "pilot", Count: 1}}
+
+	if design.JumpDrive.Rating > 0 {
+		crew = append(crew, CrewRequirement{Role: "astrogator", Count: 1})
+	}
+
+	if t.Crew.EngineerPerTons > 0 && drivesTons > 0 {
+		crew = append(crew, CrewRequirement{Role: "engineer", Count: int(math.Ceil(drivesTons / t.Crew.EngineerPerTons))})
+	}
+
+	if t.Crew.MaintenancePerTons > 0 {
+		if count := int(math.Floor(design.Hull.Tons / t.Crew.MaintenancePerTons)); count > 0 {
+			crew = append(crew, CrewRequirement{Role: "maintenance", Count: count})
+		}
+	}
+
+	if weapons > 0 {
+		crew = append(crew, CrewRequirement{Role: "gunner", Count: len(design.Weapons)})
+	}
+
+	if t.Crew.StewardPerStaterooms > 0 {
+		if count := int(math.Floor(float64(design.Staterooms.Standard) / t.Crew.StewardPerStaterooms)); count > 0 {
+			crew = append(crew, CrewRequirement{Role: "steward", Count: count})
+		}
+	}
+
+	if t.Crew.MedicPerPeople > 0 {
+		if count := int(math.Floor(float64(design.Staterooms.Standard+design.Staterooms.LowBerths) / t.Crew.MedicPerPeople)); count > 0 {
+			crew = append(crew, CrewRequirement{Role: "medic", Count: count})
+		}
+	}
+
+	return crew
+}
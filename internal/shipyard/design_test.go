@@ -0,0 +1,183 @@
+package shipyard
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func testTables(t *testing.T) *Tables {
+	t.Helper()
+	tables, err := Load("../../configs/ship_components.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
+
+// trader is a 200 ton jump-1 trader that fits the construction tables at TL12
+func trader() model.ShipDesign {
+	return model.ShipDesign{
+		TechLevel:     12,
+		Hull:          model.Hull{Tons: 200, Configuration: model.HullConfiguration_Standard},
+		ManeuverDrive: model.Drive{Rating: 1, Tons: 2},
+		JumpDrive:     model.Drive{Rating: 1, Tons: 10},
+		PowerPlant:    model.PowerPlant{Type: "fusion_12", Tons: 5},
+		FuelTons:      21,
+		Bridge:        model.Bridge{Tons: 10},
+		Computer:      model.Computer{Model: "computer_10", Software: []model.Software{{Name: "jump_control", Rating: 1}}},
+		Sensors:       model.Sensors{Grade: "civilian", Tons: 1},
+		Staterooms:    model.Staterooms{Standard: 10, Tons: 40},
+		Weapons:       []model.WeaponMount{{Mount: "double_turret", Weapons: []string{"pulse_laser", "pulse_laser"}, Tons: 1}},
+		CargoTons:     110,
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	report := testTables(t).Calculate(trader())
+
+	if !report.Valid() || len(report.Errors) != 0 {
+		t.Fatalf("the trader has errors %+v", report.Errors)
+	}
+
+	near := func(a float64, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"hull points", report.HullPoints, 80},
+		{"allocated tons", report.AllocatedTons, 200},
+		{"free tons", report.FreeTons, 0},
+		// hull 10, drives 4 and 15, plant 5, bridge 1, computer 0.16, software 0.1, sensors 3, staterooms 5, turret 2.5
+		{"cost", report.Cost, 45.76},
+		{"power available", report.PowerAvailable, 75},
+		// basic 40, thrust 20, sensors 1, lasers and turret 9
+		{"power required", report.PowerRequired, 70},
+		{"jump power required", report.JumpPowerRequired, 61},
+		{"jump fuel per parsec", report.JumpFuelPerParsec, 20},
+		{"power plant fuel per month", report.PowerPlantFuelPerMonth, 1},
+		{"max jump", float64(report.MaxJump), 1},
+		{"maintenance", report.MaintenanceCrPerMonth, 3813},
+	}
+	for _, check := range checks {
+		if !near(check.got, check.want) {
+			t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+		}
+	}
+
+	want := []CrewRequirement{{"pilot", 1}, {"astrogator", 1}, {"engineer", 1}, {"gunner", 1}, {"steward", 1}}
+	if !slices.Equal(report.Crew, want) {
+		t.Errorf("crew = %+v, want %+v", report.Crew, want)
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	tables := testTables(t)
+
+	tests := []struct {
+		name      string
+		change    func(d *model.ShipDesign)
+		component string
+		code      string
+		severity  Severity
+	}{
+		{"power plant too small to manoeuvre", func(d *model.ShipDesign) {
+			d.PowerPlant.Tons, d.CargoTons = 4, 111
+		}, "power plant", Code_Power, Severity_Error},
+		{"power plant too small to jump", func(d *model.ShipDesign) {
+			// jump-2 draws 40 where thrust 1 draws 20
+			d.JumpDrive = model.Drive{Rating: 2, Tons: 15}
+			d.FuelTons, d.CargoTons = 41, 85
+			d.Computer.Software = []model.Software{{Name: "jump_control", Rating: 2}}
+		}, "power plant", Code_Power, Severity_Error},
+		{"power plant tonnage", func(d *model.ShipDesign) {
+			d.PowerPlant.Output = 90
+		}, "power plant", Code_Tonnage, Severity_Error},
+		{"not enough fuel to jump", func(d *model.ShipDesign) {
+			d.FuelTons, d.CargoTons = 15, 116
+		}, "fuel", Code_Fuel, Severity_Warning},
+		{"no fuel for the power plant", func(d *model.ShipDesign) {
+			d.FuelTons, d.CargoTons = 0, 131
+		}, "fuel", Code_Fuel, Severity_Warning},
+		{"power plant above the tech level", func(d *model.ShipDesign) {
+			d.TechLevel = 10
+		}, "power plant", Code_TechLevel, Severity_Error},
+		{"jump drive above the tech level", func(d *model.ShipDesign) {
+			d.TechLevel = 8
+		}, "jump drive", Code_TechLevel, Severity_Error},
+		{"weapon above the tech level", func(d *model.ShipDesign) {
+			d.TechLevel = 8
+		}, "weapons", Code_TechLevel, Severity_Error},
+		{"tech level not set", func(d *model.ShipDesign) {
+			d.TechLevel = 0
+		}, "design", Code_TechLevel, Severity_Error},
+		{"no jump control", func(d *model.ShipDesign) {
+			d.Computer.Software = nil
+		}, "software", Code_Software, Severity_Error},
+		{"computer bandwidth", func(d *model.ShipDesign) {
+			d.Computer.Software = append(d.Computer.Software, model.Software{Name: "evade", Rating: 1})
+		}, "software", Code_Bandwidth, Severity_Error},
+		{"staterooms tonnage", func(d *model.ShipDesign) {
+			d.Staterooms.Tons, d.CargoTons = 36, 114
+		}, "staterooms", Code_Tonnage, Severity_Error},
+		{"over tonnage", func(d *model.ShipDesign) {
+			d.CargoTons = 120
+		}, "design", Code_Tonnage, Severity_Error},
+		{"too many mounts", func(d *model.ShipDesign) {
+			d.Weapons = append(d.Weapons, model.WeaponMount{Mount: "single_turret", Weapons: []string{"sandcaster"}, Tons: 1}, model.WeaponMount{Mount: "single_turret", Tons: 1})
+			d.CargoTons = 108
+		}, "weapons", Code_Mount, Severity_Error},
+		{"unknown power plant", func(d *model.ShipDesign) {
+			d.PowerPlant.Type = "antimatter"
+		}, "power plant", Code_Unknown, Severity_Error},
+	}
+
+	for _, test := range tests {
+		design := trader()
+		test.change(&design)
+		report := tables.Calculate(design)
+
+		found := slices.ContainsFunc(report.Errors, func(err ValidationError) bool {
+			return err.Component == test.component && err.Code == test.code && err.Severity == test.severity
+		})
+		if !found {
+			t.Errorf("%s: no %s %s on the %s in %+v", test.name, test.severity, test.code, test.component, report.Errors)
+		}
+		if valid := test.severity != Severity_Error; report.Valid() != valid {
+			t.Errorf("%s: Valid() = %v", test.name, report.Valid())
+		}
+	}
+}
+
+func TestCalculateCrew(t *testing.T) {
+	tables := testTables(t)
+
+	tests := []struct {
+		name   string
+		change func(d *model.ShipDesign)
+		want   []CrewRequirement
+	}{
+		{"trader", func(d *model.ShipDesign) {}, []CrewRequirement{{"pilot", 1}, {"astrogator", 1}, {"engineer", 1}, {"gunner", 1}, {"steward", 1}}},
+		{"no jump drive or guns", func(d *model.ShipDesign) {
+			d.JumpDrive = model.Drive{}
+			d.Weapons = nil
+		}, []CrewRequirement{{"pilot", 1}, {"engineer", 1}, {"steward", 1}}},
+		{"big drives", func(d *model.ShipDesign) {
+			d.PowerPlant.Tons = 30
+		}, []CrewRequirement{{"pilot", 1}, {"astrogator", 1}, {"engineer", 2}, {"gunner", 1}, {"steward", 1}}},
+		{"liner", func(d *model.ShipDesign) {
+			d.Hull.Tons = 2000
+			d.Staterooms = model.Staterooms{Standard: 100, LowBerths: 40}
+		}, []CrewRequirement{{"pilot", 1}, {"astrogator", 1}, {"engineer", 1}, {"maintenance", 2}, {"gunner", 1}, {"steward", 12}, {"medic", 1}}},
+	}
+
+	for _, test := range tests {
+		design := trader()
+		test.change(&design)
+		if report := tables.Calculate(design); !slices.Equal(report.Crew, test.want) {
+			t.Errorf("%s: crew = %+v, want %+v", test.name, report.Crew, test.want)
+		}
+	}
+}
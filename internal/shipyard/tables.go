@@ -0,0 +1,296 @@
+package shipyard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"visualsource/traveller/internal/dice"
+
+	"gopkg.in/yaml.v3"
+)
+
+type HullConfiguration struct {
+	Id           string  `yaml:"id" json:"id"`
+	Name         string  `yaml:"name" json:"name"`
+	CostModifier float64 `yaml:"cost_modifier" json:"costModifier"`
+}
+
+type HullTable struct {
+	CostPerTon       float64             `yaml:"cost_per_ton"`
+	TonsPerHullPoint float64             `yaml:"tons_per_hull_point"`
+	MinTons          float64             `yaml:"min_tons"`
+	Configurations   []HullConfiguration `yaml:"configurations"`
+}
+
+type BridgeSize struct {
+	MaxHull float64 `yaml:"max_hull"`
+	Tons    float64 `yaml:"tons"`
+}
+
+type BridgeTable struct {
+	CostPer100Tons float64      `yaml:"cost_per_100_tons"`
+	Sizes          []BridgeSize `yaml:"sizes"`
+}
+
+type ArmourType struct {
+	Id                  string  `yaml:"id" json:"id"`
+	Name                string  `yaml:"name" json:"name"`
+	TL                  int     `yaml:"tl" json:"tl"`
+	HullPercentPerPoint float64 `yaml:"hull_percent_per_point" json:"hullPercentPerPoint"`
+	CostPerTon          float64 `yaml:"cost_per_ton" json:"costPerTon"`
+	MaxPoints           int     `yaml:"max_points" json:"maxPoints"`
+}
+
+type DriveRating struct {
+	Rating int `yaml:"rating" json:"rating"`
+	TL     int `yaml:"tl" json:"tl"`
+}
+
+type DriveTable struct {
+	HullPercentPerRating  float64       `yaml:"hull_percent_per_rating"`
+	MinTons               float64       `yaml:"min_tons"`
+	CostPerTon            float64       `yaml:"cost_per_ton"`
+	PowerPercentPerRating float64       `yaml:"power_percent_per_rating"`
+	FuelPercentPerParsec  float64       `yaml:"fuel_percent_per_parsec"`
+	Ratings               []DriveRating `yaml:"ratings"`
+}
+
+type PowerPlantType struct {
+	Id                  string  `yaml:"id" json:"id"`
+	Name                string  `yaml:"name" json:"name"`
+	TL                  int     `yaml:"tl" json:"tl"`
+	PowerPerTon         float64 `yaml:"power_per_ton" json:"powerPerTon"`
+	CostPerTon          float64 `yaml:"cost_per_ton" json:"costPerTon"`
+	FuelPercentPerMonth float64 `yaml:"fuel_percent_per_month" json:"fuelPercentPerMonth"`
+}
+
+type ComputerModel struct {
+	Id        string  `yaml:"id" json:"id"`
+	Name      string  `yaml:"name" json:"name"`
+	TL        int     `yaml:"tl" json:"tl"`
+	Bandwidth int     `yaml:"bandwidth" json:"bandwidth"`
+	Cost      float64 `yaml:"cost" json:"cost"`
+}
+
+type SoftwareRating struct {
+	Rating    int     `yaml:"rating" json:"rating"`
+	TL        int     `yaml:"tl" json:"tl"`
+	Bandwidth int     `yaml:"bandwidth" json:"bandwidth"`
+	Cost      float64 `yaml:"cost" json:"cost"`
+}
+
+type SoftwarePackage struct {
+	Id      string           `yaml:"id" json:"id"`
+	Name    string           `yaml:"name" json:"name"`
+	Ratings []SoftwareRating `yaml:"ratings" json:"ratings"`
+}
+
+type SensorSuite struct {
+	Id    string  `yaml:"id" json:"id"`
+	Name  string  `yaml:"name" json:"name"`
+	TL    int     `yaml:"tl" json:"tl"`
+	Tons  float64 `yaml:"tons" json:"tons"`
+	Power float64 `yaml:"power" json:"power"`
+	Cost  float64 `yaml:"cost" json:"cost"`
+}
+
+type StateroomTable struct {
+	StateroomTons float64 `yaml:"stateroom_tons"`
+	StateroomCost float64 `yaml:"stateroom_cost"`
+	LowBerthTons  float64 `yaml:"low_berth_tons"`
+	LowBerthCost  float64 `yaml:"low_berth_cost"`
+	LowBerthPower float64 `yaml:"low_berth_power"`
+}
+
+type Mount struct {
+	Id      string  `yaml:"id" json:"id"`
+	Name    string  `yaml:"name" json:"name"`
+	TL      int     `yaml:"tl" json:"tl"`
+	Tons    float64 `yaml:"tons" json:"tons"`
+	Cost    float64 `yaml:"cost" json:"cost"`
+	Power   float64 `yaml:"power" json:"power"`
+	Weapons int     `yaml:"weapons" json:"weapons"`
+}
+
+type Weapon struct {
+	Id    string  `yaml:"id" json:"id"`
+	Name  string  `yaml:"name" json:"name"`
+	TL    int     `yaml:"tl" json:"tl"`
+	Cost  float64 `yaml:"cost" json:"cost"`
+	Power float64 `yaml:"power" json:"power"`
+	// longest range band the weapon can hit at
+	Range string `yaml:"range" json:"range"`
+	// empty for defensive weapons that can not attack
+	Damage string `yaml:"damage" json:"damage"`
+
+	damage dice.Expression
+}
+
+// DamageRoll is the parsed damage of the weapon
+func (w Weapon) DamageRoll() dice.Expression {
+	return w.damage
+}
+
+type CraftTable struct {
+	DockingSpaceModifier float64 `yaml:"docking_space_modifier"`
+	CostPerTon           float64 `yaml:"cost_per_ton"`
+}
+
+type CrewTable struct {
+	EngineerPerTons      float64 `yaml:"engineer_per_tons"`
+	MaintenancePerTons   float64 `yaml:"maintenance_per_tons"`
+	StewardPerStaterooms float64 `yaml:"steward_per_staterooms"`
+	MedicPerPeople       float64 `yaml:"medic_per_people"`
+}
+
+// Tables are the ship construction rules loaded from configs/ship_components.yml
+type Tables struct {
+	Hull                      HullTable         `yaml:"hull"`
+	BasicPowerPercent         float64           `yaml:"basic_power_percent"`
+	Bridge                    BridgeTable       `yaml:"bridge"`
+	Armour                    []ArmourType      `yaml:"armour"`
+	ManeuverDrive             DriveTable        `yaml:"maneuver_drive"`
+	JumpDrive                 DriveTable        `yaml:"jump_drive"`
+	PowerPlants               []PowerPlantType  `yaml:"power_plants"`
+	Computers                 []ComputerModel   `yaml:"computers"`
+	Software                  []SoftwarePackage `yaml:"software"`
+	Sensors                   []SensorSuite     `yaml:"sensors"`
+	Staterooms                StateroomTable    `yaml:"staterooms"`
+	Mounts                    []Mount           `yaml:"mounts"`
+	Weapons                   []Weapon          `yaml:"weapons"`
+	Craft                     CraftTable        `yaml:"craft"`
+	MaintenancePercentPerYear float64           `yaml:"maintenance_percent_per_year"`
+	Crew                      CrewTable         `yaml:"crew"`
+}
+
+func Load(path string) (*Tables, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables Tables
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+
+	for i := range tables.Weapons {
+		weapon := &tables.Weapons[i]
+		if weapon.Damage == "" {
+			continue
+		}
+		if weapon.damage, err = dice.ParseExpression(weapon.Damage); err != nil {
+			return nil, fmt.Errorf("weapon '%s': %w", weapon.Id, err)
+		}
+	}
+
+	return &tables, nil
+}
+
+// matches compares a design value against the id or name of a table entry
+func matches(value string, id string, name string) bool {
+	value = strings.TrimSpace(value)
+	return strings.EqualFold(value, id) || strings.EqualFold(value, name)
+}
+
+func (t *Tables) hullConfiguration(value string) (HullConfiguration, bool) {
+	for _, item := range t.Hull.Configurations {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return HullConfiguration{}, false
+}
+
+func (t *Tables) armour(value string) (ArmourType, bool) {
+	for _, item := range t.Armour {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return ArmourType{}, false
+}
+
+func (t *Tables) powerPlant(value string) (PowerPlantType, bool) {
+	for _, item := range t.PowerPlants {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return PowerPlantType{}, false
+}
+
+func (t *Tables) computer(value string) (ComputerModel, bool) {
+	for _, item := range t.Computers {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return ComputerModel{}, false
+}
+
+func (t *Tables) software(value string, rating int) (SoftwarePackage, SoftwareRating, bool) {
+	for _, item := range t.Software {
+		if !matches(value, item.Id, item.Name) {
+			continue
+		}
+		for _, r := range item.Ratings {
+			if r.Rating == rating {
+				return item, r, true
+			}
+		}
+		return item, SoftwareRating{}, false
+	}
+	return SoftwarePackage{}, SoftwareRating{}, false
+}
+
+func (t *Tables) sensors(value string) (SensorSuite, bool) {
+	for _, item := range t.Sensors {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return SensorSuite{}, false
+}
+
+func (t *Tables) mount(value string) (Mount, bool) {
+	for _, item := range t.Mounts {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return Mount{}, false
+}
+
+// Weapon finds a ship weapon by id or name
+func (t *Tables) Weapon(value string) (Weapon, bool) {
+	for _, item := range t.Weapons {
+		if matches(value, item.Id, item.Name) {
+			return item, true
+		}
+	}
+	return Weapon{}, false
+}
+
+// driveTL returns the tech level needed to build a drive of the rating, false when the rating is not in the table
+func (d *DriveTable) driveTL(rating int) (int, bool) {
+	for _, r := range d.Ratings {
+		if r.Rating == rating {
+			return r.TL, true
+		}
+	}
+	return 0, false
+}
+
+// bridgeTons returns the size of the bridge a hull needs
+func (b *BridgeTable) bridgeTons(hull float64) float64 {
+	for _, size := range b.Sizes {
+		if hull <= size.MaxHull {
+			return size.Tons
+		}
+	}
+	if len(b.Sizes) == 0 {
+		return 0
+	}
+	return b.Sizes[len(b.Sizes)-1].Tons
+}
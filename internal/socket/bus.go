@@ -0,0 +1,166 @@
+package socket
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Bus carries the messages of a session between every server instance.
+// Rooms publish what their clients send and deliver what they receive
+// from their subscription, so with a shared bus clients connected to
+// different instances still see each others messages.
+type Bus interface {
+	// Publish sends data to every subscriber of the session, this instance included
+	Publish(sessionId string, data []byte) error
+	// Subscribe starts receiving the messages published to the session
+	Subscribe(sessionId string) (Subscription, error)
+	Close() error
+}
+
+type Subscription interface {
+	Messages() <-chan []byte
+	Close() error
+}
+
+// LocalBus is a Bus for running a single instance
+type LocalBus struct {
+	mu   sync.Mutex
+	subs map[string]map[*localSubscription]bool
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subs: make(map[string]map[*localSubscription]bool)}
+}
+
+func (b *LocalBus) Publish(sessionId string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[sessionId] {
+		sub.push(data)
+	}
+	return nil
+}
+
+func (b *LocalBus) Subscribe(sessionId string) (Subscription, error) {
+	sub := &localSubscription{
+		bus:       b,
+		sessionId: sessionId,
+		out:       make(chan []byte),
+		notify:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	subs, ok := b.subs[sessionId]
+	if !ok {
+		subs = make(map[*localSubscription]bool)
+		b.subs[sessionId] = subs
+	}
+	subs[sub] = true
+	b.mu.Unlock()
+
+	go sub.pump()
+	return sub, nil
+}
+
+func (b *LocalBus) Close() error {
+	return nil
+}
+
+// localSubscription queues published messages without bound so a room
+// publishing to its own session never blocks on itself
+type localSubscription struct {
+	bus       *LocalBus
+	sessionId string
+	mu        sync.Mutex
+	queue     [][]byte
+	out       chan []byte
+	notify    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *localSubscription) push(data []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, data)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *localSubscription) pump() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, data := range queue {
+			select {
+			case s.out <- data:
+			case <-s.done:
+				return
+			}
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *localSubscription) Messages() <-chan []byte {
+	return s.out
+}
+
+func (s *localSubscription) Close() error {
+	s.closeOnce.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs[s.sessionId], s)
+		if len(s.bus.subs[s.sessionId]) == 0 {
+			delete(s.bus.subs, s.sessionId)
+		}
+		s.bus.mu.Unlock()
+		close(s.done)
+	})
+	return nil
+}
+
+type envelopeKind string
+
+const (
+	// deliver Data to every client of the session
+	envelope_All envelopeKind = "all"
+	// deliver Data to every connection of UserId
+	envelope_User envelopeKind = "user"
+	// the number of connections UserId has on the Origin instance changed
+	envelope_Presence envelopeKind = "presence"
+	// the Origin instance started a room and wants everyone's presence
+	envelope_Sync envelopeKind = "sync"
+	// the Origin instance is still running, Roster is everyone connected to it
+	envelope_Heartbeat envelopeKind = "heartbeat"
+)
+
+// envelope is what is published on the bus
+type envelope struct {
+	Kind        envelopeKind    `json:"kind"`
+	Origin      string          `json:"origin"`
+	UserId      string          `json:"userId,omitempty"`
+	Username    string          `json:"username,omitempty"`
+	Connections int             `json:"connections,omitempty"`
+	Roster      []OnlineUser    `json:"roster,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// rawMessage is a message that has already been encoded
+type rawMessage []byte
+
+func (m rawMessage) ToJson() ([]byte, error) {
+	return m, nil
+}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"log"
-	"strings"
+	"strconv"
 	"time"
 
+	"visualsource/traveller/internal/model"
+
 	"github.com/gorilla/websocket"
-	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 )
 
@@ -31,10 +32,14 @@ var upgrader = websocket.Upgrader{
 
 type Client struct {
 	hub       *Hub
+	room      *Room
 	conn      *websocket.Conn
 	send      chan []byte
 	UserId    string
+	Username  string
 	SessionId string
+	// chat sequence number the client has already seen, -1 when unknown
+	after int64
 }
 
 type clientBoardcastMessage struct {
@@ -42,14 +47,19 @@ type clientBoardcastMessage struct {
 	Target  string
 }
 
+type clientHistoryMessage struct {
+	After float64
+}
+
+// GetId identifies the user within a session, it is shared by every connection the user has open
 func (c *Client) GetId() string {
-	return strings.Join([]string{c.UserId, c.SessionId}, ":")
+	return clientId(c.UserId, c.SessionId)
 }
 
 func (c *Client) readPump() {
 	defer func() {
 		c.conn.Close()
-		c.hub.unregister <- c.GetId()
+		c.hub.leave(c)
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -57,11 +67,11 @@ func (c *Client) readPump() {
 	for {
 		_, msg, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) || websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				break
+			// read errors are permanent, keep reading would spin forever on a dead connection
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
+				log.Printf("error: %v\n", err)
 			}
-			log.Printf("error: %v\n", err)
-			continue
+			break
 		}
 		msg = bytes.TrimSpace(bytes.Replace(msg, newline, space, -1))
 
@@ -81,7 +91,7 @@ func (c *Client) readPump() {
 				continue
 			}
 
-			c.hub.broadcast <- NewBroadcastMessage(c.SessionId, d.Message, d.Target, c.UserId)
+			c.room.broadcast <- NewBroadcastMessage(c.SessionId, d.Message, d.Target, c.UserId)
 		case "PrivateMessage":
 			var d clientBoardcastMessage
 			err = data.parsePayload(&d)
@@ -89,7 +99,17 @@ func (c *Client) readPump() {
 				log.Println(err)
 				continue
 			}
-			c.hub.message <- NewPrivateMessage(c.UserId, d.Target, c.SessionId, d.Message)
+			c.room.message <- NewPrivateMessage(c.UserId, d.Target, c.SessionId, d.Message)
+		case "ChatHistory":
+			var d clientHistoryMessage
+			err = data.parsePayload(&d)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			c.room.history <- historyRequest{client: c, after: int64(d.After)}
+		case "OnlineUsers":
+			c.room.roster <- c
 		}
 	}
 }
@@ -130,23 +150,33 @@ func (c *Client) writePump() {
 	}
 }
 
-func ServeWs(hub *Hub, c echo.Context) error {
-	sess, err := session.Get("session", c)
-	if err != nil {
-		return err
-	}
-
+// ServeWs connects the user to the session, callers must check the user is a member of the session first
+func ServeWs(hub *Hub, c echo.Context, sessionId string, userId string) error {
 	conn, err := upgrader.Upgrade(c.Response().Writer, c.Request(), nil)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	sessionId := c.Param("sessionId")
-	userId := sess.Values["sub"].(string)
+	// A reconnecting client passes the last chat sequence it received so only missed messages are replayed
+	after := int64(-1)
+	if value := c.QueryParam("after"); value != "" {
+		if seq, err := strconv.ParseInt(value, 10, 64); err == nil && seq >= 0 {
+			after = seq
+		}
+	}
+
+	var user model.User
+	if err := user.GetUser(hub.db, userId); err != nil {
+		log.Println(err)
+	}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), SessionId: sessionId, UserId: userId}
-	client.hub.register <- client
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), SessionId: sessionId, UserId: userId, Username: user.Username, after: after}
+	if err := client.hub.join(client); err != nil {
+		log.Println(err)
+		conn.Close()
+		return err
+	}
 
 	go client.writePump()
 	go client.readPump()
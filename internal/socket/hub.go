@@ -1,76 +1,139 @@
 package socket
 
 import (
-	"log"
+	"database/sql"
+	"encoding/json"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucsky/cuid"
+)
+
+const (
+	// how often a room tells the other instances it is still running
+	heartbeatPeriod = 10 * time.Second
+	// users of an instance that has not been heard from for this long are taken offline
+	presenceTTL = 3 * heartbeatPeriod
 )
 
+// Hub hands out a room per session, rooms are created when the first
+// client of a session connects and torn down once the last one leaves.
 type Hub struct {
-	clients    map[string]*Client
-	message    chan PrivateMessage
-	broadcast  chan BroadcastMessge
-	register   chan *Client
-	unregister chan string
+	db  *sql.DB
+	bus Bus
+	// identifies this server instance on the bus
+	instance string
+	// heartbeat and presenceTTL default to the package constants, tests shorten them
+	heartbeat   time.Duration
+	presenceTTL time.Duration
+	mu          sync.Mutex
+	rooms       map[string]*Room
 }
 
-func NewHub() *Hub {
+func NewHub(db *sql.DB, bus Bus) *Hub {
 	return &Hub{
-		message:    make(chan PrivateMessage),
-		broadcast:  make(chan BroadcastMessge),
-		register:   make(chan *Client),
-		unregister: make(chan string),
-		clients:    make(map[string]*Client),
+		db:          db,
+		bus:         bus,
+		instance:    cuid.New(),
+		heartbeat:   heartbeatPeriod,
+		presenceTTL: presenceTTL,
+		rooms:       make(map[string]*Room),
+	}
+}
+
+func (h *Hub) publish(sessionId string, env envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return h.bus.Publish(sessionId, data)
+}
+
+// Publish sends a message to every client of the session on every instance
+func (h *Hub) Publish(sessionId string, msg Message) error {
+	data, err := msg.ToJson()
+	if err != nil {
+		return err
 	}
+	return h.publish(sessionId, envelope{Kind: envelope_All, Origin: h.instance, Data: data})
+}
+
+// PublishUser sends a message to every connection a user has open in the session on every instance
+func (h *Hub) PublishUser(sessionId string, userId string, msg Message) error {
+	data, err := msg.ToJson()
+	if err != nil {
+		return err
+	}
+	return h.publish(sessionId, envelope{Kind: envelope_User, Origin: h.instance, UserId: userId, Data: data})
+}
+
+func clientId(userId string, sessionId string) string {
+	return strings.Join([]string{userId, sessionId}, ":")
 }
 
-func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			id := client.GetId()
-			log.Printf("Registering %s\n", id)
-			h.clients[id] = client
-		case client := <-h.unregister:
-			log.Printf("Unregistering client: %s\n", client)
-			if c, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(c.send)
-			}
-		case msg := <-h.message:
-			id := strings.Join([]string{msg.ToUser, msg.SessionId}, ":")
-			info, ok := h.clients[id]
-			if ok {
-				data, err := msg.ToJson()
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				select {
-				case info.send <- data:
-				default:
-					close(info.send)
-					delete(h.clients, id)
-				}
-			}
-		case msg := <-h.broadcast:
-			for id, info := range h.clients {
-				if info.SessionId != msg.SessionId {
-					continue
-				}
-
-				data, err := msg.ToJson()
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				select {
-				case info.send <- data:
-				default:
-					close(info.send)
-					delete(h.clients, id)
-				}
-			}
+// join adds the client to the room of its session, starting the room if needed
+func (h *Hub) join(client *Client) error {
+	h.mu.Lock()
+	room, ok := h.rooms[client.SessionId]
+	if !ok {
+		var err error
+		room, err = newRoom(h, client.SessionId)
+		if err != nil {
+			h.mu.Unlock()
+			return err
 		}
+		h.rooms[client.SessionId] = room
+		go room.run()
+	}
+	room.refs++
+	client.room = room
+	h.mu.Unlock()
+
+	room.register <- client
+	return nil
+}
+
+// leave removes the client from its room and stops the room when it was the last client
+func (h *Hub) leave(client *Client) {
+	room := client.room
+	room.unregister <- client
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room.refs--
+	if room.refs == 0 {
+		delete(h.rooms, room.id)
+		close(room.done)
+	}
+}
+
+// room returns the running room of the session or nil when no one is connected
+func (h *Hub) room(sessionId string) *Room {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rooms[sessionId]
+}
+
+// Online returns the users that currently have at least one connection to the session.
+// Users on other instances are only known while someone is connected to the session on this one.
+func (h *Hub) Online(sessionId string) []OnlineUser {
+	room := h.room(sessionId)
+	if room == nil {
+		return []OnlineUser{}
+	}
+
+	reply := make(chan []OnlineUser, 1)
+	select {
+	case room.online <- reply:
+	case <-room.done:
+		return []OnlineUser{}
+	}
+
+	select {
+	case users := <-reply:
+		return users
+	case <-room.done:
+		return []OnlineUser{}
 	}
 }
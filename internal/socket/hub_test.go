@@ -0,0 +1,136 @@
+package socket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMain(m *testing.M) {
+	// rooms log every client that comes and goes
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(tb.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE chat_message (session_id TEXT NOT NULL, seq INTEGER NOT NULL, kind TEXT NOT NULL, from_user TEXT NOT NULL, to_user TEXT NOT NULL DEFAULT '', target TEXT NOT NULL DEFAULT '', content TEXT NOT NULL, created_at DATETIME NOT NULL, PRIMARY KEY (session_id, seq));`)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+// newTestClient joins a client without a websocket to the hub, what the room sends it is left on its send channel
+func newTestClient(tb testing.TB, hub *Hub, sessionId string, userId string, buffer int) *Client {
+	tb.Helper()
+
+	client := &Client{hub: hub, send: make(chan []byte, buffer), SessionId: sessionId, UserId: userId, Username: userId, after: -1}
+	if err := hub.join(client); err != nil {
+		tb.Fatal(err)
+	}
+
+	return client
+}
+
+// expectMessage reads what the room sent the client until a message of the content type that
+// matches turns up, failing the test if none does in time
+func expectMessage(t *testing.T, client *Client, contentType ContentType, match func(map[string]any) bool) map[string]any {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data, ok := <-client.send:
+			if !ok {
+				t.Fatalf("client %s was dropped waiting for %s", client.UserId, contentType)
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg["contentType"] == string(contentType) && (match == nil || match(msg)) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("client %s got no %s message", client.UserId, contentType)
+		}
+	}
+}
+
+// BenchmarkHubPublish measures how many messages a second reach clients when hundreds of
+// sessions are busy at once. Each op publishes one message to one session.
+func BenchmarkHubPublish(b *testing.B) {
+	const clientsPerSession = 4
+	// deep enough that the goroutines reading the clients never fall behind, the hub is what is measured
+	const buffer = 1 << 16
+
+	for _, sessions := range []int{100, 500} {
+		b.Run(fmt.Sprintf("sessions=%d", sessions), func(b *testing.B) {
+			hub := NewHub(newTestDB(b), NewLocalBus())
+
+			marker := rawMessage(`{"contentType":"Bench"}`)
+			var delivered atomic.Int64
+			done := make(chan struct{})
+			// a client is dropped when it falls too far behind, the benchmark can not finish then
+			dropped := make(chan struct{}, sessions*clientsPerSession)
+			target := int64(b.N * clientsPerSession)
+
+			clients := []*Client{}
+			for s := 0; s < sessions; s++ {
+				for u := 0; u < clientsPerSession; u++ {
+					client := newTestClient(b, hub, fmt.Sprintf("session-%d", s), fmt.Sprintf("user-%d", u), buffer)
+					clients = append(clients, client)
+					go func() {
+						for data := range client.send {
+							if string(data) == string(marker) && delivered.Add(1) == target {
+								close(done)
+							}
+						}
+						dropped <- struct{}{}
+					}()
+				}
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if err := hub.Publish(fmt.Sprintf("session-%d", i%sessions), marker); err != nil {
+						b.Error(err)
+					}
+					i++
+				}
+			})
+			select {
+			case <-done:
+			case <-dropped:
+				b.Fatal("a client fell behind and was dropped")
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(target)/b.Elapsed().Seconds(), "deliveries/s")
+
+			for _, client := range clients {
+				hub.leave(client)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"time"
+	"visualsource/traveller/internal/model"
 )
 
 type ContentType string
@@ -11,6 +13,21 @@ type ContentType string
 const (
 	ContentType_BroadcastMessage ContentType = "BoradcastMessage"
 	ContentType_PrivateMessage   ContentType = "PrivateMessage"
+	ContentType_ChatHistory      ContentType = "ChatHistory"
+	ContentType_Presence         ContentType = "Presence"
+	ContentType_OnlineUsers      ContentType = "OnlineUsers"
+	ContentType_Finance          ContentType = "Finance"
+	ContentType_Calendar         ContentType = "Calendar"
+	ContentType_Contracts        ContentType = "Contracts"
+	ContentType_Combat           ContentType = "Combat"
+	ContentType_SpaceCombat      ContentType = "SpaceCombat"
+	ContentType_NPCs             ContentType = "NPCs"
+	ContentType_Generated        ContentType = "Generated"
+	ContentType_Handout          ContentType = "Handout"
+	ContentType_Map              ContentType = "Map"
+	ContentType_Event            ContentType = "Event"
+	ContentType_EventAck         ContentType = "EventAck"
+	ContentType_Journal          ContentType = "Journal"
 )
 
 type Message interface {
@@ -43,6 +60,8 @@ type BroadcastMessge struct {
 	Content     string      `json:"content"`
 	FromUser    string      `json:"fromUser"`
 	Target      string      `json:"target"`
+	Seq         int64       `json:"seq"`
+	Timestamp   time.Time   `json:"timestamp"`
 }
 
 func (b *BroadcastMessge) ToJson() ([]byte, error) {
@@ -59,6 +78,8 @@ type PrivateMessage struct {
 	SessionId   string      `json:"sessionId"`
 	ToUser      string      `json:"toUser"`
 	FromUser    string      `json:"fromUser"`
+	Seq         int64       `json:"seq"`
+	Timestamp   time.Time   `json:"timestamp"`
 }
 
 func (b *PrivateMessage) ToJson() ([]byte, error) {
@@ -68,3 +89,253 @@ func (b *PrivateMessage) ToJson() ([]byte, error) {
 func NewPrivateMessage(fromUser string, toUser string, session string, msg string) PrivateMessage {
 	return PrivateMessage{ContentType: ContentType_PrivateMessage, Message: msg, FromUser: fromUser, ToUser: toUser, SessionId: session}
 }
+
+type ChatHistoryMessage struct {
+	ContentType ContentType `json:"contentType"`
+	SessionId   string      `json:"sessionId"`
+	Messages    []Message   `json:"messages"`
+}
+
+func (b *ChatHistoryMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+// NewChatHistoryMessage converts stored chat messages back into the messages that
+// were originally delivered so the client can render them the same way.
+func NewChatHistoryMessage(session string, history []model.ChatMessage) ChatHistoryMessage {
+	msgs := make([]Message, 0, len(history))
+	for _, item := range history {
+		switch item.Kind {
+		case model.ChatKind_Private:
+			msg := NewPrivateMessage(item.FromUser, item.ToUser, item.SessionId, item.Content)
+			msg.Seq = item.Seq
+			msg.Timestamp = item.CreatedAt
+			msgs = append(msgs, &msg)
+		default:
+			msg := NewBroadcastMessage(item.SessionId, item.Content, item.Target, item.FromUser)
+			msg.Seq = item.Seq
+			msg.Timestamp = item.CreatedAt
+			msgs = append(msgs, &msg)
+		}
+	}
+	return ChatHistoryMessage{ContentType: ContentType_ChatHistory, SessionId: session, Messages: msgs}
+}
+
+type PresenceStatus string
+
+const (
+	PresenceStatus_Online  PresenceStatus = "online"
+	PresenceStatus_Offline PresenceStatus = "offline"
+)
+
+type PresenceMessage struct {
+	ContentType ContentType    `json:"contentType"`
+	SessionId   string         `json:"sessionId"`
+	UserId      string         `json:"userId"`
+	Username    string         `json:"username"`
+	Status      PresenceStatus `json:"status"`
+}
+
+func (b *PresenceMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewPresenceMessage(session string, user string, username string, status PresenceStatus) PresenceMessage {
+	return PresenceMessage{ContentType: ContentType_Presence, SessionId: session, UserId: user, Username: username, Status: status}
+}
+
+type OnlineUsersMessage struct {
+	ContentType ContentType  `json:"contentType"`
+	SessionId   string       `json:"sessionId"`
+	Users       []OnlineUser `json:"users"`
+}
+
+func (b *OnlineUsersMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewOnlineUsersMessage(session string, users []OnlineUser) OnlineUsersMessage {
+	return OnlineUsersMessage{ContentType: ContentType_OnlineUsers, SessionId: session, Users: users}
+}
+
+// FinanceMessage tells clients the ledger of the session changed
+type FinanceMessage struct {
+	ContentType  ContentType         `json:"contentType"`
+	SessionId    string              `json:"sessionId"`
+	Month        int                 `json:"month"`
+	Transactions []model.Transaction `json:"transactions"`
+}
+
+func (b *FinanceMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewFinanceMessage(session string, month int, transactions []model.Transaction) FinanceMessage {
+	return FinanceMessage{ContentType: ContentType_Finance, SessionId: session, Month: month, Transactions: transactions}
+}
+
+// CalendarMessage tells clients the date of the session changed and which scheduled events fired
+type CalendarMessage struct {
+	ContentType ContentType            `json:"contentType"`
+	SessionId   string                 `json:"sessionId"`
+	Date        model.ImperialDate     `json:"date"`
+	Events      []model.ScheduledEvent `json:"events"`
+}
+
+func (b *CalendarMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewCalendarMessage(session string, date model.ImperialDate, events []model.ScheduledEvent) CalendarMessage {
+	return CalendarMessage{ContentType: ContentType_Calendar, SessionId: session, Date: date, Events: events}
+}
+
+// ContractsMessage tells clients contracts were published or changed hands
+type ContractsMessage struct {
+	ContentType ContentType      `json:"contentType"`
+	SessionId   string           `json:"sessionId"`
+	Contracts   []model.Contract `json:"contracts"`
+}
+
+func (b *ContractsMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewContractsMessage(session string, contracts []model.Contract) ContractsMessage {
+	return ContractsMessage{ContentType: ContentType_Contracts, SessionId: session, Contracts: contracts}
+}
+
+// CombatMessage carries the state of an encounter after every change so clients can redraw the tracker
+type CombatMessage struct {
+	ContentType ContentType     `json:"contentType"`
+	SessionId   string          `json:"sessionId"`
+	Encounter   model.Encounter `json:"encounter"`
+}
+
+func (b *CombatMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewCombatMessage(session string, encounter model.Encounter) CombatMessage {
+	return CombatMessage{ContentType: ContentType_Combat, SessionId: session, Encounter: encounter}
+}
+
+// SpaceCombatMessage carries the state of a space encounter after every change
+type SpaceCombatMessage struct {
+	ContentType ContentType          `json:"contentType"`
+	SessionId   string               `json:"sessionId"`
+	Encounter   model.SpaceEncounter `json:"encounter"`
+}
+
+func (b *SpaceCombatMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewSpaceCombatMessage(session string, encounter model.SpaceEncounter) SpaceCombatMessage {
+	return SpaceCombatMessage{ContentType: ContentType_SpaceCombat, SessionId: session, Encounter: encounter}
+}
+
+// NPCsMessage tells the players the NPCs they know about changed
+type NPCsMessage struct {
+	ContentType ContentType `json:"contentType"`
+	SessionId   string      `json:"sessionId"`
+}
+
+func (b *NPCsMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewNPCsMessage(session string) NPCsMessage {
+	return NPCsMessage{ContentType: ContentType_NPCs, SessionId: session}
+}
+
+// GeneratedMessage carries an encounter, mission or rumour the referee revealed
+type GeneratedMessage struct {
+	ContentType ContentType           `json:"contentType"`
+	SessionId   string                `json:"sessionId"`
+	Result      model.GeneratedResult `json:"result"`
+}
+
+func (b *GeneratedMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewGeneratedMessage(session string, result model.GeneratedResult) GeneratedMessage {
+	return GeneratedMessage{ContentType: ContentType_Generated, SessionId: session, Result: result}
+}
+
+// HandoutMessage hands a player a handout, or tells them to drop it when it is withdrawn
+type HandoutMessage struct {
+	ContentType ContentType   `json:"contentType"`
+	SessionId   string        `json:"sessionId"`
+	Handout     model.Handout `json:"handout"`
+	Withdrawn   bool          `json:"withdrawn"`
+}
+
+func (b *HandoutMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewHandoutMessage(session string, handout model.Handout, withdrawn bool) HandoutMessage {
+	return HandoutMessage{ContentType: ContentType_Handout, SessionId: session, Handout: handout, Withdrawn: withdrawn}
+}
+
+// MapMessage tells players what they know of the map changed, they load it again to see what
+type MapMessage struct {
+	ContentType ContentType `json:"contentType"`
+	SessionId   string      `json:"sessionId"`
+}
+
+func (b *MapMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewMapMessage(session string) MapMessage {
+	return MapMessage{ContentType: ContentType_Map, SessionId: session}
+}
+
+// EventMessage delivers an alert, sensor contact, ship damage report or news item to the players
+type EventMessage struct {
+	ContentType ContentType        `json:"contentType"`
+	SessionId   string             `json:"sessionId"`
+	Event       model.SessionEvent `json:"event"`
+}
+
+func (b *EventMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewEventMessage(session string, event model.SessionEvent) EventMessage {
+	return EventMessage{ContentType: ContentType_Event, SessionId: session, Event: event}
+}
+
+// EventAckMessage tells the referee a player has seen an event
+type EventAckMessage struct {
+	ContentType ContentType    `json:"contentType"`
+	SessionId   string         `json:"sessionId"`
+	Ack         model.EventAck `json:"ack"`
+}
+
+func (b *EventAckMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewEventAckMessage(session string, ack model.EventAck) EventAckMessage {
+	return EventAckMessage{ContentType: ContentType_EventAck, SessionId: session, Ack: ack}
+}
+
+// JournalMessage tells clients an entry was added to, changed in or deleted from the session journal
+type JournalMessage struct {
+	ContentType ContentType        `json:"contentType"`
+	SessionId   string             `json:"sessionId"`
+	Entry       model.JournalEntry `json:"entry"`
+	Deleted     bool               `json:"deleted"`
+}
+
+func (b *JournalMessage) ToJson() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func NewJournalMessage(session string, entry model.JournalEntry, deleted bool) JournalMessage {
+	return JournalMessage{ContentType: ContentType_Journal, SessionId: session, Entry: entry, Deleted: deleted}
+}
@@ -0,0 +1,122 @@
+package socket
+
+import (
+	"sort"
+	"time"
+)
+
+type OnlineUser struct {
+	UserId      string `json:"userId"`
+	Username    string `json:"username"`
+	Connections int    `json:"connections"`
+}
+
+func (r *Room) isOnline(userId string) bool {
+	return len(r.presence[userId]) > 0
+}
+
+// setPresence records the number of connections the user has on an instance
+// and tells the local clients when the user comes online or goes offline
+func (r *Room) setPresence(instance string, userId string, username string, connections int) {
+	wasOnline := r.isOnline(userId)
+
+	if connections > 0 {
+		instances, ok := r.presence[userId]
+		if !ok {
+			instances = make(map[string]int)
+			r.presence[userId] = instances
+		}
+		instances[instance] = connections
+		r.usernames[userId] = username
+	} else if instances, ok := r.presence[userId]; ok {
+		delete(instances, instance)
+		if len(instances) == 0 {
+			delete(r.presence, userId)
+		}
+	}
+
+	isOnline := r.isOnline(userId)
+	if wasOnline == isOnline {
+		return
+	}
+
+	status := PresenceStatus_Offline
+	if isOnline {
+		status = PresenceStatus_Online
+	}
+	msg := NewPresenceMessage(r.id, userId, username, status)
+	r.sendAll(&msg)
+
+	if !isOnline {
+		delete(r.usernames, userId)
+	}
+}
+
+func (r *Room) onlineUsers() []OnlineUser {
+	users := []OnlineUser{}
+	for userId, instances := range r.presence {
+		user := OnlineUser{UserId: userId, Username: r.usernames[userId]}
+		for _, connections := range instances {
+			user.Connections += connections
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+// sendRoster sends the list of online users of the session to the client
+func (r *Room) sendRoster(client *Client) {
+	msg := NewOnlineUsersMessage(r.id, r.onlineUsers())
+	r.sendClient(client, &msg)
+}
+
+// localRoster is every user connected to the session on this instance
+func (r *Room) localRoster() []OnlineUser {
+	users := []OnlineUser{}
+	for userId, conns := range r.clients {
+		users = append(users, OnlineUser{UserId: userId, Username: r.usernames[userId], Connections: len(conns)})
+	}
+	return users
+}
+
+// setRoster replaces everyone known to be connected to another instance, it heals any
+// presence change from that instance that was missed
+func (r *Room) setRoster(instance string, roster []OnlineUser) {
+	connected := make(map[string]bool, len(roster))
+	for _, user := range roster {
+		connected[user.UserId] = true
+		r.setPresence(instance, user.UserId, user.Username, user.Connections)
+	}
+
+	for _, userId := range r.instanceUsers(instance) {
+		if !connected[userId] {
+			r.setPresence(instance, userId, r.usernames[userId], 0)
+		}
+	}
+}
+
+// expirePresence takes the users of instances that stopped sending heartbeats offline,
+// so a crashed instance does not leave its users online forever
+func (r *Room) expirePresence() {
+	for instance, seen := range r.lastSeen {
+		if time.Since(seen) < r.hub.presenceTTL {
+			continue
+		}
+		delete(r.lastSeen, instance)
+		for _, userId := range r.instanceUsers(instance) {
+			r.setPresence(instance, userId, r.usernames[userId], 0)
+		}
+	}
+}
+
+// instanceUsers lists the users connected to an instance
+func (r *Room) instanceUsers(instance string) []string {
+	users := []string{}
+	for userId, instances := range r.presence {
+		if _, ok := instances[instance]; ok {
+			users = append(users, userId)
+		}
+	}
+	return users
+}
@@ -0,0 +1,84 @@
+package socket
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus is a Bus backed by redis pub/sub for running several instances behind a load balancer
+type RedisBus struct {
+	client *redis.Client
+}
+
+// NewRedisBus connects to the redis server at url, e.g. "redis://localhost:6379/0"
+func NewRedisBus(url string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisBus{client: client}, nil
+}
+
+func redisChannel(sessionId string) string {
+	return strings.Join([]string{"traveller", "session", sessionId}, ":")
+}
+
+func (b *RedisBus) Publish(sessionId string, data []byte) error {
+	return b.client.Publish(context.Background(), redisChannel(sessionId), data).Err()
+}
+
+func (b *RedisBus) Subscribe(sessionId string) (Subscription, error) {
+	ctx := context.Background()
+	pubsub := b.client.Subscribe(ctx, redisChannel(sessionId))
+
+	// wait for the subscription to be confirmed so nothing published after this returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &redisSubscription{pubsub: pubsub, out: make(chan []byte), done: make(chan struct{})}
+	go sub.pump()
+	return sub, nil
+}
+
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
+
+type redisSubscription struct {
+	pubsub    *redis.PubSub
+	out       chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *redisSubscription) pump() {
+	defer close(s.out)
+	for msg := range s.pubsub.Channel() {
+		select {
+		case s.out <- []byte(msg.Payload):
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *redisSubscription) Messages() <-chan []byte {
+	return s.out
+}
+
+func (s *redisSubscription) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
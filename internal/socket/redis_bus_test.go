@@ -0,0 +1,251 @@
+package socket
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standInRedis speaks just enough of the redis protocol for RedisBus, PING, PUBLISH,
+// SUBSCRIBE and UNSUBSCRIBE, so the bus can be tested without a real server
+type standInRedis struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	subs map[string]map[*standInConn]bool
+}
+
+type standInConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+	// channels this connection is subscribed to
+	channels map[string]bool
+}
+
+func (c *standInConn) write(reply string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.WriteString(c.conn, reply)
+}
+
+func bulk(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+func newStandInRedis(t *testing.T) *standInRedis {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &standInRedis{ln: ln, subs: make(map[string]map[*standInConn]bool)}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(&standInConn{conn: conn, channels: make(map[string]bool)})
+		}
+	}()
+
+	return server
+}
+
+func (s *standInRedis) URL() string {
+	return "redis://" + s.ln.Addr().String() + "/0"
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("expected an array, got %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("expected a bulk string, got %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func (s *standInRedis) serve(c *standInConn) {
+	defer func() {
+		s.mu.Lock()
+		for channel := range c.channels {
+			delete(s.subs[channel], c)
+		}
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			if len(c.channels) > 0 {
+				c.write("*2\r\n" + bulk("pong") + bulk(""))
+			} else {
+				c.write("+PONG\r\n")
+			}
+		case "CLIENT":
+			c.write("+OK\r\n")
+		case "PUBLISH":
+			s.mu.Lock()
+			receivers := []*standInConn{}
+			for sub := range s.subs[args[1]] {
+				receivers = append(receivers, sub)
+			}
+			s.mu.Unlock()
+			for _, sub := range receivers {
+				sub.write("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+			}
+			c.write(":" + strconv.Itoa(len(receivers)) + "\r\n")
+		case "SUBSCRIBE":
+			for _, channel := range args[1:] {
+				s.mu.Lock()
+				if s.subs[channel] == nil {
+					s.subs[channel] = make(map[*standInConn]bool)
+				}
+				s.subs[channel][c] = true
+				c.channels[channel] = true
+				s.mu.Unlock()
+				c.write("*3\r\n" + bulk("subscribe") + bulk(channel) + ":" + strconv.Itoa(len(c.channels)) + "\r\n")
+			}
+		case "UNSUBSCRIBE":
+			channels := args[1:]
+			if len(channels) == 0 {
+				for channel := range c.channels {
+					channels = append(channels, channel)
+				}
+			}
+			for _, channel := range channels {
+				s.mu.Lock()
+				delete(s.subs[channel], c)
+				delete(c.channels, channel)
+				s.mu.Unlock()
+				c.write("*3\r\n" + bulk("unsubscribe") + bulk(channel) + ":" + strconv.Itoa(len(c.channels)) + "\r\n")
+			}
+		case "QUIT":
+			c.write("+OK\r\n")
+			return
+		default:
+			// HELLO lands here, the client falls back to RESP2
+			c.write("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+	}
+}
+
+func newTestRedisBus(t *testing.T, server *standInRedis) *RedisBus {
+	t.Helper()
+
+	bus, err := NewRedisBus(server.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bus.Close() })
+
+	return bus
+}
+
+func TestRedisBusDeliversBetweenInstances(t *testing.T) {
+	server := newStandInRedis(t)
+	first, second := newTestRedisBus(t, server), newTestRedisBus(t, server)
+
+	sub, err := first.Subscribe("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	other, err := first.Subscribe("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if err := second.Publish("session", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-sub.Messages():
+		if string(data) != "hello" {
+			t.Fatalf("got %q, want hello", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	select {
+	case data := <-other.Messages():
+		t.Fatalf("other session got %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHubsShareSessionOverRedis(t *testing.T) {
+	server := newStandInRedis(t)
+	// every instance has to use the same database for the chat history and sequence numbers
+	db := newTestDB(t)
+	first := NewHub(db, newTestRedisBus(t, server))
+	second := NewHub(db, newTestRedisBus(t, server))
+
+	alice := newTestClient(t, first, "session", "alice", 256)
+	defer first.leave(alice)
+	bob := newTestClient(t, second, "session", "bob", 256)
+	defer second.leave(bob)
+
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "bob" && msg["status"] == string(PresenceStatus_Online)
+	})
+
+	alice.room.broadcast <- NewBroadcastMessage("session", "jump in five", "", "alice")
+	msg := expectMessage(t, bob, ContentType_BroadcastMessage, nil)
+	if msg["content"] != "jump in five" || msg["seq"] != float64(1) {
+		t.Fatalf("bob got %v", msg)
+	}
+
+	msg = expectMessage(t, alice, ContentType_BroadcastMessage, nil)
+	if msg["seq"] != float64(1) {
+		t.Fatalf("alice got %v", msg)
+	}
+
+	notice := NewPresenceMessage("session", "referee", "referee", PresenceStatus_Online)
+	if err := second.PublishUser("session", "alice", &notice); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool { return msg["userId"] == "referee" })
+}
@@ -0,0 +1,349 @@
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"visualsource/traveller/internal/model"
+)
+
+// Number of chat messages sent to a client that connects without a known sequence number
+const historyLimit = 50
+
+// Size of the inbound queues of a room so a burst from one client does not block the others
+const roomQueueSize = 64
+
+// Bounds of the wait before subscribing to the bus again after the subscription closed
+const (
+	minResubscribeDelay = 250 * time.Millisecond
+	maxResubscribeDelay = 30 * time.Second
+)
+
+type historyRequest struct {
+	client *Client
+	after  int64
+}
+
+// Room delivers the messages of a single session. Each room runs in its own
+// goroutine so a busy session never stalls the others. Everything sent to the
+// session goes through the bus so clients on other instances receive it too.
+type Room struct {
+	hub *Hub
+	id  string
+	sub Subscription
+	// number of clients that joined and have not left, guarded by hub.mu
+	refs int
+	// connections on this instance keyed by user id, a user may have several tabs open
+	clients map[string]map[*Client]bool
+	// connection count of each user on every instance, keyed by user id then instance id
+	presence map[string]map[string]int
+	// when each other instance was last heard from, their users go offline once it is too long ago
+	lastSeen   map[string]time.Time
+	usernames  map[string]string
+	message    chan PrivateMessage
+	broadcast  chan BroadcastMessge
+	history    chan historyRequest
+	online     chan chan []OnlineUser
+	roster     chan *Client
+	register   chan *Client
+	unregister chan *Client
+	done       chan struct{}
+}
+
+func newRoom(hub *Hub, id string) (*Room, error) {
+	sub, err := hub.bus.Subscribe(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Room{
+		hub:        hub,
+		id:         id,
+		sub:        sub,
+		clients:    make(map[string]map[*Client]bool),
+		presence:   make(map[string]map[string]int),
+		lastSeen:   make(map[string]time.Time),
+		usernames:  make(map[string]string),
+		message:    make(chan PrivateMessage, roomQueueSize),
+		broadcast:  make(chan BroadcastMessge, roomQueueSize),
+		history:    make(chan historyRequest, roomQueueSize),
+		online:     make(chan chan []OnlineUser),
+		roster:     make(chan *Client, roomQueueSize),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+func (r *Room) run() {
+	// the subscription is replaced when it has to be renewed
+	defer func() { r.sub.Close() }()
+
+	heartbeat := time.NewTicker(r.hub.heartbeat)
+	defer heartbeat.Stop()
+
+	// ask the other instances who they have connected
+	r.publish(envelope{Kind: envelope_Sync})
+
+	msgs := r.sub.Messages()
+	// set while waiting to subscribe again
+	var resubscribe <-chan time.Time
+	delay := minResubscribeDelay
+	for {
+		select {
+		case client := <-r.register:
+			log.Printf("Registering %s\n", client.GetId())
+			r.addClient(client)
+			r.sendHistory(client, client.after)
+			r.sendRoster(client)
+		case client := <-r.unregister:
+			log.Printf("Unregistering client: %s\n", client.GetId())
+			r.removeClient(client)
+		case reply := <-r.online:
+			reply <- r.onlineUsers()
+		case client := <-r.roster:
+			if r.isRegistered(client) {
+				r.sendRoster(client)
+			}
+		case req := <-r.history:
+			if r.isRegistered(req.client) {
+				r.sendHistory(req.client, req.after)
+			}
+		case msg := <-r.message:
+			r.deliverPrivate(msg)
+		case msg := <-r.broadcast:
+			r.deliverBroadcast(msg)
+		case data, ok := <-msgs:
+			if !ok {
+				log.Printf("Bus subscription of session %s closed, subscribing again\n", r.id)
+				msgs = nil
+				resubscribe = time.After(0)
+				continue
+			}
+			r.receive(data)
+		case <-resubscribe:
+			sub, err := r.hub.bus.Subscribe(r.id)
+			if err != nil {
+				log.Println(err)
+				delay = min(delay*2, maxResubscribeDelay)
+				resubscribe = time.After(delay)
+				continue
+			}
+			r.sub.Close()
+			r.sub = sub
+			msgs = sub.Messages()
+			resubscribe = nil
+			delay = minResubscribeDelay
+			// presence changes published while unsubscribed were missed
+			r.publish(envelope{Kind: envelope_Sync})
+		case <-heartbeat.C:
+			r.publish(envelope{Kind: envelope_Heartbeat, Roster: r.localRoster()})
+			r.expirePresence()
+		case <-r.done:
+			r.drain()
+			return
+		}
+	}
+}
+
+// drain stores the chat messages still queued when the room stops so they show up in the history
+func (r *Room) drain() {
+	for {
+		select {
+		case msg := <-r.message:
+			r.deliverPrivate(msg)
+		case msg := <-r.broadcast:
+			r.deliverBroadcast(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (r *Room) publish(env envelope) {
+	env.Origin = r.hub.instance
+	if err := r.hub.publish(r.id, env); err != nil {
+		log.Println(err)
+	}
+}
+
+// receive handles a message published to the session by any instance
+func (r *Room) receive(data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		log.Printf("JSON unmarshal error: %v\n", err)
+		return
+	}
+
+	if env.Origin != r.hub.instance {
+		r.lastSeen[env.Origin] = time.Now()
+	}
+
+	switch env.Kind {
+	case envelope_All:
+		r.sendAll(rawMessage(env.Data))
+	case envelope_User:
+		r.sendUser(env.UserId, rawMessage(env.Data))
+	case envelope_Presence:
+		// changes on this instance are applied as they happen
+		if env.Origin == r.hub.instance {
+			return
+		}
+		r.setPresence(env.Origin, env.UserId, env.Username, env.Connections)
+	case envelope_Sync:
+		if env.Origin == r.hub.instance {
+			return
+		}
+		for userId, conns := range r.clients {
+			r.publish(envelope{Kind: envelope_Presence, UserId: userId, Username: r.usernames[userId], Connections: len(conns)})
+		}
+	case envelope_Heartbeat:
+		if env.Origin == r.hub.instance {
+			return
+		}
+		r.setRoster(env.Origin, env.Roster)
+	}
+}
+
+func (r *Room) deliverPrivate(msg PrivateMessage) {
+	record := model.ChatMessage{Kind: model.ChatKind_Private, SessionId: msg.SessionId, FromUser: msg.FromUser, ToUser: msg.ToUser, Content: msg.Message}
+	if err := record.Insert(r.hub.db); err != nil {
+		log.Println(err)
+		return
+	}
+	msg.Seq = record.Seq
+	msg.Timestamp = record.CreatedAt
+
+	data, err := msg.ToJson()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	r.publish(envelope{Kind: envelope_User, UserId: msg.ToUser, Data: data})
+}
+
+func (r *Room) deliverBroadcast(msg BroadcastMessge) {
+	record := model.ChatMessage{Kind: model.ChatKind_Broadcast, SessionId: msg.SessionId, FromUser: msg.FromUser, Target: msg.Target, Content: msg.Content}
+	if err := record.Insert(r.hub.db); err != nil {
+		log.Println(err)
+		return
+	}
+	msg.Seq = record.Seq
+	msg.Timestamp = record.CreatedAt
+
+	data, err := msg.ToJson()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	r.publish(envelope{Kind: envelope_All, Data: data})
+}
+
+// addClient registers a connection and tells every instance the users new connection count
+func (r *Room) addClient(client *Client) {
+	conns, ok := r.clients[client.UserId]
+	if !ok {
+		conns = make(map[*Client]bool)
+		r.clients[client.UserId] = conns
+	}
+	conns[client] = true
+
+	r.setPresence(r.hub.instance, client.UserId, client.Username, len(conns))
+	r.publish(envelope{Kind: envelope_Presence, UserId: client.UserId, Username: client.Username, Connections: len(conns)})
+}
+
+// removeClient closes a registered connection and tells every instance the users new connection count.
+// Removing a connection that is not registered does nothing.
+func (r *Room) removeClient(client *Client) {
+	conns, ok := r.clients[client.UserId]
+	if !ok || !conns[client] {
+		return
+	}
+
+	delete(conns, client)
+	close(client.send)
+	if len(conns) == 0 {
+		delete(r.clients, client.UserId)
+	}
+
+	r.setPresence(r.hub.instance, client.UserId, client.Username, len(conns))
+	r.publish(envelope{Kind: envelope_Presence, UserId: client.UserId, Username: client.Username, Connections: len(conns)})
+}
+
+func (r *Room) isRegistered(client *Client) bool {
+	return r.clients[client.UserId][client]
+}
+
+// sendHistory queues the chat history of the session as a single message.
+// A negative after sends the most recent messages, otherwise every message after that sequence number.
+func (r *Room) sendHistory(client *Client, after int64) {
+	items, err := model.GetChatHistory(r.hub.db, r.id, client.UserId, after, historyLimit)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	msg := NewChatHistoryMessage(r.id, items)
+	r.sendClient(client, &msg)
+}
+
+// sendAll delivers a message to every connection of this room
+func (r *Room) sendAll(msg Message) {
+	data, err := msg.ToJson()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var dropped []*Client
+	for _, conns := range r.clients {
+		for client := range conns {
+			select {
+			case client.send <- data:
+			default:
+				dropped = append(dropped, client)
+			}
+		}
+	}
+
+	for _, client := range dropped {
+		r.removeClient(client)
+	}
+}
+
+// sendUser delivers a message to every connection the user has open on this instance
+func (r *Room) sendUser(userId string, msg Message) {
+	data, err := msg.ToJson()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var dropped []*Client
+	for client := range r.clients[userId] {
+		select {
+		case client.send <- data:
+		default:
+			dropped = append(dropped, client)
+		}
+	}
+
+	for _, client := range dropped {
+		r.removeClient(client)
+	}
+}
+
+// sendClient delivers a message to a single connection
+func (r *Room) sendClient(client *Client, msg Message) {
+	data, err := msg.ToJson()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+		r.removeClient(client)
+	}
+}
@@ -0,0 +1,340 @@
+package socket
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closingBus is a LocalBus whose subscriptions the test can close, as happens when a networked bus fails
+type closingBus struct {
+	*LocalBus
+	mu   sync.Mutex
+	subs []*closingSubscription
+}
+
+type closingSubscription struct {
+	Subscription
+	out  chan []byte
+	once sync.Once
+	stop chan struct{}
+}
+
+func (b *closingBus) Subscribe(sessionId string) (Subscription, error) {
+	inner, err := b.LocalBus.Subscribe(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &closingSubscription{Subscription: inner, out: make(chan []byte), stop: make(chan struct{})}
+	go func() {
+		defer close(sub.out)
+		for {
+			select {
+			case data, ok := <-inner.Messages():
+				if !ok {
+					return
+				}
+				select {
+				case sub.out <- data:
+				case <-sub.stop:
+					return
+				}
+			case <-sub.stop:
+				return
+			}
+		}
+	}()
+
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return sub, nil
+}
+
+// drop ends every subscription made so far
+func (b *closingBus) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subs {
+		sub.once.Do(func() { close(sub.stop) })
+	}
+	b.subs = nil
+}
+
+func (b *closingBus) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func (s *closingSubscription) Messages() <-chan []byte {
+	return s.out
+}
+
+func (s *closingSubscription) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return s.Subscription.Close()
+}
+
+func publishEnvelope(t *testing.T, bus Bus, sessionId string, env envelope) {
+	t.Helper()
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(sessionId, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func online(hub *Hub, sessionId string, userId string) bool {
+	for _, user := range hub.Online(sessionId) {
+		if user.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPresenceOfSilentInstanceExpires(t *testing.T) {
+	bus := NewLocalBus()
+	hub := NewHub(newTestDB(t), bus)
+	hub.heartbeat = 20 * time.Millisecond
+	hub.presenceTTL = 100 * time.Millisecond
+
+	alice := newTestClient(t, hub, "session", "alice", 256)
+	defer hub.leave(alice)
+
+	// another instance reports a user then crashes without taking them offline
+	publishEnvelope(t, bus, "session", envelope{Kind: envelope_Heartbeat, Origin: "crashed", Roster: []OnlineUser{{UserId: "bob", Username: "bob", Connections: 1}}})
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "bob" && msg["status"] == string(PresenceStatus_Online)
+	})
+	if !online(hub, "session", "bob") {
+		t.Fatal("bob should be online")
+	}
+
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "bob" && msg["status"] == string(PresenceStatus_Offline)
+	})
+	if online(hub, "session", "bob") {
+		t.Fatal("bob should have gone offline with the instance")
+	}
+	if !online(hub, "session", "alice") {
+		t.Fatal("users of this instance never expire")
+	}
+}
+
+func TestHeartbeatReplacesMissedPresence(t *testing.T) {
+	bus := NewLocalBus()
+	hub := NewHub(newTestDB(t), bus)
+
+	alice := newTestClient(t, hub, "session", "alice", 256)
+	defer hub.leave(alice)
+
+	publishEnvelope(t, bus, "session", envelope{Kind: envelope_Presence, Origin: "other", UserId: "bob", Username: "bob", Connections: 1})
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool { return msg["userId"] == "bob" })
+
+	// bob leaving was missed, the next heartbeat no longer lists him
+	publishEnvelope(t, bus, "session", envelope{Kind: envelope_Heartbeat, Origin: "other", Roster: []OnlineUser{{UserId: "carol", Username: "carol", Connections: 2}}})
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "bob" && msg["status"] == string(PresenceStatus_Offline)
+	})
+
+	users := hub.Online("session")
+	if len(users) != 2 || users[0].UserId != "alice" || users[1].UserId != "carol" || users[1].Connections != 2 {
+		t.Fatalf("online users are %v", users)
+	}
+}
+
+func TestRoomSubscribesAgainWhenSubscriptionCloses(t *testing.T) {
+	bus := &closingBus{LocalBus: NewLocalBus()}
+	hub := NewHub(newTestDB(t), bus)
+
+	alice := newTestClient(t, hub, "session", "alice", 256)
+	defer hub.leave(alice)
+
+	bus.drop()
+
+	// the room subscribes again and carries on delivering
+	deadline := time.Now().Add(2 * time.Second)
+	for bus.count() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("room did not subscribe again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	notice := NewPresenceMessage("session", "referee", "referee", PresenceStatus_Online)
+	if err := hub.Publish("session", &notice); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, alice, ContentType_Presence, func(msg map[string]any) bool { return msg["userId"] == "referee" })
+}
+
+// historySeqs is the sequence numbers of the messages of a chat history
+func historySeqs(msg map[string]any) []int64 {
+	seqs := []int64{}
+	for _, item := range msg["messages"].([]any) {
+		seqs = append(seqs, int64(item.(map[string]any)["seq"].(float64)))
+	}
+	return seqs
+}
+
+func TestReconnectReplaysMessagesAfterSeq(t *testing.T) {
+	hub := NewHub(newTestDB(t), NewLocalBus())
+
+	alice := newTestClient(t, hub, "session", "alice", 256)
+	defer hub.leave(alice)
+	if seqs := historySeqs(expectMessage(t, alice, ContentType_ChatHistory, nil)); len(seqs) != 0 {
+		t.Fatalf("a new session has the history %v", seqs)
+	}
+	bob := newTestClient(t, hub, "other", "bob", 256)
+	defer hub.leave(bob)
+
+	room := hub.room("session")
+	broadcast := func(content string) int64 {
+		t.Helper()
+		room.broadcast <- NewBroadcastMessage("session", content, "", "alice")
+		msg := expectMessage(t, alice, ContentType_BroadcastMessage, func(msg map[string]any) bool { return msg["content"] == content })
+		return int64(msg["seq"].(float64))
+	}
+
+	seqs := []int64{broadcast("one"), broadcast("two"), broadcast("three")}
+	// a private message between two other players takes a seq alice never sees
+	dave := newTestClient(t, hub, "session", "dave", 256)
+	room.message <- NewPrivateMessage("carol", "dave", "session", "psst")
+	expectMessage(t, dave, ContentType_PrivateMessage, nil)
+	hub.leave(dave)
+	seqs = append(seqs, broadcast("four"))
+	if want := []int64{1, 2, 3, 5}; !slices.Equal(seqs, want) {
+		t.Fatalf("broadcasts got the seqs %v, want %v", seqs, want)
+	}
+
+	// every session counts from 1
+	hub.room("other").broadcast <- NewBroadcastMessage("other", "elsewhere", "", "bob")
+	if msg := expectMessage(t, bob, ContentType_BroadcastMessage, nil); msg["seq"].(float64) != 1 {
+		t.Fatalf("the first message of another session has seq %v", msg["seq"])
+	}
+
+	tests := []struct {
+		after int64
+		want  []int64
+	}{
+		{-1, []int64{1, 2, 3, 5}},
+		{0, []int64{1, 2, 3, 5}},
+		{2, []int64{3, 5}},
+		{3, []int64{5}},
+		{5, []int64{}},
+	}
+	for _, test := range tests {
+		tab := &Client{hub: hub, send: make(chan []byte, 256), SessionId: "session", UserId: "alice", Username: "alice", after: test.after}
+		if err := hub.join(tab); err != nil {
+			t.Fatal(err)
+		}
+		if got := historySeqs(expectMessage(t, tab, ContentType_ChatHistory, nil)); !slices.Equal(got, test.want) {
+			t.Errorf("reconnecting after %d replayed %v, want %v", test.after, got, test.want)
+		}
+
+		// asking again over the socket gives the same answer
+		room.history <- historyRequest{client: tab, after: max(test.after, 0)}
+		if got := historySeqs(expectMessage(t, tab, ContentType_ChatHistory, nil)); !slices.Equal(got, test.want) {
+			t.Errorf("asking for the messages after %d gave %v, want %v", test.after, got, test.want)
+		}
+		hub.leave(tab)
+	}
+}
+
+// expectNoPresence reads what the room sent the client up to the marker, failing if the
+// user changed status before it
+func expectNoPresence(t *testing.T, hub *Hub, client *Client, userId string) {
+	t.Helper()
+
+	marker := rawMessage(`{"contentType":"Marker"}`)
+	if err := hub.Publish(client.SessionId, marker); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-client.send:
+			if string(data) == string(marker) {
+				return
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg["contentType"] == string(ContentType_Presence) && msg["userId"] == userId {
+				t.Fatalf("%s went %s", userId, msg["status"])
+			}
+		case <-timeout:
+			t.Fatalf("client %s never got the marker", client.UserId)
+		}
+	}
+}
+
+func TestUserWithSeveralTabs(t *testing.T) {
+	hub := NewHub(newTestDB(t), NewLocalBus())
+
+	bob := newTestClient(t, hub, "session", "bob", 256)
+	defer hub.leave(bob)
+	first := newTestClient(t, hub, "session", "alice", 256)
+	expectMessage(t, bob, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "alice" && msg["status"] == string(PresenceStatus_Online)
+	})
+	second := newTestClient(t, hub, "session", "alice", 256)
+	// the second tab is not news to anyone
+	expectNoPresence(t, hub, bob, "alice")
+
+	users := hub.Online("session")
+	if len(users) != 2 || users[0].UserId != "alice" || users[0].Connections != 2 {
+		t.Fatalf("online users are %v", users)
+	}
+
+	// a broadcast reaches every tab, a private message every tab of the recipient
+	room := hub.room("session")
+	room.broadcast <- NewBroadcastMessage("session", "hello", "", "bob")
+	for _, client := range []*Client{first, second, bob} {
+		expectMessage(t, client, ContentType_BroadcastMessage, func(msg map[string]any) bool { return msg["content"] == "hello" })
+	}
+	room.message <- NewPrivateMessage("bob", "alice", "session", "psst")
+	for _, client := range []*Client{first, second} {
+		expectMessage(t, client, ContentType_PrivateMessage, func(msg map[string]any) bool { return msg["message"] == "psst" })
+	}
+	notice := NewPresenceMessage("session", "referee", "referee", PresenceStatus_Online)
+	if err := hub.PublishUser("session", "alice", &notice); err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range []*Client{first, second} {
+		expectMessage(t, client, ContentType_Presence, func(msg map[string]any) bool { return msg["userId"] == "referee" })
+	}
+	expectNoPresence(t, hub, bob, "referee")
+
+	// closing one tab leaves alice online
+	hub.leave(first)
+	// the closed tab gets nothing more, its channel is closed once what was queued is read
+	for range first.send {
+	}
+	expectNoPresence(t, hub, bob, "alice")
+	users = hub.Online("session")
+	if len(users) != 2 || users[0].UserId != "alice" || users[0].Connections != 1 {
+		t.Fatalf("online users after closing a tab are %v", users)
+	}
+
+	// the remaining tab still gets her messages
+	room.message <- NewPrivateMessage("bob", "alice", "session", "still there?")
+	expectMessage(t, second, ContentType_PrivateMessage, func(msg map[string]any) bool { return msg["message"] == "still there?" })
+
+	hub.leave(second)
+	expectMessage(t, bob, ContentType_Presence, func(msg map[string]any) bool {
+		return msg["userId"] == "alice" && msg["status"] == string(PresenceStatus_Offline)
+	})
+	if online(hub, "session", "alice") {
+		t.Fatal("alice is online with every tab closed")
+	}
+}
@@ -0,0 +1,30 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterSpaceCombatPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/space-combat")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListSpaceEncounters).Name = "space-encounters"
+	g.POST("", h.CreateSpaceEncounter).Name = "space-encounter-create"
+	g.GET("/:encounterId", h.GetSpaceEncounter).Name = "space-encounter"
+	g.DELETE("/:encounterId", h.DeleteSpaceEncounter).Name = "space-encounter-delete"
+	g.POST("/:encounterId/ships", h.AddSpaceShip).Name = "space-ship-add"
+	g.DELETE("/:encounterId/ships/:combatantId", h.RemoveSpaceShip).Name = "space-ship-remove"
+	g.POST("/:encounterId/range", h.SetSpaceRange).Name = "space-range"
+	g.POST("/:encounterId/start", h.StartSpaceEncounter).Name = "space-encounter-start"
+	g.POST("/:encounterId/next", h.NextSpaceRound).Name = "space-encounter-next"
+	g.POST("/:encounterId/end", h.EndSpaceEncounter).Name = "space-encounter-end"
+	g.POST("/:encounterId/manoeuvre", h.Manoeuvre).Name = "space-manoeuvre"
+	g.POST("/:encounterId/evade", h.Evade).Name = "space-evade"
+	g.POST("/:encounterId/lock", h.SensorLock).Name = "space-lock"
+	g.POST("/:encounterId/fire", h.FireWeapon).Name = "space-fire"
+	g.POST("/:encounterId/repair", h.RepairCritical).Name = "space-repair"
+	g.POST("/:encounterId/board", h.BoardShip).Name = "space-board"
+}
@@ -0,0 +1,19 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterTradePages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/trade")
+
+	g.Use(sessionRequired)
+
+	g.GET("/goods", h.ListTradeGoods).Name = "trade-goods"
+	g.GET("/worlds/:worldId/market", h.GetMarket).Name = "trade-market"
+	g.GET("/ships/:shipId/cargo", h.GetCargo).Name = "trade-cargo"
+	g.POST("/ships/:shipId/buy", h.BuyCargo).Name = "trade-buy"
+	g.POST("/ships/:shipId/sell", h.SellCargo).Name = "trade-sell"
+}
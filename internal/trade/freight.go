@@ -0,0 +1,165 @@
+package trade
+
+import (
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+// Traffic describes a run between two worlds that freight, mail and passengers are rolled for
+type Traffic struct {
+	Source      model.World
+	Destination model.World
+	Parsecs     int
+	// skills of the crew member looking for freight and for passengers
+	Broker  int
+	Steward int
+}
+
+// worldDM is how much traffic a world generates from its population, starport and travel zone
+func worldDM(world model.World) int {
+	dm := 0
+	switch population := world.UWP.Population; {
+	case population <= 1:
+		dm -= 4
+	case population >= 8:
+		dm += 3
+	case population >= 6:
+		dm += 1
+	}
+	switch world.UWP.Starport {
+	case "A":
+		dm += 2
+	case "B":
+		dm += 1
+	case "E":
+		dm -= 1
+	case "X", "Y":
+		dm -= 3
+	}
+	switch world.Zone {
+	case model.TravelZone_Amber:
+		dm += 1
+	case model.TravelZone_Red:
+		dm -= 4
+	}
+	return dm
+}
+
+// dm is shared by every roll of the run, longer jumps see less traffic
+func (tr Traffic) dm() int {
+	return worldDM(tr.Source) + worldDM(tr.Destination) - (tr.Parsecs - 1)
+}
+
+// trafficDice is the number of dice rolled for lots or passengers from a modified 2D roll
+func trafficDice(value int) int {
+	switch {
+	case value <= 1:
+		return 0
+	case value <= 3:
+		return 1
+	case value <= 6:
+		return 2
+	case value <= 10:
+		return 3
+	case value <= 13:
+		return 4
+	case value <= 15:
+		return 5
+	default:
+		return min(value-10, 10)
+	}
+}
+
+// passage is the rate for the number of parsecs travelled
+func (t *Tables) passage(parsecs int) Passage {
+	rate := t.Passage[0]
+	for _, item := range t.Passage {
+		if item.Parsecs <= parsecs {
+			rate = item
+		}
+	}
+	return rate
+}
+
+func (tr Traffic) contract(kind model.ContractKind, class string) model.Contract {
+	return model.Contract{
+		Kind:          kind,
+		Class:         class,
+		SourceId:      tr.Source.Id,
+		DestinationId: tr.Destination.Id,
+		Parsecs:       tr.Parsecs,
+	}
+}
+
+// Contracts rolls the freight lots, mail and passengers waiting on the source world for the destination
+func (t *Tables) Contracts(r *dice.Roller, tr Traffic) []model.Contract {
+	rate := t.passage(tr.Parsecs)
+	contracts := []model.Contract{}
+
+	freightDM := tr.dm() + tr.Broker
+	lots := []struct {
+		class      string
+		dm         int
+		multiplier int
+	}{
+		{model.ContractClass_Major, -4, 10},
+		{model.ContractClass_Minor, 0, 5},
+		{model.ContractClass_Incidental, 2, 1},
+	}
+	for _, lot := range lots {
+		count := r.D6(trafficDice(r.D6(2) + freightDM + lot.dm))
+		for i := 0; i < count; i++ {
+			contract := tr.contract(model.ContractKind_Freight, lot.class)
+			contract.Tons = r.D6(1) * lot.multiplier
+			contract.Payment = int64(contract.Tons) * rate.Freight
+			contracts = append(contracts, contract)
+		}
+	}
+
+	// mail goes to ships on busy routes, worlds below TL 6 rarely have any to send
+	mailDM := 0
+	switch {
+	case freightDM <= -10:
+		mailDM = -2
+	case freightDM <= -5:
+		mailDM = -1
+	case freightDM >= 10:
+		mailDM = 2
+	case freightDM >= 5:
+		mailDM = 1
+	}
+	if tr.Source.UWP.TechLevel <= 5 {
+		mailDM -= 4
+	}
+	if r.D6(2)+mailDM >= 12 && t.MailContainerTons > 0 {
+		containers := r.D6(1)
+		contract := tr.contract(model.ContractKind_Mail, "")
+		contract.Tons = containers * t.MailContainerTons
+		contract.Payment = int64(containers) * t.MailContainerPayment
+		contracts = append(contracts, contract)
+	}
+
+	passengerDM := tr.dm() + tr.Steward
+	classes := []struct {
+		class string
+		dm    int
+		fare  int64
+	}{
+		{model.ContractClass_High, -4, rate.High},
+		{model.ContractClass_Middle, 0, rate.Middle},
+		{model.ContractClass_Basic, 0, rate.Basic},
+		{model.ContractClass_Low, 1, rate.Low},
+	}
+	for _, class := range classes {
+		count := r.D6(trafficDice(r.D6(2) + passengerDM + class.dm))
+		if count == 0 {
+			continue
+		}
+		contract := tr.contract(model.ContractKind_Passenger, class.class)
+		contract.Passengers = count
+		contract.Payment = int64(count) * class.fare
+		contracts = append(contracts, contract)
+	}
+
+	return contracts
+}
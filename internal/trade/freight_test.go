@@ -0,0 +1,124 @@
+package trade
+
+import (
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/model"
+)
+
+func TestWorldDM(t *testing.T) {
+	tests := []struct {
+		uwp  string
+		zone model.TravelZone
+		want int
+	}{
+		{"C555555-9", model.TravelZone_Green, 0},
+		{"A555555-9", model.TravelZone_Green, 2},
+		{"B556555-9", model.TravelZone_Amber, 2},
+		{"A558855-9", model.TravelZone_Green, 5},
+		{"E555155-9", model.TravelZone_Green, -5},
+		{"X550000-0", model.TravelZone_Red, -11},
+	}
+
+	for _, test := range tests {
+		uwp, err := model.ParseUWP(test.uwp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := worldDM(model.World{UWP: uwp, Zone: test.zone}); got != test.want {
+			t.Errorf("worldDM(%s %s) = %d, want %d", test.uwp, test.zone, got, test.want)
+		}
+	}
+}
+
+func TestTrafficDice(t *testing.T) {
+	tests := map[int]int{-5: 0, 1: 0, 2: 1, 3: 1, 4: 2, 6: 2, 7: 3, 10: 3, 11: 4, 13: 4, 14: 5, 15: 5, 16: 6, 19: 9, 20: 10, 30: 10}
+	for value, want := range tests {
+		if got := trafficDice(value); got != want {
+			t.Errorf("trafficDice(%d) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestPassageRate(t *testing.T) {
+	tables := testTables(t)
+	for parsecs, want := range map[int]int{1: 1, 3: 3, 6: 6, 9: 6} {
+		if got := tables.passage(parsecs).Parsecs; got != want {
+			t.Errorf("passage(%d) is the rate for %d parsecs, want %d", parsecs, got, want)
+		}
+	}
+}
+
+func TestContracts(t *testing.T) {
+	tables := testTables(t)
+	uwp, err := model.ParseUWP("C555555-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the worlds add nothing so only the skills change the rolls
+	traffic := Traffic{
+		Source:      model.World{Id: "source", UWP: uwp, Zone: model.TravelZone_Green},
+		Destination: model.World{Id: "destination", UWP: uwp, Zone: model.TravelZone_Green},
+		Parsecs:     1,
+		Broker:      2,
+		Steward:     1,
+	}
+
+	r := scripted(t,
+		// major lots 6 +2 -4, 2D lots of 1D x10 tons
+		3, 3, 1, 1, 4, 5,
+		// minor lots 2 +2, 2D lots of 1D x5 tons
+		1, 1, 1, 1, 2, 6,
+		// incidental lots 2 +2 +2, 2D lots of 1D tons
+		1, 1, 1, 2, 1, 1, 1,
+		// mail on 12, 1D containers
+		6, 6, 2,
+		// high passengers 2 +1 -4, none
+		1, 1,
+		// middle passengers 8 +1, 3D of them
+		4, 4, 1, 2, 3,
+		// basic passengers 2 +1, 1D of them
+		1, 1, 4,
+		// low passengers 4 +1 +1, 2D of them
+		2, 2, 6, 6,
+	)
+
+	freight := func(class string, tons int) model.Contract {
+		return model.Contract{Kind: model.ContractKind_Freight, Class: class, SourceId: "source", DestinationId: "destination", Parsecs: 1, Tons: tons, Payment: int64(tons) * 1000}
+	}
+	passengers := func(class string, count int, fare int64) model.Contract {
+		return model.Contract{Kind: model.ContractKind_Passenger, Class: class, SourceId: "source", DestinationId: "destination", Parsecs: 1, Passengers: count, Payment: int64(count) * fare}
+	}
+	want := []model.Contract{
+		freight(model.ContractClass_Major, 40),
+		freight(model.ContractClass_Major, 50),
+		freight(model.ContractClass_Minor, 10),
+		freight(model.ContractClass_Minor, 30),
+		freight(model.ContractClass_Incidental, 1),
+		freight(model.ContractClass_Incidental, 1),
+		freight(model.ContractClass_Incidental, 1),
+		{Kind: model.ContractKind_Mail, SourceId: "source", DestinationId: "destination", Parsecs: 1, Tons: 10, Payment: 50000},
+		passengers(model.ContractClass_Middle, 6, 6500),
+		passengers(model.ContractClass_Basic, 4, 2000),
+		passengers(model.ContractClass_Low, 12, 700),
+	}
+
+	if got := tables.Contracts(r, traffic); !slices.Equal(got, want) {
+		t.Fatalf("Contracts = %+v, want %+v", got, want)
+	}
+}
+
+func TestContractsWithoutSkills(t *testing.T) {
+	tables := testTables(t)
+	uwp, err := model.ParseUWP("C555555-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	traffic := Traffic{Source: model.World{UWP: uwp}, Destination: model.World{UWP: uwp}, Parsecs: 4}
+
+	// every roll is 2 -3 for the extra parsecs, mail needs 12 and gets -4 below TL 6
+	r := scripted(t, 1, 1, 1, 1, 1, 1, 6, 6, 1, 1, 1, 1, 1, 1, 1, 1)
+	if got := tables.Contracts(r, traffic); len(got) != 0 {
+		t.Fatalf("Contracts = %+v, want none", got)
+	}
+}
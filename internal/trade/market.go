@@ -0,0 +1,108 @@
+package trade
+
+import (
+	"slices"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+// Codes are the trade codes of a world along with Amber or Red for its travel zone,
+// which is what the availability and DMs of the goods are keyed by.
+func Codes(world model.World) []string {
+	return ZoneCodes(world.UWP.TradeCodes(), world.Zone)
+}
+
+// ZoneCodes adds Amber or Red for the travel zone to the trade codes
+func ZoneCodes(tradeCodes []string, zone model.TravelZone) []string {
+	codes := slices.Clone(tradeCodes)
+	switch zone {
+	case model.TravelZone_Amber:
+		codes = append(codes, "Amber")
+	case model.TravelZone_Red:
+		codes = append(codes, "Red")
+	}
+	return codes
+}
+
+// Available reports whether the good is on sale on a world with the given codes
+func (g Good) Available(codes []string) bool {
+	for _, code := range g.Availability {
+		if code == Availability_All || slices.Contains(codes, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// dm is the highest DM of the list that applies to the world
+func dm(dms map[string]int, codes []string) int {
+	best, found := 0, false
+	for _, code := range codes {
+		if value, ok := dms[code]; ok && (!found || value > best) {
+			best, found = value, true
+		}
+	}
+	return best
+}
+
+// Market rolls what is on offer on a world this week. Every good gets a sale roll
+// so anything can be sold, only the goods available on the world get tons and a purchase roll.
+func (t *Tables) Market(r *dice.Roller, world model.World) []model.MarketGood {
+	codes := Codes(world)
+	goods := make([]model.MarketGood, 0, len(t.Goods))
+	for _, good := range t.Goods {
+		item := model.MarketGood{GoodId: good.Id, SaleRoll: r.D6(3)}
+		if good.Available(codes) {
+			item.Tons = max(good.tons.Roll(r), 1) * good.Multiplier
+			item.PurchaseRoll = r.D6(3)
+		}
+		goods = append(goods, item)
+	}
+	return goods
+}
+
+func (t *Tables) percent(values []int, roll int) int {
+	i := min(max(roll-t.PriceTable.MinRoll, 0), len(values)-1)
+	return values[i]
+}
+
+// PurchasePrice is the price per ton of buying a good, broker is the skill of the buyer
+func (t *Tables) PurchasePrice(good Good, codes []string, roll int, broker int) int64 {
+	modified := roll + broker + dm(good.PurchaseDMs, codes) - dm(good.SaleDMs, codes)
+	return good.BasePrice * int64(t.percent(t.PriceTable.Purchase, modified)) / 100
+}
+
+// SalePrice is the price per ton a good sells for, broker is the skill of the seller
+func (t *Tables) SalePrice(good Good, codes []string, roll int, broker int) int64 {
+	modified := roll + broker + dm(good.SaleDMs, codes) - dm(good.PurchaseDMs, codes)
+	return good.BasePrice * int64(t.percent(t.PriceTable.Sale, modified)) / 100
+}
+
+type Price struct {
+	GoodId    string `json:"goodId"`
+	Name      string `json:"name"`
+	BasePrice int64  `json:"basePrice"`
+	// Tons is how much is left to buy, zero when the good is not sold here
+	Tons          int   `json:"tons"`
+	PurchasePrice int64 `json:"purchasePrice"`
+	SalePrice     int64 `json:"salePrice"`
+}
+
+// Prices works out the price of every good in a market snapshot for a trader with the given Broker skill
+func (t *Tables) Prices(market *model.Market, world model.World, broker int) []Price {
+	codes := Codes(world)
+	prices := make([]Price, 0, len(market.Goods))
+	for _, item := range market.Goods {
+		good, ok := t.Good(item.GoodId)
+		if !ok {
+			continue
+		}
+		price := Price{GoodId: good.Id, Name: good.Name, BasePrice: good.BasePrice, Tons: item.Tons}
+		if item.PurchaseRoll > 0 {
+			price.PurchasePrice = t.PurchasePrice(good, codes, item.PurchaseRoll, broker)
+		}
+		price.SalePrice = t.SalePrice(good, codes, item.SaleRoll, broker)
+		prices = append(prices, price)
+	}
+	return prices
+}
@@ -0,0 +1,200 @@
+package trade
+
+import (
+	"slices"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+// scriptedSource hands out die faces in order so each roll is known, Intn gets one less than the face
+type scriptedSource struct {
+	t     *testing.T
+	faces []int
+}
+
+func (s *scriptedSource) Intn(n int) int {
+	s.t.Helper()
+	if len(s.faces) == 0 {
+		s.t.Fatal("ran out of scripted rolls")
+	}
+	face := s.faces[0]
+	s.faces = s.faces[1:]
+	if face < 1 || face > n {
+		s.t.Fatalf("scripted face %d is not on a d%d", face, n)
+	}
+	return face - 1
+}
+
+// scripted is a roller that rolls the faces given, the test fails when they are not all used
+func scripted(t *testing.T, faces ...int) *dice.Roller {
+	src := &scriptedSource{t: t, faces: faces}
+	t.Cleanup(func() {
+		if len(src.faces) > 0 {
+			t.Errorf("%d scripted rolls were not used", len(src.faces))
+		}
+	})
+	return dice.New(src)
+}
+
+func testTables(t *testing.T) *Tables {
+	t.Helper()
+	tables, err := Load("../../configs/trade_goods.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
+
+func testGood(t *testing.T, tables *Tables, id string) Good {
+	t.Helper()
+	good, ok := tables.Good(id)
+	if !ok {
+		t.Fatalf("no trade good %s", id)
+	}
+	return good
+}
+
+func TestPriceTables(t *testing.T) {
+	tables := testTables(t)
+	table := tables.PriceTable
+
+	// buying gets cheaper and selling dearer as the roll goes up
+	for i := 1; i < len(table.Purchase); i++ {
+		if table.Purchase[i] > table.Purchase[i-1] || table.Sale[i] < table.Sale[i-1] {
+			t.Fatalf("price table is out of order at roll %d", table.MinRoll+i)
+		}
+	}
+	// an average roll of 3D with no DMs is about the base price
+	if table.Purchase[10-table.MinRoll] != 90 || table.Sale[10-table.MinRoll] != 90 {
+		t.Fatalf("a roll of 10 is %d%% to buy and %d%% to sell", table.Purchase[10-table.MinRoll], table.Sale[10-table.MinRoll])
+	}
+}
+
+func TestPurchasePrice(t *testing.T) {
+	tables := testTables(t)
+	// base 20000, purchase DMs In +2 Ht +3 Ri +1, sale DMs Ni +2 Lt +1 Po +1
+	electronics := testGood(t, tables, "common_electronics")
+
+	tests := []struct {
+		name   string
+		codes  []string
+		roll   int
+		broker int
+		want   int64
+	}{
+		{"no DMs", nil, 10, 0, 18000},
+		{"purchase DM", []string{"In"}, 10, 0, 16000},
+		{"highest purchase DM", []string{"Ri", "In", "Ht"}, 10, 0, 15000},
+		{"sale DM raises the price", []string{"Ni"}, 10, 0, 20000},
+		{"both DMs", []string{"In", "Ni"}, 10, 0, 18000},
+		{"broker", nil, 10, 2, 16000},
+		{"unskilled broker", nil, 10, -3, 21000},
+		{"lowest 3D roll", nil, 3, 0, 25000},
+		{"off the bottom of the table", []string{"Ni"}, 3, -6, 60000},
+		{"off the top of the table", []string{"Ht"}, 18, 6, 3000},
+	}
+
+	for _, test := range tests {
+		if got := tables.PurchasePrice(electronics, test.codes, test.roll, test.broker); got != test.want {
+			t.Errorf("%s: PurchasePrice(%v, %d, %d) = %d, want %d", test.name, test.codes, test.roll, test.broker, got, test.want)
+		}
+	}
+}
+
+func TestSalePrice(t *testing.T) {
+	tables := testTables(t)
+	electronics := testGood(t, tables, "common_electronics")
+
+	tests := []struct {
+		name   string
+		codes  []string
+		roll   int
+		broker int
+		want   int64
+	}{
+		{"no DMs", nil, 10, 0, 18000},
+		{"sale DM", []string{"Ni"}, 10, 0, 21000},
+		{"highest sale DM", []string{"Po", "Ni", "Lt"}, 10, 0, 21000},
+		{"purchase DM lowers the price", []string{"Ht"}, 10, 0, 15000},
+		{"broker", nil, 10, 2, 21000},
+		{"unskilled broker", nil, 10, -3, 15000},
+		{"off the bottom of the table", []string{"Ht"}, 3, -3, 2000},
+		{"off the top of the table", []string{"Ni"}, 18, 6, 80000},
+	}
+
+	for _, test := range tests {
+		if got := tables.SalePrice(electronics, test.codes, test.roll, test.broker); got != test.want {
+			t.Errorf("%s: SalePrice(%v, %d, %d) = %d, want %d", test.name, test.codes, test.roll, test.broker, got, test.want)
+		}
+	}
+}
+
+func TestDM(t *testing.T) {
+	// consumables buy with Ag +3 Wa +2 Ga +1 As -4
+	dms := testGood(t, testTables(t), "common_consumables").PurchaseDMs
+
+	tests := []struct {
+		codes []string
+		want  int
+	}{
+		{nil, 0},
+		{[]string{"Hi"}, 0},
+		{[]string{"Ga", "Ag"}, 3},
+		{[]string{"As"}, -4},
+		{[]string{"As", "Ga"}, 1},
+	}
+	for _, test := range tests {
+		if got := dm(dms, test.codes); got != test.want {
+			t.Errorf("dm(%v) = %d, want %d", test.codes, got, test.want)
+		}
+	}
+}
+
+func TestMarketPrices(t *testing.T) {
+	tables := testTables(t)
+	tables.Goods = []Good{
+		{Id: "spice", Name: "Spice", Availability: []string{"Ri"}, Multiplier: 10, BasePrice: 10000, PurchaseDMs: map[string]int{"Ri": 2}, SaleDMs: map[string]int{"Amber": 1}, tons: dice.Expression{Count: 1, Sides: 6}},
+		{Id: "ore", Name: "Ore", Availability: []string{"Ni"}, Multiplier: 5, BasePrice: 1000, tons: dice.Expression{Count: 1, Sides: 6}},
+	}
+
+	uwp, err := model.ParseUWP("A788899-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := model.World{UWP: uwp, Zone: model.TravelZone_Amber}
+	if codes := Codes(world); !slices.Contains(codes, "Ri") || slices.Contains(codes, "Ni") || !slices.Contains(codes, "Amber") {
+		t.Fatalf("the world has the codes %v", codes)
+	}
+
+	// spice is on sale: 3D sale roll, 1D tons and a 3D purchase roll, ore only gets a sale roll
+	goods := tables.Market(scripted(t, 4, 4, 4, 3, 2, 3, 5, 1, 1, 1), world)
+	want := []model.MarketGood{
+		{GoodId: "spice", Tons: 30, PurchaseRoll: 10, SaleRoll: 12},
+		{GoodId: "ore", SaleRoll: 3},
+	}
+	if !slices.Equal(goods, want) {
+		t.Fatalf("Market = %+v, want %+v", goods, want)
+	}
+
+	prices := tables.Prices(&model.Market{Goods: goods}, world, 1)
+	wantPrices := []Price{
+		// buying 10 +1 broker +2 Ri -1 Amber, selling 12 +1 broker +1 Amber -2 Ri
+		{GoodId: "spice", Name: "Spice", BasePrice: 10000, Tons: 30, PurchasePrice: 8000, SalePrice: 10500},
+		// selling 3 +1 broker, nothing to buy
+		{GoodId: "ore", Name: "Ore", BasePrice: 1000, SalePrice: 600},
+	}
+	if !slices.Equal(prices, wantPrices) {
+		t.Fatalf("Prices = %+v, want %+v", prices, wantPrices)
+	}
+}
+
+func TestMarketOffersAtLeastOneLot(t *testing.T) {
+	tables := testTables(t)
+	tables.Goods = []Good{{Id: "spice", Availability: []string{Availability_All}, Multiplier: 10, BasePrice: 10000, tons: dice.Expression{Count: 1, Sides: 6, Modifier: -3}}}
+
+	goods := tables.Market(scripted(t, 1, 1, 1, 1, 1, 1, 1), model.World{})
+	if len(goods) != 1 || goods[0].Tons != 10 {
+		t.Fatalf("Market = %+v, want 10 tons", goods)
+	}
+}
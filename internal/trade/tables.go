@@ -0,0 +1,90 @@
+package trade
+
+import (
+	"fmt"
+	"os"
+	"visualsource/traveller/internal/dice"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Availability_All marks goods that can be bought on any world
+const Availability_All = "All"
+
+type Good struct {
+	Id           string   `yaml:"id" json:"id"`
+	Name         string   `yaml:"name" json:"name"`
+	Availability []string `yaml:"availability" json:"availability"`
+	// Tons is rolled and then multiplied to get the tons on offer
+	Tons        string         `yaml:"tons" json:"tons"`
+	Multiplier  int            `yaml:"multiplier" json:"multiplier"`
+	BasePrice   int64          `yaml:"base_price" json:"basePrice"`
+	PurchaseDMs map[string]int `yaml:"purchase_dms" json:"purchaseDMs"`
+	SaleDMs     map[string]int `yaml:"sale_dms" json:"saleDMs"`
+
+	tons dice.Expression
+}
+
+type PriceTable struct {
+	MinRoll  int   `yaml:"min_roll"`
+	Purchase []int `yaml:"purchase"`
+	Sale     []int `yaml:"sale"`
+}
+
+type Passage struct {
+	Parsecs int   `yaml:"parsecs" json:"parsecs"`
+	High    int64 `yaml:"high" json:"high"`
+	Middle  int64 `yaml:"middle" json:"middle"`
+	Basic   int64 `yaml:"basic" json:"basic"`
+	Low     int64 `yaml:"low" json:"low"`
+	Freight int64 `yaml:"freight" json:"freight"`
+}
+
+type Tables struct {
+	Goods                []Good     `yaml:"goods"`
+	PriceTable           PriceTable `yaml:"price_table"`
+	Passage              []Passage  `yaml:"passage"`
+	MailContainerTons    int        `yaml:"mail_container_tons"`
+	MailContainerPayment int64      `yaml:"mail_container_payment"`
+}
+
+func Load(path string) (*Tables, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables Tables
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+
+	for i := range tables.Goods {
+		good := &tables.Goods[i]
+		if good.tons, err = dice.ParseExpression(good.Tons); err != nil {
+			return nil, fmt.Errorf("trade good '%s': %w", good.Id, err)
+		}
+		if good.Multiplier <= 0 {
+			good.Multiplier = 1
+		}
+	}
+
+	if len(tables.PriceTable.Purchase) == 0 || len(tables.PriceTable.Purchase) != len(tables.PriceTable.Sale) {
+		return nil, fmt.Errorf("trade price table needs the same number of purchase and sale entries")
+	}
+
+	if len(tables.Passage) == 0 {
+		return nil, fmt.Errorf("trade tables need at least one passage rate")
+	}
+
+	return &tables, nil
+}
+
+func (t *Tables) Good(id string) (Good, bool) {
+	for _, item := range t.Goods {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return Good{}, false
+}
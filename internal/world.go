@@ -0,0 +1,20 @@
+package traveller
+
+import (
+	handler "visualsource/traveller/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RegisterWorldPages(e *echo.Echo, h *handler.Handler) {
+	g := e.Group("/session/:sessionId/worlds")
+
+	g.Use(sessionRequired)
+
+	g.GET("", h.ListWorlds).Name = "worlds"
+	g.POST("", h.CreateWorld).Name = "world-create"
+	g.POST("/generate", h.GenerateWorld).Name = "world-generate"
+	g.GET("/:worldId", h.GetWorld).Name = "world"
+	g.PUT("/:worldId", h.UpdateWorld).Name = "world-update"
+	g.DELETE("/:worldId", h.DeleteWorld).Name = "world-delete"
+}
@@ -0,0 +1,60 @@
+package worldgen
+
+import (
+	"strings"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+var nameStarts = []string{"al", "an", "ar", "be", "ca", "da", "de", "el", "fa", "ga", "ha", "is", "ka", "ke", "la", "ma", "mo", "na", "or", "pa", "qu", "re", "sa", "ta", "ul", "va", "ve", "xa", "ya", "za"}
+var nameMiddles = []string{"ba", "da", "di", "ga", "ki", "la", "li", "ma", "na", "ni", "ra", "ri", "ro", "sa", "ta", "ti", "va", "ze"}
+var nameEnds = []string{"a", "an", "ar", "e", "el", "en", "ia", "is", "on", "or", "os", "um", "us", "ys"}
+
+// Name makes up a world name from a few syllables
+func Name(r *dice.Roller) string {
+	name := nameStarts[r.Pick(len(nameStarts))]
+	for i := r.D(3) - 1; i > 0; i-- {
+		name += nameMiddles[r.Pick(len(nameMiddles))]
+	}
+	name += nameEnds[r.Pick(len(nameEnds))]
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// System rolls the world of a hex along with the gas giants and belts in its system
+func System(r *dice.Roller, hex string) model.World {
+	world := World(r, Name(r))
+	world.Hex = hex
+
+	// gas giants are present on 9-
+	if r.D6(2) <= 9 {
+		world.GasGiants = (r.D6(1) + 1) / 2
+	}
+
+	// belts are present on 8+, a world of size 0 is a belt itself
+	if r.D6(2) >= 8 || world.UWP.Size == 0 {
+		world.Belts = (r.D6(1) + 2) / 3
+	}
+
+	return world
+}
+
+// Sector fills a map of the given size, rolling for a system in every hex.
+// The hexes are rolled in order so the same roller seed always gives the same map.
+func Sector(r *dice.Roller, width int, height int, density model.SectorDensity) []model.World {
+	count, target, ok := density.Presence()
+	if !ok {
+		count, target, _ = model.SectorDensity_Standard.Presence()
+	}
+
+	worlds := []model.World{}
+	for column := 1; column <= width; column++ {
+		for row := 1; row <= height; row++ {
+			if r.D6(count) < target {
+				continue
+			}
+			worlds = append(worlds, System(r, model.Hex(column, row)))
+		}
+	}
+
+	return worlds
+}
@@ -0,0 +1,42 @@
+package worldgen
+
+import (
+	"reflect"
+	"testing"
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+func TestSectorIsReproducible(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		sector := Sector(dice.Seeded(seed), 8, 10, model.SectorDensity_Standard)
+		again := Sector(dice.Seeded(seed), 8, 10, model.SectorDensity_Standard)
+		if !reflect.DeepEqual(sector, again) {
+			t.Fatalf("seed %d rolled %+v then %+v", seed, sector, again)
+		}
+
+		other := Sector(dice.Seeded(seed+1000), 8, 10, model.SectorDensity_Standard)
+		if reflect.DeepEqual(sector, other) {
+			t.Fatalf("seeds %d and %d rolled the same sector", seed, seed+1000)
+		}
+	}
+}
+
+func TestSectorHexes(t *testing.T) {
+	worlds := Sector(dice.Seeded(1), 8, 10, model.SectorDensity_Dense)
+	if len(worlds) == 0 {
+		t.Fatal("a dense sector rolled no worlds")
+	}
+
+	seen := map[string]bool{}
+	for _, world := range worlds {
+		column, row, err := model.ParseHex(world.Hex)
+		if err != nil || column < 1 || column > 8 || row < 1 || row > 10 {
+			t.Fatalf("world %s is in hex %q outside the map", world.Name, world.Hex)
+		}
+		if seen[world.Hex] {
+			t.Fatalf("hex %s rolled twice", world.Hex)
+		}
+		seen[world.Hex] = true
+	}
+}
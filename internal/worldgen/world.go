@@ -0,0 +1,174 @@
+package worldgen
+
+import (
+	"visualsource/traveller/internal/dice"
+	"visualsource/traveller/internal/model"
+)
+
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// UWP rolls a world profile following the world creation rules
+func UWP(r *dice.Roller) model.UWP {
+	var uwp model.UWP
+
+	uwp.Size = r.D6(2) - 2
+
+	if uwp.Size == 0 {
+		uwp.Atmosphere = 0
+	} else {
+		uwp.Atmosphere = clamp(r.D6(2)-7+uwp.Size, 0, 15)
+	}
+
+	if uwp.Size <= 1 {
+		uwp.Hydrographics = 0
+	} else {
+		dm := uwp.Atmosphere - 7
+		if uwp.Atmosphere <= 1 || uwp.Atmosphere >= 10 && uwp.Atmosphere <= 12 {
+			dm -= 4
+		}
+		uwp.Hydrographics = clamp(r.D6(2)+dm, 0, 10)
+	}
+
+	uwp.Population = r.D6(2) - 2
+
+	if uwp.Population > 0 {
+		uwp.Government = clamp(r.D6(2)-7+uwp.Population, 0, 15)
+		uwp.LawLevel = clamp(r.D6(2)-7+uwp.Government, 0, 15)
+	}
+
+	uwp.Starport = starport(r.D6(2) + starportDM(uwp.Population))
+
+	if uwp.Population > 0 {
+		uwp.TechLevel = clamp(r.D6(1)+techLevelDM(&uwp), 0, 33)
+	}
+
+	return uwp
+}
+
+func starportDM(population int) int {
+	switch {
+	case population >= 10:
+		return 2
+	case population >= 8:
+		return 1
+	case population <= 2:
+		return -2
+	case population <= 4:
+		return -1
+	}
+	return 0
+}
+
+func starport(roll int) string {
+	switch {
+	case roll <= 2:
+		return "X"
+	case roll <= 4:
+		return "E"
+	case roll <= 6:
+		return "D"
+	case roll <= 8:
+		return "C"
+	case roll <= 10:
+		return "B"
+	}
+	return "A"
+}
+
+func techLevelDM(uwp *model.UWP) int {
+	dm := map[string]int{"A": 6, "B": 4, "C": 2, "X": -4}[uwp.Starport]
+
+	switch {
+	case uwp.Size <= 1:
+		dm += 2
+	case uwp.Size <= 4:
+		dm += 1
+	}
+
+	if uwp.Atmosphere <= 3 || uwp.Atmosphere >= 10 {
+		dm += 1
+	}
+
+	switch uwp.Hydrographics {
+	case 0, 9:
+		dm += 1
+	case 10:
+		dm += 2
+	}
+
+	switch {
+	case uwp.Population >= 1 && uwp.Population <= 5, uwp.Population == 8:
+		dm += 1
+	case uwp.Population == 9:
+		dm += 2
+	case uwp.Population >= 10:
+		dm += 4
+	}
+
+	switch uwp.Government {
+	case 0, 5:
+		dm += 1
+	case 7:
+		dm += 2
+	case 13, 14:
+		dm -= 2
+	}
+
+	return dm
+}
+
+// base chances by starport, a base is present when 2D meets the target
+var baseTargets = map[string]map[string]int{
+	model.Base_Naval:    {"A": 8, "B": 8},
+	model.Base_Scout:    {"A": 10, "B": 9, "C": 8, "D": 7},
+	model.Base_Military: {"A": 10, "B": 10, "C": 10},
+	model.Base_Corsair:  {"C": 10, "D": 12, "E": 10, "X": 10},
+}
+
+// Bases rolls for the bases present at a world
+func Bases(r *dice.Roller, uwp model.UWP) []string {
+	bases := []string{}
+	for _, code := range []string{model.Base_Naval, model.Base_Scout, model.Base_Military, model.Base_Corsair} {
+		target, ok := baseTargets[code][uwp.Starport]
+		if !ok {
+			continue
+		}
+		if code == model.Base_Corsair && uwp.LawLevel >= 2 {
+			// corsairs avoid worlds that enforce the law
+			continue
+		}
+		if _, ok := r.Check(target, 0); ok {
+			bases = append(bases, code)
+		}
+	}
+	return bases
+}
+
+// Zone suggests a travel zone, worlds with a dangerous atmosphere or an unusual
+// government are marked amber. Red zones are left for the referee to decide.
+func Zone(uwp model.UWP) model.TravelZone {
+	if uwp.Atmosphere >= 10 || uwp.Government == 0 && uwp.Population > 0 || uwp.Government == 7 || uwp.Government == 10 || uwp.LawLevel >= 9 {
+		return model.TravelZone_Amber
+	}
+	return model.TravelZone_Green
+}
+
+// World generates a full world with the given name
+func World(r *dice.Roller, name string) model.World {
+	uwp := UWP(r)
+	return model.World{
+		Name:       name,
+		UWP:        uwp,
+		Bases:      Bases(r, uwp),
+		Zone:       Zone(uwp),
+		TradeCodes: uwp.TradeCodes(),
+	}
+}
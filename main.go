@@ -56,6 +56,22 @@ func main() {
 
 	traveller.RegisterAuthPages(e, h)
 	traveller.RegisterSessionPages(e, h)
+	traveller.RegisterShipPages(e, h)
+	traveller.RegisterFinancePages(e, h)
+	traveller.RegisterCalendarPages(e, h)
+	traveller.RegisterWorldPages(e, h)
+	traveller.RegisterSectorPages(e, h)
+	traveller.RegisterTradePages(e, h)
+	traveller.RegisterContractPages(e, h)
+	traveller.RegisterCharacterPages(e, h)
+	traveller.RegisterCombatPages(e, h)
+	traveller.RegisterSpaceCombatPages(e, h)
+	traveller.RegisterNPCPages(e, h)
+	traveller.RegisterGeneratorPages(e, h)
+	traveller.RegisterNotePages(e, h)
+	traveller.RegisterMapPages(e, h)
+	traveller.RegisterEventPages(e, h)
+	traveller.RegisterJournalPages(e, h)
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
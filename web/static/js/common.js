@@ -17,6 +17,15 @@ class Client {
     #ws
     /**@type {string} */
     #session
+    /** Sequence number of the last chat message received
+     * @type {number} */
+    #lastSeq = 0
+    /** Ids of the referee events already in the feed
+     * @type {Set<string>} */
+    #shownEvents = new Set()
+    /** Users online in the session keyed by user id, Presence frames can come before the roster
+     * @type {Map<string, {userId: string, username: string}>} */
+    online = new Map()
     constructor(){
         /**
          * @type {string}
@@ -26,6 +35,7 @@ class Client {
 
     onSocketOpen = () => {
         console.info("Websocket has opened!");
+        this.#loadPendingEvents();
     }
     onSocketClose = (ev) => {
         console.info("Websocket has closed!");
@@ -35,71 +45,242 @@ class Client {
      * @param {WebsocketMessageEvent} msg 
      */
     onSocketMessage = async (msg) => {
+        console.log(msg);
+        // the server batches queued messages into one frame separated by newlines
+        for(const line of msg.detail.data.split("\n")){
+            if(!line) continue;
+            this.#handleMessage(line);
+        }
+    }
+    /**
+     * @param {string} raw 
+     */
+    #handleMessage(raw){
         try {
-            console.log(msg);
-            const data = JSON.parse(msg.detail.data);
+            const data = JSON.parse(raw);
             switch (data.contentType) {
-                case "BoradcastMessage": {
-                    const feed = document.getElementById("global-feed");
+                case "ChatHistory": {
+                    for(const item of data.messages ?? []){
+                        this.#renderChat(item);
+                    }
+                    break;
+                }
+                case "BoradcastMessage":
+                case "PrivateMessage":
+                    this.#renderChat(data);
+                    break;
+                case "OnlineUsers":
+                    this.online = new Map((data.users ?? []).map(user => [user.userId, user]));
+                    htmx.trigger(document.body, "presence");
+                    break;
+                case "Presence": {
+                    if(data.status === "online") {
+                        this.online.set(data.userId, { userId: data.userId, username: data.username });
+                    } else {
+                        this.online.delete(data.userId);
+                    }
+                    // refresh the player list dialog
+                    htmx.trigger(document.body, "presence");
+                    break;
+                }
+                case "Finance":
+                    // refresh the finances panel
+                    htmx.trigger(document.body, "finance");
+                    break;
+                case "Calendar":
+                    // refresh the date and show the events that came due
+                    htmx.trigger(document.body, "calendar");
+                    for(const event of data.events ?? []){
+                        this.#renderEvent(event);
+                    }
+                    break;
+                case "Contracts":
+                    // refresh the freight and passenger board
+                    htmx.trigger(document.body, "contracts");
+                    break;
+                case "Combat":
+                    // redraw the combat tracker
+                    htmx.trigger(document.body, "combat");
+                    break;
+                case "SpaceCombat":
+                    // redraw the space combat display
+                    htmx.trigger(document.body, "space-combat");
+                    break;
+                case "NPCs":
+                    // refresh the known NPCs
+                    htmx.trigger(document.body, "npcs");
+                    break;
+                case "Generated":
+                    // an encounter, mission or rumour was revealed
+                    htmx.trigger(document.body, "generated");
+                    break;
+                case "Handout":
+                    // a handout was shared with or withdrawn from this player
+                    htmx.trigger(document.body, "handouts");
+                    break;
+                case "Map":
+                    // more of the map was revealed or hidden
+                    htmx.trigger(document.body, "map");
+                    break;
+                case "Event":
+                    // the referee sent an alert, sensor contact, damage report or news item
+                    this.#renderSessionEvent(data.event);
+                    break;
+                case "EventAck":
+                    // a player acknowledged an event
+                    htmx.trigger(document.body, "event-ack");
+                    break;
+                case "Journal":
+                    // an entry was added to, changed in or removed from the session journal
+                    htmx.trigger(document.body, "journal");
+                    break;
+                default:
+                    break;
+            }
+        } catch (error) {
+            console.error(error);
+        }
+    }
+    /**
+     * Adds a scheduled event that came due to the notification feed
+     * @param {{ date: string, title: string, description: string }} event 
+     */
+    #renderEvent(event){
+        const feed = document.getElementById("global-feed");
+        if(!feed) return;
 
-                    const el = document.createElement("div");
-                    el.style = "margin: 5px 8px; border: black solid 1px;padding: 2px 4px;"
-                    el.setAttribute("data-type","message");
+        const el = document.createElement("div");
+        el.style = "margin: 5px 8px; border: black solid 1px;padding: 2px 4px;"
+        el.setAttribute("data-type","event");
 
-                    const header = document.createElement("h4");
-                    header.style = "margin-bottom:0;margin-top:2px;"
-                    header.textContent = "Notification";
+        const header = document.createElement("h4");
+        header.style = "margin-bottom:0;margin-top:2px;"
+        header.textContent = `${event.date} ${event.title}`;
 
-                    el.appendChild(header);
+        el.appendChild(header);
 
-                    el.appendChild(document.createElement("hr"));
+        el.appendChild(document.createElement("hr"));
 
-                    const text = document.createElement("p");
-                    text.style = "margin-top:0px;-webkit-line-clamp:2;overflow:hidden;-webkit-box-orient:vertical;display:-webkit-box;";
-                    text.textContent = data.content;
+        const text = document.createElement("p");
+        text.style = "margin-top:0px;-webkit-line-clamp:2;overflow:hidden;-webkit-box-orient:vertical;display:-webkit-box;";
+        text.textContent = event.description;
 
-                    el.appendChild(text);
+        el.appendChild(text);
 
-                    feed.appendChild(el);
-                    break;
-                }
-                case "PrivateMessage":{
-                    const target = htmx.find("#private-message-board");
+        feed.appendChild(el);
+    }
+    /**
+     * Shows the referee events that have not been acknowledged yet, the feed is empty after a reload
+     */
+    async #loadPendingEvents(){
+        try {
+            const res = await fetch(`/session/${this.#session}/events?pending=true`);
+            if(!res.ok) return;
+            for(const event of await res.json()){
+                this.#renderSessionEvent(event);
+            }
+        } catch (error) {
+            console.error(error);
+        }
+    }
+    /**
+     * Adds an event the referee sent to the notification feed, players can acknowledge it
+     * @param {{ id: string, date: string, title: string, body: string, event: { kind: string, severity: string }, acks: { userId: string }[] }} event 
+     */
+    #renderSessionEvent(event){
+        const feed = document.getElementById("global-feed");
+        if(!feed || this.#shownEvents.has(event.id)) return;
+        this.#shownEvents.add(event.id);
+
+        const colors = { info: "lightblue", warning: "orange", critical: "tomato" };
+        const kinds = { alert: "Alert", sensor_contact: "Sensor Contact", ship_damage: "Ship Damage", news: "News" };
 
-                    // linegreen for current user, blue for other
-                    const textboxColor = "limegreen";
+        const el = document.createElement("div");
+        el.style = `margin: 5px 8px; border: black solid 1px;padding: 2px 4px;background-color: ${colors[event.event.severity] ?? "white"};`
+        el.setAttribute("data-type","session-event");
+        el.setAttribute("data-severity",event.event.severity);
 
-                    const el = document.createElement("div");
-                    el.style = `margin: 5px 8px; border: black solid 1px;padding: 2px 4px;display:flex; background-color: ${textboxColor};`
+        const header = document.createElement("h4");
+        header.style = "margin-bottom:0;margin-top:2px;"
+        header.textContent = `${event.date} ${kinds[event.event.kind] ?? event.event.kind}: ${event.title}`;
 
-                    /*const i = document.createElement("img");
-                    i.style="height:45px;width:45px";
+        el.appendChild(header);
 
-                    const encoder = new TextEncoder();
-                    const item = encoder.encode("collin_blosser@yahoo.com")
+        el.appendChild(document.createElement("hr"));
 
-                    const hash = await crypto.subtle.digest("SHA-256",item).then(e=> Array.from(new Uint8Array(e)) ).then(e=>e.map(b=>b.toString(16).padStart(2,"0")).join(""));
+        const text = document.createElement("p");
+        text.style = "margin-top:0px;";
+        text.textContent = event.body;
 
-                    i.src = `https://www.gravatar.com/avatar/${hash}`;
-                    i.alt="user-icon"
+        el.appendChild(text);
 
-                    el.appendChild(i);*/
+        if(!event.acks.length){
+            const button = document.createElement("button");
+            button.textContent = "Acknowledge";
+            button.addEventListener("click", async () => {
+                const res = await fetch(`/session/${this.#session}/events/${event.id}/ack`, { method: "POST" });
+                if(res.ok) button.remove();
+            });
+            el.appendChild(button);
+        }
 
-                    const text = document.createElement("p");
-                    text.style = "margin-top:0px;";
-                    text.textContent = data.message;
+        feed.appendChild(el);
+    }
+    /**
+     * Renders a chat message, skipping any that have already been shown
+     * @param {{ contentType: string, seq: number, content?: string, message?: string }} data 
+     */
+    #renderChat(data){
+        if(data.seq <= this.#lastSeq) return;
+        this.#lastSeq = data.seq;
 
-                    el.appendChild(text);
+        switch (data.contentType) {
+            case "BoradcastMessage": {
+                const feed = document.getElementById("global-feed");
+                if(!feed) return;
 
-                    target.appendChild(el);
+                const el = document.createElement("div");
+                el.style = "margin: 5px 8px; border: black solid 1px;padding: 2px 4px;"
+                el.setAttribute("data-type","message");
 
-                    break;
-                }
-                default:
-                    break;
+                const header = document.createElement("h4");
+                header.style = "margin-bottom:0;margin-top:2px;"
+                header.textContent = "Notification";
+
+                el.appendChild(header);
+
+                el.appendChild(document.createElement("hr"));
+
+                const text = document.createElement("p");
+                text.style = "margin-top:0px;-webkit-line-clamp:2;overflow:hidden;-webkit-box-orient:vertical;display:-webkit-box;";
+                text.textContent = data.content;
+
+                el.appendChild(text);
+
+                feed.appendChild(el);
+                break;
             }
-        } catch (error) {
-            console.error(error);
+            case "PrivateMessage":{
+                const target = htmx.find("#private-message-board");
+                if(!target) return;
+
+                // linegreen for current user, blue for other
+                const textboxColor = "limegreen";
+
+                const el = document.createElement("div");
+                el.style = `margin: 5px 8px; border: black solid 1px;padding: 2px 4px;display:flex; background-color: ${textboxColor};`
+
+                const text = document.createElement("p");
+                text.style = "margin-top:0px;";
+                text.textContent = data.message;
+
+                el.appendChild(text);
+
+                target.appendChild(el);
+                break;
+            }
+            default:
+                break;
         }
     }
     /**
@@ -108,7 +289,11 @@ class Client {
      */
     onSocketError = (err) => console.error(err);
     init(){
-        this.#ws = new ReconnectingWebsocket(`ws://${document.location.host}/session/${this.#session}/ws`);
+        // on reconnect only ask for the chat messages that were missed
+        this.#ws = new ReconnectingWebsocket(() => {
+            const url = `ws://${document.location.host}/session/${this.#session}/ws`;
+            return this.#lastSeq > 0 ? `${url}?after=${this.#lastSeq}` : url;
+        });
         this.#ws.addEventListener("close",this.onSocketClose);
         this.#ws.addEventListener("error",this.onSocketError);
         this.#ws.addEventListener("message",this.onSocketMessage);
@@ -135,6 +320,14 @@ class Client {
     }
 
 
+    /**
+     * Ask the server for the users currently online in the session
+     */
+    requestOnline(){
+        if(!this.#ws) throw new Error("Websocket is not ready!");
+        this.#ws.send(JSON.stringify({ contentType: "OnlineUsers", payload: {} }));
+    }
+
     initWorld(){
         /**
          * @type {HTMLDivElement}
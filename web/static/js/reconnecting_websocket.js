@@ -29,7 +29,7 @@ export class ReconnectingWebsocket extends EventTarget {
     #closeCalled = false 
     /** @type {(string | ArrayBuffer | Blob | ArrayBufferView)[]} */
     #messageQueue = []
-    /**@type {string} */
+    /**@type {string | (() => string)} */
     #url
     /**@type {string | string[] | undefined} */
     #protocols
@@ -37,7 +37,7 @@ export class ReconnectingWebsocket extends EventTarget {
     #ws
     /**
      * 
-     * @param {string} url 
+     * @param {string | (() => string)} url url or a function that returns the url to use for each connection attempt
      * @param {string|string[]|undefined} protocols 
      */
     constructor(url,protocols){
@@ -161,7 +161,8 @@ export class ReconnectingWebsocket extends EventTarget {
 
         this.#wait().then(()=>{
             if(this.#closeCalled) return;
-            this.#ws = new WebSocket(this.#url,this.#protocols)
+            const url = typeof this.#url === "function" ? this.#url() : this.#url;
+            this.#ws = new WebSocket(url,this.#protocols)
             this.#ws.binaryType = this.#binaryType;
             this.#connectLock = false;
             this.#addListeners();